@@ -3,8 +3,16 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/shopspring/decimal"
 	"net/http"
+	"quotation-metrics/internal/calendar"
+	"quotation-metrics/internal/instrument"
 	"quotation-metrics/internal/trade"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -13,25 +21,32 @@ type Quotation struct {
 }
 
 func (q *Quotation) GetMetrics(w http.ResponseWriter, r *http.Request) {
-	ticker := r.URL.Query().Get("ticker")
-	date := r.URL.Query().Get("date")
-
-	if ticker == "" {
-		http.Error(w, "Missing ticker", http.StatusBadRequest)
+	filter, err := metricFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	var dateTime time.Time
-	var err error
-	if date != "" {
-		dateTime, err = time.Parse("2006-01-02", date)
+	if date := r.URL.Query().Get("date"); date != "" {
+		filter.Date, err = time.Parse("2006-01-02", date)
 		if err != nil {
 			http.Error(w, "Failed to parse date", http.StatusBadRequest)
 			return
 		}
 	}
 
-	metrics, err := q.service.Metrics(r.Context(), ticker, dateTime)
+	for _, include := range strings.Split(r.URL.Query().Get("include"), ",") {
+		switch include {
+		case "":
+		case "changes":
+			filter.IncludeChanges = true
+		default:
+			http.Error(w, "Invalid include", http.StatusBadRequest)
+			return
+		}
+	}
+
+	metrics, err := q.service.Metrics(r.Context(), filter)
 	if err != nil {
 		http.Error(w, "Failed to get metrics", http.StatusInternalServerError)
 		return
@@ -48,17 +63,324 @@ func (q *Quotation) GetMetrics(w http.ResponseWriter, r *http.Request) {
 	w.Write(marshal)
 }
 
+func (q *Quotation) GetMetricHistory(w http.ResponseWriter, r *http.Request) {
+	filter, err := metricFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if start := r.URL.Query().Get("start"); start != "" {
+		filter.Date, err = time.Parse("2006-01-02", start)
+		if err != nil {
+			http.Error(w, "Failed to parse start", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if end := r.URL.Query().Get("end"); end != "" {
+		filter.End, err = time.Parse("2006-01-02", end)
+		if err != nil {
+			http.Error(w, "Failed to parse end", http.StatusBadRequest)
+			return
+		}
+	}
+
+	history, err := q.service.MetricHistory(r.Context(), filter)
+	if err != nil {
+		http.Error(w, "Failed to get metric history", http.StatusInternalServerError)
+		return
+	}
+
+	marshal, err := json.Marshal(history)
+	if err != nil {
+		http.Error(w, "Failed to marshal metric history", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(marshal)
+}
+
+func (q *Quotation) GetTrades(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	filter := trade.TradeFilter{
+		Ticker: query.Get("ticker"),
+	}
+
+	var err error
+	if date := query.Get("date"); date != "" {
+		filter.Date, err = time.Parse("2006-01-02", date)
+		if err != nil {
+			http.Error(w, "Failed to parse date", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if fromTime := query.Get("from_time"); fromTime != "" {
+		filter.FromTime, err = parseTimeOfDay(fromTime)
+		if err != nil {
+			http.Error(w, "Failed to parse from_time", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if toTime := query.Get("to_time"); toTime != "" {
+		filter.ToTime, err = parseTimeOfDay(toTime)
+		if err != nil {
+			http.Error(w, "Failed to parse to_time", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if minQty := query.Get("min_qty"); minQty != "" {
+		filter.MinQty, err = strconv.Atoi(minQty)
+		if err != nil {
+			http.Error(w, "Failed to parse min_qty", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if cursor := query.Get("cursor"); cursor != "" {
+		filter.Cursor, err = strconv.Atoi(cursor)
+		if err != nil {
+			http.Error(w, "Failed to parse cursor", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		filter.Limit, err = strconv.Atoi(limit)
+		if err != nil {
+			http.Error(w, "Failed to parse limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	filter.InstrumentType, err = instrumentTypeParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := q.service.Trades(r.Context(), filter)
+	if err != nil {
+		if errors.Is(err, calendar.ErrNotTradingDay) {
+			http.Error(w, "Not a trading day", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to get trades", http.StatusInternalServerError)
+		return
+	}
+
+	marshal, err := json.Marshal(page)
+	if err != nil {
+		http.Error(w, "Failed to marshal trades", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(marshal)
+}
+
+func (q *Quotation) GetAnomalies(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	filter := trade.AnomalyFilter{
+		Ticker: query.Get("ticker"),
+		Reason: query.Get("reason"),
+	}
+
+	var err error
+	if date := query.Get("date"); date != "" {
+		filter.Date, err = time.Parse("2006-01-02", date)
+		if err != nil {
+			http.Error(w, "Failed to parse date", http.StatusBadRequest)
+			return
+		}
+	}
+
+	filter.InstrumentType, err = instrumentTypeParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	anomalies, err := q.service.Anomalies(r.Context(), filter)
+	if err != nil {
+		if errors.Is(err, calendar.ErrNotTradingDay) {
+			http.Error(w, "Not a trading day", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to get anomalies", http.StatusInternalServerError)
+		return
+	}
+
+	marshal, err := json.Marshal(anomalies)
+	if err != nil {
+		http.Error(w, "Failed to marshal anomalies", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(marshal)
+}
+
+func (q *Quotation) GetBlocks(w http.ResponseWriter, r *http.Request) {
+	date := r.URL.Query().Get("date")
+	if date == "" {
+		http.Error(w, "Missing date", http.StatusBadRequest)
+		return
+	}
+
+	dateTime, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		http.Error(w, "Failed to parse date", http.StatusBadRequest)
+		return
+	}
+
+	instrumentType, err := instrumentTypeParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	blocks, err := q.service.BlockTrades(r.Context(), trade.BlockTradeFilter{
+		Ticker:         r.URL.Query().Get("ticker"),
+		Date:           dateTime,
+		InstrumentType: instrumentType,
+	})
+	if err != nil {
+		if errors.Is(err, calendar.ErrNotTradingDay) {
+			http.Error(w, "Not a trading day", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to get block trades", http.StatusInternalServerError)
+		return
+	}
+
+	marshal, err := json.Marshal(blocks)
+	if err != nil {
+		http.Error(w, "Failed to marshal block trades", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(marshal)
+}
+
+func (q *Quotation) GetTopBrokers(w http.ResponseWriter, r *http.Request) {
+	filter, err := brokerFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if filter.Ticker == "" {
+		http.Error(w, "Missing ticker", http.StatusBadRequest)
+		return
+	}
+
+	ranking, err := q.service.TopBrokers(r.Context(), filter)
+	if err != nil {
+		http.Error(w, "Failed to get top brokers", http.StatusInternalServerError)
+		return
+	}
+
+	marshal, err := json.Marshal(ranking)
+	if err != nil {
+		http.Error(w, "Failed to marshal top brokers", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(marshal)
+}
+
+func (q *Quotation) GetBrokerFlows(w http.ResponseWriter, r *http.Request) {
+	filter, err := brokerFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	flows, err := q.service.BrokerFlows(r.Context(), filter)
+	if err != nil {
+		http.Error(w, "Failed to get broker flows", http.StatusInternalServerError)
+		return
+	}
+
+	marshal, err := json.Marshal(flows)
+	if err != nil {
+		http.Error(w, "Failed to marshal broker flows", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(marshal)
+}
+
+func (q *Quotation) GetBrokerMatrix(w http.ResponseWriter, r *http.Request) {
+	filter, err := brokerFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	matrix, err := q.service.BrokerMatrix(r.Context(), filter)
+	if err != nil {
+		http.Error(w, "Failed to get broker matrix", http.StatusInternalServerError)
+		return
+	}
+
+	marshal, err := json.Marshal(matrix)
+	if err != nil {
+		http.Error(w, "Failed to marshal broker matrix", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(marshal)
+}
+
 func (q *Quotation) BatchUpload(w http.ResponseWriter, r *http.Request) {
-	file, _, err := r.FormFile("Quotation")
+	file, header, err := r.FormFile("Quotation")
 	if err != nil {
 		http.Error(w, "Failed to get file", http.StatusBadRequest)
 		return
 	}
 
+	upload, err := q.service.CreateUpload(r.Context(), &trade.Upload{
+		FileName: header.Filename,
+		Uploader: r.FormValue("uploader"),
+	}, file)
+	if err != nil {
+		file.Close()
+		switch {
+		case errors.Is(err, trade.ErrDuplicateFile) && upload != nil:
+			w.Header().Add("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(fmt.Sprintf(`{"message":"file already uploaded","upload_id":%d}`, upload.ID)))
+		case errors.Is(err, trade.ErrDuplicateFile):
+			http.Error(w, "File already uploaded", http.StatusConflict)
+		default:
+			http.Error(w, "Failed to create upload", http.StatusInternalServerError)
+		}
+		return
+	}
+
 	// background process
 	go func() {
 		defer file.Close()
-		err := q.service.BatchInsert(context.Background(), file)
+		err := q.service.BatchInsert(context.Background(), upload, file)
 		if err != nil {
 			switch err {
 			default:
@@ -69,11 +391,914 @@ func (q *Quotation) BatchUpload(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Add("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(`{"message":"file uploaded successfully"}`))
+	w.Write([]byte(fmt.Sprintf(`{"message":"file uploaded successfully","upload_id":%d}`, upload.ID)))
 }
 
-func NewQuotation(service trade.Service) *Quotation {
-	return &Quotation{
-		service: service,
+func (q *Quotation) ValidateUpload(w http.ResponseWriter, r *http.Request) {
+	file, _, err := r.FormFile("Quotation")
+	if err != nil {
+		http.Error(w, "Failed to get file", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	var maxErrors int
+	if value := r.URL.Query().Get("max_errors"); value != "" {
+		maxErrors, err = strconv.Atoi(value)
+		if err != nil {
+			http.Error(w, "Failed to parse max_errors", http.StatusBadRequest)
+			return
+		}
+	}
+
+	validation, err := q.service.Validate(r.Context(), file, maxErrors)
+	if err != nil {
+		http.Error(w, "Failed to validate file", http.StatusInternalServerError)
+		return
+	}
+
+	marshal, err := json.Marshal(validation)
+	if err != nil {
+		http.Error(w, "Failed to marshal validation", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(marshal)
+}
+
+func (q *Quotation) GetUpload(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	upload, err := q.service.Upload(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, trade.ErrUploadNotFound) {
+			http.Error(w, "Upload not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to get upload", http.StatusInternalServerError)
+		return
+	}
+
+	marshal, err := json.Marshal(upload)
+	if err != nil {
+		http.Error(w, "Failed to marshal upload", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(marshal)
+}
+
+func (q *Quotation) DeleteUpload(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	audit := &trade.UploadAudit{
+		UploadID: id,
+		Actor:    r.URL.Query().Get("actor"),
+		Reason:   r.URL.Query().Get("reason"),
+	}
+	err = q.service.DeleteUpload(r.Context(), audit)
+	if err != nil {
+		switch {
+		case errors.Is(err, trade.ErrUploadNotFound):
+			http.Error(w, "Upload not found", http.StatusNotFound)
+		case errors.Is(err, trade.ErrUploadProcessing):
+			http.Error(w, "Upload is processing", http.StatusConflict)
+		case errors.Is(err, trade.ErrUploadDeleted):
+			http.Error(w, "Upload already deleted", http.StatusConflict)
+		default:
+			http.Error(w, "Failed to delete upload", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	marshal, err := json.Marshal(audit)
+	if err != nil {
+		http.Error(w, "Failed to marshal audit", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(marshal)
+}
+
+func (q *Quotation) ReplayUpload(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	upload, file, err := q.service.Replay(r.Context(), id, r.URL.Query().Get("actor"))
+	if err != nil {
+		switch {
+		case errors.Is(err, trade.ErrUploadNotFound):
+			http.Error(w, "Upload not found", http.StatusNotFound)
+		case errors.Is(err, trade.ErrFileNotStored):
+			http.Error(w, "Stored file not found", http.StatusNotFound)
+		case errors.Is(err, trade.ErrUploadProcessing):
+			http.Error(w, "Upload is processing", http.StatusConflict)
+		case errors.Is(err, trade.ErrDuplicateFile):
+			http.Error(w, "File already uploaded", http.StatusConflict)
+		default:
+			http.Error(w, "Failed to replay upload", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// background process, the outcome is recorded in the status of the new upload
+	go func() {
+		defer file.Close()
+		q.service.BatchInsert(context.Background(), upload, file)
+	}()
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(fmt.Sprintf(`{"message":"file replayed successfully","upload_id":%d}`, upload.ID)))
+}
+
+func (q *Quotation) GetQuality(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	report, err := q.service.Quality(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, trade.ErrUploadNotFound) {
+			http.Error(w, "Upload not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to get quality report", http.StatusInternalServerError)
+		return
+	}
+
+	marshal, err := json.Marshal(report)
+	if err != nil {
+		http.Error(w, "Failed to marshal quality report", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(marshal)
+}
+
+func (q *Quotation) GetCompleteness(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Failed to parse id", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := trade.SequenceFilter{
+		UploadID: id,
+		Ticker:   query.Get("ticker"),
+	}
+
+	if incomplete := query.Get("incomplete"); incomplete != "" {
+		filter.Incomplete, err = strconv.ParseBool(incomplete)
+		if err != nil {
+			http.Error(w, "Failed to parse incomplete", http.StatusBadRequest)
+			return
+		}
+	}
+
+	completeness, err := q.service.Completeness(r.Context(), filter)
+	if err != nil {
+		if errors.Is(err, trade.ErrUploadNotFound) {
+			http.Error(w, "Upload not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to get completeness", http.StatusInternalServerError)
+		return
+	}
+
+	marshal, err := json.Marshal(completeness)
+	if err != nil {
+		http.Error(w, "Failed to marshal completeness", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(marshal)
+}
+
+func (q *Quotation) GetOptionChain(w http.ResponseWriter, r *http.Request) {
+	underlying := chi.URLParam(r, "underlying")
+
+	date := r.URL.Query().Get("date")
+	if date == "" {
+		http.Error(w, "Missing date", http.StatusBadRequest)
+		return
+	}
+
+	dateTime, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		http.Error(w, "Failed to parse date", http.StatusBadRequest)
+		return
+	}
+
+	series, err := q.service.OptionChain(r.Context(), trade.OptionFilter{
+		Underlying: underlying,
+		Date:       dateTime,
+	})
+	if err != nil {
+		if errors.Is(err, calendar.ErrNotTradingDay) {
+			http.Error(w, "Not a trading day", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to get option chain", http.StatusInternalServerError)
+		return
+	}
+
+	marshal, err := json.Marshal(series)
+	if err != nil {
+		http.Error(w, "Failed to marshal option chain", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(marshal)
+}
+
+func (q *Quotation) GetContinuousFuture(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	filter := trade.ContinuousFilter{
+		Root:     chi.URLParam(r, "root"),
+		Roll:     trade.RollVolume,
+		RollDays: trade.DefaultRollDays,
+		Adjust:   trade.AdjustNone,
+	}
+
+	var err error
+	if start := query.Get("start"); start != "" {
+		filter.Start, err = time.Parse("2006-01-02", start)
+		if err != nil {
+			http.Error(w, "Failed to parse start", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if end := query.Get("end"); end != "" {
+		filter.End, err = time.Parse("2006-01-02", end)
+		if err != nil {
+			http.Error(w, "Failed to parse end", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if roll := query.Get("roll"); roll != "" {
+		if roll != trade.RollVolume && roll != trade.RollExpiry {
+			http.Error(w, "Invalid roll", http.StatusBadRequest)
+			return
+		}
+		filter.Roll = roll
+	}
+
+	if days := query.Get("days"); days != "" {
+		filter.RollDays, err = strconv.Atoi(days)
+		if err != nil || filter.RollDays < 0 {
+			http.Error(w, "Failed to parse days", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if adjust := query.Get("adjust"); adjust != "" {
+		if adjust != trade.AdjustNone && adjust != trade.AdjustDifference && adjust != trade.AdjustRatio {
+			http.Error(w, "Invalid adjust", http.StatusBadRequest)
+			return
+		}
+		filter.Adjust = adjust
+	}
+
+	series, err := q.service.ContinuousFuture(r.Context(), filter)
+	if err != nil {
+		http.Error(w, "Failed to get continuous series", http.StatusInternalServerError)
+		return
+	}
+
+	marshal, err := json.Marshal(series)
+	if err != nil {
+		http.Error(w, "Failed to marshal continuous series", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(marshal)
+}
+
+func (q *Quotation) GetCorporateActions(w http.ResponseWriter, r *http.Request) {
+	ticker := r.URL.Query().Get("ticker")
+	if ticker == "" {
+		http.Error(w, "Missing ticker", http.StatusBadRequest)
+		return
+	}
+
+	actions, err := q.service.CorporateActions(r.Context(), ticker)
+	if err != nil {
+		http.Error(w, "Failed to get corporate actions", http.StatusInternalServerError)
+		return
+	}
+
+	marshal, err := json.Marshal(actions)
+	if err != nil {
+		http.Error(w, "Failed to marshal corporate actions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(marshal)
+}
+
+// corporateActionRequest is the body of the corporate action upload, the ex date is formatted as 2006-01-02
+type corporateActionRequest struct {
+	Ticker string          `json:"ticker"`
+	ExDate string          `json:"ex_date"`
+	Type   string          `json:"type"`
+	Factor decimal.Decimal `json:"factor"`
+}
+
+func (q *Quotation) CreateCorporateActions(w http.ResponseWriter, r *http.Request) {
+	var body []corporateActionRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Failed to decode body", http.StatusBadRequest)
+		return
+	}
+
+	actions := make([]*trade.CorporateAction, len(body))
+	for i, action := range body {
+		exDate, err := time.Parse("2006-01-02", action.ExDate)
+		if err != nil {
+			http.Error(w, "Failed to parse ex_date", http.StatusBadRequest)
+			return
+		}
+
+		actions[i] = &trade.CorporateAction{
+			Ticker: action.Ticker,
+			ExDate: exDate,
+			Type:   action.Type,
+			Factor: action.Factor,
+		}
+	}
+
+	err := q.service.SaveCorporateActions(r.Context(), actions)
+	if err != nil {
+		if errors.Is(err, trade.ErrInvalidCorporateAction) {
+			http.Error(w, "Invalid corporate action", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to save corporate actions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(fmt.Sprintf(`{"imported":%d}`, len(actions))))
+}
+
+func (q *Quotation) ImportCorporateActions(w http.ResponseWriter, r *http.Request) {
+	file, _, err := r.FormFile("CorporateActions")
+	if err != nil {
+		http.Error(w, "Failed to get file", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	imported, err := q.service.ImportCorporateActions(r.Context(), file)
+	if err != nil {
+		if errors.Is(err, trade.ErrInvalidCorporateAction) {
+			http.Error(w, "Failed to parse corporate actions file", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to import corporate actions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(fmt.Sprintf(`{"imported":%d}`, imported)))
+}
+
+func (q *Quotation) ImportInstruments(w http.ResponseWriter, r *http.Request) {
+	file, _, err := r.FormFile("Instruments")
+	if err != nil {
+		http.Error(w, "Failed to get file", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	imported, err := q.service.ImportInstruments(r.Context(), file)
+	if err != nil {
+		if errors.Is(err, trade.ErrInvalidInstrumentFile) {
+			http.Error(w, "Failed to parse instruments file", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to import instruments", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(fmt.Sprintf(`{"imported":%d}`, imported)))
+}
+
+func (q *Quotation) GetActivity(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	filter := trade.ActivityFilter{
+		Ticker: chi.URLParam(r, "ticker"),
+	}
+
+	var err error
+	if start := query.Get("start"); start != "" {
+		filter.Start, err = time.Parse("2006-01-02", start)
+		if err != nil {
+			http.Error(w, "Failed to parse start", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if end := query.Get("end"); end != "" {
+		filter.End, err = time.Parse("2006-01-02", end)
+		if err != nil {
+			http.Error(w, "Failed to parse end", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if bucket := query.Get("bucket"); bucket != "" {
+		filter.BucketMinutes, err = parseBucket(bucket)
+		if err != nil {
+			http.Error(w, "Failed to parse bucket", http.StatusBadRequest)
+			return
+		}
+	}
+
+	activity, err := q.service.Activity(r.Context(), filter)
+	if err != nil {
+		http.Error(w, "Failed to get activity", http.StatusInternalServerError)
+		return
+	}
+
+	marshal, err := json.Marshal(activity)
+	if err != nil {
+		http.Error(w, "Failed to marshal activity", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(marshal)
+}
+
+func (q *Quotation) GetMarketBreadth(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	start, err := time.Parse("2006-01-02", query.Get("start"))
+	if err != nil {
+		http.Error(w, "Failed to parse start", http.StatusBadRequest)
+		return
+	}
+
+	end, err := time.Parse("2006-01-02", query.Get("end"))
+	if err != nil {
+		http.Error(w, "Failed to parse end", http.StatusBadRequest)
+		return
+	}
+
+	if end.Before(start) {
+		http.Error(w, "End before start", http.StatusBadRequest)
+		return
+	}
+
+	filter := trade.BreadthFilter{
+		Start: start,
+		End:   end,
+	}
+
+	if days := query.Get("days"); days != "" {
+		filter.Days, err = strconv.Atoi(days)
+		if err != nil {
+			http.Error(w, "Failed to parse days", http.StatusBadRequest)
+			return
+		}
+	}
+
+	breadth, err := q.service.MarketBreadth(r.Context(), filter)
+	if err != nil {
+		http.Error(w, "Failed to get market breadth", http.StatusInternalServerError)
+		return
+	}
+
+	marshal, err := json.Marshal(breadth)
+	if err != nil {
+		http.Error(w, "Failed to marshal market breadth", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(marshal)
+}
+
+func (q *Quotation) GetLiquidity(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	start, err := time.Parse("2006-01-02", query.Get("start"))
+	if err != nil {
+		http.Error(w, "Failed to parse start", http.StatusBadRequest)
+		return
+	}
+
+	end, err := time.Parse("2006-01-02", query.Get("end"))
+	if err != nil {
+		http.Error(w, "Failed to parse end", http.StatusBadRequest)
+		return
+	}
+
+	if end.Before(start) {
+		http.Error(w, "End before start", http.StatusBadRequest)
+		return
+	}
+
+	liquidity, err := q.service.Liquidity(r.Context(), trade.LiquidityFilter{
+		Ticker: chi.URLParam(r, "ticker"),
+		Start:  start,
+		End:    end,
+	})
+	if err != nil {
+		http.Error(w, "Failed to get liquidity", http.StatusInternalServerError)
+		return
+	}
+
+	marshal, err := json.Marshal(liquidity)
+	if err != nil {
+		http.Error(w, "Failed to marshal liquidity", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(marshal)
+}
+
+func (q *Quotation) GetOrderFlow(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	filter := trade.OrderFlowFilter{
+		Ticker:   chi.URLParam(r, "ticker"),
+		Intraday: query.Get("intraday") == "true",
+	}
+
+	var err error
+	if start := query.Get("start"); start != "" {
+		filter.Start, err = time.Parse("2006-01-02", start)
+		if err != nil {
+			http.Error(w, "Failed to parse start", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if end := query.Get("end"); end != "" {
+		filter.End, err = time.Parse("2006-01-02", end)
+		if err != nil {
+			http.Error(w, "Failed to parse end", http.StatusBadRequest)
+			return
+		}
+	}
+
+	flows, err := q.service.OrderFlow(r.Context(), filter)
+	if err != nil {
+		http.Error(w, "Failed to get order flow", http.StatusInternalServerError)
+		return
+	}
+
+	marshal, err := json.Marshal(flows)
+	if err != nil {
+		http.Error(w, "Failed to marshal order flow", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(marshal)
+}
+
+func (q *Quotation) GetBenchmarks(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	date := query.Get("date")
+	if date == "" {
+		http.Error(w, "Missing date", http.StatusBadRequest)
+		return
+	}
+
+	dateTime, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		http.Error(w, "Failed to parse date", http.StatusBadRequest)
+		return
+	}
+
+	if len(query["window"]) == 0 {
+		http.Error(w, "Missing window", http.StatusBadRequest)
+		return
+	}
+
+	if len(query["window"]) > trade.MaxBenchmarkWindows {
+		http.Error(w, fmt.Sprintf("Too many windows, at most %d", trade.MaxBenchmarkWindows), http.StatusBadRequest)
+		return
+	}
+
+	filter := trade.BenchmarkFilter{
+		Ticker: chi.URLParam(r, "ticker"),
+		Date:   dateTime,
+	}
+
+	for _, value := range query["window"] {
+		window, err := parseWindow(value)
+		if err != nil {
+			http.Error(w, "Failed to parse window", http.StatusBadRequest)
+			return
+		}
+		filter.Windows = append(filter.Windows, window)
+	}
+
+	benchmarks, err := q.service.Benchmarks(r.Context(), filter)
+	if err != nil {
+		if errors.Is(err, calendar.ErrNotTradingDay) {
+			http.Error(w, "Not a trading day", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to get benchmarks", http.StatusInternalServerError)
+		return
+	}
+
+	marshal, err := json.Marshal(benchmarks)
+	if err != nil {
+		http.Error(w, "Failed to marshal benchmarks", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(marshal)
+}
+
+func (q *Quotation) GetAuctions(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	filter := trade.AuctionFilter{
+		Ticker: chi.URLParam(r, "ticker"),
+		Type:   query.Get("auction"),
+	}
+
+	if filter.Type != "" && filter.Type != trade.AuctionOpening && filter.Type != trade.AuctionClosing {
+		http.Error(w, "Invalid auction", http.StatusBadRequest)
+		return
+	}
+
+	var err error
+	if start := query.Get("start"); start != "" {
+		filter.Start, err = time.Parse("2006-01-02", start)
+		if err != nil {
+			http.Error(w, "Failed to parse start", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if end := query.Get("end"); end != "" {
+		filter.End, err = time.Parse("2006-01-02", end)
+		if err != nil {
+			http.Error(w, "Failed to parse end", http.StatusBadRequest)
+			return
+		}
+	}
+
+	auctions, err := q.service.Auctions(r.Context(), filter)
+	if err != nil {
+		http.Error(w, "Failed to get auctions", http.StatusInternalServerError)
+		return
+	}
+
+	marshal, err := json.Marshal(auctions)
+	if err != nil {
+		http.Error(w, "Failed to marshal auctions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(marshal)
+}
+
+func (q *Quotation) GetTradingDay(w http.ResponseWriter, r *http.Request) {
+	date, err := time.Parse("2006-01-02", chi.URLParam(r, "date"))
+	if err != nil {
+		http.Error(w, "Failed to parse date", http.StatusBadRequest)
+		return
+	}
+
+	marshal, err := json.Marshal(q.service.TradingDay(date))
+	if err != nil {
+		http.Error(w, "Failed to marshal trading day", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(marshal)
+}
+
+func (q *Quotation) GetTradingDays(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	start, err := time.Parse("2006-01-02", query.Get("start"))
+	if err != nil {
+		http.Error(w, "Failed to parse start", http.StatusBadRequest)
+		return
+	}
+
+	end, err := time.Parse("2006-01-02", query.Get("end"))
+	if err != nil {
+		http.Error(w, "Failed to parse end", http.StatusBadRequest)
+		return
+	}
+
+	if end.Before(start) {
+		http.Error(w, "End before start", http.StatusBadRequest)
+		return
+	}
+
+	marshal, err := json.Marshal(q.service.TradingDays(start, end))
+	if err != nil {
+		http.Error(w, "Failed to marshal trading days", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(marshal)
+}
+
+func NewQuotation(service trade.Service) *Quotation {
+	return &Quotation{
+		service: service,
+	}
+}
+
+// parseTimeOfDay accepts a time of day with or without seconds, e.g. 10:00 or 10:00:30
+func parseTimeOfDay(value string) (time.Time, error) {
+	t, err := time.Parse("15:04:05", value)
+	if err != nil {
+		return time.Parse("15:04", value)
+	}
+	return t, nil
+}
+
+// parseWindow reads an intraday window such as 10:00-11:30, the end must be after the start
+func parseWindow(value string) (trade.BenchmarkWindow, error) {
+	from, to, ok := strings.Cut(value, "-")
+	if !ok {
+		return trade.BenchmarkWindow{}, fmt.Errorf("unexpected window: %s", value)
+	}
+
+	fromTime, err := parseTimeOfDay(from)
+	if err != nil {
+		return trade.BenchmarkWindow{}, err
+	}
+
+	toTime, err := parseTimeOfDay(to)
+	if err != nil {
+		return trade.BenchmarkWindow{}, err
+	}
+
+	if !toTime.After(fromTime) {
+		return trade.BenchmarkWindow{}, fmt.Errorf("unexpected window: %s", value)
+	}
+
+	return trade.BenchmarkWindow{From: fromTime, To: toTime}, nil
+}
+
+// parseBucket reads a bucket size such as 15m or 1h as whole minutes of at most a day
+func parseBucket(value string) (int, error) {
+	bucket, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if bucket < time.Minute || bucket > 24*time.Hour || bucket%time.Minute != 0 {
+		return 0, fmt.Errorf("unexpected bucket: %s", value)
+	}
+	return int(bucket.Minutes()), nil
+}
+
+// metricFilter reads the query parameters shared by the metric endpoints, the session defaults to the regular one
+func metricFilter(r *http.Request) (trade.MetricFilter, error) {
+	query := r.URL.Query()
+
+	filter := trade.MetricFilter{
+		Ticker:  query.Get("ticker"),
+		Session: trade.SessionRegular,
+	}
+
+	if filter.Ticker == "" {
+		return filter, errors.New("Missing ticker")
+	}
+
+	if name := query.Get("session"); name != "" {
+		var ok bool
+		filter.Session, ok = trade.Sessions[name]
+		if !ok {
+			return filter, errors.New("Invalid session")
+		}
+	}
+
+	var err error
+	filter.InstrumentType, err = instrumentTypeParam(r)
+	if err != nil {
+		return filter, err
+	}
+
+	if consolidated := query.Get("consolidated"); consolidated != "" {
+		filter.Consolidated, err = strconv.ParseBool(consolidated)
+		if err != nil {
+			return filter, errors.New("Failed to parse consolidated")
+		}
+	}
+
+	if adjusted := query.Get("adjusted"); adjusted != "" {
+		filter.Adjusted, err = strconv.ParseBool(adjusted)
+		if err != nil {
+			return filter, errors.New("Failed to parse adjusted")
+		}
+	}
+
+	return filter, nil
+}
+
+// brokerFilter reads the query parameters shared by the broker endpoints
+func brokerFilter(r *http.Request) (trade.BrokerFilter, error) {
+	query := r.URL.Query()
+
+	filter := trade.BrokerFilter{
+		Ticker: query.Get("ticker"),
+		Broker: query.Get("broker"),
+	}
+
+	var err error
+	if start := query.Get("start"); start != "" {
+		filter.Start, err = time.Parse("2006-01-02", start)
+		if err != nil {
+			return filter, errors.New("Failed to parse start")
+		}
+	}
+
+	if end := query.Get("end"); end != "" {
+		filter.End, err = time.Parse("2006-01-02", end)
+		if err != nil {
+			return filter, errors.New("Failed to parse end")
+		}
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		filter.Limit, err = strconv.Atoi(limit)
+		if err != nil {
+			return filter, errors.New("Failed to parse limit")
+		}
+	}
+
+	filter.InstrumentType, err = instrumentTypeParam(r)
+	if err != nil {
+		return filter, err
+	}
+
+	return filter, nil
+}
+
+// instrumentTypeParam reads the optional instrument type filter shared by the query endpoints
+func instrumentTypeParam(r *http.Request) (instrument.Type, error) {
+	value := r.URL.Query().Get("type")
+	if value != "" && !instrument.Valid(value) {
+		return "", errors.New("Invalid type")
 	}
+	return instrument.Type(value), nil
 }
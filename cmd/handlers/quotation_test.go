@@ -13,7 +13,10 @@ import (
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"quotation-metrics/internal/calendar"
+	"quotation-metrics/internal/instrument"
 	"quotation-metrics/internal/trade"
+	"strings"
 	"testing"
 	"time"
 )
@@ -22,16 +25,164 @@ type mockService struct {
 	mock.Mock
 }
 
-func (m *mockService) BatchInsert(ctx context.Context, reader io.Reader) error {
-	args := m.Called(ctx, reader)
+func (m *mockService) CreateUpload(ctx context.Context, upload *trade.Upload, file io.ReadSeeker) (*trade.Upload, error) {
+	args := m.Called(ctx, upload, file)
+	return args.Get(0).(*trade.Upload), args.Error(1)
+}
+
+func (m *mockService) BatchInsert(ctx context.Context, upload *trade.Upload, reader io.Reader) error {
+	args := m.Called(ctx, upload, reader)
 	return args.Error(0)
 }
 
-func (m *mockService) Metrics(ctx context.Context, ticker string, date time.Time) (*trade.Metric, error) {
-	args := m.Called(ctx, ticker, date)
+func (m *mockService) Metrics(ctx context.Context, filter trade.MetricFilter) (*trade.Metric, error) {
+	args := m.Called(ctx, filter)
 	return args.Get(0).(*trade.Metric), args.Error(1)
 }
 
+func (m *mockService) Trades(ctx context.Context, filter trade.TradeFilter) (*trade.TradePage, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).(*trade.TradePage), args.Error(1)
+}
+
+func (m *mockService) Anomalies(ctx context.Context, filter trade.AnomalyFilter) ([]*trade.Anomaly, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]*trade.Anomaly), args.Error(1)
+}
+
+func (m *mockService) BlockTrades(ctx context.Context, filter trade.BlockTradeFilter) ([]*trade.BlockTrade, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]*trade.BlockTrade), args.Error(1)
+}
+
+func (m *mockService) TopBrokers(ctx context.Context, filter trade.BrokerFilter) (*trade.BrokerRanking, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).(*trade.BrokerRanking), args.Error(1)
+}
+
+func (m *mockService) BrokerFlows(ctx context.Context, filter trade.BrokerFilter) ([]*trade.BrokerFlow, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]*trade.BrokerFlow), args.Error(1)
+}
+
+func (m *mockService) BrokerMatrix(ctx context.Context, filter trade.BrokerFilter) (*trade.BrokerMatrix, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).(*trade.BrokerMatrix), args.Error(1)
+}
+
+func (m *mockService) ClassifyInstruments(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+func (m *mockService) ImportInstruments(ctx context.Context, reader io.Reader) (int, error) {
+	args := m.Called(ctx, reader)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *mockService) ContinuousFuture(ctx context.Context, filter trade.ContinuousFilter) ([]*trade.ContinuousPoint, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]*trade.ContinuousPoint), args.Error(1)
+}
+
+func (m *mockService) MetricHistory(ctx context.Context, filter trade.MetricFilter) ([]*trade.DailyMetric, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]*trade.DailyMetric), args.Error(1)
+}
+
+func (m *mockService) CorporateActions(ctx context.Context, ticker string) ([]*trade.CorporateAction, error) {
+	args := m.Called(ctx, ticker)
+	return args.Get(0).([]*trade.CorporateAction), args.Error(1)
+}
+
+func (m *mockService) SaveCorporateActions(ctx context.Context, actions []*trade.CorporateAction) error {
+	args := m.Called(ctx, actions)
+	return args.Error(0)
+}
+
+func (m *mockService) ImportCorporateActions(ctx context.Context, reader io.Reader) (int, error) {
+	args := m.Called(ctx, reader)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *mockService) OptionChain(ctx context.Context, filter trade.OptionFilter) ([]*trade.OptionSeries, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]*trade.OptionSeries), args.Error(1)
+}
+
+func (m *mockService) Activity(ctx context.Context, filter trade.ActivityFilter) (*trade.Activity, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).(*trade.Activity), args.Error(1)
+}
+
+func (m *mockService) MarketBreadth(ctx context.Context, filter trade.BreadthFilter) ([]*trade.MarketBreadth, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]*trade.MarketBreadth), args.Error(1)
+}
+
+func (m *mockService) Liquidity(ctx context.Context, filter trade.LiquidityFilter) (*trade.Liquidity, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).(*trade.Liquidity), args.Error(1)
+}
+
+func (m *mockService) OrderFlow(ctx context.Context, filter trade.OrderFlowFilter) ([]*trade.DailyOrderFlow, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]*trade.DailyOrderFlow), args.Error(1)
+}
+
+func (m *mockService) Benchmarks(ctx context.Context, filter trade.BenchmarkFilter) (*trade.Benchmarks, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).(*trade.Benchmarks), args.Error(1)
+}
+
+func (m *mockService) Auctions(ctx context.Context, filter trade.AuctionFilter) ([]*trade.Auction, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]*trade.Auction), args.Error(1)
+}
+
+func (m *mockService) Upload(ctx context.Context, id int) (*trade.Upload, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*trade.Upload), args.Error(1)
+}
+
+func (m *mockService) Completeness(ctx context.Context, filter trade.SequenceFilter) (*trade.Completeness, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).(*trade.Completeness), args.Error(1)
+}
+
+func (m *mockService) Quality(ctx context.Context, uploadID int) (*trade.QualityReport, error) {
+	args := m.Called(ctx, uploadID)
+	return args.Get(0).(*trade.QualityReport), args.Error(1)
+}
+
+func (m *mockService) Validate(ctx context.Context, reader io.Reader, maxErrors int) (*trade.Validation, error) {
+	args := m.Called(ctx, reader, maxErrors)
+	return args.Get(0).(*trade.Validation), args.Error(1)
+}
+
+func (m *mockService) DeleteUpload(ctx context.Context, audit *trade.UploadAudit) error {
+	args := m.Called(ctx, audit)
+	return args.Error(0)
+}
+
+func (m *mockService) Replay(ctx context.Context, id int, actor string) (*trade.Upload, io.ReadCloser, error) {
+	args := m.Called(ctx, id, actor)
+	if args.Get(1) != nil {
+		return args.Get(0).(*trade.Upload), args.Get(1).(io.ReadCloser), args.Error(2)
+	}
+	return args.Get(0).(*trade.Upload), nil, args.Error(2)
+}
+
+func (m *mockService) TradingDay(date time.Time) *trade.TradingDay {
+	args := m.Called(date)
+	return args.Get(0).(*trade.TradingDay)
+}
+
+func (m *mockService) TradingDays(start, end time.Time) *trade.TradingDays {
+	args := m.Called(start, end)
+	return args.Get(0).(*trade.TradingDays)
+}
+
 func TestGetMetrics(t *testing.T) {
 	cases := []struct {
 		name string
@@ -51,7 +202,7 @@ func TestGetMetrics(t *testing.T) {
 				date   string
 			}{ticker: "GOOG", date: "2024-06-20"},
 			mockFunc: func(m *mockService) {
-				m.On("Metrics", mock.Anything, "GOOG", time.Date(2024, 06, 20, 0, 0, 0, 0, time.UTC)).
+				m.On("Metrics", mock.Anything, trade.MetricFilter{Ticker: "GOOG", Date: time.Date(2024, 06, 20, 0, 0, 0, 0, time.UTC), Session: trade.SessionRegular}).
 					Return(&trade.Metric{
 						Ticker:         "GOOG",
 						MaxDailyVolume: 11,
@@ -68,12 +219,143 @@ func TestGetMetrics(t *testing.T) {
 				date   string
 			}{ticker: "GOOG", date: "2024-06-20"},
 			mockFunc: func(m *mockService) {
-				m.On("Metrics", mock.Anything, "GOOG", time.Date(2024, 06, 20, 0, 0, 0, 0, time.UTC)).
+				m.On("Metrics", mock.Anything, trade.MetricFilter{Ticker: "GOOG", Date: time.Date(2024, 06, 20, 0, 0, 0, 0, time.UTC), Session: trade.SessionRegular}).
 					Return((*trade.Metric)(nil), errors.New("mock-error")).Once()
 			},
 			status: http.StatusInternalServerError,
 			want:   "Failed to get metrics\n",
 		},
+		{
+			name: "success with every session",
+			req: struct {
+				ticker string
+				date   string
+			}{ticker: "GOOG&session=all", date: "2024-06-20"},
+			mockFunc: func(m *mockService) {
+				m.On("Metrics", mock.Anything, trade.MetricFilter{Ticker: "GOOG", Date: time.Date(2024, 06, 20, 0, 0, 0, 0, time.UTC)}).
+					Return(&trade.Metric{
+						Ticker:         "GOOG",
+						MaxDailyVolume: 15,
+						MaxRangeValue:  decimal.NewFromInt(30),
+					}, nil).Once()
+			},
+			status: http.StatusOK,
+			want:   "{\"ticker\":\"GOOG\",\"max_range_value\":\"30\",\"max_daily_volume\":15}",
+		},
+		{
+			name: "success with instrument type",
+			req: struct {
+				ticker string
+				date   string
+			}{ticker: "PETR4&type=stock", date: ""},
+			mockFunc: func(m *mockService) {
+				m.On("Metrics", mock.Anything, trade.MetricFilter{Ticker: "PETR4", Session: trade.SessionRegular, InstrumentType: instrument.TypeStock}).
+					Return(&trade.Metric{
+						Ticker:         "PETR4",
+						MaxDailyVolume: 300,
+						MaxRangeValue:  decimal.NewFromInt(39),
+					}, nil).Once()
+			},
+			status: http.StatusOK,
+			want:   "{\"ticker\":\"PETR4\",\"max_range_value\":\"39\",\"max_daily_volume\":300}",
+		},
+		{
+			name: "success consolidated",
+			req: struct {
+				ticker string
+				date   string
+			}{ticker: "PETR4&consolidated=true", date: ""},
+			mockFunc: func(m *mockService) {
+				m.On("Metrics", mock.Anything, trade.MetricFilter{Ticker: "PETR4", Session: trade.SessionRegular, Consolidated: true}).
+					Return(&trade.Metric{
+						Ticker:         "PETR4",
+						MaxDailyVolume: 320,
+						MaxRangeValue:  decimal.NewFromInt(39),
+					}, nil).Once()
+			},
+			status: http.StatusOK,
+			want:   "{\"ticker\":\"PETR4\",\"max_range_value\":\"39\",\"max_daily_volume\":320}",
+		},
+		{
+			name: "success with changes",
+			req: struct {
+				ticker string
+				date   string
+			}{ticker: "PETR4&include=changes", date: ""},
+			mockFunc: func(m *mockService) {
+				m.On("Metrics", mock.Anything, trade.MetricFilter{Ticker: "PETR4", Session: trade.SessionRegular, IncludeChanges: true}).
+					Return(&trade.Metric{
+						Ticker:         "PETR4",
+						MaxDailyVolume: 320,
+						MaxRangeValue:  decimal.NewFromInt(39),
+						Changes: &trade.MetricChanges{
+							TradeDate:       time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC),
+							ClosePrice:      decimal.NewFromInt(38),
+							PreviousDate:    time.Date(2024, 06, 27, 0, 0, 0, 0, time.UTC),
+							PreviousClose:   decimal.NewFromInt(40),
+							Change:          decimal.NewFromInt(-2),
+							ChangePercent:   decimal.NewFromInt(-5),
+							Volume:          320,
+							PreviousVolume:  160,
+							VolumeChange:    160,
+							AverageVolume:   decimal.NewFromInt(200),
+							VolumeVsAverage: decimal.NewFromFloat(1.6),
+						},
+					}, nil).Once()
+			},
+			status: http.StatusOK,
+			want:   `{"ticker":"PETR4","max_range_value":"39","max_daily_volume":320,"changes":{"trade_date":"2024-06-28T00:00:00Z","close_price":"38","previous_date":"2024-06-27T00:00:00Z","previous_close":"40","change":"-2","change_percent":"-5","volume":320,"previous_volume":160,"volume_change":160,"average_volume_20d":"200","volume_vs_average":"1.6"}}`,
+		},
+		{
+			name: "failed because error invalid include",
+			req: struct {
+				ticker string
+				date   string
+			}{ticker: "PETR4&include=history", date: ""},
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Invalid include\n",
+		},
+		{
+			name: "failed because error parse adjusted",
+			req: struct {
+				ticker string
+				date   string
+			}{ticker: "PETR4&adjusted=maybe", date: ""},
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Failed to parse adjusted\n",
+		},
+		{
+			name: "failed because error parse consolidated",
+			req: struct {
+				ticker string
+				date   string
+			}{ticker: "PETR4&consolidated=maybe", date: ""},
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Failed to parse consolidated\n",
+		},
+		{
+			name: "failed because error invalid instrument type",
+			req: struct {
+				ticker string
+				date   string
+			}{ticker: "PETR4&type=bond", date: ""},
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Invalid type\n",
+		},
+		{
+			name: "failed because error invalid session",
+			req: struct {
+				ticker string
+				date   string
+			}{ticker: "GOOG&session=night", date: "2024-06-20"},
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Invalid session\n",
+		},
 		{
 			name: "failed because error parse date",
 			req: struct {
@@ -121,78 +403,2483 @@ func TestGetMetrics(t *testing.T) {
 	}
 }
 
-func TestBatchUpload(t *testing.T) {
+func TestGetTrades(t *testing.T) {
 	cases := []struct {
 		name     string
-		req      string
+		query    string
 		mockFunc func(m *mockService)
 		status   int
 		want     string
 	}{
 		{
-			name: "success",
-			req:  "header\nGOOG,29,11,2024-06-20\n",
+			name:  "success",
+			query: "ticker=PETR4&date=2024-06-28&from_time=10:00&to_time=11:30:15&min_qty=100&cursor=10&limit=1",
 			mockFunc: func(m *mockService) {
-				m.On("BatchInsert", mock.Anything, mock.Anything).
-					Return(nil)
+				m.On("Trades", mock.Anything, trade.TradeFilter{
+					Ticker:   "PETR4",
+					Date:     time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC),
+					FromTime: time.Date(0, 1, 1, 10, 0, 0, 0, time.UTC),
+					ToTime:   time.Date(0, 1, 1, 11, 30, 15, 0, time.UTC),
+					MinQty:   100,
+					Cursor:   10,
+					Limit:    1,
+				}).Return(&trade.TradePage{
+					Trades: []*trade.Trade{
+						{
+							ID:             11,
+							InstrumentCode: "PETR4",
+							TradePrice:     decimal.NewFromFloat(38.5),
+							TradeQuantity:  100,
+							CloseTime:      "100001250",
+							TradeDate:      time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC),
+							TradedAt:       time.Date(2024, 06, 28, 13, 0, 1, 250000000, time.UTC),
+							BuyerCode:      "3",
+							SellerCode:     "23",
+							SessionType:    1,
+							TradeID:        5501,
+						},
+					},
+					NextCursor: 11,
+				}, nil).Once()
 			},
 			status: http.StatusOK,
-			want:   `{"message":"file uploaded successfully"}`,
+			want:   `{"trades":[{"id":11,"instrument_code":"PETR4","trade_price":"38.5","trade_quantity":100,"close_time":"100001250","trade_date":"2024-06-28T00:00:00Z","traded_at":"2024-06-28T13:00:01.25Z","buyer_code":"3","seller_code":"23","session_type":1,"trade_id":5501}],"next_cursor":11}`,
 		},
 		{
-			name:     "missing file",
-			req:      "",
+			name:  "failed because error in trades",
+			query: "ticker=PETR4",
+			mockFunc: func(m *mockService) {
+				m.On("Trades", mock.Anything, trade.TradeFilter{Ticker: "PETR4"}).
+					Return((*trade.TradePage)(nil), errors.New("mock-error")).Once()
+			},
+			status: http.StatusInternalServerError,
+			want:   "Failed to get trades\n",
+		},
+		{
+			name:     "failed because error parse from_time",
+			query:    "from_time=10h",
 			mockFunc: func(m *mockService) {},
 			status:   http.StatusBadRequest,
-			want:     "Failed to get file\n",
+			want:     "Failed to parse from_time\n",
+		},
+		{
+			name:  "success with instrument type",
+			query: "type=option",
+			mockFunc: func(m *mockService) {
+				m.On("Trades", mock.Anything, trade.TradeFilter{InstrumentType: instrument.TypeOption}).
+					Return(&trade.TradePage{Trades: []*trade.Trade{}}, nil).Once()
+			},
+			status: http.StatusOK,
+			want:   `{"trades":[]}`,
+		},
+		{
+			name:     "failed because error invalid instrument type",
+			query:    "type=bond",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Invalid type\n",
+		},
+		{
+			name:     "failed because error parse min_qty",
+			query:    "min_qty=a",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Failed to parse min_qty\n",
+		},
+		{
+			name:     "failed because error parse cursor",
+			query:    "cursor=a",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Failed to parse cursor\n",
 		},
 	}
 
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
 			m := new(mockService)
-			if tc.mockFunc != nil {
-				tc.mockFunc(m)
+			tc.mockFunc(m)
+
+			s := NewQuotation(m)
+
+			req, err := http.NewRequest("GET", "/trades?"+tc.query, nil)
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
 			}
 
-			q := &Quotation{service: m}
+			rr := httptest.NewRecorder()
 
-			body := &bytes.Buffer{}
-			header := http.Header{}
+			r := chi.NewRouter()
+			r.Get("/trades", s.GetTrades)
 
-			if tc.req != "" {
-				writer := multipart.NewWriter(body)
-				part, err := writer.CreateFormFile("Quotation", "example.csv")
-				if err != nil {
-					t.Fatalf("failed to create form file: %v", err)
-				}
-				_, err = part.Write([]byte(tc.req))
-				if err != nil {
-					t.Fatalf("failed to write to form file: %v", err)
-				}
-				err = writer.Close()
-				if err != nil {
-					t.Fatalf("failed to close multipart writer: %v", err)
-				}
+			r.ServeHTTP(rr, req)
 
-				header.Set("Content-Type", writer.FormDataContentType())
-			}
+			assert.Equal(t, tc.status, rr.Code)
+			assert.Equal(t, tc.want, rr.Body.String())
+			m.AssertExpectations(t)
+		})
+	}
+}
 
-			req, err := http.NewRequest("POST", "/upload", body)
+func TestGetAnomalies(t *testing.T) {
+	cases := []struct {
+		name     string
+		query    string
+		mockFunc func(m *mockService)
+		status   int
+		want     string
+	}{
+		{
+			name:  "success",
+			query: "ticker=PETR4&date=2024-06-28&reason=price_deviation",
+			mockFunc: func(m *mockService) {
+				m.On("Anomalies", mock.Anything, trade.AnomalyFilter{
+					Ticker: "PETR4",
+					Date:   time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC),
+					Reason: "price_deviation",
+				}).Return([]*trade.Anomaly{
+					{
+						ID:             1,
+						InstrumentCode: "PETR4",
+						TradePrice:     decimal.NewFromInt(382),
+						TradeQuantity:  100,
+						CloseTime:      "100200000",
+						TradeDate:      time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC),
+						Reason:         "price_deviation",
+						ReferenceValue: decimal.NewFromFloat(38.1),
+					},
+				}, nil).Once()
+			},
+			status: http.StatusOK,
+			want:   `[{"id":1,"instrument_code":"PETR4","trade_price":"382","trade_quantity":100,"close_time":"100200000","trade_date":"2024-06-28T00:00:00Z","reason":"price_deviation","reference_value":"38.1"}]`,
+		},
+		{
+			name:  "failed because error in anomalies",
+			query: "",
+			mockFunc: func(m *mockService) {
+				m.On("Anomalies", mock.Anything, trade.AnomalyFilter{}).
+					Return(([]*trade.Anomaly)(nil), errors.New("mock-error")).Once()
+			},
+			status: http.StatusInternalServerError,
+			want:   "Failed to get anomalies\n",
+		},
+		{
+			name:     "failed because error parse date",
+			query:    "date=2024-06-2J",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Failed to parse date\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := new(mockService)
+			tc.mockFunc(m)
+
+			s := NewQuotation(m)
+
+			req, err := http.NewRequest("GET", "/anomalies?"+tc.query, nil)
 			if err != nil {
 				t.Fatalf("failed to create request: %v", err)
 			}
-			req.Header = header
 
 			rr := httptest.NewRecorder()
 
 			r := chi.NewRouter()
-			r.Post("/upload", q.BatchUpload)
+			r.Get("/anomalies", s.GetAnomalies)
+
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.status, rr.Code)
+			assert.Equal(t, tc.want, rr.Body.String())
+			m.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetBlocks(t *testing.T) {
+	cases := []struct {
+		name     string
+		query    string
+		mockFunc func(m *mockService)
+		status   int
+		want     string
+	}{
+		{
+			name:  "success",
+			query: "date=2024-06-28&ticker=PETR4",
+			mockFunc: func(m *mockService) {
+				m.On("BlockTrades", mock.Anything, trade.BlockTradeFilter{
+					Ticker: "PETR4",
+					Date:   time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC),
+				}).Return([]*trade.BlockTrade{
+					{
+						ID:             1,
+						InstrumentCode: "PETR4",
+						TradePrice:     decimal.NewFromFloat(38.2),
+						TradeQuantity:  50000,
+						CloseTime:      "100100000",
+						TradeDate:      time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC),
+						BuyerCode:      "72",
+						SellerCode:     "8",
+						Criterion:      "absolute",
+					},
+				}, nil).Once()
+			},
+			status: http.StatusOK,
+			want:   `[{"id":1,"instrument_code":"PETR4","trade_price":"38.2","trade_quantity":50000,"close_time":"100100000","trade_date":"2024-06-28T00:00:00Z","buyer_code":"72","seller_code":"8","criterion":"absolute"}]`,
+		},
+		{
+			name:  "failed because error in block trades",
+			query: "date=2024-06-28",
+			mockFunc: func(m *mockService) {
+				m.On("BlockTrades", mock.Anything, trade.BlockTradeFilter{Date: time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC)}).
+					Return(([]*trade.BlockTrade)(nil), errors.New("mock-error")).Once()
+			},
+			status: http.StatusInternalServerError,
+			want:   "Failed to get block trades\n",
+		},
+		{
+			name:  "failed because not a trading day",
+			query: "date=2024-06-29",
+			mockFunc: func(m *mockService) {
+				m.On("BlockTrades", mock.Anything, trade.BlockTradeFilter{Date: time.Date(2024, 06, 29, 0, 0, 0, 0, time.UTC)}).
+					Return(([]*trade.BlockTrade)(nil), calendar.ErrNotTradingDay).Once()
+			},
+			status: http.StatusBadRequest,
+			want:   "Not a trading day\n",
+		},
+		{
+			name:     "failed because error missing date",
+			query:    "",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Missing date\n",
+		},
+		{
+			name:     "failed because error parse date",
+			query:    "date=2024-06-2J",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Failed to parse date\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := new(mockService)
+			tc.mockFunc(m)
+
+			s := NewQuotation(m)
+
+			req, err := http.NewRequest("GET", "/blocks?"+tc.query, nil)
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+
+			rr := httptest.NewRecorder()
+
+			r := chi.NewRouter()
+			r.Get("/blocks", s.GetBlocks)
+
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.status, rr.Code)
+			assert.Equal(t, tc.want, rr.Body.String())
+			m.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetBrokers(t *testing.T) {
+	cases := []struct {
+		name     string
+		path     string
+		mockFunc func(m *mockService)
+		status   int
+		want     string
+	}{
+		{
+			name: "success top brokers",
+			path: "/brokers/top?ticker=PETR4&start=2024-06-28&end=2024-06-28&limit=1",
+			mockFunc: func(m *mockService) {
+				m.On("TopBrokers", mock.Anything, trade.BrokerFilter{
+					Ticker: "PETR4",
+					Start:  time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC),
+					End:    time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC),
+					Limit:  1,
+				}).Return(&trade.BrokerRanking{
+					Ticker:  "PETR4",
+					Buyers:  []*trade.BrokerVolume{{Broker: "3", Quantity: 500, FinancialVolume: decimal.NewFromInt(19000), Trades: 2}},
+					Sellers: []*trade.BrokerVolume{{Broker: "72", Quantity: 400, FinancialVolume: decimal.NewFromInt(15200), Trades: 1}},
+				}, nil).Once()
+			},
+			status: http.StatusOK,
+			want:   `{"ticker":"PETR4","buyers":[{"broker":"3","quantity":500,"financial_volume":"19000","trades":2}],"sellers":[{"broker":"72","quantity":400,"financial_volume":"15200","trades":1}]}`,
+		},
+		{
+			name:     "failed because error missing ticker in top brokers",
+			path:     "/brokers/top",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Missing ticker\n",
+		},
+		{
+			name: "failed because error in top brokers",
+			path: "/brokers/top?ticker=PETR4",
+			mockFunc: func(m *mockService) {
+				m.On("TopBrokers", mock.Anything, trade.BrokerFilter{Ticker: "PETR4"}).
+					Return((*trade.BrokerRanking)(nil), errors.New("mock-error")).Once()
+			},
+			status: http.StatusInternalServerError,
+			want:   "Failed to get top brokers\n",
+		},
+		{
+			name: "success broker flows",
+			path: "/brokers/flow?broker=3",
+			mockFunc: func(m *mockService) {
+				m.On("BrokerFlows", mock.Anything, trade.BrokerFilter{Broker: "3"}).
+					Return([]*trade.BrokerFlow{
+						{
+							Broker:             "3",
+							TradeDate:          time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC),
+							BoughtQuantity:     500,
+							SoldQuantity:       200,
+							NetQuantity:        300,
+							NetFinancialVolume: decimal.NewFromInt(11400),
+						},
+					}, nil).Once()
+			},
+			status: http.StatusOK,
+			want:   `[{"broker":"3","trade_date":"2024-06-28T00:00:00Z","bought_quantity":500,"sold_quantity":200,"net_quantity":300,"net_financial_volume":"11400"}]`,
+		},
+		{
+			name:     "failed because error parse start in broker flows",
+			path:     "/brokers/flow?start=2024-06-2J",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Failed to parse start\n",
+		},
+		{
+			name: "failed because error in broker flows",
+			path: "/brokers/flow",
+			mockFunc: func(m *mockService) {
+				m.On("BrokerFlows", mock.Anything, trade.BrokerFilter{}).
+					Return(([]*trade.BrokerFlow)(nil), errors.New("mock-error")).Once()
+			},
+			status: http.StatusInternalServerError,
+			want:   "Failed to get broker flows\n",
+		},
+		{
+			name: "success broker matrix",
+			path: "/brokers/matrix?end=2024-06-28",
+			mockFunc: func(m *mockService) {
+				m.On("BrokerMatrix", mock.Anything, trade.BrokerFilter{End: time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC)}).
+					Return(&trade.BrokerMatrix{
+						Tickers: []string{"PETR4", "VALE3"},
+						Rows:    []*trade.BrokerMatrixRow{{Broker: "3", NetQuantity: []int{300, -100}}},
+					}, nil).Once()
+			},
+			status: http.StatusOK,
+			want:   `{"tickers":["PETR4","VALE3"],"rows":[{"broker":"3","net_quantity":[300,-100]}]}`,
+		},
+		{
+			name:     "failed because error invalid instrument type in broker matrix",
+			path:     "/brokers/matrix?type=bond",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Invalid type\n",
+		},
+		{
+			name:     "failed because error parse limit in broker matrix",
+			path:     "/brokers/matrix?limit=a",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Failed to parse limit\n",
+		},
+		{
+			name: "failed because error in broker matrix",
+			path: "/brokers/matrix",
+			mockFunc: func(m *mockService) {
+				m.On("BrokerMatrix", mock.Anything, trade.BrokerFilter{}).
+					Return((*trade.BrokerMatrix)(nil), errors.New("mock-error")).Once()
+			},
+			status: http.StatusInternalServerError,
+			want:   "Failed to get broker matrix\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := new(mockService)
+			tc.mockFunc(m)
+
+			s := NewQuotation(m)
+
+			req, err := http.NewRequest("GET", tc.path, nil)
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+
+			rr := httptest.NewRecorder()
+
+			r := chi.NewRouter()
+			r.Get("/brokers/top", s.GetTopBrokers)
+			r.Get("/brokers/flow", s.GetBrokerFlows)
+			r.Get("/brokers/matrix", s.GetBrokerMatrix)
+
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.status, rr.Code)
+			assert.Equal(t, tc.want, rr.Body.String())
+			m.AssertExpectations(t)
+		})
+	}
+}
+
+func TestBatchUpload(t *testing.T) {
+	cases := []struct {
+		name     string
+		req      string
+		mockFunc func(m *mockService)
+		status   int
+		want     string
+	}{
+		{
+			name: "success",
+			req:  "header\nGOOG,29,11,2024-06-20\n",
+			mockFunc: func(m *mockService) {
+				m.On("CreateUpload", mock.Anything, &trade.Upload{FileName: "example.csv", Uploader: "ops"}, mock.Anything).
+					Return(&trade.Upload{ID: 7, FileName: "example.csv", Uploader: "ops", Status: trade.UploadProcessing}, nil)
+				m.On("BatchInsert", mock.Anything, mock.Anything, mock.Anything).
+					Return(nil)
+			},
+			status: http.StatusOK,
+			want:   `{"message":"file uploaded successfully","upload_id":7}`,
+		},
+		{
+			name: "failed because file already uploaded",
+			req:  "header\nGOOG,29,11,2024-06-20\n",
+			mockFunc: func(m *mockService) {
+				m.On("CreateUpload", mock.Anything, mock.Anything, mock.Anything).
+					Return(&trade.Upload{ID: 3, FileName: "example.csv", Status: trade.UploadCompleted}, trade.ErrDuplicateFile)
+			},
+			status: http.StatusConflict,
+			want:   `{"message":"file already uploaded","upload_id":3}`,
+		},
+		{
+			name: "failed because file already being uploaded",
+			req:  "header\nGOOG,29,11,2024-06-20\n",
+			mockFunc: func(m *mockService) {
+				m.On("CreateUpload", mock.Anything, mock.Anything, mock.Anything).
+					Return((*trade.Upload)(nil), trade.ErrDuplicateFile)
+			},
+			status: http.StatusConflict,
+			want:   "File already uploaded\n",
+		},
+		{
+			name: "failed to create upload",
+			req:  "header\nGOOG,29,11,2024-06-20\n",
+			mockFunc: func(m *mockService) {
+				m.On("CreateUpload", mock.Anything, mock.Anything, mock.Anything).
+					Return((*trade.Upload)(nil), errors.New("mock-error"))
+			},
+			status: http.StatusInternalServerError,
+			want:   "Failed to create upload\n",
+		},
+		{
+			name:     "missing file",
+			req:      "",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Failed to get file\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := new(mockService)
+			if tc.mockFunc != nil {
+				tc.mockFunc(m)
+			}
+
+			q := &Quotation{service: m}
+
+			body := &bytes.Buffer{}
+			header := http.Header{}
+
+			if tc.req != "" {
+				writer := multipart.NewWriter(body)
+				part, err := writer.CreateFormFile("Quotation", "example.csv")
+				if err != nil {
+					t.Fatalf("failed to create form file: %v", err)
+				}
+				_, err = part.Write([]byte(tc.req))
+				if err != nil {
+					t.Fatalf("failed to write to form file: %v", err)
+				}
+				err = writer.WriteField("uploader", "ops")
+				if err != nil {
+					t.Fatalf("failed to write uploader field: %v", err)
+				}
+				err = writer.Close()
+				if err != nil {
+					t.Fatalf("failed to close multipart writer: %v", err)
+				}
+
+				header.Set("Content-Type", writer.FormDataContentType())
+			}
+
+			req, err := http.NewRequest("POST", "/upload", body)
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+			req.Header = header
+
+			rr := httptest.NewRecorder()
+
+			r := chi.NewRouter()
+			r.Post("/upload", q.BatchUpload)
+
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.status, rr.Code)
+			assert.Equal(t, tc.want, rr.Body.String())
+		})
+	}
+}
+
+func TestGetOptionChain(t *testing.T) {
+	strike := decimal.NewFromInt(35)
+
+	cases := []struct {
+		name     string
+		path     string
+		mockFunc func(m *mockService)
+		status   int
+		want     string
+	}{
+		{
+			name: "success",
+			path: "/options/PETR4?date=2024-06-28",
+			mockFunc: func(m *mockService) {
+				m.On("OptionChain", mock.Anything, trade.OptionFilter{
+					Underlying: "PETR4",
+					Date:       time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC),
+				}).Return([]*trade.OptionSeries{
+					{
+						Ticker:         "PETRG350",
+						Underlying:     "PETR4",
+						Right:          instrument.RightCall,
+						ExpiryMonth:    time.July,
+						Series:         "350",
+						Strike:         &strike,
+						MaxRangeValue:  decimal.NewFromFloat(0.45),
+						MaxDailyVolume: 12000,
+					},
+				}, nil).Once()
+			},
+			status: http.StatusOK,
+			want:   `[{"ticker":"PETRG350","underlying":"PETR4","right":"call","expiry_month":7,"series":"350","strike":"35","max_range_value":"0.45","max_daily_volume":12000}]`,
+		},
+		{
+			name: "failed because service error",
+			path: "/options/PETR4?date=2024-06-28",
+			mockFunc: func(m *mockService) {
+				m.On("OptionChain", mock.Anything, mock.Anything).
+					Return(([]*trade.OptionSeries)(nil), errors.New("mock-error")).Once()
+			},
+			status: http.StatusInternalServerError,
+			want:   "Failed to get option chain\n",
+		},
+		{
+			name: "failed because not a trading day",
+			path: "/options/PETR4?date=2024-06-29",
+			mockFunc: func(m *mockService) {
+				m.On("OptionChain", mock.Anything, mock.Anything).
+					Return(([]*trade.OptionSeries)(nil), calendar.ErrNotTradingDay).Once()
+			},
+			status: http.StatusBadRequest,
+			want:   "Not a trading day\n",
+		},
+		{
+			name:     "failed because error missing date",
+			path:     "/options/PETR4",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Missing date\n",
+		},
+		{
+			name:     "failed because error parse date",
+			path:     "/options/PETR4?date=2024-06-2J",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Failed to parse date\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := new(mockService)
+			tc.mockFunc(m)
+
+			s := NewQuotation(m)
+
+			req, err := http.NewRequest("GET", tc.path, nil)
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+
+			rr := httptest.NewRecorder()
+
+			r := chi.NewRouter()
+			r.Get("/options/{underlying}", s.GetOptionChain)
+
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.status, rr.Code)
+			assert.Equal(t, tc.want, rr.Body.String())
+			m.AssertExpectations(t)
+		})
+	}
+}
+
+func TestImportInstruments(t *testing.T) {
+	cases := []struct {
+		name     string
+		req      string
+		mockFunc func(m *mockService)
+		status   int
+		want     string
+	}{
+		{
+			name: "success",
+			req:  "ticker;underlying;strike\nPETRG350;PETR4;35,00\n",
+			mockFunc: func(m *mockService) {
+				m.On("ImportInstruments", mock.Anything, mock.Anything).Return(1, nil).Once()
+			},
+			status: http.StatusOK,
+			want:   `{"imported":1}`,
+		},
+		{
+			name: "failed because invalid file",
+			req:  "ticker;underlying;strike\nPETRG350;PETR4;abc\n",
+			mockFunc: func(m *mockService) {
+				m.On("ImportInstruments", mock.Anything, mock.Anything).
+					Return(0, fmt.Errorf("%w: line 2: failed to parse strike", trade.ErrInvalidInstrumentFile)).Once()
+			},
+			status: http.StatusBadRequest,
+			want:   "Failed to parse instruments file\n",
+		},
+		{
+			name: "failed because service error",
+			req:  "ticker;underlying;strike\nPETRG350;PETR4;35,00\n",
+			mockFunc: func(m *mockService) {
+				m.On("ImportInstruments", mock.Anything, mock.Anything).Return(0, errors.New("mock-error")).Once()
+			},
+			status: http.StatusInternalServerError,
+			want:   "Failed to import instruments\n",
+		},
+		{
+			name:     "missing file",
+			req:      "",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Failed to get file\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := new(mockService)
+			tc.mockFunc(m)
+
+			q := &Quotation{service: m}
+
+			body := &bytes.Buffer{}
+			header := http.Header{}
+
+			if tc.req != "" {
+				writer := multipart.NewWriter(body)
+				part, err := writer.CreateFormFile("Instruments", "instruments.csv")
+				if err != nil {
+					t.Fatalf("failed to create form file: %v", err)
+				}
+				_, err = part.Write([]byte(tc.req))
+				if err != nil {
+					t.Fatalf("failed to write to form file: %v", err)
+				}
+				err = writer.Close()
+				if err != nil {
+					t.Fatalf("failed to close multipart writer: %v", err)
+				}
+
+				header.Set("Content-Type", writer.FormDataContentType())
+			}
+
+			req, err := http.NewRequest("POST", "/instruments/import", body)
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+			req.Header = header
+
+			rr := httptest.NewRecorder()
+
+			r := chi.NewRouter()
+			r.Post("/instruments/import", q.ImportInstruments)
+
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.status, rr.Code)
+			assert.Equal(t, tc.want, rr.Body.String())
+			m.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetContinuousFuture(t *testing.T) {
+	cases := []struct {
+		name     string
+		path     string
+		mockFunc func(m *mockService)
+		status   int
+		want     string
+	}{
+		{
+			name: "success with defaults",
+			path: "/futures/WIN/continuous",
+			mockFunc: func(m *mockService) {
+				m.On("ContinuousFuture", mock.Anything, trade.ContinuousFilter{
+					Root:     "WIN",
+					Roll:     trade.RollVolume,
+					RollDays: trade.DefaultRollDays,
+					Adjust:   trade.AdjustNone,
+				}).Return([]*trade.ContinuousPoint{
+					{
+						TradeDate:     time.Date(2024, 8, 8, 0, 0, 0, 0, time.UTC),
+						Ticker:        "WINV24",
+						ClosePrice:    decimal.NewFromInt(131700),
+						AdjustedPrice: decimal.NewFromInt(131700),
+						Volume:        900,
+						Rolled:        true,
+					},
+				}, nil).Once()
+			},
+			status: http.StatusOK,
+			want:   `[{"trade_date":"2024-08-08T00:00:00Z","ticker":"WINV24","close_price":"131700","adjusted_price":"131700","volume":900,"rolled":true}]`,
+		},
+		{
+			name: "success with expiry rollover and ratio adjustment",
+			path: "/futures/DOL/continuous?start=2024-08-01&end=2024-08-31&roll=expiry&days=2&adjust=ratio",
+			mockFunc: func(m *mockService) {
+				m.On("ContinuousFuture", mock.Anything, trade.ContinuousFilter{
+					Root:     "DOL",
+					Start:    time.Date(2024, 8, 1, 0, 0, 0, 0, time.UTC),
+					End:      time.Date(2024, 8, 31, 0, 0, 0, 0, time.UTC),
+					Roll:     trade.RollExpiry,
+					RollDays: 2,
+					Adjust:   trade.AdjustRatio,
+				}).Return([]*trade.ContinuousPoint{}, nil).Once()
+			},
+			status: http.StatusOK,
+			want:   `[]`,
+		},
+		{
+			name: "failed because service error",
+			path: "/futures/WIN/continuous",
+			mockFunc: func(m *mockService) {
+				m.On("ContinuousFuture", mock.Anything, mock.Anything).
+					Return(([]*trade.ContinuousPoint)(nil), errors.New("mock-error")).Once()
+			},
+			status: http.StatusInternalServerError,
+			want:   "Failed to get continuous series\n",
+		},
+		{
+			name:     "failed because error parse start",
+			path:     "/futures/WIN/continuous?start=2024-08-0J",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Failed to parse start\n",
+		},
+		{
+			name:     "failed because error parse end",
+			path:     "/futures/WIN/continuous?end=2024-08-0J",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Failed to parse end\n",
+		},
+		{
+			name:     "failed because error invalid roll",
+			path:     "/futures/WIN/continuous?roll=open_interest",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Invalid roll\n",
+		},
+		{
+			name:     "failed because error parse days",
+			path:     "/futures/WIN/continuous?roll=expiry&days=-1",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Failed to parse days\n",
+		},
+		{
+			name:     "failed because error invalid adjust",
+			path:     "/futures/WIN/continuous?adjust=log",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Invalid adjust\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := new(mockService)
+			tc.mockFunc(m)
+
+			s := NewQuotation(m)
+
+			req, err := http.NewRequest("GET", tc.path, nil)
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+
+			rr := httptest.NewRecorder()
+
+			r := chi.NewRouter()
+			r.Get("/futures/{root}/continuous", s.GetContinuousFuture)
+
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.status, rr.Code)
+			assert.Equal(t, tc.want, rr.Body.String())
+			m.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetMetricHistory(t *testing.T) {
+	cases := []struct {
+		name     string
+		query    string
+		mockFunc func(m *mockService)
+		status   int
+		want     string
+	}{
+		{
+			name:  "success adjusted with start and end",
+			query: "ticker=PETR4&start=2024-05-01&end=2024-06-30&adjusted=true",
+			mockFunc: func(m *mockService) {
+				m.On("MetricHistory", mock.Anything, trade.MetricFilter{
+					Ticker:   "PETR4",
+					Date:     time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+					End:      time.Date(2024, 6, 30, 0, 0, 0, 0, time.UTC),
+					Session:  trade.SessionRegular,
+					Adjusted: true,
+				}).Return([]*trade.DailyMetric{
+					{
+						Ticker:         "PETR4",
+						TradeDate:      time.Date(2024, 5, 31, 0, 0, 0, 0, time.UTC),
+						MaxRangeValue:  decimal.NewFromInt(40),
+						ClosePrice:     decimal.NewFromInt(39),
+						MaxDailyVolume: 2000,
+					},
+				}, nil).Once()
+			},
+			status: http.StatusOK,
+			want:   `[{"ticker":"PETR4","trade_date":"2024-05-31T00:00:00Z","max_range_value":"40","close_price":"39","max_daily_volume":2000}]`,
+		},
+		{
+			name:  "failed because service error",
+			query: "ticker=PETR4",
+			mockFunc: func(m *mockService) {
+				m.On("MetricHistory", mock.Anything, mock.Anything).
+					Return(([]*trade.DailyMetric)(nil), errors.New("mock-error")).Once()
+			},
+			status: http.StatusInternalServerError,
+			want:   "Failed to get metric history\n",
+		},
+		{
+			name:     "failed because error missing ticker",
+			query:    "start=2024-05-01",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Missing ticker\n",
+		},
+		{
+			name:     "failed because error parse start",
+			query:    "ticker=PETR4&start=2024-05-0J",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Failed to parse start\n",
+		},
+		{
+			name:     "failed because error parse end",
+			query:    "ticker=PETR4&end=2024-05-0J",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Failed to parse end\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := new(mockService)
+			tc.mockFunc(m)
+
+			s := NewQuotation(m)
+
+			req, err := http.NewRequest("GET", "/metrics/history?"+tc.query, nil)
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+
+			rr := httptest.NewRecorder()
+
+			r := chi.NewRouter()
+			r.Get("/metrics/history", s.GetMetricHistory)
+
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.status, rr.Code)
+			assert.Equal(t, tc.want, rr.Body.String())
+			m.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetCorporateActions(t *testing.T) {
+	cases := []struct {
+		name     string
+		query    string
+		mockFunc func(m *mockService)
+		status   int
+		want     string
+	}{
+		{
+			name:  "success",
+			query: "ticker=PETR4",
+			mockFunc: func(m *mockService) {
+				m.On("CorporateActions", mock.Anything, "PETR4").Return([]*trade.CorporateAction{
+					{ID: 1, Ticker: "PETR4", ExDate: time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC), Type: trade.ActionSplit, Factor: decimal.NewFromInt(2)},
+				}, nil).Once()
+			},
+			status: http.StatusOK,
+			want:   `[{"id":1,"ticker":"PETR4","ex_date":"2024-06-03T00:00:00Z","type":"split","factor":"2"}]`,
+		},
+		{
+			name:  "failed because service error",
+			query: "ticker=PETR4",
+			mockFunc: func(m *mockService) {
+				m.On("CorporateActions", mock.Anything, "PETR4").
+					Return(([]*trade.CorporateAction)(nil), errors.New("mock-error")).Once()
+			},
+			status: http.StatusInternalServerError,
+			want:   "Failed to get corporate actions\n",
+		},
+		{
+			name:     "failed because error missing ticker",
+			query:    "",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Missing ticker\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := new(mockService)
+			tc.mockFunc(m)
+
+			s := NewQuotation(m)
+
+			req, err := http.NewRequest("GET", "/corporate-actions?"+tc.query, nil)
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+
+			rr := httptest.NewRecorder()
+
+			r := chi.NewRouter()
+			r.Get("/corporate-actions", s.GetCorporateActions)
+
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.status, rr.Code)
+			assert.Equal(t, tc.want, rr.Body.String())
+			m.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCreateCorporateActions(t *testing.T) {
+	cases := []struct {
+		name     string
+		body     string
+		mockFunc func(m *mockService)
+		status   int
+		want     string
+	}{
+		{
+			name: "success",
+			body: `[{"ticker":"PETR4","ex_date":"2024-06-03","type":"split","factor":2}]`,
+			mockFunc: func(m *mockService) {
+				m.On("SaveCorporateActions", mock.Anything, []*trade.CorporateAction{
+					{Ticker: "PETR4", ExDate: time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC), Type: trade.ActionSplit, Factor: decimal.NewFromInt(2)},
+				}).Return(nil).Once()
+			},
+			status: http.StatusOK,
+			want:   `{"imported":1}`,
+		},
+		{
+			name: "failed because invalid corporate action",
+			body: `[{"ticker":"PETR4","ex_date":"2024-06-03","type":"merger","factor":2}]`,
+			mockFunc: func(m *mockService) {
+				m.On("SaveCorporateActions", mock.Anything, mock.Anything).
+					Return(fmt.Errorf("%w: unknown type", trade.ErrInvalidCorporateAction)).Once()
+			},
+			status: http.StatusBadRequest,
+			want:   "Invalid corporate action\n",
+		},
+		{
+			name: "failed because service error",
+			body: `[{"ticker":"PETR4","ex_date":"2024-06-03","type":"split","factor":2}]`,
+			mockFunc: func(m *mockService) {
+				m.On("SaveCorporateActions", mock.Anything, mock.Anything).Return(errors.New("mock-error")).Once()
+			},
+			status: http.StatusInternalServerError,
+			want:   "Failed to save corporate actions\n",
+		},
+		{
+			name:     "failed because error parse ex date",
+			body:     `[{"ticker":"PETR4","ex_date":"03/06/2024","type":"split","factor":2}]`,
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Failed to parse ex_date\n",
+		},
+		{
+			name:     "failed because error decode body",
+			body:     `{"ticker":`,
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Failed to decode body\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := new(mockService)
+			tc.mockFunc(m)
+
+			s := NewQuotation(m)
+
+			req, err := http.NewRequest("POST", "/corporate-actions", bytes.NewBufferString(tc.body))
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+
+			rr := httptest.NewRecorder()
+
+			r := chi.NewRouter()
+			r.Post("/corporate-actions", s.CreateCorporateActions)
+
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.status, rr.Code)
+			assert.Equal(t, tc.want, rr.Body.String())
+			m.AssertExpectations(t)
+		})
+	}
+}
+
+func TestImportCorporateActions(t *testing.T) {
+	cases := []struct {
+		name     string
+		req      string
+		mockFunc func(m *mockService)
+		status   int
+		want     string
+	}{
+		{
+			name: "success",
+			req:  "ticker;ex_date;type;factor\nPETR4;2024-06-03;split;2\n",
+			mockFunc: func(m *mockService) {
+				m.On("ImportCorporateActions", mock.Anything, mock.Anything).Return(1, nil).Once()
+			},
+			status: http.StatusOK,
+			want:   `{"imported":1}`,
+		},
+		{
+			name: "failed because invalid file",
+			req:  "ticker;ex_date;type;factor\nPETR4;2024-06-03;split;abc\n",
+			mockFunc: func(m *mockService) {
+				m.On("ImportCorporateActions", mock.Anything, mock.Anything).
+					Return(0, fmt.Errorf("%w: line 2: failed to parse factor", trade.ErrInvalidCorporateAction)).Once()
+			},
+			status: http.StatusBadRequest,
+			want:   "Failed to parse corporate actions file\n",
+		},
+		{
+			name: "failed because service error",
+			req:  "ticker;ex_date;type;factor\nPETR4;2024-06-03;split;2\n",
+			mockFunc: func(m *mockService) {
+				m.On("ImportCorporateActions", mock.Anything, mock.Anything).Return(0, errors.New("mock-error")).Once()
+			},
+			status: http.StatusInternalServerError,
+			want:   "Failed to import corporate actions\n",
+		},
+		{
+			name:     "missing file",
+			req:      "",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Failed to get file\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := new(mockService)
+			tc.mockFunc(m)
+
+			q := &Quotation{service: m}
+
+			body := &bytes.Buffer{}
+			header := http.Header{}
+
+			if tc.req != "" {
+				writer := multipart.NewWriter(body)
+				part, err := writer.CreateFormFile("CorporateActions", "corporate_actions.csv")
+				if err != nil {
+					t.Fatalf("failed to create form file: %v", err)
+				}
+				_, err = part.Write([]byte(tc.req))
+				if err != nil {
+					t.Fatalf("failed to write to form file: %v", err)
+				}
+				err = writer.Close()
+				if err != nil {
+					t.Fatalf("failed to close multipart writer: %v", err)
+				}
+
+				header.Set("Content-Type", writer.FormDataContentType())
+			}
+
+			req, err := http.NewRequest("POST", "/corporate-actions/import", body)
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+			req.Header = header
+
+			rr := httptest.NewRecorder()
+
+			r := chi.NewRouter()
+			r.Post("/corporate-actions/import", q.ImportCorporateActions)
+
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.status, rr.Code)
+			assert.Equal(t, tc.want, rr.Body.String())
+			m.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetTradingDay(t *testing.T) {
+	cases := []struct {
+		name     string
+		path     string
+		mockFunc func(m *mockService)
+		status   int
+		want     string
+	}{
+		{
+			name: "success",
+			path: "/calendar/2024-11-20",
+			mockFunc: func(m *mockService) {
+				m.On("TradingDay", time.Date(2024, 11, 20, 0, 0, 0, 0, time.UTC)).Return(&trade.TradingDay{
+					Date:       time.Date(2024, 11, 20, 0, 0, 0, 0, time.UTC),
+					TradingDay: false,
+					Previous:   time.Date(2024, 11, 19, 0, 0, 0, 0, time.UTC),
+					Next:       time.Date(2024, 11, 21, 0, 0, 0, 0, time.UTC),
+				}).Once()
+			},
+			status: http.StatusOK,
+			want:   `{"date":"2024-11-20T00:00:00Z","trading_day":false,"previous":"2024-11-19T00:00:00Z","next":"2024-11-21T00:00:00Z"}`,
+		},
+		{
+			name:     "failed because error parse date",
+			path:     "/calendar/2024-11-2J",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Failed to parse date\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := new(mockService)
+			tc.mockFunc(m)
+
+			q := NewQuotation(m)
+
+			req, err := http.NewRequest("GET", tc.path, nil)
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+
+			rr := httptest.NewRecorder()
+
+			r := chi.NewRouter()
+			r.Get("/calendar/{date}", q.GetTradingDay)
+
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.status, rr.Code)
+			assert.Equal(t, tc.want, rr.Body.String())
+			m.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetTradingDays(t *testing.T) {
+	cases := []struct {
+		name     string
+		query    string
+		mockFunc func(m *mockService)
+		status   int
+		want     string
+	}{
+		{
+			name:  "success",
+			query: "start=2024-11-19&end=2024-11-21",
+			mockFunc: func(m *mockService) {
+				start := time.Date(2024, 11, 19, 0, 0, 0, 0, time.UTC)
+				end := time.Date(2024, 11, 21, 0, 0, 0, 0, time.UTC)
+				m.On("TradingDays", start, end).Return(&trade.TradingDays{
+					Start: start,
+					End:   end,
+					Count: 2,
+					Days:  []time.Time{start, end},
+				}).Once()
+			},
+			status: http.StatusOK,
+			want:   `{"start":"2024-11-19T00:00:00Z","end":"2024-11-21T00:00:00Z","count":2,"days":["2024-11-19T00:00:00Z","2024-11-21T00:00:00Z"]}`,
+		},
+		{
+			name:     "failed because error parse start",
+			query:    "start=2024-11-1J&end=2024-11-21",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Failed to parse start\n",
+		},
+		{
+			name:     "failed because error parse end",
+			query:    "start=2024-11-19",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Failed to parse end\n",
+		},
+		{
+			name:     "failed because end before start",
+			query:    "start=2024-11-21&end=2024-11-19",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "End before start\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := new(mockService)
+			tc.mockFunc(m)
+
+			q := NewQuotation(m)
+
+			req, err := http.NewRequest("GET", "/calendar/trading-days?"+tc.query, nil)
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+
+			rr := httptest.NewRecorder()
+
+			r := chi.NewRouter()
+			r.Get("/calendar/trading-days", q.GetTradingDays)
+
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.status, rr.Code)
+			assert.Equal(t, tc.want, rr.Body.String())
+			m.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetActivity(t *testing.T) {
+	cases := []struct {
+		name     string
+		path     string
+		mockFunc func(m *mockService)
+		status   int
+		want     string
+	}{
+		{
+			name: "success",
+			path: "/activity/PETR4?start=2024-06-03&end=2024-06-28&bucket=1h",
+			mockFunc: func(m *mockService) {
+				m.On("Activity", mock.Anything, trade.ActivityFilter{
+					Ticker:        "PETR4",
+					Start:         time.Date(2024, 06, 3, 0, 0, 0, 0, time.UTC),
+					End:           time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC),
+					BucketMinutes: 60,
+				}).Return(&trade.Activity{
+					Ticker:        "PETR4",
+					BucketMinutes: 60,
+					Days:          2,
+					Buckets: []*trade.ActivityBucket{
+						{Start: "10:00", AverageVolume: decimal.NewFromInt(15000), AverageTrades: decimal.NewFromFloat(60.5)},
+					},
+				}, nil).Once()
+			},
+			status: http.StatusOK,
+			want:   `{"ticker":"PETR4","bucket_minutes":60,"days":2,"buckets":[{"start":"10:00","average_volume":"15000","average_trades":"60.5"}]}`,
+		},
+		{
+			name: "failed because service error",
+			path: "/activity/PETR4",
+			mockFunc: func(m *mockService) {
+				m.On("Activity", mock.Anything, trade.ActivityFilter{Ticker: "PETR4"}).
+					Return((*trade.Activity)(nil), errors.New("mock-error")).Once()
+			},
+			status: http.StatusInternalServerError,
+			want:   "Failed to get activity\n",
+		},
+		{
+			name:     "failed because error parse start",
+			path:     "/activity/PETR4?start=2024-06-0J",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Failed to parse start\n",
+		},
+		{
+			name:     "failed because error parse end",
+			path:     "/activity/PETR4?end=2024-06-2J",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Failed to parse end\n",
+		},
+		{
+			name:     "failed because error parse bucket",
+			path:     "/activity/PETR4?bucket=15",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Failed to parse bucket\n",
+		},
+		{
+			name:     "failed because bucket is not whole minutes",
+			path:     "/activity/PETR4?bucket=90s",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Failed to parse bucket\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := new(mockService)
+			tc.mockFunc(m)
+
+			q := NewQuotation(m)
+
+			req, err := http.NewRequest("GET", tc.path, nil)
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+
+			rr := httptest.NewRecorder()
+
+			r := chi.NewRouter()
+			r.Get("/activity/{ticker}", q.GetActivity)
+
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.status, rr.Code)
+			assert.Equal(t, tc.want, rr.Body.String())
+			m.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetMarketBreadth(t *testing.T) {
+	start := time.Date(2024, 06, 27, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name     string
+		query    string
+		mockFunc func(m *mockService)
+		status   int
+		want     string
+	}{
+		{
+			name:  "success",
+			query: "start=2024-06-27&end=2024-06-28&days=50",
+			mockFunc: func(m *mockService) {
+				m.On("MarketBreadth", mock.Anything, trade.BreadthFilter{Start: start, End: end, Days: 50}).
+					Return([]*trade.MarketBreadth{
+						{
+							TradeDate:       end,
+							Advancing:       210,
+							Declining:       150,
+							Unchanged:       12,
+							NewHighs:        8,
+							NewLows:         3,
+							FinancialVolume: decimal.NewFromFloat(25000000.5),
+						},
+					}, nil).Once()
+			},
+			status: http.StatusOK,
+			want:   `[{"trade_date":"2024-06-28T00:00:00Z","advancing":210,"declining":150,"unchanged":12,"new_highs":8,"new_lows":3,"financial_volume":"25000000.5"}]`,
+		},
+		{
+			name:  "failed because service error",
+			query: "start=2024-06-27&end=2024-06-28",
+			mockFunc: func(m *mockService) {
+				m.On("MarketBreadth", mock.Anything, trade.BreadthFilter{Start: start, End: end}).
+					Return(([]*trade.MarketBreadth)(nil), errors.New("mock-error")).Once()
+			},
+			status: http.StatusInternalServerError,
+			want:   "Failed to get market breadth\n",
+		},
+		{
+			name:     "failed because error parse start",
+			query:    "end=2024-06-28",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Failed to parse start\n",
+		},
+		{
+			name:     "failed because error parse end",
+			query:    "start=2024-06-27&end=2024-06-2J",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Failed to parse end\n",
+		},
+		{
+			name:     "failed because end before start",
+			query:    "start=2024-06-28&end=2024-06-27",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "End before start\n",
+		},
+		{
+			name:     "failed because error parse days",
+			query:    "start=2024-06-27&end=2024-06-28&days=many",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Failed to parse days\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := new(mockService)
+			tc.mockFunc(m)
+
+			q := NewQuotation(m)
+
+			req, err := http.NewRequest("GET", "/market/breadth?"+tc.query, nil)
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+
+			rr := httptest.NewRecorder()
+
+			r := chi.NewRouter()
+			r.Get("/market/breadth", q.GetMarketBreadth)
+
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.status, rr.Code)
+			assert.Equal(t, tc.want, rr.Body.String())
+			m.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetLiquidity(t *testing.T) {
+	start := time.Date(2024, 06, 03, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name     string
+		query    string
+		mockFunc func(m *mockService)
+		status   int
+		want     string
+	}{
+		{
+			name:  "success",
+			query: "start=2024-06-03&end=2024-06-28",
+			mockFunc: func(m *mockService) {
+				m.On("Liquidity", mock.Anything, trade.LiquidityFilter{Ticker: "petr4", Start: start, End: end}).
+					Return(&trade.Liquidity{
+						Ticker:                 "PETR4",
+						Start:                  start,
+						End:                    end,
+						TradingDays:            19,
+						ZeroVolumeDays:         1,
+						AverageFinancialVolume: decimal.NewFromFloat(1500000.25),
+						AmihudIlliquidity:      decimal.NewFromFloat(0.0123),
+						SerialCovariance:       decimal.NewFromFloat(-0.0001),
+						RollSpread:             decimal.NewFromFloat(0.02),
+					}, nil).Once()
+			},
+			status: http.StatusOK,
+			want:   `{"ticker":"PETR4","start":"2024-06-03T00:00:00Z","end":"2024-06-28T00:00:00Z","trading_days":19,"zero_volume_days":1,"average_financial_volume":"1500000.25","amihud_illiquidity":"0.0123","serial_covariance":"-0.0001","roll_spread":"0.02"}`,
+		},
+		{
+			name:  "failed because service error",
+			query: "start=2024-06-03&end=2024-06-28",
+			mockFunc: func(m *mockService) {
+				m.On("Liquidity", mock.Anything, trade.LiquidityFilter{Ticker: "petr4", Start: start, End: end}).
+					Return((*trade.Liquidity)(nil), errors.New("mock-error")).Once()
+			},
+			status: http.StatusInternalServerError,
+			want:   "Failed to get liquidity\n",
+		},
+		{
+			name:     "failed because error parse start",
+			query:    "start=2024-06&end=2024-06-28",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Failed to parse start\n",
+		},
+		{
+			name:     "failed because error parse end",
+			query:    "start=2024-06-03",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Failed to parse end\n",
+		},
+		{
+			name:     "failed because end before start",
+			query:    "start=2024-06-28&end=2024-06-03",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "End before start\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := new(mockService)
+			tc.mockFunc(m)
+
+			q := NewQuotation(m)
+
+			req, err := http.NewRequest("GET", "/liquidity/petr4?"+tc.query, nil)
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+
+			rr := httptest.NewRecorder()
+
+			r := chi.NewRouter()
+			r.Get("/liquidity/{ticker}", q.GetLiquidity)
+
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.status, rr.Code)
+			assert.Equal(t, tc.want, rr.Body.String())
+			m.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetOrderFlow(t *testing.T) {
+	date := time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name     string
+		query    string
+		mockFunc func(m *mockService)
+		status   int
+		want     string
+	}{
+		{
+			name:  "success with intraday buckets",
+			query: "start=2024-06-28&end=2024-06-28&intraday=true",
+			mockFunc: func(m *mockService) {
+				m.On("OrderFlow", mock.Anything, trade.OrderFlowFilter{Ticker: "petr4", Start: date, End: date, Intraday: true}).
+					Return([]*trade.DailyOrderFlow{
+						{
+							TradeDate:  date,
+							BuyVolume:  300,
+							SellVolume: 100,
+							BuyTrades:  3,
+							SellTrades: 1,
+							Imbalance:  decimal.NewFromFloat(0.5),
+							Buckets: []*trade.OrderFlowBucket{
+								{Start: "10:00", BuyVolume: 300, SellVolume: 100, Imbalance: decimal.NewFromFloat(0.5)},
+							},
+						},
+					}, nil).Once()
+			},
+			status: http.StatusOK,
+			want:   `[{"trade_date":"2024-06-28T00:00:00Z","buy_volume":300,"sell_volume":100,"unclassified_volume":0,"buy_trades":3,"sell_trades":1,"imbalance":"0.5","buckets":[{"start":"10:00","buy_volume":300,"sell_volume":100,"imbalance":"0.5"}]}]`,
+		},
+		{
+			name:  "failed because service error",
+			query: "",
+			mockFunc: func(m *mockService) {
+				m.On("OrderFlow", mock.Anything, trade.OrderFlowFilter{Ticker: "petr4"}).
+					Return(([]*trade.DailyOrderFlow)(nil), errors.New("mock-error")).Once()
+			},
+			status: http.StatusInternalServerError,
+			want:   "Failed to get order flow\n",
+		},
+		{
+			name:     "failed because error parse start",
+			query:    "start=2024-06-2J",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Failed to parse start\n",
+		},
+		{
+			name:     "failed because error parse end",
+			query:    "end=2024-06-2J",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Failed to parse end\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := new(mockService)
+			tc.mockFunc(m)
+
+			q := NewQuotation(m)
+
+			req, err := http.NewRequest("GET", "/order-flow/petr4?"+tc.query, nil)
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+
+			rr := httptest.NewRecorder()
+
+			r := chi.NewRouter()
+			r.Get("/order-flow/{ticker}", q.GetOrderFlow)
+
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.status, rr.Code)
+			assert.Equal(t, tc.want, rr.Body.String())
+			m.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetBenchmarks(t *testing.T) {
+	date := time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC)
+	clock := func(hour, minute int) time.Time {
+		return time.Date(0, 1, 1, hour, minute, 0, 0, time.UTC)
+	}
+	filter := trade.BenchmarkFilter{
+		Ticker: "petr4",
+		Date:   date,
+		Windows: []trade.BenchmarkWindow{
+			{From: clock(10, 0), To: clock(11, 30)},
+			{From: clock(14, 0), To: clock(15, 0)},
+		},
+	}
+
+	cases := []struct {
+		name     string
+		query    string
+		mockFunc func(m *mockService)
+		status   int
+		want     string
+	}{
+		{
+			name:  "success",
+			query: "date=2024-06-28&window=10:00-11:30&window=14:00-15:00",
+			mockFunc: func(m *mockService) {
+				m.On("Benchmarks", mock.Anything, filter).Return(&trade.Benchmarks{
+					Ticker: "PETR4",
+					Date:   date,
+					Windows: []*trade.IntervalBenchmark{
+						{From: "10:00:00", To: "11:30:00", Trades: 2, Volume: 300, VWAP: decimal.NewFromFloat(38.4), TWAP: decimal.NewFromFloat(38.45)},
+						{From: "14:00:00", To: "15:00:00", VWAP: decimal.Zero, TWAP: decimal.Zero},
+					},
+				}, nil).Once()
+			},
+			status: http.StatusOK,
+			want:   `{"ticker":"PETR4","date":"2024-06-28T00:00:00Z","windows":[{"from":"10:00:00","to":"11:30:00","trades":2,"volume":300,"vwap":"38.4","twap":"38.45"},{"from":"14:00:00","to":"15:00:00","trades":0,"volume":0,"vwap":"0","twap":"0"}]}`,
+		},
+		{
+			name:  "failed because not a trading day",
+			query: "date=2024-06-28&window=10:00-11:30&window=14:00-15:00",
+			mockFunc: func(m *mockService) {
+				m.On("Benchmarks", mock.Anything, filter).Return((*trade.Benchmarks)(nil), calendar.ErrNotTradingDay).Once()
+			},
+			status: http.StatusBadRequest,
+			want:   "Not a trading day\n",
+		},
+		{
+			name:  "failed because service error",
+			query: "date=2024-06-28&window=10:00-11:30&window=14:00-15:00",
+			mockFunc: func(m *mockService) {
+				m.On("Benchmarks", mock.Anything, filter).Return((*trade.Benchmarks)(nil), errors.New("mock-error")).Once()
+			},
+			status: http.StatusInternalServerError,
+			want:   "Failed to get benchmarks\n",
+		},
+		{
+			name:     "failed because missing date",
+			query:    "window=10:00-11:30",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Missing date\n",
+		},
+		{
+			name:     "failed because error parse date",
+			query:    "date=2024-06-2J&window=10:00-11:30",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Failed to parse date\n",
+		},
+		{
+			name:     "failed because missing window",
+			query:    "date=2024-06-28",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Missing window\n",
+		},
+		{
+			name:     "failed because too many windows",
+			query:    "date=2024-06-28" + strings.Repeat("&window=10:00-11:30", trade.MaxBenchmarkWindows+1),
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Too many windows, at most 20\n",
+		},
+		{
+			name:     "failed because window end before start",
+			query:    "date=2024-06-28&window=11:30-10:00",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Failed to parse window\n",
+		},
+		{
+			name:     "failed because window without end",
+			query:    "date=2024-06-28&window=10:00",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Failed to parse window\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := new(mockService)
+			tc.mockFunc(m)
+
+			q := NewQuotation(m)
+
+			req, err := http.NewRequest("GET", "/benchmarks/petr4?"+tc.query, nil)
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+
+			rr := httptest.NewRecorder()
+
+			r := chi.NewRouter()
+			r.Get("/benchmarks/{ticker}", q.GetBenchmarks)
+
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.status, rr.Code)
+			assert.Equal(t, tc.want, rr.Body.String())
+			m.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetAuctions(t *testing.T) {
+	date := time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name     string
+		query    string
+		mockFunc func(m *mockService)
+		status   int
+		want     string
+	}{
+		{
+			name:  "success",
+			query: "start=2024-06-28&end=2024-06-28&auction=closing",
+			mockFunc: func(m *mockService) {
+				m.On("Auctions", mock.Anything, trade.AuctionFilter{Ticker: "petr4", Start: date, End: date, Type: trade.AuctionClosing}).
+					Return([]*trade.Auction{
+						{
+							Ticker:    "PETR4",
+							TradeDate: date,
+							Type:      trade.AuctionClosing,
+							CloseTime: "170312345",
+							Price:     decimal.NewFromFloat(38.51),
+							Volume:    250000,
+							Trades:    412,
+						},
+					}, nil).Once()
+			},
+			status: http.StatusOK,
+			want:   `[{"ticker":"PETR4","trade_date":"2024-06-28T00:00:00Z","type":"closing","close_time":"170312345","price":"38.51","volume":250000,"trades":412}]`,
+		},
+		{
+			name:  "failed because service error",
+			query: "",
+			mockFunc: func(m *mockService) {
+				m.On("Auctions", mock.Anything, trade.AuctionFilter{Ticker: "petr4"}).
+					Return(([]*trade.Auction)(nil), errors.New("mock-error")).Once()
+			},
+			status: http.StatusInternalServerError,
+			want:   "Failed to get auctions\n",
+		},
+		{
+			name:     "failed because invalid auction",
+			query:    "auction=midday",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Invalid auction\n",
+		},
+		{
+			name:     "failed because error parse start",
+			query:    "start=2024-06-2J",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Failed to parse start\n",
+		},
+		{
+			name:     "failed because error parse end",
+			query:    "end=2024-06-2J",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Failed to parse end\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := new(mockService)
+			tc.mockFunc(m)
+
+			q := NewQuotation(m)
+
+			req, err := http.NewRequest("GET", "/auctions/petr4?"+tc.query, nil)
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+
+			rr := httptest.NewRecorder()
+
+			r := chi.NewRouter()
+			r.Get("/auctions/{ticker}", q.GetAuctions)
+
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.status, rr.Code)
+			assert.Equal(t, tc.want, rr.Body.String())
+			m.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetUpload(t *testing.T) {
+	createdAt := time.Date(2024, 06, 28, 18, 30, 0, 0, time.UTC)
+
+	cases := []struct {
+		name     string
+		id       string
+		mockFunc func(m *mockService)
+		status   int
+		want     string
+	}{
+		{
+			name: "success",
+			id:   "7",
+			mockFunc: func(m *mockService) {
+				m.On("Upload", mock.Anything, 7).
+					Return(&trade.Upload{
+						ID:        7,
+						FileName:  "28-06-2024_NEGOCIOSAVISTA.txt",
+						FileSize:  2048,
+						Checksum:  "abc123",
+						Uploader:  "ops",
+						Status:    trade.UploadProcessing,
+						CreatedAt: createdAt,
+					}, nil).Once()
+			},
+			status: http.StatusOK,
+			want:   `{"id":7,"file_name":"28-06-2024_NEGOCIOSAVISTA.txt","file_size":2048,"sha256":"abc123","uploader":"ops","status":"processing","trades":0,"created_at":"2024-06-28T18:30:00Z"}`,
+		},
+		{
+			name: "failed because upload not found",
+			id:   "8",
+			mockFunc: func(m *mockService) {
+				m.On("Upload", mock.Anything, 8).
+					Return((*trade.Upload)(nil), trade.ErrUploadNotFound).Once()
+			},
+			status: http.StatusNotFound,
+			want:   "Upload not found\n",
+		},
+		{
+			name: "failed because service error",
+			id:   "7",
+			mockFunc: func(m *mockService) {
+				m.On("Upload", mock.Anything, 7).
+					Return((*trade.Upload)(nil), errors.New("mock-error")).Once()
+			},
+			status: http.StatusInternalServerError,
+			want:   "Failed to get upload\n",
+		},
+		{
+			name:     "failed because error parse id",
+			id:       "x",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Failed to parse id\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := new(mockService)
+			tc.mockFunc(m)
+
+			q := NewQuotation(m)
+
+			req, err := http.NewRequest("GET", "/uploads/"+tc.id, nil)
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+
+			rr := httptest.NewRecorder()
+
+			r := chi.NewRouter()
+			r.Get("/uploads/{id}", q.GetUpload)
+
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.status, rr.Code)
+			assert.Equal(t, tc.want, rr.Body.String())
+			m.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetCompleteness(t *testing.T) {
+	date := time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name     string
+		path     string
+		mockFunc func(m *mockService)
+		status   int
+		want     string
+	}{
+		{
+			name: "success",
+			path: "/uploads/7/completeness?ticker=petr4&incomplete=true",
+			mockFunc: func(m *mockService) {
+				m.On("Completeness", mock.Anything, trade.SequenceFilter{UploadID: 7, Ticker: "petr4", Incomplete: true}).
+					Return(&trade.Completeness{
+						UploadID:   7,
+						Sequences:  1,
+						Incomplete: 1,
+						Missing:    2,
+						Details: []*trade.TradeSequence{
+							{
+								Ticker:       "PETR4",
+								TradeDate:    date,
+								Trades:       3,
+								FirstTradeID: 10,
+								LastTradeID:  50,
+								Step:         10,
+								Missing:      2,
+								Gaps:         1,
+							},
+						},
+					}, nil).Once()
+			},
+			status: http.StatusOK,
+			want:   `{"upload_id":7,"sequences":1,"incomplete":1,"missing":2,"duplicates":0,"out_of_order":0,"out_of_step":0,"details":[{"ticker":"PETR4","trade_date":"2024-06-28T00:00:00Z","trades":3,"first_trade_id":10,"last_trade_id":50,"step":10,"missing":2,"gaps":1,"duplicates":0,"out_of_order":0,"out_of_step":0,"complete":false}]}`,
+		},
+		{
+			name: "failed because upload not found",
+			path: "/uploads/8/completeness",
+			mockFunc: func(m *mockService) {
+				m.On("Completeness", mock.Anything, trade.SequenceFilter{UploadID: 8}).
+					Return((*trade.Completeness)(nil), trade.ErrUploadNotFound).Once()
+			},
+			status: http.StatusNotFound,
+			want:   "Upload not found\n",
+		},
+		{
+			name: "failed because service error",
+			path: "/uploads/7/completeness",
+			mockFunc: func(m *mockService) {
+				m.On("Completeness", mock.Anything, trade.SequenceFilter{UploadID: 7}).
+					Return((*trade.Completeness)(nil), errors.New("mock-error")).Once()
+			},
+			status: http.StatusInternalServerError,
+			want:   "Failed to get completeness\n",
+		},
+		{
+			name:     "failed because error parse id",
+			path:     "/uploads/x/completeness",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Failed to parse id\n",
+		},
+		{
+			name:     "failed because error parse incomplete",
+			path:     "/uploads/7/completeness?incomplete=maybe",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Failed to parse incomplete\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := new(mockService)
+			tc.mockFunc(m)
+
+			q := NewQuotation(m)
+
+			req, err := http.NewRequest("GET", tc.path, nil)
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+
+			rr := httptest.NewRecorder()
+
+			r := chi.NewRouter()
+			r.Get("/uploads/{id}/completeness", q.GetCompleteness)
+
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.status, rr.Code)
+			assert.Equal(t, tc.want, rr.Body.String())
+			m.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetQuality(t *testing.T) {
+	date := time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name     string
+		id       string
+		mockFunc func(m *mockService)
+		status   int
+		want     string
+	}{
+		{
+			name: "success",
+			id:   "7",
+			mockFunc: func(m *mockService) {
+				m.On("Quality", mock.Anything, 7).
+					Return(&trade.QualityReport{
+						UploadID: 7,
+						Status:   trade.UploadRejected,
+						Rows:     5,
+						Rejected: true,
+						Issues: []*trade.QualityIssue{
+							{
+								Issue:    trade.QualityPriceDeviation,
+								Rows:     1,
+								Ratio:    0.2,
+								Rejected: true,
+								Samples: []*trade.QualitySample{
+									{Line: 5, Ticker: "PETR4", TradeDate: date, CloseTime: "100000000", Price: decimal.NewFromInt(50), Quantity: 100, Reference: "38"},
+								},
+							},
+						},
+					}, nil).Once()
+			},
+			status: http.StatusOK,
+			want:   `{"upload_id":7,"status":"rejected","rows":5,"rejected":true,"issues":[{"issue":"price_deviation","rows":1,"ratio":0.2,"rejected":true,"samples":[{"line":5,"ticker":"PETR4","trade_date":"2024-06-28T00:00:00Z","close_time":"100000000","price":"50","quantity":100,"reference":"38"}]}]}`,
+		},
+		{
+			name: "failed because upload not found",
+			id:   "8",
+			mockFunc: func(m *mockService) {
+				m.On("Quality", mock.Anything, 8).
+					Return((*trade.QualityReport)(nil), trade.ErrUploadNotFound).Once()
+			},
+			status: http.StatusNotFound,
+			want:   "Upload not found\n",
+		},
+		{
+			name: "failed because service error",
+			id:   "7",
+			mockFunc: func(m *mockService) {
+				m.On("Quality", mock.Anything, 7).
+					Return((*trade.QualityReport)(nil), errors.New("mock-error")).Once()
+			},
+			status: http.StatusInternalServerError,
+			want:   "Failed to get quality report\n",
+		},
+		{
+			name:     "failed because error parse id",
+			id:       "x",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Failed to parse id\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := new(mockService)
+			tc.mockFunc(m)
+
+			q := NewQuotation(m)
+
+			req, err := http.NewRequest("GET", "/uploads/"+tc.id+"/quality", nil)
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+
+			rr := httptest.NewRecorder()
+
+			r := chi.NewRouter()
+			r.Get("/uploads/{id}/quality", q.GetQuality)
+
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.status, rr.Code)
+			assert.Equal(t, tc.want, rr.Body.String())
+			m.AssertExpectations(t)
+		})
+	}
+}
+
+func TestDeleteUpload(t *testing.T) {
+	createdAt := time.Date(2024, 6, 28, 18, 30, 0, 0, time.UTC)
+
+	cases := []struct {
+		name     string
+		path     string
+		mockFunc func(m *mockService)
+		status   int
+		want     string
+	}{
+		{
+			name: "success",
+			path: "/uploads/7?actor=ops&reason=wrong%20file",
+			mockFunc: func(m *mockService) {
+				m.On("DeleteUpload", mock.Anything, &trade.UploadAudit{UploadID: 7, Actor: "ops", Reason: "wrong file"}).
+					Run(func(args mock.Arguments) {
+						audit := args.Get(1).(*trade.UploadAudit)
+						audit.ID = 1
+						audit.Action = trade.AuditDelete
+						audit.Trades = 1500
+						audit.Metrics = 3
+						audit.CreatedAt = createdAt
+					}).
+					Return(nil).Once()
+			},
+			status: http.StatusOK,
+			want:   `{"id":1,"upload_id":7,"action":"delete","actor":"ops","reason":"wrong file","trades":1500,"metrics":3,"created_at":"2024-06-28T18:30:00Z"}`,
+		},
+		{
+			name: "failed because upload not found",
+			path: "/uploads/8",
+			mockFunc: func(m *mockService) {
+				m.On("DeleteUpload", mock.Anything, &trade.UploadAudit{UploadID: 8}).
+					Return(trade.ErrUploadNotFound).Once()
+			},
+			status: http.StatusNotFound,
+			want:   "Upload not found\n",
+		},
+		{
+			name: "failed because upload is processing",
+			path: "/uploads/7",
+			mockFunc: func(m *mockService) {
+				m.On("DeleteUpload", mock.Anything, mock.Anything).
+					Return(trade.ErrUploadProcessing).Once()
+			},
+			status: http.StatusConflict,
+			want:   "Upload is processing\n",
+		},
+		{
+			name: "failed because upload already deleted",
+			path: "/uploads/7",
+			mockFunc: func(m *mockService) {
+				m.On("DeleteUpload", mock.Anything, mock.Anything).
+					Return(trade.ErrUploadDeleted).Once()
+			},
+			status: http.StatusConflict,
+			want:   "Upload already deleted\n",
+		},
+		{
+			name: "failed because service error",
+			path: "/uploads/7",
+			mockFunc: func(m *mockService) {
+				m.On("DeleteUpload", mock.Anything, mock.Anything).
+					Return(errors.New("mock-error")).Once()
+			},
+			status: http.StatusInternalServerError,
+			want:   "Failed to delete upload\n",
+		},
+		{
+			name:     "failed because error parse id",
+			path:     "/uploads/x",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Failed to parse id\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := new(mockService)
+			tc.mockFunc(m)
+
+			q := NewQuotation(m)
+
+			req, err := http.NewRequest("DELETE", tc.path, nil)
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+
+			rr := httptest.NewRecorder()
+
+			r := chi.NewRouter()
+			r.Delete("/uploads/{id}", q.DeleteUpload)
+
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.status, rr.Code)
+			assert.Equal(t, tc.want, rr.Body.String())
+			m.AssertExpectations(t)
+		})
+	}
+}
+
+func TestReplayUpload(t *testing.T) {
+	cases := []struct {
+		name     string
+		path     string
+		mockFunc func(m *mockService)
+		status   int
+		want     string
+	}{
+		{
+			name: "success",
+			path: "/uploads/3/replay?actor=admin",
+			mockFunc: func(m *mockService) {
+				m.On("Replay", mock.Anything, 3, "admin").
+					Return(&trade.Upload{ID: 8, Status: trade.UploadProcessing}, io.NopCloser(strings.NewReader("header\n")), nil).Once()
+				m.On("BatchInsert", mock.Anything, mock.Anything, mock.Anything).
+					Return(nil)
+			},
+			status: http.StatusOK,
+			want:   `{"message":"file replayed successfully","upload_id":8}`,
+		},
+		{
+			name: "failed because upload not found",
+			path: "/uploads/3/replay",
+			mockFunc: func(m *mockService) {
+				m.On("Replay", mock.Anything, 3, "").
+					Return((*trade.Upload)(nil), nil, trade.ErrUploadNotFound).Once()
+			},
+			status: http.StatusNotFound,
+			want:   "Upload not found\n",
+		},
+		{
+			name: "failed because file not stored",
+			path: "/uploads/3/replay",
+			mockFunc: func(m *mockService) {
+				m.On("Replay", mock.Anything, 3, "").
+					Return((*trade.Upload)(nil), nil, trade.ErrFileNotStored).Once()
+			},
+			status: http.StatusNotFound,
+			want:   "Stored file not found\n",
+		},
+		{
+			name: "failed because upload is processing",
+			path: "/uploads/3/replay",
+			mockFunc: func(m *mockService) {
+				m.On("Replay", mock.Anything, 3, "").
+					Return((*trade.Upload)(nil), nil, trade.ErrUploadProcessing).Once()
+			},
+			status: http.StatusConflict,
+			want:   "Upload is processing\n",
+		},
+		{
+			name: "failed because file already uploaded",
+			path: "/uploads/3/replay",
+			mockFunc: func(m *mockService) {
+				m.On("Replay", mock.Anything, 3, "").
+					Return((*trade.Upload)(nil), nil, trade.ErrDuplicateFile).Once()
+			},
+			status: http.StatusConflict,
+			want:   "File already uploaded\n",
+		},
+		{
+			name: "failed because service error",
+			path: "/uploads/3/replay",
+			mockFunc: func(m *mockService) {
+				m.On("Replay", mock.Anything, 3, "").
+					Return((*trade.Upload)(nil), nil, errors.New("mock-error")).Once()
+			},
+			status: http.StatusInternalServerError,
+			want:   "Failed to replay upload\n",
+		},
+		{
+			name:     "failed because error parse id",
+			path:     "/uploads/x/replay",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Failed to parse id\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := new(mockService)
+			tc.mockFunc(m)
+
+			q := NewQuotation(m)
+
+			req, err := http.NewRequest("POST", tc.path, nil)
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+
+			rr := httptest.NewRecorder()
+
+			r := chi.NewRouter()
+			r.Post("/uploads/{id}/replay", q.ReplayUpload)
+
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tc.status, rr.Code)
+			assert.Equal(t, tc.want, rr.Body.String())
+		})
+	}
+}
+
+func TestValidateUpload(t *testing.T) {
+	date := time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name     string
+		req      string
+		query    string
+		mockFunc func(m *mockService)
+		status   int
+		want     string
+	}{
+		{
+			name:  "success",
+			req:   "header\nGOOG,29,11,2024-06-20\n",
+			query: "?max_errors=5",
+			mockFunc: func(m *mockService) {
+				m.On("Validate", mock.Anything, mock.Anything, 5).
+					Return(&trade.Validation{
+						Rows:            2,
+						ValidRows:       1,
+						InvalidRows:     1,
+						Columns:         []string{"DataReferencia", "CodigoInstrumento"},
+						ReferenceDate:   &date,
+						StartDate:       &date,
+						EndDate:         &date,
+						DistinctTickers: 1,
+						Tickers:         []string{"PETR4"},
+						Errors:          []*trade.ValidationError{{Line: 3, Error: "unexpected number of columns: 2"}},
+						Quality:         []*trade.QualityIssue{},
+					}, nil).Once()
+			},
+			status: http.StatusOK,
+			want:   `{"rows":2,"valid_rows":1,"invalid_rows":1,"columns":["DataReferencia","CodigoInstrumento"],"reference_date":"2024-06-28T00:00:00Z","start_date":"2024-06-28T00:00:00Z","end_date":"2024-06-28T00:00:00Z","distinct_tickers":1,"tickers":["PETR4"],"errors":[{"line":3,"error":"unexpected number of columns: 2"}],"quality":[],"valid":false}`,
+		},
+		{
+			name: "failed because service error",
+			req:  "header\nGOOG,29,11,2024-06-20\n",
+			mockFunc: func(m *mockService) {
+				m.On("Validate", mock.Anything, mock.Anything, 0).
+					Return((*trade.Validation)(nil), errors.New("mock-error")).Once()
+			},
+			status: http.StatusInternalServerError,
+			want:   "Failed to validate file\n",
+		},
+		{
+			name:     "failed because error parse max errors",
+			req:      "header\nGOOG,29,11,2024-06-20\n",
+			query:    "?max_errors=all",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Failed to parse max_errors\n",
+		},
+		{
+			name:     "missing file",
+			req:      "",
+			mockFunc: func(m *mockService) {},
+			status:   http.StatusBadRequest,
+			want:     "Failed to get file\n",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := new(mockService)
+			tc.mockFunc(m)
+
+			q := &Quotation{service: m}
+
+			body := &bytes.Buffer{}
+			header := http.Header{}
+
+			if tc.req != "" {
+				writer := multipart.NewWriter(body)
+				part, err := writer.CreateFormFile("Quotation", "example.csv")
+				if err != nil {
+					t.Fatalf("failed to create form file: %v", err)
+				}
+				_, err = part.Write([]byte(tc.req))
+				if err != nil {
+					t.Fatalf("failed to write to form file: %v", err)
+				}
+				err = writer.Close()
+				if err != nil {
+					t.Fatalf("failed to close multipart writer: %v", err)
+				}
+
+				header.Set("Content-Type", writer.FormDataContentType())
+			}
+
+			req, err := http.NewRequest("POST", "/upload/validate"+tc.query, body)
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+			req.Header = header
+
+			rr := httptest.NewRecorder()
+
+			r := chi.NewRouter()
+			r.Post("/upload/validate", q.ValidateUpload)
 
 			r.ServeHTTP(rr, req)
 
 			assert.Equal(t, tc.status, rr.Code)
 			assert.Equal(t, tc.want, rr.Body.String())
+			m.AssertExpectations(t)
 		})
 	}
 }
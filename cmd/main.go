@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/joho/godotenv"
@@ -9,6 +10,7 @@ import (
 	"quotation-metrics/cmd/handlers"
 	"quotation-metrics/internal/config"
 	"quotation-metrics/internal/platform"
+	"quotation-metrics/internal/storage"
 	"quotation-metrics/internal/trade"
 )
 
@@ -40,12 +42,49 @@ func main() {
 
 	quotationRepository := trade.NewRepository(db)
 
-	quotationService := trade.NewService(quotationRepository, cfg)
+	blobStore, err := storage.New(context.Background(), cfg.Storage)
+	if err != nil {
+		log.Fatalf("failed to create blob store %v", err)
+	}
+
+	quotationService := trade.NewService(quotationRepository, cfg, blobStore)
+
+	err = quotationService.ClassifyInstruments(context.Background())
+	if err != nil {
+		log.Printf("failed to classify instruments %v", err)
+	}
 
 	quotationHandler := handlers.NewQuotation(quotationService)
 
 	r.Post("/upload", quotationHandler.BatchUpload)
+	r.Post("/upload/validate", quotationHandler.ValidateUpload)
+	r.Get("/uploads/{id}", quotationHandler.GetUpload)
+	r.Delete("/uploads/{id}", quotationHandler.DeleteUpload)
+	r.Get("/uploads/{id}/completeness", quotationHandler.GetCompleteness)
+	r.Get("/uploads/{id}/quality", quotationHandler.GetQuality)
+	r.Post("/uploads/{id}/replay", quotationHandler.ReplayUpload)
 	r.Get("/metrics", quotationHandler.GetMetrics)
+	r.Get("/metrics/history", quotationHandler.GetMetricHistory)
+	r.Get("/trades", quotationHandler.GetTrades)
+	r.Get("/anomalies", quotationHandler.GetAnomalies)
+	r.Get("/blocks", quotationHandler.GetBlocks)
+	r.Get("/brokers/top", quotationHandler.GetTopBrokers)
+	r.Get("/brokers/flow", quotationHandler.GetBrokerFlows)
+	r.Get("/brokers/matrix", quotationHandler.GetBrokerMatrix)
+	r.Post("/instruments/import", quotationHandler.ImportInstruments)
+	r.Get("/options/{underlying}", quotationHandler.GetOptionChain)
+	r.Get("/futures/{root}/continuous", quotationHandler.GetContinuousFuture)
+	r.Get("/corporate-actions", quotationHandler.GetCorporateActions)
+	r.Post("/corporate-actions", quotationHandler.CreateCorporateActions)
+	r.Post("/corporate-actions/import", quotationHandler.ImportCorporateActions)
+	r.Get("/activity/{ticker}", quotationHandler.GetActivity)
+	r.Get("/market/breadth", quotationHandler.GetMarketBreadth)
+	r.Get("/liquidity/{ticker}", quotationHandler.GetLiquidity)
+	r.Get("/order-flow/{ticker}", quotationHandler.GetOrderFlow)
+	r.Get("/benchmarks/{ticker}", quotationHandler.GetBenchmarks)
+	r.Get("/auctions/{ticker}", quotationHandler.GetAuctions)
+	r.Get("/calendar/trading-days", quotationHandler.GetTradingDays)
+	r.Get("/calendar/{date}", quotationHandler.GetTradingDay)
 
 	log.Println("server started on port 8080")
 	if err := http.ListenAndServe(":8080", r); err != nil {
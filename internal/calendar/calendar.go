@@ -0,0 +1,86 @@
+package calendar
+
+import (
+	"bufio"
+	_ "embed"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrNotTradingDay is returned when a single day filter falls on a weekend or holiday
+var ErrNotTradingDay = errors.New("not a trading day")
+
+//go:embed holidays.txt
+var holidayList string
+
+// Calendar answers which days have a B3 trading session
+type Calendar struct {
+	holidays map[string]bool
+}
+
+// New returns the calendar of the embedded holidays plus the extra ones, e.g. a holiday declared after the release
+func New(extra []time.Time) *Calendar {
+	holidays := make(map[string]bool)
+
+	scanner := bufio.NewScanner(strings.NewReader(holidayList))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		holidays[line] = true
+	}
+
+	for _, date := range extra {
+		holidays[date.Format("2006-01-02")] = true
+	}
+
+	return &Calendar{holidays: holidays}
+}
+
+// IsTradingDay reports whether the date is a weekday and not a holiday
+func (c *Calendar) IsTradingDay(date time.Time) bool {
+	if date.Weekday() == time.Saturday || date.Weekday() == time.Sunday {
+		return false
+	}
+	return !c.holidays[date.Format("2006-01-02")]
+}
+
+// Previous returns the last trading day before the date
+func (c *Calendar) Previous(date time.Time) time.Time {
+	date = truncate(date).AddDate(0, 0, -1)
+	for !c.IsTradingDay(date) {
+		date = date.AddDate(0, 0, -1)
+	}
+	return date
+}
+
+// Next returns the first trading day after the date
+func (c *Calendar) Next(date time.Time) time.Time {
+	date = truncate(date).AddDate(0, 0, 1)
+	for !c.IsTradingDay(date) {
+		date = date.AddDate(0, 0, 1)
+	}
+	return date
+}
+
+// TradingDays returns the trading days from start to end, both inclusive
+func (c *Calendar) TradingDays(start, end time.Time) []time.Time {
+	days := make([]time.Time, 0)
+	for date := truncate(start); !date.After(truncate(end)); date = date.AddDate(0, 0, 1) {
+		if c.IsTradingDay(date) {
+			days = append(days, date)
+		}
+	}
+	return days
+}
+
+// TradingDaysBetween returns the number of trading days from start to end, both inclusive
+func (c *Calendar) TradingDaysBetween(start, end time.Time) int {
+	return len(c.TradingDays(start, end))
+}
+
+func truncate(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+}
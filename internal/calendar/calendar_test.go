@@ -0,0 +1,57 @@
+package calendar
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestIsTradingDay(t *testing.T) {
+	c := New([]time.Time{date(2024, 7, 9)})
+
+	cases := []struct {
+		name string
+		date time.Time
+		want bool
+	}{
+		{name: "weekday", date: date(2024, 6, 28), want: true},
+		{name: "saturday", date: date(2024, 6, 29), want: false},
+		{name: "sunday", date: date(2024, 6, 30), want: false},
+		{name: "embedded holiday", date: date(2024, 5, 30), want: false},
+		{name: "extra holiday", date: date(2024, 7, 9), want: false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, c.IsTradingDay(tc.date))
+		})
+	}
+}
+
+func TestPreviousAndNext(t *testing.T) {
+	c := New(nil)
+
+	// carnival monday and tuesday
+	assert.Equal(t, date(2024, 2, 9), c.Previous(date(2024, 2, 14)))
+	assert.Equal(t, date(2024, 2, 14), c.Next(date(2024, 2, 9)))
+	// weekend
+	assert.Equal(t, date(2024, 6, 28), c.Previous(date(2024, 7, 1)))
+	assert.Equal(t, date(2024, 7, 1), c.Next(date(2024, 6, 28)))
+	// the time of day is ignored
+	assert.Equal(t, date(2024, 7, 1), c.Next(time.Date(2024, 6, 28, 17, 30, 0, 0, time.UTC)))
+}
+
+func TestTradingDaysBetween(t *testing.T) {
+	c := New(nil)
+
+	assert.Equal(t, 5, c.TradingDaysBetween(date(2024, 6, 24), date(2024, 6, 30)))
+	// corpus christi
+	assert.Equal(t, 4, c.TradingDaysBetween(date(2024, 5, 27), date(2024, 5, 31)))
+	assert.Equal(t, 0, c.TradingDaysBetween(date(2024, 6, 29), date(2024, 6, 30)))
+	assert.Equal(t, 0, c.TradingDaysBetween(date(2024, 7, 1), date(2024, 6, 28)))
+	assert.Equal(t, []time.Time{date(2024, 2, 9), date(2024, 2, 14)}, c.TradingDays(date(2024, 2, 9), date(2024, 2, 14)))
+}
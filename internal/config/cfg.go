@@ -1,8 +1,14 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
+	// the exchange timezone is loaded even where the image has no zoneinfo
+	_ "time/tzdata"
 )
 
 type Database struct {
@@ -18,11 +24,104 @@ type Database struct {
 type App struct {
 	BatchSize int
 	Workers   int
+	// Timezone is the exchange timezone, trade times in the files are local to it
+	Timezone string
+}
+
+// Anomaly holds the anomaly thresholds, below MinSamples trades of the ticker in the file the stored baseline is used
+type Anomaly struct {
+	PriceDeviation float64
+	SizeMultiplier float64
+	MinSamples     int
+}
+
+type Block struct {
+	MinQuantity  int
+	SizeMultiple float64
+	MinSamples   int
+	Thresholds   map[string]int
+}
+
+type Sequence struct {
+	// TradeIDStep is the increment B3 numbers the trades of a ticker and day with
+	TradeIDStep int
+}
+
+type Calendar struct {
+	// Holidays are added to the embedded B3 holiday list
+	Holidays []time.Time
+}
+
+// Window is a time of day range of the exchange, the end is exclusive
+type Window struct {
+	Start time.Time
+	End   time.Time
+}
+
+type Auction struct {
+	// Opening and Closing are the windows where the auction uncross prints
+	Opening Window
+	Closing Window
+}
+
+type Quality struct {
+	// PriceDeviation is the share of the previous close a trade price may move before it is reported
+	PriceDeviation float64
+	// TradingHours is the time of day window of the exchange, trades outside it are reported
+	TradingHours Window
+	// Thresholds is the maximum share of the rows with each issue before the file is rejected
+	// Issues without a threshold are only reported
+	Thresholds map[string]float64
+	// Samples is the number of rows kept as examples of each issue
+	Samples int
+}
+
+// issues the rows of an upload are checked for, QUALITY_THRESHOLDS only takes these names
+const (
+	QualityNonPositivePrice     = "non_positive_price"
+	QualityNonPositiveQuantity  = "non_positive_quantity"
+	QualityOutsideReferenceDate = "outside_reference_date"
+	QualityPriceDeviation       = "price_deviation"
+	QualityOutsideTradingHours  = "outside_trading_hours"
+)
+
+var qualityIssues = []string{
+	QualityNonPositivePrice,
+	QualityNonPositiveQuantity,
+	QualityOutsideReferenceDate,
+	QualityPriceDeviation,
+	QualityOutsideTradingHours,
+}
+
+const (
+	StorageLocal = "local"
+	StorageS3    = "s3"
+)
+
+type Storage struct {
+	// Driver is where the raw uploaded files are kept, local or s3
+	Driver string
+	// Path is the directory of the local store
+	Path string
+	// Endpoint, Bucket and the keys of the S3 compatible store, e.g. a local MinIO
+	Endpoint  string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	Region    string
+	UseSSL    bool
 }
 
 type Config struct {
 	Database Database
 	App      App
+	Anomaly  Anomaly
+	Block    Block
+	Sequence Sequence
+	Calendar Calendar
+	Auction  Auction
+	Quality  Quality
+	Storage  Storage
 }
 
 func Load() (*Config, error) {
@@ -36,6 +135,96 @@ func Load() (*Config, error) {
 		return nil, err
 	}
 
+	// traded_at of the trades stored before the date columns migration is backfilled in the default zone, changing it
+	// on a database with those trades leaves them in the old zone
+	timezone := getEnv("EXCHANGE_TIMEZONE", "America/Sao_Paulo")
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return nil, err
+	}
+
+	priceDeviation, err := getEnvFloat("ANOMALY_PRICE_DEVIATION", 0.2)
+	if err != nil {
+		return nil, err
+	}
+
+	sizeMultiplier, err := getEnvFloat("ANOMALY_SIZE_MULTIPLIER", 50)
+	if err != nil {
+		return nil, err
+	}
+
+	minSamples, err := getEnvInt("ANOMALY_MIN_SAMPLES", 20)
+	if err != nil {
+		return nil, err
+	}
+
+	blockMinQuantity, err := getEnvInt("BLOCK_TRADE_MIN_QUANTITY", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	blockSizeMultiple, err := getEnvFloat("BLOCK_TRADE_SIZE_MULTIPLE", 10)
+	if err != nil {
+		return nil, err
+	}
+
+	blockMinSamples, err := getEnvInt("BLOCK_TRADE_MIN_SAMPLES", 20)
+	if err != nil {
+		return nil, err
+	}
+
+	blockThresholds, err := parseThresholds(os.Getenv("BLOCK_TRADE_THRESHOLDS"))
+	if err != nil {
+		return nil, err
+	}
+
+	tradeIDStep, err := getEnvInt("SEQUENCE_TRADE_ID_STEP", 10)
+	if err != nil {
+		return nil, err
+	}
+	if tradeIDStep <= 0 {
+		return nil, fmt.Errorf("invalid trade id step %d", tradeIDStep)
+	}
+
+	holidays, err := parseDates(os.Getenv("CALENDAR_HOLIDAYS"))
+	if err != nil {
+		return nil, err
+	}
+
+	openingAuction, err := parseWindow(getEnv("AUCTION_OPENING", "10:00-10:15"))
+	if err != nil {
+		return nil, err
+	}
+
+	closingAuction, err := parseWindow(getEnv("AUCTION_CLOSING", "16:55-17:10"))
+	if err != nil {
+		return nil, err
+	}
+
+	qualityPriceDeviation, err := getEnvFloat("QUALITY_PRICE_DEVIATION", 0.2)
+	if err != nil {
+		return nil, err
+	}
+
+	tradingHours, err := parseWindow(getEnv("QUALITY_TRADING_HOURS", "09:00-18:30"))
+	if err != nil {
+		return nil, err
+	}
+
+	qualityThresholds, err := parseRatios(os.Getenv("QUALITY_THRESHOLDS"))
+	if err != nil {
+		return nil, err
+	}
+
+	qualitySamples, err := getEnvInt("QUALITY_SAMPLES", 5)
+	if err != nil {
+		return nil, err
+	}
+
+	storage, err := loadStorage()
+	if err != nil {
+		return nil, err
+	}
+
 	return &Config{
 		Database: Database{
 			Host:     os.Getenv("POSTGRES_HOST"),
@@ -49,6 +238,201 @@ func Load() (*Config, error) {
 		App: App{
 			BatchSize: batchSize,
 			Workers:   workers,
+			Timezone:  timezone,
+		},
+		Anomaly: Anomaly{
+			PriceDeviation: priceDeviation,
+			SizeMultiplier: sizeMultiplier,
+			MinSamples:     minSamples,
+		},
+		Block: Block{
+			MinQuantity:  blockMinQuantity,
+			SizeMultiple: blockSizeMultiple,
+			MinSamples:   blockMinSamples,
+			Thresholds:   blockThresholds,
+		},
+		Sequence: Sequence{
+			TradeIDStep: tradeIDStep,
+		},
+		Calendar: Calendar{
+			Holidays: holidays,
 		},
+		Auction: Auction{
+			Opening: openingAuction,
+			Closing: closingAuction,
+		},
+		Quality: Quality{
+			PriceDeviation: qualityPriceDeviation,
+			TradingHours:   tradingHours,
+			Thresholds:     qualityThresholds,
+			Samples:        qualitySamples,
+		},
+		Storage: storage,
 	}, nil
 }
+
+func loadStorage() (Storage, error) {
+	useSSL, err := getEnvBool("BLOB_STORE_USE_SSL", false)
+	if err != nil {
+		return Storage{}, err
+	}
+
+	storage := Storage{
+		Driver:    getEnv("BLOB_STORE", StorageLocal),
+		Path:      getEnv("BLOB_STORE_PATH", "data/uploads"),
+		Endpoint:  os.Getenv("BLOB_STORE_ENDPOINT"),
+		Bucket:    os.Getenv("BLOB_STORE_BUCKET"),
+		AccessKey: os.Getenv("BLOB_STORE_ACCESS_KEY"),
+		SecretKey: os.Getenv("BLOB_STORE_SECRET_KEY"),
+		Region:    os.Getenv("BLOB_STORE_REGION"),
+		UseSSL:    useSSL,
+	}
+
+	switch storage.Driver {
+	case StorageLocal:
+	case StorageS3:
+		if storage.Endpoint == "" || storage.Bucket == "" {
+			return Storage{}, fmt.Errorf("blob store %q requires an endpoint and a bucket", storage.Driver)
+		}
+	default:
+		return Storage{}, fmt.Errorf("invalid blob store %q", storage.Driver)
+	}
+
+	return storage, nil
+}
+
+// parseThresholds parses a list of ticker thresholds in the format PETR4:100000,VALE3:50000
+func parseThresholds(value string) (map[string]int, error) {
+	thresholds := make(map[string]int)
+	if value == "" {
+		return thresholds, nil
+	}
+
+	for _, entry := range strings.Split(value, ",") {
+		ticker, quantity, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || ticker == "" {
+			return nil, fmt.Errorf("invalid threshold %q", entry)
+		}
+
+		threshold, err := strconv.Atoi(quantity)
+		if err != nil {
+			return nil, err
+		}
+		thresholds[ticker] = threshold
+	}
+
+	return thresholds, nil
+}
+
+// parseRatios parses a list of ratios in the format price_deviation:0.01,outside_trading_hours:0.05
+// A name that is not a quality issue fails, a misspelt threshold would otherwise never reject a file
+func parseRatios(value string) (map[string]float64, error) {
+	ratios := make(map[string]float64)
+	if value == "" {
+		return ratios, nil
+	}
+
+	for _, entry := range strings.Split(value, ",") {
+		key, ratio, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid ratio %q", entry)
+		}
+		if !slices.Contains(qualityIssues, key) {
+			return nil, fmt.Errorf("unknown quality issue %q", key)
+		}
+
+		parsed, err := strconv.ParseFloat(ratio, 64)
+		if err != nil {
+			return nil, err
+		}
+		if parsed < 0 || parsed > 1 {
+			return nil, fmt.Errorf("invalid ratio %q", entry)
+		}
+		ratios[key] = parsed
+	}
+
+	return ratios, nil
+}
+
+// parseDates parses a list of dates in the format 2024-11-20,2024-12-24
+func parseDates(value string) ([]time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	var dates []time.Time
+	for _, entry := range strings.Split(value, ",") {
+		date, err := time.Parse("2006-01-02", strings.TrimSpace(entry))
+		if err != nil {
+			return nil, err
+		}
+		dates = append(dates, date)
+	}
+
+	return dates, nil
+}
+
+// parseWindow parses a time of day window in the format 16:55-17:10, seconds are optional
+func parseWindow(value string) (Window, error) {
+	start, end, ok := strings.Cut(value, "-")
+	if !ok {
+		return Window{}, fmt.Errorf("invalid window %q", value)
+	}
+
+	var window Window
+	var err error
+	if window.Start, err = parseTimeOfDay(strings.TrimSpace(start)); err != nil {
+		return Window{}, err
+	}
+	if window.End, err = parseTimeOfDay(strings.TrimSpace(end)); err != nil {
+		return Window{}, err
+	}
+
+	if !window.End.After(window.Start) {
+		return Window{}, fmt.Errorf("invalid window %q", value)
+	}
+
+	return window, nil
+}
+
+func parseTimeOfDay(value string) (time.Time, error) {
+	if t, err := time.Parse("15:04:05", value); err == nil {
+		return t, nil
+	}
+	return time.Parse("15:04", value)
+}
+
+// getEnv returns the value of the environment variable or the fallback when it is not set
+func getEnv(key string, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// getEnvInt returns the fallback when the variable is not set
+func getEnvInt(key string, fallback int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	return strconv.Atoi(value)
+}
+
+// getEnvBool returns the fallback when the variable is not set
+func getEnvBool(key string, fallback bool) (bool, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	return strconv.ParseBool(value)
+}
+
+// getEnvFloat returns the fallback when the variable is not set
+func getEnvFloat(key string, fallback float64) (float64, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	return strconv.ParseFloat(value, 64)
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -43,9 +44,685 @@ func TestLoad(t *testing.T) {
 				App: App{
 					BatchSize: 100,
 					Workers:   4,
+					Timezone:  "America/Sao_Paulo",
+				},
+				Anomaly: Anomaly{
+					PriceDeviation: 0.2,
+					SizeMultiplier: 50,
+					MinSamples:     20,
+				},
+				Block: Block{
+					SizeMultiple: 10,
+					MinSamples:   20,
+					Thresholds:   map[string]int{},
+				},
+				Sequence: Sequence{
+					TradeIDStep: 10,
+				},
+				Quality: Quality{
+					PriceDeviation: 0.2,
+					Thresholds:     map[string]float64{},
+					Samples:        5,
+				},
+				Storage: Storage{
+					Driver: "local",
+					Path:   "data/uploads",
+				},
+			},
+		},
+		{
+			name: "success with anomaly thresholds",
+			mockFunc: func() {
+
+				t.Setenv("POSTGRES_HOST", "localhost")
+				t.Setenv("POSTGRES_USER", "testuser")
+				t.Setenv("POSTGRES_PASSWORD", "testpassword")
+				t.Setenv("POSTGRES_PORT", "5432")
+				t.Setenv("POSTGRES_DB", "testdb")
+				t.Setenv("POSTGRES_SLLMODE", "disable")
+				t.Setenv("POSTGRES_TIMEZONE", "UTC")
+
+				t.Setenv("BATCH_SIZE", "100")
+				t.Setenv("WORKERS", "4")
+
+				t.Setenv("ANOMALY_PRICE_DEVIATION", "0.05")
+				t.Setenv("ANOMALY_SIZE_MULTIPLIER", "10")
+				t.Setenv("ANOMALY_MIN_SAMPLES", "5")
+			},
+			want: &Config{
+				Database: Database{
+					Host:     "localhost",
+					User:     "testuser",
+					Password: "testpassword",
+					Port:     "5432",
+					DbName:   "testdb",
+					SSLMode:  "disable",
+					TimeZone: "UTC",
+				},
+				App: App{
+					BatchSize: 100,
+					Workers:   4,
+					Timezone:  "America/Sao_Paulo",
+				},
+				Anomaly: Anomaly{
+					PriceDeviation: 0.05,
+					SizeMultiplier: 10,
+					MinSamples:     5,
+				},
+				Block: Block{
+					SizeMultiple: 10,
+					MinSamples:   20,
+					Thresholds:   map[string]int{},
+				},
+				Sequence: Sequence{
+					TradeIDStep: 10,
+				},
+				Quality: Quality{
+					PriceDeviation: 0.2,
+					Thresholds:     map[string]float64{},
+					Samples:        5,
+				},
+				Storage: Storage{
+					Driver: "local",
+					Path:   "data/uploads",
+				},
+			},
+		},
+		{
+			name: "success with block trade thresholds",
+			mockFunc: func() {
+
+				t.Setenv("POSTGRES_HOST", "localhost")
+				t.Setenv("POSTGRES_USER", "testuser")
+				t.Setenv("POSTGRES_PASSWORD", "testpassword")
+				t.Setenv("POSTGRES_PORT", "5432")
+				t.Setenv("POSTGRES_DB", "testdb")
+				t.Setenv("POSTGRES_SLLMODE", "disable")
+				t.Setenv("POSTGRES_TIMEZONE", "UTC")
+
+				t.Setenv("BATCH_SIZE", "100")
+				t.Setenv("WORKERS", "4")
+
+				t.Setenv("ANOMALY_PRICE_DEVIATION", "0.2")
+				t.Setenv("ANOMALY_SIZE_MULTIPLIER", "50")
+				t.Setenv("ANOMALY_MIN_SAMPLES", "20")
+
+				t.Setenv("BLOCK_TRADE_MIN_QUANTITY", "50000")
+				t.Setenv("BLOCK_TRADE_SIZE_MULTIPLE", "0")
+				t.Setenv("BLOCK_TRADE_MIN_SAMPLES", "5")
+				t.Setenv("BLOCK_TRADE_THRESHOLDS", "PETR4:100000, VALE3:80000")
+			},
+			want: &Config{
+				Database: Database{
+					Host:     "localhost",
+					User:     "testuser",
+					Password: "testpassword",
+					Port:     "5432",
+					DbName:   "testdb",
+					SSLMode:  "disable",
+					TimeZone: "UTC",
+				},
+				App: App{
+					BatchSize: 100,
+					Workers:   4,
+					Timezone:  "America/Sao_Paulo",
+				},
+				Anomaly: Anomaly{
+					PriceDeviation: 0.2,
+					SizeMultiplier: 50,
+					MinSamples:     20,
+				},
+				Block: Block{
+					MinQuantity: 50000,
+					MinSamples:  5,
+					Thresholds:  map[string]int{"PETR4": 100000, "VALE3": 80000},
+				},
+				Sequence: Sequence{
+					TradeIDStep: 10,
+				},
+				Quality: Quality{
+					PriceDeviation: 0.2,
+					Thresholds:     map[string]float64{},
+					Samples:        5,
+				},
+				Storage: Storage{
+					Driver: "local",
+					Path:   "data/uploads",
+				},
+			},
+		},
+		{
+			name: "success with calendar holidays",
+			mockFunc: func() {
+
+				t.Setenv("POSTGRES_HOST", "localhost")
+				t.Setenv("POSTGRES_USER", "testuser")
+				t.Setenv("POSTGRES_PASSWORD", "testpassword")
+				t.Setenv("POSTGRES_PORT", "5432")
+				t.Setenv("POSTGRES_DB", "testdb")
+				t.Setenv("POSTGRES_SLLMODE", "disable")
+				t.Setenv("POSTGRES_TIMEZONE", "UTC")
+
+				t.Setenv("BATCH_SIZE", "100")
+				t.Setenv("WORKERS", "4")
+
+				t.Setenv("ANOMALY_PRICE_DEVIATION", "0.2")
+				t.Setenv("ANOMALY_SIZE_MULTIPLIER", "50")
+				t.Setenv("ANOMALY_MIN_SAMPLES", "20")
+
+				t.Setenv("BLOCK_TRADE_MIN_QUANTITY", "0")
+				t.Setenv("BLOCK_TRADE_SIZE_MULTIPLE", "10")
+				t.Setenv("BLOCK_TRADE_MIN_SAMPLES", "20")
+				t.Setenv("BLOCK_TRADE_THRESHOLDS", "")
+
+				t.Setenv("CALENDAR_HOLIDAYS", "2024-07-09, 2025-01-25")
+			},
+			want: &Config{
+				Database: Database{
+					Host:     "localhost",
+					User:     "testuser",
+					Password: "testpassword",
+					Port:     "5432",
+					DbName:   "testdb",
+					SSLMode:  "disable",
+					TimeZone: "UTC",
+				},
+				App: App{
+					BatchSize: 100,
+					Workers:   4,
+					Timezone:  "America/Sao_Paulo",
+				},
+				Anomaly: Anomaly{
+					PriceDeviation: 0.2,
+					SizeMultiplier: 50,
+					MinSamples:     20,
+				},
+				Block: Block{
+					SizeMultiple: 10,
+					MinSamples:   20,
+					Thresholds:   map[string]int{},
+				},
+				Calendar: Calendar{
+					Holidays: []time.Time{
+						time.Date(2024, 7, 9, 0, 0, 0, 0, time.UTC),
+						time.Date(2025, 1, 25, 0, 0, 0, 0, time.UTC),
+					},
+				},
+				Sequence: Sequence{
+					TradeIDStep: 10,
+				},
+				Quality: Quality{
+					PriceDeviation: 0.2,
+					Thresholds:     map[string]float64{},
+					Samples:        5,
+				},
+				Storage: Storage{
+					Driver: "local",
+					Path:   "data/uploads",
+				},
+			},
+		},
+		{
+			name: "success with exchange timezone",
+			mockFunc: func() {
+
+				t.Setenv("POSTGRES_HOST", "localhost")
+				t.Setenv("POSTGRES_USER", "testuser")
+				t.Setenv("POSTGRES_PASSWORD", "testpassword")
+				t.Setenv("POSTGRES_PORT", "5432")
+				t.Setenv("POSTGRES_DB", "testdb")
+				t.Setenv("POSTGRES_SLLMODE", "disable")
+				t.Setenv("POSTGRES_TIMEZONE", "UTC")
+
+				t.Setenv("BATCH_SIZE", "100")
+				t.Setenv("WORKERS", "4")
+
+				t.Setenv("ANOMALY_PRICE_DEVIATION", "0.2")
+				t.Setenv("ANOMALY_SIZE_MULTIPLIER", "50")
+				t.Setenv("ANOMALY_MIN_SAMPLES", "20")
+
+				t.Setenv("BLOCK_TRADE_MIN_QUANTITY", "0")
+				t.Setenv("BLOCK_TRADE_SIZE_MULTIPLE", "10")
+				t.Setenv("BLOCK_TRADE_MIN_SAMPLES", "20")
+				t.Setenv("BLOCK_TRADE_THRESHOLDS", "")
+
+				t.Setenv("CALENDAR_HOLIDAYS", "")
+				t.Setenv("EXCHANGE_TIMEZONE", "UTC")
+			},
+			want: &Config{
+				Database: Database{
+					Host:     "localhost",
+					User:     "testuser",
+					Password: "testpassword",
+					Port:     "5432",
+					DbName:   "testdb",
+					SSLMode:  "disable",
+					TimeZone: "UTC",
+				},
+				App: App{
+					BatchSize: 100,
+					Workers:   4,
+					Timezone:  "UTC",
+				},
+				Anomaly: Anomaly{
+					PriceDeviation: 0.2,
+					SizeMultiplier: 50,
+					MinSamples:     20,
+				},
+				Block: Block{
+					SizeMultiple: 10,
+					MinSamples:   20,
+					Thresholds:   map[string]int{},
+				},
+				Sequence: Sequence{
+					TradeIDStep: 10,
+				},
+				Quality: Quality{
+					PriceDeviation: 0.2,
+					Thresholds:     map[string]float64{},
+					Samples:        5,
+				},
+				Storage: Storage{
+					Driver: "local",
+					Path:   "data/uploads",
+				},
+			},
+		},
+		{
+			name: "success with auction windows",
+			mockFunc: func() {
+
+				t.Setenv("POSTGRES_HOST", "localhost")
+				t.Setenv("POSTGRES_USER", "testuser")
+				t.Setenv("POSTGRES_PASSWORD", "testpassword")
+				t.Setenv("POSTGRES_PORT", "5432")
+				t.Setenv("POSTGRES_DB", "testdb")
+				t.Setenv("POSTGRES_SLLMODE", "disable")
+				t.Setenv("POSTGRES_TIMEZONE", "UTC")
+
+				t.Setenv("BATCH_SIZE", "100")
+				t.Setenv("WORKERS", "4")
+				t.Setenv("EXCHANGE_TIMEZONE", "")
+
+				t.Setenv("AUCTION_OPENING", "10:00-10:00:30")
+				t.Setenv("AUCTION_CLOSING", "17:55 - 18:10")
+			},
+			want: &Config{
+				Database: Database{
+					Host:     "localhost",
+					User:     "testuser",
+					Password: "testpassword",
+					Port:     "5432",
+					DbName:   "testdb",
+					SSLMode:  "disable",
+					TimeZone: "UTC",
+				},
+				App: App{
+					BatchSize: 100,
+					Workers:   4,
+					Timezone:  "America/Sao_Paulo",
+				},
+				Anomaly: Anomaly{
+					PriceDeviation: 0.2,
+					SizeMultiplier: 50,
+					MinSamples:     20,
+				},
+				Block: Block{
+					SizeMultiple: 10,
+					MinSamples:   20,
+					Thresholds:   map[string]int{},
+				},
+				Auction: Auction{
+					Opening: Window{
+						Start: time.Date(0, 1, 1, 10, 0, 0, 0, time.UTC),
+						End:   time.Date(0, 1, 1, 10, 0, 30, 0, time.UTC),
+					},
+					Closing: Window{
+						Start: time.Date(0, 1, 1, 17, 55, 0, 0, time.UTC),
+						End:   time.Date(0, 1, 1, 18, 10, 0, 0, time.UTC),
+					},
+				},
+				Sequence: Sequence{
+					TradeIDStep: 10,
+				},
+				Quality: Quality{
+					PriceDeviation: 0.2,
+					Thresholds:     map[string]float64{},
+					Samples:        5,
+				},
+				Storage: Storage{
+					Driver: "local",
+					Path:   "data/uploads",
+				},
+			},
+		},
+		{
+			name: "success with quality thresholds",
+			mockFunc: func() {
+
+				t.Setenv("POSTGRES_HOST", "localhost")
+				t.Setenv("POSTGRES_USER", "testuser")
+				t.Setenv("POSTGRES_PASSWORD", "testpassword")
+				t.Setenv("POSTGRES_PORT", "5432")
+				t.Setenv("POSTGRES_DB", "testdb")
+				t.Setenv("POSTGRES_SLLMODE", "disable")
+				t.Setenv("POSTGRES_TIMEZONE", "UTC")
+
+				t.Setenv("BATCH_SIZE", "100")
+				t.Setenv("WORKERS", "4")
+
+				t.Setenv("QUALITY_PRICE_DEVIATION", "0.1")
+				t.Setenv("QUALITY_TRADING_HOURS", "10:00-18:00")
+				t.Setenv("QUALITY_THRESHOLDS", "non_positive_price:0, outside_trading_hours:0.05")
+				t.Setenv("QUALITY_SAMPLES", "3")
+			},
+			want: &Config{
+				Database: Database{
+					Host:     "localhost",
+					User:     "testuser",
+					Password: "testpassword",
+					Port:     "5432",
+					DbName:   "testdb",
+					SSLMode:  "disable",
+					TimeZone: "UTC",
+				},
+				App: App{
+					BatchSize: 100,
+					Workers:   4,
+					Timezone:  "America/Sao_Paulo",
+				},
+				Anomaly: Anomaly{
+					PriceDeviation: 0.2,
+					SizeMultiplier: 50,
+					MinSamples:     20,
+				},
+				Block: Block{
+					SizeMultiple: 10,
+					MinSamples:   20,
+					Thresholds:   map[string]int{},
+				},
+				Sequence: Sequence{
+					TradeIDStep: 10,
+				},
+				Quality: Quality{
+					PriceDeviation: 0.1,
+					TradingHours: Window{
+						Start: time.Date(0, 1, 1, 10, 0, 0, 0, time.UTC),
+						End:   time.Date(0, 1, 1, 18, 0, 0, 0, time.UTC),
+					},
+					Thresholds: map[string]float64{
+						"non_positive_price":    0,
+						"outside_trading_hours": 0.05,
+					},
+					Samples: 3,
+				},
+				Storage: Storage{
+					Driver: "local",
+					Path:   "data/uploads",
 				},
 			},
 		},
+		{
+			name: "success with s3 blob store",
+			mockFunc: func() {
+
+				t.Setenv("POSTGRES_HOST", "localhost")
+				t.Setenv("POSTGRES_USER", "testuser")
+				t.Setenv("POSTGRES_PASSWORD", "testpassword")
+				t.Setenv("POSTGRES_PORT", "5432")
+				t.Setenv("POSTGRES_DB", "testdb")
+				t.Setenv("POSTGRES_SLLMODE", "disable")
+				t.Setenv("POSTGRES_TIMEZONE", "UTC")
+
+				t.Setenv("BATCH_SIZE", "100")
+				t.Setenv("WORKERS", "4")
+
+				t.Setenv("BLOB_STORE", "s3")
+				t.Setenv("BLOB_STORE_ENDPOINT", "localhost:9000")
+				t.Setenv("BLOB_STORE_BUCKET", "quotation-uploads")
+				t.Setenv("BLOB_STORE_ACCESS_KEY", "minioadmin")
+				t.Setenv("BLOB_STORE_SECRET_KEY", "minioadmin")
+				t.Setenv("BLOB_STORE_USE_SSL", "true")
+			},
+			want: &Config{
+				Database: Database{
+					Host:     "localhost",
+					User:     "testuser",
+					Password: "testpassword",
+					Port:     "5432",
+					DbName:   "testdb",
+					SSLMode:  "disable",
+					TimeZone: "UTC",
+				},
+				App: App{
+					BatchSize: 100,
+					Workers:   4,
+					Timezone:  "America/Sao_Paulo",
+				},
+				Anomaly: Anomaly{
+					PriceDeviation: 0.2,
+					SizeMultiplier: 50,
+					MinSamples:     20,
+				},
+				Block: Block{
+					SizeMultiple: 10,
+					MinSamples:   20,
+					Thresholds:   map[string]int{},
+				},
+				Sequence: Sequence{
+					TradeIDStep: 10,
+				},
+				Quality: Quality{
+					PriceDeviation: 0.1,
+					TradingHours: Window{
+						Start: time.Date(0, 1, 1, 10, 0, 0, 0, time.UTC),
+						End:   time.Date(0, 1, 1, 18, 0, 0, 0, time.UTC),
+					},
+					Thresholds: map[string]float64{
+						"non_positive_price":    0,
+						"outside_trading_hours": 0.05,
+					},
+					Samples: 3,
+				},
+				Storage: Storage{
+					Driver:    "s3",
+					Path:      "data/uploads",
+					Endpoint:  "localhost:9000",
+					Bucket:    "quotation-uploads",
+					AccessKey: "minioadmin",
+					SecretKey: "minioadmin",
+					UseSSL:    true,
+				},
+			},
+		},
+		{
+			name: "success with trade id step",
+			mockFunc: func() {
+
+				t.Setenv("SEQUENCE_TRADE_ID_STEP", "1")
+			},
+			want: &Config{
+				Database: Database{
+					Host:     "localhost",
+					User:     "testuser",
+					Password: "testpassword",
+					Port:     "5432",
+					DbName:   "testdb",
+					SSLMode:  "disable",
+					TimeZone: "UTC",
+				},
+				App: App{
+					BatchSize: 100,
+					Workers:   4,
+					Timezone:  "America/Sao_Paulo",
+				},
+				Anomaly: Anomaly{
+					PriceDeviation: 0.2,
+					SizeMultiplier: 50,
+					MinSamples:     20,
+				},
+				Block: Block{
+					SizeMultiple: 10,
+					MinSamples:   20,
+					Thresholds:   map[string]int{},
+				},
+				Sequence: Sequence{
+					TradeIDStep: 1,
+				},
+				Quality: Quality{
+					PriceDeviation: 0.1,
+					TradingHours: Window{
+						Start: time.Date(0, 1, 1, 10, 0, 0, 0, time.UTC),
+						End:   time.Date(0, 1, 1, 18, 0, 0, 0, time.UTC),
+					},
+					Thresholds: map[string]float64{
+						"non_positive_price":    0,
+						"outside_trading_hours": 0.05,
+					},
+					Samples: 3,
+				},
+				Storage: Storage{
+					Driver:    "s3",
+					Path:      "data/uploads",
+					Endpoint:  "localhost:9000",
+					Bucket:    "quotation-uploads",
+					AccessKey: "minioadmin",
+					SecretKey: "minioadmin",
+					UseSSL:    true,
+				},
+			},
+		},
+		{
+			name: "failed because s3 blob store without bucket",
+			mockFunc: func() {
+
+				t.Setenv("BATCH_SIZE", "100")
+				t.Setenv("WORKERS", "4")
+
+				t.Setenv("BLOB_STORE_BUCKET", "")
+			},
+			want: nil,
+			err:  errors.New("blob store \"s3\" requires an endpoint and a bucket"),
+		},
+		{
+			name: "failed because error in parse blob store",
+			mockFunc: func() {
+
+				t.Setenv("BATCH_SIZE", "100")
+				t.Setenv("WORKERS", "4")
+
+				t.Setenv("BLOB_STORE", "ftp")
+			},
+			want: nil,
+			err:  errors.New("invalid blob store \"ftp\""),
+		},
+		{
+			name: "failed because error in parse blob store ssl",
+			mockFunc: func() {
+
+				t.Setenv("BATCH_SIZE", "100")
+				t.Setenv("WORKERS", "4")
+
+				t.Setenv("BLOB_STORE_USE_SSL", "maybe")
+			},
+			want: nil,
+			err: &strconv.NumError{
+				Func: "ParseBool",
+				Num:  "maybe",
+				Err:  errors.New("invalid syntax"),
+			},
+		},
+		{
+			name: "failed because error in parse quality thresholds",
+			mockFunc: func() {
+
+				t.Setenv("BATCH_SIZE", "100")
+				t.Setenv("WORKERS", "4")
+
+				t.Setenv("QUALITY_THRESHOLDS", "price_deviation:2")
+			},
+			want: nil,
+			err:  errors.New("invalid ratio \"price_deviation:2\""),
+		},
+		{
+			name: "failed because error in parse auction window",
+			mockFunc: func() {
+
+				t.Setenv("BATCH_SIZE", "100")
+				t.Setenv("WORKERS", "4")
+
+				t.Setenv("AUCTION_CLOSING", "17:10-16:55")
+			},
+			want: nil,
+			err:  errors.New("invalid window \"17:10-16:55\""),
+		},
+		{
+			name: "failed because error in parse calendar holidays",
+			mockFunc: func() {
+
+				t.Setenv("BATCH_SIZE", "100")
+				t.Setenv("WORKERS", "4")
+
+				t.Setenv("CALENDAR_HOLIDAYS", "2024-07-9")
+			},
+			want: nil,
+			err: &time.ParseError{
+				Layout:     "2006-01-02",
+				Value:      "2024-07-9",
+				LayoutElem: "02",
+				ValueElem:  "9",
+				Message:    "",
+			},
+		},
+		{
+			name: "failed because trade id step is not positive",
+			mockFunc: func() {
+
+				t.Setenv("SEQUENCE_TRADE_ID_STEP", "0")
+			},
+			want: nil,
+			err:  errors.New("invalid trade id step 0"),
+		},
+		{
+			name: "failed because error in parse block trade thresholds",
+			mockFunc: func() {
+
+				t.Setenv("BATCH_SIZE", "100")
+				t.Setenv("WORKERS", "4")
+
+				t.Setenv("BLOCK_TRADE_THRESHOLDS", "PETR4")
+			},
+			want: nil,
+			err:  errors.New("invalid threshold \"PETR4\""),
+		},
+		{
+			name: "failed because error in parse anomaly price deviation",
+			mockFunc: func() {
+
+				t.Setenv("BATCH_SIZE", "100")
+				t.Setenv("WORKERS", "4")
+
+				t.Setenv("ANOMALY_PRICE_DEVIATION", "i")
+			},
+			want: nil,
+			err: &strconv.NumError{
+				Func: "ParseFloat",
+				Num:  "i",
+				Err:  errors.New("invalid syntax"),
+			},
+		},
+		{
+			name: "failed because error in load exchange timezone",
+			mockFunc: func() {
+
+				t.Setenv("BATCH_SIZE", "100")
+				t.Setenv("WORKERS", "4")
+
+				t.Setenv("EXCHANGE_TIMEZONE", "America/Nowhere")
+			},
+			want: nil,
+			err:  errors.New("unknown time zone America/Nowhere"),
+		},
 		{
 			name: "failed because error in parse workers",
 			mockFunc: func() {
@@ -101,3 +778,68 @@ func TestLoad(t *testing.T) {
 		})
 	}
 }
+
+func TestParseWindow(t *testing.T) {
+	cases := []struct {
+		name    string
+		value   string
+		want    Window
+		wantErr bool
+	}{
+		{
+			name:  "success with minutes",
+			value: "16:55-17:10",
+			want: Window{
+				Start: time.Date(0, 1, 1, 16, 55, 0, 0, time.UTC),
+				End:   time.Date(0, 1, 1, 17, 10, 0, 0, time.UTC),
+			},
+		},
+		{
+			name:  "success with seconds",
+			value: "10:00:00 - 10:00:30",
+			want: Window{
+				Start: time.Date(0, 1, 1, 10, 0, 0, 0, time.UTC),
+				End:   time.Date(0, 1, 1, 10, 0, 30, 0, time.UTC),
+			},
+		},
+		{name: "failed because missing end", value: "10:00", wantErr: true},
+		{name: "failed because invalid time", value: "10:00-25:00", wantErr: true},
+		{name: "failed because end before start", value: "10:15-10:00", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseWindow(tc.value)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.True(t, tc.want.Start.Equal(got.Start))
+			assert.True(t, tc.want.End.Equal(got.End))
+		})
+	}
+}
+
+func TestParseRatios(t *testing.T) {
+	cases := []struct {
+		name    string
+		value   string
+		want    map[string]float64
+		wantErr error
+	}{
+		{name: "success empty", value: "", want: map[string]float64{}},
+		{name: "success", value: "price_deviation:0.01, non_positive_quantity:0", want: map[string]float64{"price_deviation": 0.01, "non_positive_quantity": 0}},
+		{name: "failed because missing ratio", value: "price_deviation", wantErr: errors.New("invalid ratio \"price_deviation\"")},
+		{name: "failed because ratio above one", value: "price_deviation:1.5", wantErr: errors.New("invalid ratio \"price_deviation:1.5\"")},
+		{name: "failed because unknown quality issue", value: "price_deviaton:0.01", wantErr: errors.New("unknown quality issue \"price_deviaton\"")},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseRatios(tc.value)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package instrument
+
+import (
+	"bufio"
+	_ "embed"
+	"regexp"
+	"strings"
+)
+
+type Type string
+
+const (
+	TypeStock      Type = "stock"
+	TypeFractional Type = "fractional"
+	TypeOption     Type = "option"
+	TypeFuture     Type = "future"
+	TypeBDR        Type = "bdr"
+	TypeETF        Type = "etf"
+	TypeOther      Type = "other"
+)
+
+// Types lists every type returned by Classify
+var Types = []Type{TypeStock, TypeFractional, TypeOption, TypeFuture, TypeBDR, TypeETF, TypeOther}
+
+//go:embed etfs.txt
+var etfList string
+
+var etfs = parseList(etfList)
+
+var (
+	// four character root followed by 3 to 8 for shares or 11 for units
+	stockPattern      = regexp.MustCompile(`^[A-Z][A-Z0-9]{3}([3-8]|11)$`)
+	fractionalPattern = regexp.MustCompile(`^[A-Z][A-Z0-9]{3}([3-8]|11)F$`)
+	bdrPattern        = regexp.MustCompile(`^[A-Z][A-Z0-9]{3}3[2-5]$`)
+	// three character root, contract month code and two digit year, e.g. WINQ24, DOLU24, DI1F25
+	futurePattern = regexp.MustCompile(`^[A-Z][A-Z0-9]{2}[FGHJKMNQUVXZ][0-9]{2}$`)
+	// four character root, month letter (A-L calls, M-X puts) and series, e.g. PETRG350, VALEM600E
+	optionPattern = regexp.MustCompile(`^[A-Z][A-Z0-9]{3}[A-X][0-9]{1,4}[A-Z0-9]{0,2}$`)
+)
+
+// Classify recognizes the instrument type from the B3 ticker conventions
+func Classify(ticker string) Type {
+	ticker = strings.ToUpper(strings.TrimSpace(ticker))
+
+	switch {
+	case fractionalPattern.MatchString(ticker):
+		return TypeFractional
+	case etfs[ticker]:
+		return TypeETF
+	case stockPattern.MatchString(ticker):
+		return TypeStock
+	// checked before futures, a BDR like NFLX34 also looks like a contract month and year
+	case bdrPattern.MatchString(ticker):
+		return TypeBDR
+	case futurePattern.MatchString(ticker):
+		return TypeFuture
+	case optionPattern.MatchString(ticker):
+		return TypeOption
+	default:
+		return TypeOther
+	}
+}
+
+// Fractional returns the fractional market ticker of a standard lot ticker, e.g. PETR4F for PETR4
+func Fractional(ticker string) (string, bool) {
+	ticker = strings.ToUpper(strings.TrimSpace(ticker))
+	if !stockPattern.MatchString(ticker) {
+		return "", false
+	}
+	return ticker + "F", true
+}
+
+// Underlying returns the standard lot ticker of a fractional market ticker, e.g. PETR4 for PETR4F
+func Underlying(ticker string) (string, bool) {
+	ticker = strings.ToUpper(strings.TrimSpace(ticker))
+	if !fractionalPattern.MatchString(ticker) {
+		return "", false
+	}
+	return strings.TrimSuffix(ticker, "F"), true
+}
+
+// Valid reports whether the value is one of the known types
+func Valid(value string) bool {
+	for _, t := range Types {
+		if string(t) == value {
+			return true
+		}
+	}
+	return false
+}
+
+func parseList(list string) map[string]bool {
+	values := make(map[string]bool)
+
+	scanner := bufio.NewScanner(strings.NewReader(list))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		values[line] = true
+	}
+
+	return values
+}
@@ -0,0 +1,86 @@
+package instrument
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	cases := []struct {
+		ticker string
+		want   Type
+	}{
+		{ticker: "PETR3", want: TypeStock},
+		{ticker: "PETR4", want: TypeStock},
+		{ticker: "TAEE11", want: TypeStock},
+		{ticker: "B3SA3", want: TypeStock},
+		{ticker: "petr4", want: TypeStock},
+		{ticker: "PETR4F", want: TypeFractional},
+		{ticker: "TAEE11F", want: TypeFractional},
+		{ticker: "PETRG350", want: TypeOption},
+		{ticker: "VALEM600E", want: TypeOption},
+		{ticker: "PETRA290W2", want: TypeOption},
+		{ticker: "WINQ24", want: TypeFuture},
+		{ticker: "DOLU24", want: TypeFuture},
+		{ticker: "DI1F25", want: TypeFuture},
+		{ticker: "AAPL34", want: TypeBDR},
+		{ticker: "NFLX34", want: TypeBDR},
+		{ticker: "BOVA11", want: TypeETF},
+		{ticker: "IVVB11", want: TypeETF},
+		{ticker: "TF583R", want: TypeOther},
+		{ticker: "", want: TypeOther},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.ticker, func(t *testing.T) {
+			assert.Equal(t, tc.want, Classify(tc.ticker))
+		})
+	}
+}
+
+func TestFractional(t *testing.T) {
+	cases := []struct {
+		ticker string
+		want   string
+		wantOk bool
+	}{
+		{ticker: "PETR4", want: "PETR4F", wantOk: true},
+		{ticker: "taee11", want: "TAEE11F", wantOk: true},
+		{ticker: "PETR4F", want: "", wantOk: false},
+		{ticker: "WINQ24", want: "", wantOk: false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.ticker, func(t *testing.T) {
+			got, ok := Fractional(tc.ticker)
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.wantOk, ok)
+		})
+	}
+}
+
+func TestUnderlying(t *testing.T) {
+	cases := []struct {
+		ticker string
+		want   string
+		wantOk bool
+	}{
+		{ticker: "PETR4F", want: "PETR4", wantOk: true},
+		{ticker: "TAEE11F", want: "TAEE11", wantOk: true},
+		{ticker: "PETR4", want: "", wantOk: false},
+		{ticker: "TF583R", want: "", wantOk: false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.ticker, func(t *testing.T) {
+			got, ok := Underlying(tc.ticker)
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.wantOk, ok)
+		})
+	}
+}
+
+func TestValid(t *testing.T) {
+	assert.True(t, Valid("option"))
+	assert.False(t, Valid("bond"))
+}
@@ -0,0 +1,72 @@
+package instrument
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// monthCodes maps the contract month letter of the future tickers to the month
+var monthCodes = map[byte]time.Month{
+	'F': time.January,
+	'G': time.February,
+	'H': time.March,
+	'J': time.April,
+	'K': time.May,
+	'M': time.June,
+	'N': time.July,
+	'Q': time.August,
+	'U': time.September,
+	'V': time.October,
+	'X': time.November,
+	'Z': time.December,
+}
+
+// Future holds the metadata encoded in a future ticker
+type Future struct {
+	Root  string
+	Month time.Month
+	Year  int
+}
+
+// ParseFuture reads the future metadata from the ticker, e.g. WINQ24 is the August 2024 contract of WIN
+func ParseFuture(ticker string) (Future, bool) {
+	ticker = strings.ToUpper(strings.TrimSpace(ticker))
+	if Classify(ticker) != TypeFuture {
+		return Future{}, false
+	}
+
+	year, err := strconv.Atoi(ticker[4:])
+	if err != nil {
+		return Future{}, false
+	}
+
+	return Future{
+		Root:  ticker[:3],
+		Month: monthCodes[ticker[3]],
+		Year:  2000 + year,
+	}, true
+}
+
+// Expiry returns the last trading day of the contract
+// Index futures expire on the Wednesday closest to the 15th, the other contracts on the first weekday of the month
+// Exchange holidays are not considered
+func (f Future) Expiry() time.Time {
+	switch f.Root {
+	case "WIN", "IND":
+		day := time.Date(f.Year, f.Month, 15, 0, 0, 0, 0, time.UTC)
+		offset := int(time.Wednesday - day.Weekday())
+		if offset > 3 {
+			offset -= 7
+		} else if offset < -3 {
+			offset += 7
+		}
+		return day.AddDate(0, 0, offset)
+	default:
+		day := time.Date(f.Year, f.Month, 1, 0, 0, 0, 0, time.UTC)
+		for day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+			day = day.AddDate(0, 0, 1)
+		}
+		return day
+	}
+}
@@ -0,0 +1,69 @@
+package instrument
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestParseFuture(t *testing.T) {
+	cases := []struct {
+		ticker string
+		want   Future
+		wantOk bool
+	}{
+		{ticker: "WINQ24", want: Future{Root: "WIN", Month: time.August, Year: 2024}, wantOk: true},
+		{ticker: "dolu24", want: Future{Root: "DOL", Month: time.September, Year: 2024}, wantOk: true},
+		{ticker: "DI1F25", want: Future{Root: "DI1", Month: time.January, Year: 2025}, wantOk: true},
+		{ticker: "PETR4", want: Future{}, wantOk: false},
+		{ticker: "PETRG350", want: Future{}, wantOk: false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.ticker, func(t *testing.T) {
+			got, ok := ParseFuture(tc.ticker)
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.wantOk, ok)
+		})
+	}
+}
+
+func TestFutureExpiry(t *testing.T) {
+	cases := []struct {
+		name   string
+		future Future
+		want   time.Time
+	}{
+		{
+			name:   "index future on the wednesday before the 15th",
+			future: Future{Root: "WIN", Month: time.August, Year: 2024},
+			want:   time.Date(2024, 8, 14, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:   "index future on the wednesday after the 15th",
+			future: Future{Root: "IND", Month: time.June, Year: 2024},
+			want:   time.Date(2024, 6, 12, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:   "index future when the 15th is a wednesday",
+			future: Future{Root: "WIN", Month: time.May, Year: 2024},
+			want:   time.Date(2024, 5, 15, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:   "other future on the first weekday",
+			future: Future{Root: "DOL", Month: time.September, Year: 2024},
+			want:   time.Date(2024, 9, 2, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:   "other future on the first day",
+			future: Future{Root: "DI1", Month: time.January, Year: 2025},
+			want:   time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, tc.future.Expiry())
+		})
+	}
+}
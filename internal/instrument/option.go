@@ -0,0 +1,53 @@
+package instrument
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	RightCall = "call"
+	RightPut  = "put"
+)
+
+// Option holds the metadata encoded in an option ticker
+type Option struct {
+	Root        string
+	Right       string
+	ExpiryMonth time.Month
+	Series      string
+}
+
+// ParseOption reads the option metadata from the ticker, e.g. PETRG350 is a July call on PETR with series 350
+// The month letter goes from A to L for calls and from M to X for puts
+func ParseOption(ticker string) (Option, bool) {
+	ticker = strings.ToUpper(strings.TrimSpace(ticker))
+	if Classify(ticker) != TypeOption {
+		return Option{}, false
+	}
+
+	letter := ticker[4]
+	option := Option{
+		Root:   ticker[:4],
+		Series: ticker[5:],
+	}
+
+	if letter < 'M' {
+		option.Right = RightCall
+		option.ExpiryMonth = time.Month(letter-'A') + time.January
+	} else {
+		option.Right = RightPut
+		option.ExpiryMonth = time.Month(letter-'M') + time.January
+	}
+
+	return option, true
+}
+
+// Root returns the four character root shared by a stock and its options, e.g. PETR for PETR4
+func Root(ticker string) string {
+	ticker = strings.ToUpper(strings.TrimSpace(ticker))
+	if len(ticker) < 4 {
+		return ticker
+	}
+	return ticker[:4]
+}
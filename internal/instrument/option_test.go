@@ -0,0 +1,36 @@
+package instrument
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestParseOption(t *testing.T) {
+	cases := []struct {
+		ticker string
+		want   Option
+		wantOk bool
+	}{
+		{ticker: "PETRG350", want: Option{Root: "PETR", Right: RightCall, ExpiryMonth: time.July, Series: "350"}, wantOk: true},
+		{ticker: "VALEM600E", want: Option{Root: "VALE", Right: RightPut, ExpiryMonth: time.January, Series: "600E"}, wantOk: true},
+		{ticker: "bbasx45", want: Option{Root: "BBAS", Right: RightPut, ExpiryMonth: time.December, Series: "45"}, wantOk: true},
+		{ticker: "PETRA290W2", want: Option{Root: "PETR", Right: RightCall, ExpiryMonth: time.January, Series: "290W2"}, wantOk: true},
+		{ticker: "PETR4", want: Option{}, wantOk: false},
+		{ticker: "WINQ24", want: Option{}, wantOk: false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.ticker, func(t *testing.T) {
+			got, ok := ParseOption(tc.ticker)
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.wantOk, ok)
+		})
+	}
+}
+
+func TestRoot(t *testing.T) {
+	assert.Equal(t, "PETR", Root("PETR4"))
+	assert.Equal(t, "TAEE", Root("taee11"))
+	assert.Equal(t, "AB", Root("AB"))
+}
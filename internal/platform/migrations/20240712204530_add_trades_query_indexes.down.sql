@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS trades_instrument_code_trade_date_index;
+DROP INDEX IF EXISTS trades_trade_date_index;
@@ -0,0 +1,2 @@
+CREATE INDEX trades_instrument_code_trade_date_index ON trades(instrument_code, trade_date, id);
+CREATE INDEX trades_trade_date_index ON trades(trade_date, id);
@@ -0,0 +1,13 @@
+CREATE TABLE anomalies
+(
+    id              SERIAL PRIMARY KEY,
+    instrument_code VARCHAR(255),
+    trade_price     DECIMAL(19, 4),
+    trade_quantity  INT,
+    close_time      VARCHAR(50),
+    trade_date      TIMESTAMP,
+    reason          VARCHAR(50),
+    reference_value DECIMAL(19, 4)
+);
+
+CREATE INDEX anomalies_instrument_code_trade_date_index ON anomalies(instrument_code, trade_date);
@@ -0,0 +1,14 @@
+CREATE TABLE block_trades
+(
+    id              SERIAL PRIMARY KEY,
+    instrument_code VARCHAR(255),
+    trade_price     DECIMAL(19, 4),
+    trade_quantity  INT,
+    close_time      VARCHAR(50),
+    trade_date      TIMESTAMP,
+    buyer_code      VARCHAR(20),
+    seller_code     VARCHAR(20),
+    criterion       VARCHAR(50)
+);
+
+CREATE INDEX block_trades_trade_date_index ON block_trades(trade_date, instrument_code);
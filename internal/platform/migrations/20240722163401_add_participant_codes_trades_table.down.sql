@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS trades_buyer_code_trade_date_index;
+DROP INDEX IF EXISTS trades_seller_code_trade_date_index;
+
+ALTER TABLE trades DROP COLUMN IF EXISTS buyer_code;
+ALTER TABLE trades DROP COLUMN IF EXISTS seller_code;
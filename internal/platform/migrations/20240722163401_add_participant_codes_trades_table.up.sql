@@ -0,0 +1,5 @@
+ALTER TABLE trades ADD COLUMN buyer_code VARCHAR(20);
+ALTER TABLE trades ADD COLUMN seller_code VARCHAR(20);
+
+CREATE INDEX trades_buyer_code_trade_date_index ON trades(buyer_code, trade_date);
+CREATE INDEX trades_seller_code_trade_date_index ON trades(seller_code, trade_date);
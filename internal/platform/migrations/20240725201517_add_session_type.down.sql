@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS metrics_ticker_session_type_trade_date_index;
+
+ALTER TABLE metrics DROP COLUMN IF EXISTS session_type;
+ALTER TABLE trades DROP COLUMN IF EXISTS session_type;
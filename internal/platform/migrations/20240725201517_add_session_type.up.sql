@@ -0,0 +1,6 @@
+ALTER TABLE trades ADD COLUMN session_type SMALLINT;
+
+-- metrics loaded before the split mixed every session, they are kept as regular session metrics
+ALTER TABLE metrics ADD COLUMN session_type SMALLINT NOT NULL DEFAULT 1;
+
+CREATE INDEX metrics_ticker_session_type_trade_date_index ON metrics(ticker, session_type, trade_date);
@@ -0,0 +1,7 @@
+CREATE TABLE instruments
+(
+    ticker          VARCHAR(255) PRIMARY KEY,
+    instrument_type VARCHAR(50) NOT NULL
+);
+
+CREATE INDEX instruments_instrument_type_index ON instruments(instrument_type);
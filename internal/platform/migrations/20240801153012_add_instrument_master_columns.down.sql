@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS instruments_underlying_index;
+
+ALTER TABLE instruments DROP COLUMN IF EXISTS strike;
+ALTER TABLE instruments DROP COLUMN IF EXISTS underlying;
@@ -0,0 +1,4 @@
+ALTER TABLE instruments ADD COLUMN underlying VARCHAR(255);
+ALTER TABLE instruments ADD COLUMN strike DECIMAL(19, 4);
+
+CREATE INDEX instruments_underlying_index ON instruments(underlying);
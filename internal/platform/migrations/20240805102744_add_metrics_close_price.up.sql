@@ -0,0 +1 @@
+ALTER TABLE metrics ADD COLUMN close_price DECIMAL(19, 4);
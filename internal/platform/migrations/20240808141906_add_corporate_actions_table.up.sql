@@ -0,0 +1,9 @@
+CREATE TABLE corporate_actions
+(
+    id          SERIAL PRIMARY KEY,
+    ticker      VARCHAR(255)   NOT NULL,
+    ex_date     TIMESTAMP      NOT NULL,
+    action_type VARCHAR(50)    NOT NULL,
+    factor      DECIMAL(19, 8) NOT NULL,
+    UNIQUE (ticker, ex_date, action_type)
+);
@@ -0,0 +1,7 @@
+ALTER TABLE trades DROP COLUMN IF EXISTS traded_at;
+
+ALTER TABLE corporate_actions ALTER COLUMN ex_date TYPE TIMESTAMP;
+ALTER TABLE block_trades ALTER COLUMN trade_date TYPE TIMESTAMP;
+ALTER TABLE anomalies ALTER COLUMN trade_date TYPE TIMESTAMP;
+ALTER TABLE metrics ALTER COLUMN trade_date TYPE TIMESTAMP;
+ALTER TABLE trades ALTER COLUMN trade_date TYPE TIMESTAMP;
@@ -0,0 +1,16 @@
+-- trade dates are exchange calendar days, a DATE is not shifted by the server or session timezone
+ALTER TABLE trades ALTER COLUMN trade_date TYPE DATE USING trade_date::DATE;
+ALTER TABLE metrics ALTER COLUMN trade_date TYPE DATE USING trade_date::DATE;
+ALTER TABLE anomalies ALTER COLUMN trade_date TYPE DATE USING trade_date::DATE;
+ALTER TABLE block_trades ALTER COLUMN trade_date TYPE DATE USING trade_date::DATE;
+ALTER TABLE corporate_actions ALTER COLUMN ex_date TYPE DATE USING ex_date::DATE;
+
+-- the instant of the trade, close_time is HHMMSSmmm local to the exchange
+ALTER TABLE trades ADD COLUMN traded_at TIMESTAMPTZ;
+
+-- the trades already stored are backfilled in the default EXCHANGE_TIMEZONE, a migration can not read the config,
+-- databases loaded with another EXCHANGE_TIMEZONE must recompute traded_at of these rows in that zone
+
+UPDATE trades
+SET traded_at = (trade_date + to_timestamp(close_time, 'HH24MISSMS')::TIME) AT TIME ZONE 'America/Sao_Paulo'
+WHERE close_time ~ '^[0-9]{9}$';
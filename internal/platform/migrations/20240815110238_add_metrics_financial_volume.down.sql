@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS metrics_trade_date_index;
+
+ALTER TABLE metrics DROP COLUMN IF EXISTS financial_volume;
@@ -0,0 +1,15 @@
+ALTER TABLE metrics ADD COLUMN financial_volume DECIMAL(24, 4);
+
+-- metrics loaded before the column existed are filled from their trades
+UPDATE metrics m
+SET financial_volume = t.financial_volume
+FROM (
+    SELECT instrument_code, trade_date, COALESCE(session_type, 1) AS session_type, SUM(trade_price * trade_quantity) AS financial_volume
+    FROM trades
+    GROUP BY instrument_code, trade_date, COALESCE(session_type, 1)
+) t
+WHERE t.instrument_code = m.ticker
+  AND t.trade_date = m.trade_date
+  AND t.session_type = m.session_type;
+
+CREATE INDEX metrics_trade_date_index ON metrics(trade_date);
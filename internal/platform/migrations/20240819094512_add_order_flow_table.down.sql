@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS order_flow;
+
+ALTER TABLE trades DROP COLUMN IF EXISTS trade_id;
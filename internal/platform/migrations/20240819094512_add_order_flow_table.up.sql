@@ -0,0 +1,16 @@
+ALTER TABLE trades ADD COLUMN trade_id BIGINT;
+
+CREATE TABLE order_flow
+(
+    id                  SERIAL PRIMARY KEY,
+    ticker              VARCHAR(255),
+    trade_date          DATE,
+    bucket_minute       INT,
+    buy_volume          INT,
+    sell_volume         INT,
+    unclassified_volume INT,
+    buy_trades          INT,
+    sell_trades         INT
+);
+
+CREATE INDEX order_flow_ticker_trade_date_index ON order_flow(ticker, trade_date);
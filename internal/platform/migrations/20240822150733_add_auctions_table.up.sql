@@ -0,0 +1,13 @@
+CREATE TABLE auctions
+(
+    id           SERIAL PRIMARY KEY,
+    ticker       VARCHAR(255),
+    trade_date   DATE,
+    auction_type VARCHAR(50),
+    close_time   VARCHAR(50),
+    price        DECIMAL(19, 4),
+    volume       INT,
+    trades       INT
+);
+
+CREATE INDEX auctions_ticker_trade_date_index ON auctions(ticker, trade_date);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS trade_sequences;
+DROP TABLE IF EXISTS uploads;
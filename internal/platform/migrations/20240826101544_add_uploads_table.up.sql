@@ -0,0 +1,27 @@
+CREATE TABLE uploads
+(
+    id          SERIAL PRIMARY KEY,
+    file_name   VARCHAR(255),
+    status      VARCHAR(50),
+    trades      INT,
+    created_at  TIMESTAMPTZ DEFAULT NOW(),
+    finished_at TIMESTAMPTZ
+);
+
+CREATE TABLE trade_sequences
+(
+    id             SERIAL PRIMARY KEY,
+    upload_id      INT,
+    ticker         VARCHAR(255),
+    trade_date     DATE,
+    trades         INT,
+    first_trade_id BIGINT,
+    last_trade_id  BIGINT,
+    step           BIGINT,
+    missing        BIGINT,
+    gaps           INT,
+    duplicates     INT,
+    out_of_order   INT
+);
+
+CREATE INDEX trade_sequences_upload_id_index ON trade_sequences(upload_id);
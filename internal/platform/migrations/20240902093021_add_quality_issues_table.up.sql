@@ -0,0 +1,13 @@
+CREATE TABLE quality_issues
+(
+    id        SERIAL PRIMARY KEY,
+    upload_id INT,
+    issue     VARCHAR(50),
+    row_count INT,
+    ratio     DOUBLE PRECISION,
+    rejected  BOOLEAN,
+    -- the first rows found with the issue
+    samples   JSONB
+);
+
+CREATE INDEX quality_issues_upload_id_index ON quality_issues(upload_id);
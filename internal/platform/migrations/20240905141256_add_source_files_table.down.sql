@@ -0,0 +1,13 @@
+DROP INDEX IF EXISTS metrics_source_file_id_index;
+DROP INDEX IF EXISTS trades_source_file_id_index;
+
+ALTER TABLE metrics DROP COLUMN IF EXISTS source_file_id;
+ALTER TABLE trades DROP COLUMN IF EXISTS source_file_id;
+
+DROP INDEX IF EXISTS source_files_sha256_index;
+
+ALTER TABLE source_files DROP COLUMN IF EXISTS uploader;
+ALTER TABLE source_files DROP COLUMN IF EXISTS reference_date;
+ALTER TABLE source_files DROP COLUMN IF EXISTS sha256;
+ALTER TABLE source_files DROP COLUMN IF EXISTS file_size;
+ALTER TABLE source_files RENAME TO uploads;
@@ -0,0 +1,16 @@
+-- the uploads become the registry of the ingested source files
+ALTER TABLE uploads RENAME TO source_files;
+ALTER TABLE source_files ADD COLUMN file_size BIGINT;
+ALTER TABLE source_files ADD COLUMN sha256 VARCHAR(64);
+ALTER TABLE source_files ADD COLUMN reference_date DATE;
+ALTER TABLE source_files ADD COLUMN uploader VARCHAR(255);
+
+-- a file is ingested once, failed and rejected uploads of it can be retried
+CREATE UNIQUE INDEX source_files_sha256_index ON source_files(sha256) WHERE status IN ('processing', 'completed');
+
+-- rows loaded before the registry have no source file
+ALTER TABLE trades ADD COLUMN source_file_id INT;
+ALTER TABLE metrics ADD COLUMN source_file_id INT;
+
+CREATE INDEX trades_source_file_id_index ON trades(source_file_id);
+CREATE INDEX metrics_source_file_id_index ON metrics(source_file_id);
@@ -0,0 +1,14 @@
+CREATE TABLE upload_audit
+(
+    id         SERIAL PRIMARY KEY,
+    upload_id  INT,
+    action     VARCHAR(50),
+    actor      VARCHAR(255),
+    reason     TEXT,
+    -- trades removed and metrics recomputed by the action
+    trades     INT,
+    metrics    INT,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX upload_audit_upload_id_index ON upload_audit(upload_id);
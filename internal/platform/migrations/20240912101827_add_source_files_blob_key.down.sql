@@ -0,0 +1,2 @@
+ALTER TABLE source_files DROP COLUMN IF EXISTS replay_of;
+ALTER TABLE source_files DROP COLUMN IF EXISTS blob_key;
@@ -0,0 +1,3 @@
+-- files uploaded before the blob store have no stored copy and cannot be replayed
+ALTER TABLE source_files ADD COLUMN blob_key VARCHAR(255);
+ALTER TABLE source_files ADD COLUMN replay_of INT;
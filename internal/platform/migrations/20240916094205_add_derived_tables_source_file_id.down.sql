@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS order_flow_source_file_id_index;
+DROP INDEX IF EXISTS auctions_source_file_id_index;
+DROP INDEX IF EXISTS block_trades_source_file_id_index;
+DROP INDEX IF EXISTS anomalies_source_file_id_index;
+
+ALTER TABLE order_flow DROP COLUMN IF EXISTS source_file_id;
+ALTER TABLE auctions DROP COLUMN IF EXISTS source_file_id;
+ALTER TABLE block_trades DROP COLUMN IF EXISTS source_file_id;
+ALTER TABLE anomalies DROP COLUMN IF EXISTS trade_id;
+ALTER TABLE anomalies DROP COLUMN IF EXISTS source_file_id;
@@ -0,0 +1,22 @@
+-- the rows derived from a file are rolled back with its trades, rows loaded before have no source file
+ALTER TABLE anomalies ADD COLUMN source_file_id INT;
+ALTER TABLE anomalies ADD COLUMN trade_id BIGINT;
+ALTER TABLE block_trades ADD COLUMN source_file_id INT;
+ALTER TABLE auctions ADD COLUMN source_file_id INT;
+ALTER TABLE order_flow ADD COLUMN source_file_id INT;
+
+-- the anomalies already flagged are linked to the trade they were flagged on
+UPDATE anomalies a
+SET source_file_id = t.source_file_id,
+    trade_id       = t.trade_id
+FROM trades t
+WHERE t.instrument_code = a.instrument_code
+  AND t.trade_date = a.trade_date
+  AND t.close_time = a.close_time
+  AND t.trade_price = a.trade_price
+  AND t.trade_quantity = a.trade_quantity;
+
+CREATE INDEX anomalies_source_file_id_index ON anomalies(source_file_id);
+CREATE INDEX block_trades_source_file_id_index ON block_trades(source_file_id);
+CREATE INDEX auctions_source_file_id_index ON auctions(source_file_id);
+CREATE INDEX order_flow_source_file_id_index ON order_flow(source_file_id);
@@ -0,0 +1 @@
+ALTER TABLE trade_sequences DROP COLUMN IF EXISTS out_of_step;
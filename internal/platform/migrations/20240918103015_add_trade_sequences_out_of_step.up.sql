@@ -0,0 +1,2 @@
+-- sequences checked before have no out of step count
+ALTER TABLE trade_sequences ADD COLUMN out_of_step INT;
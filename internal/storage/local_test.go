@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocalStore(t *testing.T) {
+	cases := []struct {
+		name    string
+		key     string
+		put     []string
+		want    string
+		wantErr error
+	}{
+		{
+			name: "success",
+			key:  "source-files/abc123",
+			put:  []string{"DataReferencia;CodigoInstrumento\n"},
+			want: "DataReferencia;CodigoInstrumento\n",
+		},
+		{
+			name: "success replacing blob",
+			key:  "source-files/abc123",
+			put:  []string{"first\n", "second\n"},
+			want: "second\n",
+		},
+		{
+			name:    "failed because blob not found",
+			key:     "source-files/abc123",
+			wantErr: ErrBlobNotFound,
+		},
+		{
+			name:    "failed because key outside the store",
+			key:     "../abc123",
+			wantErr: errors.New("invalid blob key \"../abc123\""),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			store, err := NewLocalStore(filepath.Join(dir, "uploads"))
+			require.NoError(t, err)
+
+			for _, content := range tc.put {
+				err = store.Put(context.Background(), tc.key, strings.NewReader(content), int64(len(content)))
+				require.NoError(t, err)
+			}
+
+			reader, err := store.Get(context.Background(), tc.key)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			defer reader.Close()
+
+			got, err := io.ReadAll(reader)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, string(got))
+
+			// only the blob is left in its directory
+			entries, err := os.ReadDir(filepath.Join(dir, "uploads", "source-files"))
+			require.NoError(t, err)
+			assert.Len(t, entries, 1)
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"os"
+	"quotation-metrics/internal/config"
+	"strings"
+	"testing"
+)
+
+// TestS3Store runs against an S3 compatible store such as the MinIO of the development compose, e.g.
+// BLOB_STORE_TEST_ENDPOINT=localhost:9000 go test ./internal/storage/
+func TestS3Store(t *testing.T) {
+	endpoint := os.Getenv("BLOB_STORE_TEST_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("BLOB_STORE_TEST_ENDPOINT is not set")
+	}
+
+	store, err := NewS3Store(context.Background(), config.Storage{
+		Driver:    config.StorageS3,
+		Endpoint:  endpoint,
+		Bucket:    "quotation-uploads-test",
+		AccessKey: getEnv("BLOB_STORE_TEST_ACCESS_KEY", "minioadmin"),
+		SecretKey: getEnv("BLOB_STORE_TEST_SECRET_KEY", "minioadmin"),
+	})
+	require.NoError(t, err)
+
+	t.Run("success", func(t *testing.T) {
+		content := "DataReferencia;CodigoInstrumento\n"
+		err := store.Put(context.Background(), "source-files/abc123", strings.NewReader(content), int64(len(content)))
+		require.NoError(t, err)
+
+		reader, err := store.Get(context.Background(), "source-files/abc123")
+		require.NoError(t, err)
+		defer reader.Close()
+
+		got, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Equal(t, content, string(got))
+	})
+
+	t.Run("failed because blob not found", func(t *testing.T) {
+		_, err := store.Get(context.Background(), "source-files/missing")
+		assert.Equal(t, ErrBlobNotFound, err)
+	})
+}
+
+func getEnv(key string, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
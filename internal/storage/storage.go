@@ -0,0 +1,32 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"quotation-metrics/internal/config"
+)
+
+// ErrBlobNotFound is returned when there is no blob stored with the key
+var ErrBlobNotFound = errors.New("blob not found")
+
+// BlobStore keeps the raw uploaded files so they can be read again to be reprocessed
+type BlobStore interface {
+	// Put stores the content of the reader with the key, replacing any blob stored with it
+	Put(ctx context.Context, key string, reader io.Reader, size int64) error
+	// Get opens the blob stored with the key, the caller closes it
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+}
+
+// New returns the blob store of the configured driver
+func New(ctx context.Context, cfg config.Storage) (BlobStore, error) {
+	switch cfg.Driver {
+	case config.StorageLocal:
+		return NewLocalStore(cfg.Path)
+	case config.StorageS3:
+		return NewS3Store(ctx, cfg)
+	default:
+		return nil, fmt.Errorf("invalid blob store %q", cfg.Driver)
+	}
+}
@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"quotation-metrics/internal/config"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	dir := t.TempDir()
+
+	cases := []struct {
+		name    string
+		cfg     config.Storage
+		want    BlobStore
+		wantErr error
+	}{
+		{
+			name: "success with local store",
+			cfg:  config.Storage{Driver: config.StorageLocal, Path: filepath.Join(dir, "uploads")},
+			want: &LocalStore{dir: filepath.Join(dir, "uploads")},
+		},
+		{
+			name:    "failed because invalid driver",
+			cfg:     config.Storage{Driver: "ftp"},
+			wantErr: errors.New("invalid blob store \"ftp\""),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := New(context.Background(), tc.cfg)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
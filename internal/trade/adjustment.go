@@ -0,0 +1,34 @@
+package trade
+
+import (
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+// adjustmentFactor returns the cumulative factor of the corporate actions with ex date after the date
+// Prices before an action are divided by the factor and volumes multiplied by it
+func adjustmentFactor(actions []*CorporateAction, date time.Time) decimal.Decimal {
+	factor := decimal.NewFromInt(1)
+	for _, action := range actions {
+		if action.ExDate.After(date) {
+			factor = factor.Mul(action.Factor)
+		}
+	}
+	return factor
+}
+
+// adjustDailyMetrics applies the corporate actions to the prices and volumes of the daily metrics
+func adjustDailyMetrics(metrics []*DailyMetric, actions []*CorporateAction) {
+	one := decimal.NewFromInt(1)
+
+	for _, metric := range metrics {
+		factor := adjustmentFactor(actions, metric.TradeDate)
+		if factor.Equal(one) {
+			continue
+		}
+
+		metric.MaxRangeValue = metric.MaxRangeValue.Div(factor).Round(4)
+		metric.ClosePrice = metric.ClosePrice.Div(factor).Round(4)
+		metric.MaxDailyVolume = int(decimal.NewFromInt(int64(metric.MaxDailyVolume)).Mul(factor).Round(0).IntPart())
+	}
+}
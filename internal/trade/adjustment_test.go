@@ -0,0 +1,51 @@
+package trade
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestAdjustmentFactor(t *testing.T) {
+	actions := []*CorporateAction{
+		{Ticker: "PETR4", ExDate: time.Date(2024, 4, 25, 0, 0, 0, 0, time.UTC), Type: ActionBonus, Factor: decimal.NewFromFloat(1.5)},
+		{Ticker: "PETR4", ExDate: time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC), Type: ActionSplit, Factor: decimal.NewFromInt(2)},
+	}
+
+	cases := []struct {
+		name string
+		date time.Time
+		want decimal.Decimal
+	}{
+		{name: "before both actions", date: time.Date(2024, 4, 24, 0, 0, 0, 0, time.UTC), want: decimal.NewFromInt(3)},
+		{name: "on the first ex date", date: time.Date(2024, 4, 25, 0, 0, 0, 0, time.UTC), want: decimal.NewFromInt(2)},
+		{name: "after both actions", date: time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC), want: decimal.NewFromInt(1)},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.True(t, tc.want.Equal(adjustmentFactor(actions, tc.date)), adjustmentFactor(actions, tc.date).String())
+		})
+	}
+}
+
+func TestAdjustDailyMetrics(t *testing.T) {
+	before := time.Date(2024, 5, 31, 0, 0, 0, 0, time.UTC)
+	after := time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC)
+
+	metrics := []*DailyMetric{
+		{Ticker: "PETR4", TradeDate: before, MaxRangeValue: decimal.NewFromInt(80), ClosePrice: decimal.NewFromInt(78), MaxDailyVolume: 1000},
+		{Ticker: "PETR4", TradeDate: after, MaxRangeValue: decimal.NewFromInt(40), ClosePrice: decimal.NewFromInt(39), MaxDailyVolume: 2100},
+	}
+	actions := []*CorporateAction{
+		{Ticker: "PETR4", ExDate: after, Type: ActionSplit, Factor: decimal.NewFromInt(2)},
+	}
+
+	adjustDailyMetrics(metrics, actions)
+
+	assert.Equal(t, []*DailyMetric{
+		{Ticker: "PETR4", TradeDate: before, MaxRangeValue: decimal.New(400000, -4), ClosePrice: decimal.New(390000, -4), MaxDailyVolume: 2000},
+		{Ticker: "PETR4", TradeDate: after, MaxRangeValue: decimal.NewFromInt(40), ClosePrice: decimal.NewFromInt(39), MaxDailyVolume: 2100},
+	}, metrics)
+}
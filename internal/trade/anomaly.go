@@ -0,0 +1,177 @@
+package trade
+
+import (
+	"container/heap"
+	"github.com/shopspring/decimal"
+	"quotation-metrics/internal/config"
+)
+
+const (
+	AnomalyPriceDeviation = "price_deviation"
+	AnomalyExtremeSize    = "extreme_size"
+)
+
+// anomalyDetector flags trades that deviate from the history of their ticker seen so far in the file
+// Until the file has MinSamples trades of the ticker they are checked against the stored baseline of the ticker
+type anomalyDetector struct {
+	cfg     config.Anomaly
+	tickers map[string]*tickerHistory
+}
+
+type tickerHistory struct {
+	prices   *runningMedian
+	quantity int
+	trades   int
+}
+
+func newAnomalyDetector(cfg config.Anomaly) *anomalyDetector {
+	return &anomalyDetector{
+		cfg:     cfg,
+		tickers: make(map[string]*tickerHistory),
+	}
+}
+
+// check compares the trade with the ticker history, or with the stored baseline while the history is short, and
+// records it afterwards. Flagged trades are kept out of the history so a single bad print does not move the reference
+func (d *anomalyDetector) check(trade *Trade, baseline *AnomalyBaseline) []*Anomaly {
+	history, ok := d.tickers[trade.InstrumentCode]
+	if !ok {
+		history = &tickerHistory{prices: newRunningMedian()}
+		d.tickers[trade.InstrumentCode] = history
+	}
+
+	var anomalies []*Anomaly
+
+	priceOutlier := false
+	if d.cfg.PriceDeviation > 0 {
+		reference := decimal.Zero
+		switch {
+		case history.prices.Len() >= d.cfg.MinSamples && history.prices.Len() > 0:
+			reference = history.prices.Median()
+		case baseline != nil:
+			reference = baseline.ClosePrice
+		}
+		if reference.IsPositive() {
+			deviation := trade.TradePrice.Sub(reference).Abs().Div(reference)
+			if deviation.GreaterThan(decimal.NewFromFloat(d.cfg.PriceDeviation)) {
+				priceOutlier = true
+				anomalies = append(anomalies, newAnomaly(trade, AnomalyPriceDeviation, reference))
+			}
+		}
+	}
+
+	sizeOutlier := false
+	if d.cfg.SizeMultiplier > 0 {
+		average := decimal.Zero
+		switch {
+		case history.trades >= d.cfg.MinSamples && history.trades > 0:
+			average = decimal.NewFromInt(int64(history.quantity)).Div(decimal.NewFromInt(int64(history.trades)))
+		case baseline != nil:
+			average = baseline.AverageQuantity
+		}
+		if average.IsPositive() && decimal.NewFromInt(int64(trade.TradeQuantity)).GreaterThan(average.Mul(decimal.NewFromFloat(d.cfg.SizeMultiplier))) {
+			sizeOutlier = true
+			anomalies = append(anomalies, newAnomaly(trade, AnomalyExtremeSize, average))
+		}
+	}
+
+	if !priceOutlier {
+		history.prices.Push(trade.TradePrice)
+	}
+	if !sizeOutlier {
+		history.quantity += trade.TradeQuantity
+		history.trades++
+	}
+
+	return anomalies
+}
+
+func newAnomaly(trade *Trade, reason string, reference decimal.Decimal) *Anomaly {
+	return &Anomaly{
+		InstrumentCode: trade.InstrumentCode,
+		TradePrice:     trade.TradePrice,
+		TradeQuantity:  trade.TradeQuantity,
+		CloseTime:      trade.CloseTime,
+		TradeDate:      trade.TradeDate,
+		Reason:         reason,
+		ReferenceValue: reference.Round(4),
+		TradeID:        trade.TradeID,
+	}
+}
+
+// hasReason reports whether any of the anomalies was flagged with the reason
+func hasReason(anomalies []*Anomaly, reason string) bool {
+	for _, anomaly := range anomalies {
+		if anomaly.Reason == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// runningMedian keeps the lower half of the values in a max heap and the upper half in a min heap
+type runningMedian struct {
+	lower decimalHeap
+	upper decimalHeap
+}
+
+func newRunningMedian() *runningMedian {
+	return &runningMedian{
+		lower: decimalHeap{max: true},
+	}
+}
+
+func (m *runningMedian) Len() int {
+	return m.lower.Len() + m.upper.Len()
+}
+
+func (m *runningMedian) Push(value decimal.Decimal) {
+	if m.lower.Len() == 0 || value.LessThanOrEqual(m.lower.values[0]) {
+		heap.Push(&m.lower, value)
+	} else {
+		heap.Push(&m.upper, value)
+	}
+
+	// rebalance so the lower half has the same size or one more value than the upper half
+	if m.lower.Len() > m.upper.Len()+1 {
+		heap.Push(&m.upper, heap.Pop(&m.lower))
+	} else if m.upper.Len() > m.lower.Len() {
+		heap.Push(&m.lower, heap.Pop(&m.upper))
+	}
+}
+
+func (m *runningMedian) Median() decimal.Decimal {
+	if m.lower.Len() == 0 {
+		return decimal.Zero
+	}
+	if m.lower.Len() > m.upper.Len() {
+		return m.lower.values[0]
+	}
+	return m.lower.values[0].Add(m.upper.values[0]).Div(decimal.NewFromInt(2))
+}
+
+type decimalHeap struct {
+	values []decimal.Decimal
+	max    bool
+}
+
+func (h decimalHeap) Len() int { return len(h.values) }
+
+func (h decimalHeap) Less(i, j int) bool {
+	if h.max {
+		return h.values[i].GreaterThan(h.values[j])
+	}
+	return h.values[i].LessThan(h.values[j])
+}
+
+func (h decimalHeap) Swap(i, j int) { h.values[i], h.values[j] = h.values[j], h.values[i] }
+
+func (h *decimalHeap) Push(x any) { h.values = append(h.values, x.(decimal.Decimal)) }
+
+func (h *decimalHeap) Pop() any {
+	old := h.values
+	n := len(old)
+	value := old[n-1]
+	h.values = old[:n-1]
+	return value
+}
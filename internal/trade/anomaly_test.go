@@ -0,0 +1,177 @@
+package trade
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"quotation-metrics/internal/config"
+	"testing"
+	"time"
+)
+
+func TestRunningMedian(t *testing.T) {
+	cases := []struct {
+		name   string
+		values []float64
+		want   decimal.Decimal
+	}{
+		{
+			name:   "empty",
+			values: nil,
+			want:   decimal.Zero,
+		},
+		{
+			name:   "odd number of values",
+			values: []float64{10, 1, 7, 3, 100},
+			want:   decimal.NewFromInt(7),
+		},
+		{
+			name:   "even number of values",
+			values: []float64{4, 1, 3, 2},
+			want:   decimal.NewFromFloat(2.5),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := newRunningMedian()
+			for _, v := range tc.values {
+				m.Push(decimal.NewFromFloat(v))
+			}
+
+			assert.True(t, tc.want.Equal(m.Median()), "want %s got %s", tc.want, m.Median())
+			assert.Equal(t, len(tc.values), m.Len())
+		})
+	}
+}
+
+func TestAnomalyDetector(t *testing.T) {
+	date := time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC)
+	history := []*Trade{
+		{InstrumentCode: "PETR4", TradePrice: decimal.NewFromFloat(38.0), TradeQuantity: 100, TradeDate: date},
+		{InstrumentCode: "PETR4", TradePrice: decimal.NewFromFloat(38.2), TradeQuantity: 200, TradeDate: date},
+		{InstrumentCode: "PETR4", TradePrice: decimal.NewFromFloat(37.9), TradeQuantity: 100, TradeDate: date},
+		{InstrumentCode: "PETR4", TradePrice: decimal.NewFromFloat(38.1), TradeQuantity: 200, TradeDate: date},
+	}
+
+	baseline := &AnomalyBaseline{Ticker: "PETR4", ClosePrice: decimal.NewFromFloat(38.3), AverageQuantity: decimal.NewFromInt(120)}
+
+	cases := []struct {
+		name     string
+		cfg      config.Anomaly
+		baseline *AnomalyBaseline
+		trade    *Trade
+		want     []*Anomaly
+	}{
+		{
+			name:  "normal trade",
+			cfg:   config.Anomaly{PriceDeviation: 0.1, SizeMultiplier: 10, MinSamples: 4},
+			trade: &Trade{InstrumentCode: "PETR4", TradePrice: decimal.NewFromFloat(38.5), TradeQuantity: 300, TradeDate: date},
+			want:  nil,
+		},
+		{
+			name:  "fat finger price",
+			cfg:   config.Anomaly{PriceDeviation: 0.1, SizeMultiplier: 10, MinSamples: 4},
+			trade: &Trade{InstrumentCode: "PETR4", TradePrice: decimal.NewFromFloat(380.5), TradeQuantity: 100, CloseTime: "100000000", TradeDate: date},
+			want: []*Anomaly{
+				{
+					InstrumentCode: "PETR4",
+					TradePrice:     decimal.NewFromFloat(380.5),
+					TradeQuantity:  100,
+					CloseTime:      "100000000",
+					TradeDate:      date,
+					Reason:         AnomalyPriceDeviation,
+					ReferenceValue: decimal.NewFromFloat(38.05),
+				},
+			},
+		},
+		{
+			name:  "extreme size",
+			cfg:   config.Anomaly{PriceDeviation: 0.1, SizeMultiplier: 10, MinSamples: 4},
+			trade: &Trade{InstrumentCode: "PETR4", TradePrice: decimal.NewFromFloat(38.0), TradeQuantity: 1600, CloseTime: "100000000", TradeDate: date},
+			want: []*Anomaly{
+				{
+					InstrumentCode: "PETR4",
+					TradePrice:     decimal.NewFromFloat(38.0),
+					TradeQuantity:  1600,
+					CloseTime:      "100000000",
+					TradeDate:      date,
+					Reason:         AnomalyExtremeSize,
+					ReferenceValue: decimal.NewFromInt(150),
+				},
+			},
+		},
+		{
+			name:  "not enough samples",
+			cfg:   config.Anomaly{PriceDeviation: 0.1, SizeMultiplier: 10, MinSamples: 5},
+			trade: &Trade{InstrumentCode: "PETR4", TradePrice: decimal.NewFromFloat(380.5), TradeQuantity: 1600, TradeDate: date},
+			want:  nil,
+		},
+		{
+			name:     "fat finger price against the stored close",
+			cfg:      config.Anomaly{PriceDeviation: 0.1, SizeMultiplier: 10, MinSamples: 5},
+			baseline: baseline,
+			trade:    &Trade{InstrumentCode: "PETR4", TradePrice: decimal.NewFromFloat(380.5), TradeQuantity: 100, CloseTime: "100000000", TradeDate: date},
+			want: []*Anomaly{
+				{
+					InstrumentCode: "PETR4",
+					TradePrice:     decimal.NewFromFloat(380.5),
+					TradeQuantity:  100,
+					CloseTime:      "100000000",
+					TradeDate:      date,
+					Reason:         AnomalyPriceDeviation,
+					ReferenceValue: decimal.NewFromFloat(38.3),
+				},
+			},
+		},
+		{
+			name:     "extreme size against the stored average",
+			cfg:      config.Anomaly{PriceDeviation: 0.1, SizeMultiplier: 10, MinSamples: 5},
+			baseline: baseline,
+			trade:    &Trade{InstrumentCode: "PETR4", TradePrice: decimal.NewFromFloat(38.0), TradeQuantity: 1300, CloseTime: "100000000", TradeDate: date},
+			want: []*Anomaly{
+				{
+					InstrumentCode: "PETR4",
+					TradePrice:     decimal.NewFromFloat(38.0),
+					TradeQuantity:  1300,
+					CloseTime:      "100000000",
+					TradeDate:      date,
+					Reason:         AnomalyExtremeSize,
+					ReferenceValue: decimal.NewFromInt(120),
+				},
+			},
+		},
+		{
+			// with enough samples in the file the stored average is no longer used
+			name:     "file history over the stored baseline",
+			cfg:      config.Anomaly{PriceDeviation: 0.1, SizeMultiplier: 10, MinSamples: 4},
+			baseline: baseline,
+			trade:    &Trade{InstrumentCode: "PETR4", TradePrice: decimal.NewFromFloat(38.0), TradeQuantity: 1300, TradeDate: date},
+			want:     nil,
+		},
+		{
+			name:  "disabled",
+			cfg:   config.Anomaly{},
+			trade: &Trade{InstrumentCode: "PETR4", TradePrice: decimal.NewFromFloat(380.5), TradeQuantity: 1600, TradeDate: date},
+			want:  nil,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := newAnomalyDetector(tc.cfg)
+			for _, trade := range history {
+				assert.Nil(t, d.check(trade, tc.baseline))
+			}
+
+			got := d.check(tc.trade, tc.baseline)
+			assert.Equal(t, len(tc.want), len(got))
+			for i := range tc.want {
+				assert.Equal(t, tc.want[i].Reason, got[i].Reason)
+				assert.Equal(t, tc.want[i].TradeQuantity, got[i].TradeQuantity)
+				assert.Equal(t, tc.want[i].CloseTime, got[i].CloseTime)
+				assert.True(t, tc.want[i].TradePrice.Equal(got[i].TradePrice))
+				assert.True(t, tc.want[i].ReferenceValue.Equal(got[i].ReferenceValue), "want %s got %s", tc.want[i].ReferenceValue, got[i].ReferenceValue)
+			}
+		})
+	}
+}
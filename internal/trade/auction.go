@@ -0,0 +1,93 @@
+package trade
+
+import (
+	"sort"
+
+	"quotation-metrics/internal/config"
+)
+
+// auctionTracker finds the opening and closing auction of each ticker and day of the regular session
+// The auction trades print together at the uncross, so the opening auction is the earliest instant inside the
+// opening window and the closing auction the latest instant inside the closing window
+type auctionTracker struct {
+	opening  window
+	closing  window
+	auctions map[string]*Auction
+}
+
+// window holds the bounds as HHMMSSmmm so they compare with the close time of the trades
+type window struct {
+	start string
+	end   string
+}
+
+func newAuctionTracker(cfg config.Auction) *auctionTracker {
+	return &auctionTracker{
+		opening:  newWindow(cfg.Opening),
+		closing:  newWindow(cfg.Closing),
+		auctions: make(map[string]*Auction),
+	}
+}
+
+func newWindow(w config.Window) window {
+	return window{
+		start: w.Start.Format("150405") + "000",
+		end:   w.End.Format("150405") + "000",
+	}
+}
+
+func (w window) contains(closeTime string) bool {
+	return closeTime >= w.start && closeTime < w.end
+}
+
+func (a *auctionTracker) track(trade *Trade) {
+	if trade.SessionType != SessionRegular {
+		return
+	}
+
+	if a.opening.contains(trade.CloseTime) {
+		a.update(trade, AuctionOpening, func(current string) bool { return trade.CloseTime < current })
+	}
+
+	if a.closing.contains(trade.CloseTime) {
+		a.update(trade, AuctionClosing, func(current string) bool { return trade.CloseTime > current })
+	}
+}
+
+// update restarts the auction when the trade printed before the current uncross and adds the trade when it printed with it
+func (a *auctionTracker) update(trade *Trade, auctionType string, replaces func(current string) bool) {
+	key := metricKey(trade) + "|" + auctionType
+	auction, ok := a.auctions[key]
+	if !ok || replaces(auction.CloseTime) {
+		a.auctions[key] = &Auction{
+			Ticker:    trade.InstrumentCode,
+			TradeDate: trade.TradeDate,
+			Type:      auctionType,
+			CloseTime: trade.CloseTime,
+			Price:     trade.TradePrice,
+			Volume:    trade.TradeQuantity,
+			Trades:    1,
+		}
+		return
+	}
+
+	if trade.CloseTime == auction.CloseTime {
+		auction.Volume += trade.TradeQuantity
+		auction.Trades++
+	}
+}
+
+// list returns the auctions ordered by key so the batch insert is deterministic
+func (a *auctionTracker) list() []*Auction {
+	keys := make([]string, 0, len(a.auctions))
+	for key := range a.auctions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	auctions := make([]*Auction, 0, len(keys))
+	for _, key := range keys {
+		auctions = append(auctions, a.auctions[key])
+	}
+	return auctions
+}
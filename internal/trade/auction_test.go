@@ -0,0 +1,63 @@
+package trade
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"quotation-metrics/internal/config"
+	"testing"
+	"time"
+)
+
+func TestAuctionTracker(t *testing.T) {
+	date := time.Date(2024, 6, 28, 0, 0, 0, 0, time.UTC)
+	clock := func(hour, minute int) time.Time {
+		return time.Date(0, 1, 1, hour, minute, 0, 0, time.UTC)
+	}
+	trade := func(ticker, closeTime string, price float64, quantity, session int) *Trade {
+		return &Trade{
+			InstrumentCode: ticker,
+			TradePrice:     decimal.NewFromFloat(price),
+			TradeQuantity:  quantity,
+			CloseTime:      closeTime,
+			TradeDate:      date,
+			SessionType:    session,
+		}
+	}
+
+	tracker := newAuctionTracker(config.Auction{
+		Opening: config.Window{Start: clock(10, 0), End: clock(10, 15)},
+		Closing: config.Window{Start: clock(16, 55), End: clock(17, 10)},
+	})
+
+	for _, tr := range []*Trade{
+		// continuous trading after the opening uncross, read before it in the file
+		trade("PETR4", "100012000", 38.6, 100, SessionRegular),
+		trade("PETR4", "100000120", 38.5, 1000, SessionRegular),
+		trade("PETR4", "100000120", 38.5, 500, SessionRegular),
+		trade("PETR4", "095959000", 38.4, 10, SessionRegular),
+		// continuous trading before the closing call is outside the window
+		trade("PETR4", "165459999", 38.9, 100, SessionRegular),
+		trade("PETR4", "170312345", 39.1, 2000, SessionRegular),
+		trade("PETR4", "170312345", 39.1, 3000, SessionRegular),
+		trade("PETR4", "170100000", 39.0, 10, SessionRegular),
+		// after market trades are not part of the auctions
+		trade("PETR4", "171000000", 39.2, 10, SessionAfterMarket),
+		trade("VALE3", "100000500", 60.1, 200, SessionRegular),
+	} {
+		tracker.track(tr)
+	}
+
+	got := tracker.list()
+
+	assert.Len(t, got, 3)
+	assert.Equal(t, &Auction{Ticker: "PETR4", TradeDate: date, Type: AuctionClosing, CloseTime: "170312345", Price: decimal.NewFromFloat(39.1), Volume: 5000, Trades: 2}, got[0])
+	assert.Equal(t, &Auction{Ticker: "PETR4", TradeDate: date, Type: AuctionOpening, CloseTime: "100000120", Price: decimal.NewFromFloat(38.5), Volume: 1500, Trades: 2}, got[1])
+	assert.Equal(t, &Auction{Ticker: "VALE3", TradeDate: date, Type: AuctionOpening, CloseTime: "100000500", Price: decimal.NewFromFloat(60.1), Volume: 200, Trades: 1}, got[2])
+}
+
+func TestAuctionTrackerWithoutWindows(t *testing.T) {
+	tracker := newAuctionTracker(config.Auction{})
+	tracker.track(&Trade{InstrumentCode: "PETR4", CloseTime: "100000000", SessionType: SessionRegular, TradeQuantity: 100})
+
+	assert.Empty(t, tracker.list())
+}
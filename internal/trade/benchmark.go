@@ -0,0 +1,77 @@
+package trade
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// MaxBenchmarkWindows bounds the windows of a single request
+const MaxBenchmarkWindows = 20
+
+// intervalBenchmark computes the VWAP and TWAP of the trades inside the window, the trades are ordered by time
+// For the TWAP each price holds until the next trade or the end of the window, the time before the first trade is left out
+func intervalBenchmark(trades []*Trade, window BenchmarkWindow) *IntervalBenchmark {
+	benchmark := &IntervalBenchmark{
+		From: window.From.Format("15:04:05"),
+		To:   window.To.Format("15:04:05"),
+	}
+
+	type tick struct {
+		clock time.Time
+		price decimal.Decimal
+	}
+
+	var ticks []tick
+	amount := decimal.Zero
+	for _, trade := range trades {
+		clock, ok := closeClock(trade.CloseTime)
+		if !ok || clock.Before(window.From) || !clock.Before(window.To) {
+			continue
+		}
+
+		benchmark.Trades++
+		benchmark.Volume += trade.TradeQuantity
+		amount = amount.Add(financialVolume(trade))
+		ticks = append(ticks, tick{clock: clock, price: trade.TradePrice})
+	}
+
+	if benchmark.Trades == 0 {
+		return benchmark
+	}
+
+	if benchmark.Volume > 0 {
+		benchmark.VWAP = amount.Div(decimal.NewFromInt(int64(benchmark.Volume))).Round(4)
+	}
+
+	weighted := decimal.Zero
+	var elapsed time.Duration
+	for i, tick := range ticks {
+		until := window.To
+		if i+1 < len(ticks) {
+			until = ticks[i+1].clock
+		}
+		duration := until.Sub(tick.clock)
+		weighted = weighted.Add(tick.price.Mul(decimal.NewFromInt(int64(duration / time.Millisecond))))
+		elapsed += duration
+	}
+
+	// the window end is exclusive, so the last price always holds for some time
+	benchmark.TWAP = weighted.Div(decimal.NewFromInt(int64(elapsed / time.Millisecond))).Round(4)
+
+	return benchmark
+}
+
+// closeClock reads the HHMMSSmmm close time as a time of day comparable with the window bounds
+func closeClock(closeTime string) (time.Time, bool) {
+	if len(closeTime) != 9 {
+		return time.Time{}, false
+	}
+
+	clock, err := time.Parse("150405.000", closeTime[:6]+"."+closeTime[6:])
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return clock, true
+}
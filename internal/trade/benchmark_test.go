@@ -0,0 +1,79 @@
+package trade
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestIntervalBenchmark(t *testing.T) {
+	trade := func(closeTime string, price float64, quantity int) *Trade {
+		return &Trade{
+			InstrumentCode: "PETR4",
+			TradePrice:     decimal.NewFromFloat(price),
+			TradeQuantity:  quantity,
+			CloseTime:      closeTime,
+		}
+	}
+	trades := []*Trade{
+		trade("095959999", 37, 1000),
+		trade("100000000", 38, 100),
+		trade("100000000", 38.2, 100),
+		trade("100030000", 38.6, 200),
+		trade("100100000", 50, 5),
+		trade("invalid", 1, 1),
+	}
+	window := func(from, to string) BenchmarkWindow {
+		fromTime, _ := time.Parse("15:04:05", from)
+		toTime, _ := time.Parse("15:04:05", to)
+		return BenchmarkWindow{From: fromTime, To: toTime}
+	}
+
+	cases := []struct {
+		name       string
+		window     BenchmarkWindow
+		wantTrades int
+		wantVolume int
+		wantVWAP   string
+		wantTWAP   string
+	}{
+		{
+			// 38.2 holds for 30s and 38.6 for 30s, the end of the window is exclusive
+			name:       "time weighted until the end of the window",
+			window:     window("10:00:00", "10:01:00"),
+			wantTrades: 3,
+			wantVolume: 400,
+			wantVWAP:   "38.35",
+			wantTWAP:   "38.4",
+		},
+		{
+			name:       "trade on the start of the window",
+			window:     window("09:59:59", "10:00:00"),
+			wantTrades: 1,
+			wantVolume: 1000,
+			wantVWAP:   "37",
+			wantTWAP:   "37",
+		},
+		{
+			name:       "window without trades",
+			window:     window("11:00:00", "12:00:00"),
+			wantTrades: 0,
+			wantVolume: 0,
+			wantVWAP:   "0",
+			wantTWAP:   "0",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := intervalBenchmark(trades, tc.window)
+
+			assert.Equal(t, tc.window.From.Format("15:04:05"), got.From)
+			assert.Equal(t, tc.wantTrades, got.Trades)
+			assert.Equal(t, tc.wantVolume, got.Volume)
+			assert.Equal(t, tc.wantVWAP, got.VWAP.String())
+			assert.Equal(t, tc.wantTWAP, got.TWAP.String())
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package trade
+
+import (
+	"github.com/shopspring/decimal"
+	"quotation-metrics/internal/config"
+)
+
+const (
+	BlockCriterionAbsolute        = "absolute"
+	BlockCriterionAverageMultiple = "average_multiple"
+)
+
+// blockClassifier marks trades above the ticker size threshold as block trades
+// The threshold is the configured absolute quantity or a multiple of the ticker average trade size seen so far in the file
+type blockClassifier struct {
+	cfg     config.Block
+	tickers map[string]*sizeHistory
+}
+
+type sizeHistory struct {
+	quantity int
+	trades   int
+}
+
+func newBlockClassifier(cfg config.Block) *blockClassifier {
+	return &blockClassifier{
+		cfg:     cfg,
+		tickers: make(map[string]*sizeHistory),
+	}
+}
+
+func (c *blockClassifier) classify(trade *Trade) *BlockTrade {
+	history, ok := c.tickers[trade.InstrumentCode]
+	if !ok {
+		history = &sizeHistory{}
+		c.tickers[trade.InstrumentCode] = history
+	}
+	defer func() {
+		history.quantity += trade.TradeQuantity
+		history.trades++
+	}()
+
+	threshold, ok := c.cfg.Thresholds[trade.InstrumentCode]
+	if !ok {
+		threshold = c.cfg.MinQuantity
+	}
+	if threshold > 0 && trade.TradeQuantity >= threshold {
+		return newBlockTrade(trade, BlockCriterionAbsolute)
+	}
+
+	if c.cfg.SizeMultiple > 0 && history.trades > 0 && history.trades >= c.cfg.MinSamples {
+		average := decimal.NewFromInt(int64(history.quantity)).Div(decimal.NewFromInt(int64(history.trades)))
+		if decimal.NewFromInt(int64(trade.TradeQuantity)).GreaterThanOrEqual(average.Mul(decimal.NewFromFloat(c.cfg.SizeMultiple))) {
+			return newBlockTrade(trade, BlockCriterionAverageMultiple)
+		}
+	}
+
+	return nil
+}
+
+func newBlockTrade(trade *Trade, criterion string) *BlockTrade {
+	return &BlockTrade{
+		InstrumentCode: trade.InstrumentCode,
+		TradePrice:     trade.TradePrice,
+		TradeQuantity:  trade.TradeQuantity,
+		CloseTime:      trade.CloseTime,
+		TradeDate:      trade.TradeDate,
+		BuyerCode:      trade.BuyerCode,
+		SellerCode:     trade.SellerCode,
+		Criterion:      criterion,
+	}
+}
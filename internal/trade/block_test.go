@@ -0,0 +1,70 @@
+package trade
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"quotation-metrics/internal/config"
+	"testing"
+	"time"
+)
+
+func TestBlockClassifier(t *testing.T) {
+	date := time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC)
+	history := []*Trade{
+		{InstrumentCode: "PETR4", TradePrice: decimal.NewFromFloat(38.0), TradeQuantity: 100, TradeDate: date},
+		{InstrumentCode: "PETR4", TradePrice: decimal.NewFromFloat(38.2), TradeQuantity: 300, TradeDate: date},
+	}
+
+	cases := []struct {
+		name  string
+		cfg   config.Block
+		trade *Trade
+		want  *BlockTrade
+	}{
+		{
+			name:  "regular trade",
+			cfg:   config.Block{MinQuantity: 10000, SizeMultiple: 10, MinSamples: 2},
+			trade: &Trade{InstrumentCode: "PETR4", TradePrice: decimal.NewFromFloat(38.1), TradeQuantity: 500, TradeDate: date},
+			want:  nil,
+		},
+		{
+			name: "above absolute threshold",
+			cfg:  config.Block{MinQuantity: 10000, MinSamples: 2},
+			trade: &Trade{InstrumentCode: "PETR4", TradePrice: decimal.NewFromFloat(38.1), TradeQuantity: 10000, CloseTime: "103000000",
+				TradeDate: date, BuyerCode: "3", SellerCode: "72"},
+			want: &BlockTrade{InstrumentCode: "PETR4", TradePrice: decimal.NewFromFloat(38.1), TradeQuantity: 10000, CloseTime: "103000000",
+				TradeDate: date, BuyerCode: "3", SellerCode: "72", Criterion: BlockCriterionAbsolute},
+		},
+		{
+			name:  "below ticker threshold",
+			cfg:   config.Block{MinQuantity: 10000, MinSamples: 2, Thresholds: map[string]int{"PETR4": 50000}},
+			trade: &Trade{InstrumentCode: "PETR4", TradePrice: decimal.NewFromFloat(38.1), TradeQuantity: 10000, TradeDate: date},
+			want:  nil,
+		},
+		{
+			name: "above average multiple",
+			cfg:  config.Block{SizeMultiple: 10, MinSamples: 2},
+			trade: &Trade{InstrumentCode: "PETR4", TradePrice: decimal.NewFromFloat(38.1), TradeQuantity: 2000, CloseTime: "103000000",
+				TradeDate: date, BuyerCode: "3", SellerCode: "72"},
+			want: &BlockTrade{InstrumentCode: "PETR4", TradePrice: decimal.NewFromFloat(38.1), TradeQuantity: 2000, CloseTime: "103000000",
+				TradeDate: date, BuyerCode: "3", SellerCode: "72", Criterion: BlockCriterionAverageMultiple},
+		},
+		{
+			name:  "not enough samples for average multiple",
+			cfg:   config.Block{SizeMultiple: 10, MinSamples: 3},
+			trade: &Trade{InstrumentCode: "PETR4", TradePrice: decimal.NewFromFloat(38.1), TradeQuantity: 2000, TradeDate: date},
+			want:  nil,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := newBlockClassifier(tc.cfg)
+			for _, trade := range history {
+				assert.Nil(t, c.classify(trade))
+			}
+
+			assert.Equal(t, tc.want, c.classify(tc.trade))
+		})
+	}
+}
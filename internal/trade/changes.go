@@ -0,0 +1,54 @@
+package trade
+
+import (
+	"github.com/shopspring/decimal"
+)
+
+// AverageVolumeDays is the number of days before the latest one averaged to compare its volume
+const AverageVolumeDays = 20
+
+// metricChanges compares the latest daily metric with the previous day and the average volume of the days before it
+// The daily metrics are ordered by date, nil is returned when there is no previous day to compare with
+func metricChanges(daily []*DailyMetric) *MetricChanges {
+	if len(daily) < 2 {
+		return nil
+	}
+
+	latest := daily[len(daily)-1]
+	previous := daily[len(daily)-2]
+	hundred := decimal.NewFromInt(100)
+
+	changes := &MetricChanges{
+		TradeDate:      latest.TradeDate,
+		ClosePrice:     latest.ClosePrice,
+		PreviousDate:   previous.TradeDate,
+		PreviousClose:  previous.ClosePrice,
+		Change:         latest.ClosePrice.Sub(previous.ClosePrice),
+		Volume:         latest.MaxDailyVolume,
+		PreviousVolume: previous.MaxDailyVolume,
+		VolumeChange:   latest.MaxDailyVolume - previous.MaxDailyVolume,
+	}
+
+	if previous.ClosePrice.IsPositive() {
+		changes.ChangePercent = changes.Change.Div(previous.ClosePrice).Mul(hundred).Round(2)
+	}
+
+	// the latest day is left out of its own average
+	window := daily[:len(daily)-1]
+	if len(window) > AverageVolumeDays {
+		window = window[len(window)-AverageVolumeDays:]
+	}
+
+	total := 0
+	for _, day := range window {
+		total += day.MaxDailyVolume
+	}
+	average := decimal.NewFromInt(int64(total)).Div(decimal.NewFromInt(int64(len(window))))
+	changes.AverageVolume = average.Round(2)
+
+	if average.IsPositive() {
+		changes.VolumeVsAverage = decimal.NewFromInt(int64(latest.MaxDailyVolume)).Div(average).Round(2)
+	}
+
+	return changes
+}
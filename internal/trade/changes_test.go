@@ -0,0 +1,91 @@
+package trade
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestMetricChanges(t *testing.T) {
+	day := func(d int, close int64, volume int) *DailyMetric {
+		return &DailyMetric{
+			Ticker:         "PETR4",
+			TradeDate:      time.Date(2024, 6, d, 0, 0, 0, 0, time.UTC),
+			ClosePrice:     decimal.NewFromInt(close),
+			MaxDailyVolume: volume,
+		}
+	}
+
+	// 21 days before the latest one, only the last 20 are averaged
+	long := []*DailyMetric{day(1, 10, 100000)}
+	for d := 2; d <= 21; d++ {
+		long = append(long, day(d, 10, 1000))
+	}
+	long = append(long, day(22, 11, 3000))
+
+	cases := []struct {
+		name  string
+		daily []*DailyMetric
+		want  *MetricChanges
+	}{
+		{
+			name:  "without previous day",
+			daily: []*DailyMetric{day(28, 38, 1000)},
+			want:  nil,
+		},
+		{
+			name:  "with previous day",
+			daily: []*DailyMetric{day(26, 40, 2000), day(27, 40, 1000), day(28, 38, 1500)},
+			want: &MetricChanges{
+				TradeDate:       time.Date(2024, 6, 28, 0, 0, 0, 0, time.UTC),
+				ClosePrice:      decimal.NewFromInt(38),
+				PreviousDate:    time.Date(2024, 6, 27, 0, 0, 0, 0, time.UTC),
+				PreviousClose:   decimal.NewFromInt(40),
+				Change:          decimal.NewFromInt(-2),
+				ChangePercent:   decimal.New(-500, -2),
+				Volume:          1500,
+				PreviousVolume:  1000,
+				VolumeChange:    500,
+				AverageVolume:   decimal.New(150000, -2),
+				VolumeVsAverage: decimal.New(100, -2),
+			},
+		},
+		{
+			name:  "average limited to twenty days",
+			daily: long,
+			want: &MetricChanges{
+				TradeDate:       time.Date(2024, 6, 22, 0, 0, 0, 0, time.UTC),
+				ClosePrice:      decimal.NewFromInt(11),
+				PreviousDate:    time.Date(2024, 6, 21, 0, 0, 0, 0, time.UTC),
+				PreviousClose:   decimal.NewFromInt(10),
+				Change:          decimal.NewFromInt(1),
+				ChangePercent:   decimal.New(1000, -2),
+				Volume:          3000,
+				PreviousVolume:  1000,
+				VolumeChange:    2000,
+				AverageVolume:   decimal.New(100000, -2),
+				VolumeVsAverage: decimal.New(300, -2),
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, metricChanges(tc.daily))
+		})
+	}
+}
+
+func TestMetricChangesWithoutPreviousClose(t *testing.T) {
+	daily := []*DailyMetric{
+		{Ticker: "PETR4", TradeDate: time.Date(2024, 6, 27, 0, 0, 0, 0, time.UTC), ClosePrice: decimal.Zero},
+		{Ticker: "PETR4", TradeDate: time.Date(2024, 6, 28, 0, 0, 0, 0, time.UTC), ClosePrice: decimal.NewFromInt(38), MaxDailyVolume: 1500},
+	}
+
+	got := metricChanges(daily)
+	assert.Equal(t, "38", got.Change.String())
+	assert.True(t, got.ChangePercent.IsZero())
+	assert.True(t, got.VolumeVsAverage.IsZero())
+	assert.Equal(t, 1500, got.VolumeChange)
+}
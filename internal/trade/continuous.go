@@ -0,0 +1,183 @@
+package trade
+
+import (
+	"github.com/shopspring/decimal"
+	"quotation-metrics/internal/calendar"
+	"quotation-metrics/internal/instrument"
+	"time"
+)
+
+// contractSession holds the contracts traded on a date
+type contractSession struct {
+	date      time.Time
+	contracts map[string]*ContractDay
+}
+
+// rollover records the closes of both contracts on the first date of the new contract
+type rollover struct {
+	oldClose decimal.Decimal
+	newClose decimal.Decimal
+}
+
+// continuousSeries stitches the daily closes of the contracts into a single series following the rollover rule
+// Back adjustment keeps the latest contract prices and shifts the history at every rollover
+func continuousSeries(days []*ContractDay, filter ContinuousFilter, cal *calendar.Calendar) []*ContinuousPoint {
+	points := make([]*ContinuousPoint, 0)
+	rollovers := make(map[int]rollover)
+	lastClose := make(map[string]decimal.Decimal)
+
+	var current string
+	for _, session := range groupSessions(days) {
+		next := selectContract(session, current, filter, cal)
+		if next == "" {
+			continue
+		}
+
+		day := session.contracts[next]
+		point := &ContinuousPoint{
+			TradeDate:     session.date,
+			Ticker:        next,
+			ClosePrice:    day.ClosePrice,
+			AdjustedPrice: day.ClosePrice,
+			Volume:        day.Volume,
+		}
+
+		if current != "" && next != current {
+			oldClose, ok := lastClose[current]
+			if old, traded := session.contracts[current]; traded {
+				oldClose, ok = old.ClosePrice, true
+			}
+			if ok {
+				rollovers[len(points)] = rollover{oldClose: oldClose, newClose: day.ClosePrice}
+			}
+			point.Rolled = true
+		}
+
+		current = next
+		points = append(points, point)
+
+		for ticker, contract := range session.contracts {
+			lastClose[ticker] = contract.ClosePrice
+		}
+	}
+
+	adjust(points, rollovers, filter.Adjust)
+
+	return points
+}
+
+// selectContract returns the contract followed on the session, or an empty string to skip the session
+func selectContract(session contractSession, current string, filter ContinuousFilter, cal *calendar.Calendar) string {
+	var currentExpiry time.Time
+	if current != "" {
+		future, _ := instrument.ParseFuture(current)
+		currentExpiry = future.Expiry()
+	}
+
+	switch filter.Roll {
+	case RollExpiry:
+		// keep the current contract until its roll date, waiting for it when it did not trade
+		if current != "" && session.date.Before(rollDate(cal, currentExpiry, filter.RollDays)) {
+			if _, ok := session.contracts[current]; ok {
+				return current
+			}
+			return ""
+		}
+
+		// follow the contract with the nearest expiry that did not reach its roll date
+		var next string
+		var nextExpiry time.Time
+		for ticker := range session.contracts {
+			future, ok := instrument.ParseFuture(ticker)
+			if !ok {
+				continue
+			}
+			expiry := future.Expiry()
+			if !session.date.Before(rollDate(cal, expiry, filter.RollDays)) || expiry.Before(currentExpiry) {
+				continue
+			}
+			if next == "" || expiry.Before(nextExpiry) || (expiry.Equal(nextExpiry) && ticker < next) {
+				next, nextExpiry = ticker, expiry
+			}
+		}
+		return next
+	default:
+		currentDay, traded := session.contracts[current]
+		if current != "" && !traded && !session.date.After(currentExpiry) {
+			return ""
+		}
+
+		// follow the most traded contract expiring after the current one, the current contract wins ties
+		next := ""
+		volume := -1
+		if traded {
+			next, volume = current, currentDay.Volume
+		}
+		for ticker, day := range session.contracts {
+			future, ok := instrument.ParseFuture(ticker)
+			if !ok {
+				continue
+			}
+			expiry := future.Expiry()
+			if expiry.Before(session.date) || !expiry.After(currentExpiry) {
+				continue
+			}
+			if day.Volume > volume || (day.Volume == volume && next != current && ticker < next) {
+				next, volume = ticker, day.Volume
+			}
+		}
+		return next
+	}
+}
+
+// adjust back adjusts the prices before every rollover, walking from the latest point to the first
+func adjust(points []*ContinuousPoint, rollovers map[int]rollover, method string) {
+	one := decimal.NewFromInt(1)
+	difference := decimal.Zero
+	ratio := one
+
+	for i := len(points) - 1; i >= 0; i-- {
+		switch method {
+		case AdjustDifference:
+			points[i].AdjustedPrice = points[i].ClosePrice.Add(difference)
+		case AdjustRatio:
+			// points after the last rollover keep the contract price as it is
+			if !ratio.Equal(one) {
+				points[i].AdjustedPrice = points[i].ClosePrice.Mul(ratio).Round(4)
+			}
+		}
+
+		roll, ok := rollovers[i]
+		if !ok {
+			continue
+		}
+		difference = difference.Add(roll.newClose.Sub(roll.oldClose))
+		if roll.oldClose.IsPositive() {
+			ratio = ratio.Mul(roll.newClose.Div(roll.oldClose))
+		}
+	}
+}
+
+// groupSessions groups the contract days ordered by date into one session per date
+func groupSessions(days []*ContractDay) []contractSession {
+	sessions := make([]contractSession, 0)
+	for _, day := range days {
+		if len(sessions) == 0 || !sessions[len(sessions)-1].date.Equal(day.TradeDate) {
+			sessions = append(sessions, contractSession{
+				date:      day.TradeDate,
+				contracts: make(map[string]*ContractDay),
+			})
+		}
+		sessions[len(sessions)-1].contracts[day.Ticker] = day
+	}
+	return sessions
+}
+
+// rollDate moves the expiry back by the number of trading days
+func rollDate(cal *calendar.Calendar, expiry time.Time, days int) time.Time {
+	date := expiry
+	for ; days > 0; days-- {
+		date = cal.Previous(date)
+	}
+	return date
+}
@@ -0,0 +1,108 @@
+package trade
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"quotation-metrics/internal/calendar"
+	"testing"
+	"time"
+)
+
+func TestContinuousSeries(t *testing.T) {
+	aug7 := time.Date(2024, 8, 7, 0, 0, 0, 0, time.UTC)
+	aug8 := time.Date(2024, 8, 8, 0, 0, 0, 0, time.UTC)
+	aug9 := time.Date(2024, 8, 9, 0, 0, 0, 0, time.UTC)
+
+	days := []*ContractDay{
+		{Ticker: "WINQ24", TradeDate: aug7, ClosePrice: decimal.NewFromInt(130000), Volume: 1000},
+		{Ticker: "WINV24", TradeDate: aug7, ClosePrice: decimal.NewFromInt(131500), Volume: 100},
+		{Ticker: "WINQ24", TradeDate: aug8, ClosePrice: decimal.NewFromInt(130200), Volume: 800},
+		{Ticker: "WINV24", TradeDate: aug8, ClosePrice: decimal.NewFromInt(131700), Volume: 900},
+		{Ticker: "WINQ24", TradeDate: aug9, ClosePrice: decimal.NewFromInt(130100), Volume: 300},
+		{Ticker: "WINV24", TradeDate: aug9, ClosePrice: decimal.NewFromInt(131900), Volume: 1200},
+	}
+
+	cases := []struct {
+		name   string
+		days   []*ContractDay
+		filter ContinuousFilter
+		want   []*ContinuousPoint
+	}{
+		{
+			name:   "volume crossover without adjustment",
+			days:   days,
+			filter: ContinuousFilter{Roll: RollVolume, Adjust: AdjustNone},
+			want: []*ContinuousPoint{
+				{TradeDate: aug7, Ticker: "WINQ24", ClosePrice: decimal.NewFromInt(130000), AdjustedPrice: decimal.NewFromInt(130000), Volume: 1000},
+				{TradeDate: aug8, Ticker: "WINV24", ClosePrice: decimal.NewFromInt(131700), AdjustedPrice: decimal.NewFromInt(131700), Volume: 900, Rolled: true},
+				{TradeDate: aug9, Ticker: "WINV24", ClosePrice: decimal.NewFromInt(131900), AdjustedPrice: decimal.NewFromInt(131900), Volume: 1200},
+			},
+		},
+		{
+			name:   "volume crossover with difference adjustment",
+			days:   days,
+			filter: ContinuousFilter{Roll: RollVolume, Adjust: AdjustDifference},
+			want: []*ContinuousPoint{
+				{TradeDate: aug7, Ticker: "WINQ24", ClosePrice: decimal.NewFromInt(130000), AdjustedPrice: decimal.NewFromInt(131500), Volume: 1000},
+				{TradeDate: aug8, Ticker: "WINV24", ClosePrice: decimal.NewFromInt(131700), AdjustedPrice: decimal.NewFromInt(131700), Volume: 900, Rolled: true},
+				{TradeDate: aug9, Ticker: "WINV24", ClosePrice: decimal.NewFromInt(131900), AdjustedPrice: decimal.NewFromInt(131900), Volume: 1200},
+			},
+		},
+		{
+			name:   "volume crossover with ratio adjustment",
+			days:   days,
+			filter: ContinuousFilter{Roll: RollVolume, Adjust: AdjustRatio},
+			want: []*ContinuousPoint{
+				{TradeDate: aug7, Ticker: "WINQ24", ClosePrice: decimal.NewFromInt(130000), AdjustedPrice: decimal.RequireFromString("131497.6959"), Volume: 1000},
+				{TradeDate: aug8, Ticker: "WINV24", ClosePrice: decimal.NewFromInt(131700), AdjustedPrice: decimal.NewFromInt(131700), Volume: 900, Rolled: true},
+				{TradeDate: aug9, Ticker: "WINV24", ClosePrice: decimal.NewFromInt(131900), AdjustedPrice: decimal.NewFromInt(131900), Volume: 1200},
+			},
+		},
+		{
+			name:   "days before expiry with difference adjustment",
+			days:   days,
+			filter: ContinuousFilter{Roll: RollExpiry, RollDays: 3, Adjust: AdjustDifference},
+			want: []*ContinuousPoint{
+				{TradeDate: aug7, Ticker: "WINQ24", ClosePrice: decimal.NewFromInt(130000), AdjustedPrice: decimal.NewFromInt(131800), Volume: 1000},
+				{TradeDate: aug8, Ticker: "WINQ24", ClosePrice: decimal.NewFromInt(130200), AdjustedPrice: decimal.NewFromInt(132000), Volume: 800},
+				{TradeDate: aug9, Ticker: "WINV24", ClosePrice: decimal.NewFromInt(131900), AdjustedPrice: decimal.NewFromInt(131900), Volume: 1200, Rolled: true},
+			},
+		},
+		{
+			name: "current contract without trades is skipped before the roll date",
+			days: []*ContractDay{
+				{Ticker: "WINQ24", TradeDate: aug7, ClosePrice: decimal.NewFromInt(130000), Volume: 1000},
+				{Ticker: "WINV24", TradeDate: aug8, ClosePrice: decimal.NewFromInt(131700), Volume: 900},
+			},
+			filter: ContinuousFilter{Roll: RollExpiry, RollDays: 3, Adjust: AdjustNone},
+			want: []*ContinuousPoint{
+				{TradeDate: aug7, Ticker: "WINQ24", ClosePrice: decimal.NewFromInt(130000), AdjustedPrice: decimal.NewFromInt(130000), Volume: 1000},
+			},
+		},
+		{
+			name:   "empty series",
+			days:   []*ContractDay{},
+			filter: ContinuousFilter{Roll: RollVolume, Adjust: AdjustNone},
+			want:   []*ContinuousPoint{},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, continuousSeries(tc.days, tc.filter, calendar.New(nil)))
+		})
+	}
+}
+
+func TestRollDate(t *testing.T) {
+	cal := calendar.New(nil)
+	expiry := time.Date(2024, 8, 14, 0, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, expiry, rollDate(cal, expiry, 0))
+	assert.Equal(t, time.Date(2024, 8, 9, 0, 0, 0, 0, time.UTC), rollDate(cal, expiry, 3))
+	assert.Equal(t, time.Date(2024, 8, 7, 0, 0, 0, 0, time.UTC), rollDate(cal, expiry, 5))
+
+	// the holiday on 2024-11-20 is skipped
+	holidayExpiry := time.Date(2024, 11, 21, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2024, 11, 19, 0, 0, 0, 0, time.UTC), rollDate(cal, holidayExpiry, 1))
+}
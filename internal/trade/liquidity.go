@@ -0,0 +1,70 @@
+package trade
+
+import (
+	"math"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// amihudScale expresses the Amihud illiquidity as the absolute return per million of financial volume
+var amihudScale = decimal.NewFromInt(1_000_000)
+
+// liquidityMeasures computes the liquidity of the ticker from its daily volumes over the trading days of the period
+// The days without trades count as zero volume in the average financial volume
+func liquidityMeasures(liquidity *Liquidity, daily []*DailyLiquidity, tradingDays []time.Time) {
+	traded := make(map[time.Time]bool, len(daily))
+	total := decimal.Zero
+	for _, day := range daily {
+		if day.Volume > 0 {
+			traded[day.TradeDate] = true
+		}
+		total = total.Add(day.FinancialVolume)
+	}
+
+	liquidity.TradingDays = len(tradingDays)
+	for _, day := range tradingDays {
+		if !traded[day] {
+			liquidity.ZeroVolumeDays++
+		}
+	}
+
+	if len(tradingDays) > 0 {
+		liquidity.AverageFinancialVolume = total.Div(decimal.NewFromInt(int64(len(tradingDays)))).Round(2)
+	}
+
+	liquidity.AmihudIlliquidity = amihudIlliquidity(daily)
+}
+
+// amihudIlliquidity averages the absolute close to close return over the financial volume of the day
+// The returns are taken between consecutive days the ticker traded
+func amihudIlliquidity(daily []*DailyLiquidity) decimal.Decimal {
+	sum := decimal.Zero
+	count := 0
+	for i := 1; i < len(daily); i++ {
+		previous, current := daily[i-1], daily[i]
+		if !previous.ClosePrice.IsPositive() || !current.FinancialVolume.IsPositive() {
+			continue
+		}
+
+		ret := current.ClosePrice.Div(previous.ClosePrice).Sub(decimal.NewFromInt(1)).Abs()
+		sum = sum.Add(ret.Div(current.FinancialVolume))
+		count++
+	}
+
+	if count == 0 {
+		return decimal.Zero
+	}
+
+	return sum.Div(decimal.NewFromInt(int64(count))).Mul(amihudScale).Round(6)
+}
+
+// rollSpread estimates the effective spread from the serial covariance of the trade price changes as 2 * sqrt(-cov)
+// A non negative covariance leaves the spread undefined and zero is returned
+func rollSpread(covariance decimal.Decimal) decimal.Decimal {
+	if !covariance.IsNegative() {
+		return decimal.Zero
+	}
+
+	return decimal.NewFromFloat(2 * math.Sqrt(-covariance.InexactFloat64())).Round(4)
+}
@@ -0,0 +1,97 @@
+package trade
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestLiquidityMeasures(t *testing.T) {
+	day := func(d int, close int64, volume int, financialVolume int64) *DailyLiquidity {
+		return &DailyLiquidity{
+			TradeDate:       time.Date(2024, 7, d, 0, 0, 0, 0, time.UTC),
+			ClosePrice:      decimal.NewFromInt(close),
+			Volume:          volume,
+			FinancialVolume: decimal.NewFromInt(financialVolume),
+		}
+	}
+	tradingDays := func(days ...int) []time.Time {
+		dates := make([]time.Time, 0, len(days))
+		for _, d := range days {
+			dates = append(dates, time.Date(2024, 7, d, 0, 0, 0, 0, time.UTC))
+		}
+		return dates
+	}
+
+	cases := []struct {
+		name            string
+		daily           []*DailyLiquidity
+		tradingDays     []time.Time
+		wantZero        int
+		wantAverage     string
+		wantIlliquidity string
+	}{
+		{
+			name: "returns between consecutive traded days",
+			daily: []*DailyLiquidity{
+				day(8, 10, 100, 1000),
+				day(9, 11, 100, 1100),
+				day(11, 11, 200, 2200),
+			},
+			tradingDays:     tradingDays(8, 9, 10, 11),
+			wantZero:        1,
+			wantAverage:     "1075",
+			wantIlliquidity: "45.454545",
+		},
+		{
+			name: "days without financial volume are skipped",
+			daily: []*DailyLiquidity{
+				day(8, 10, 100, 1000),
+				day(9, 12, 0, 0),
+			},
+			tradingDays:     tradingDays(8, 9),
+			wantZero:        1,
+			wantAverage:     "500",
+			wantIlliquidity: "0",
+		},
+		{
+			name:            "no trading days",
+			daily:           []*DailyLiquidity{},
+			tradingDays:     []time.Time{},
+			wantZero:        0,
+			wantAverage:     "0",
+			wantIlliquidity: "0",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			liquidity := &Liquidity{}
+			liquidityMeasures(liquidity, tc.daily, tc.tradingDays)
+
+			assert.Equal(t, len(tc.tradingDays), liquidity.TradingDays)
+			assert.Equal(t, tc.wantZero, liquidity.ZeroVolumeDays)
+			assert.Equal(t, tc.wantAverage, liquidity.AverageFinancialVolume.String())
+			assert.Equal(t, tc.wantIlliquidity, liquidity.AmihudIlliquidity.String())
+		})
+	}
+}
+
+func TestRollSpread(t *testing.T) {
+	cases := []struct {
+		name       string
+		covariance decimal.Decimal
+		want       string
+	}{
+		{name: "negative covariance", covariance: decimal.New(-25, -4), want: "0.1"},
+		{name: "zero covariance", covariance: decimal.Zero, want: "0"},
+		{name: "positive covariance", covariance: decimal.New(1, -2), want: "0"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, rollSpread(tc.covariance).String())
+		})
+	}
+}
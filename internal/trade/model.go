@@ -2,16 +2,40 @@ package trade
 
 import (
 	"github.com/shopspring/decimal"
+	"quotation-metrics/internal/config"
+	"quotation-metrics/internal/instrument"
 	"time"
 )
 
 type Trade struct {
-	ID             int             `json:"-"`
+	ID             int             `json:"id"`
 	InstrumentCode string          `json:"instrument_code"`
 	TradePrice     decimal.Decimal `json:"trade_price"`
 	TradeQuantity  int             `json:"trade_quantity"`
 	CloseTime      string          `json:"close_time"`
 	TradeDate      time.Time       `json:"trade_date"`
+	// TradedAt is the instant of the trade, the close time is local to the exchange timezone
+	TradedAt    time.Time `json:"traded_at"`
+	BuyerCode   string    `json:"buyer_code"`
+	SellerCode  string    `json:"seller_code"`
+	SessionType int       `json:"session_type"`
+	// TradeID is the B3 CodigoIdentificadorNegocio, sequential within the ticker and day
+	TradeID int64 `json:"trade_id"`
+	// Auction is the opening or closing auction the trade printed in, empty for continuous trading
+	Auction string `json:"auction,omitempty"`
+}
+
+// B3 TipoSessaoPregao codes
+const (
+	SessionRegular     = 1
+	SessionAfterMarket = 6
+)
+
+// Sessions maps the session names accepted by the api to the B3 codes, zero means every session
+var Sessions = map[string]int{
+	"regular":      SessionRegular,
+	"after_market": SessionAfterMarket,
+	"all":          0,
 }
 
 type Metric struct {
@@ -20,4 +44,576 @@ type Metric struct {
 	MaxRangeValue  decimal.Decimal `json:"max_range_value"`
 	MaxDailyVolume int             `json:"max_daily_volume"`
 	TradeDate      time.Time       `json:"-"`
+	SessionType    int             `json:"-"`
+	// ClosePrice is the price of the last trade of the session, CloseTime is only kept while aggregating
+	ClosePrice decimal.Decimal `json:"-"`
+	CloseTime  string          `json:"-"`
+	// FinancialVolume is the sum of price times quantity of every trade of the session
+	FinancialVolume decimal.Decimal `json:"-"`
+	// Changes is only filled when requested with the filter
+	Changes *MetricChanges `json:"changes,omitempty"`
+}
+
+// MetricChanges compares the latest trading day of a ticker with the previous one and its average volume
+type MetricChanges struct {
+	TradeDate       time.Time       `json:"trade_date"`
+	ClosePrice      decimal.Decimal `json:"close_price"`
+	PreviousDate    time.Time       `json:"previous_date"`
+	PreviousClose   decimal.Decimal `json:"previous_close"`
+	Change          decimal.Decimal `json:"change"`
+	ChangePercent   decimal.Decimal `json:"change_percent"`
+	Volume          int             `json:"volume"`
+	PreviousVolume  int             `json:"previous_volume"`
+	VolumeChange    int             `json:"volume_change"`
+	AverageVolume   decimal.Decimal `json:"average_volume_20d"`
+	VolumeVsAverage decimal.Decimal `json:"volume_vs_average"`
+}
+
+type MetricFilter struct {
+	Ticker         string
+	Date           time.Time
+	Session        int
+	InstrumentType instrument.Type
+	// Consolidated merges the fractional market trades into the standard lot ticker
+	Consolidated bool
+	// Adjusted applies the corporate action factors to prices and volumes
+	Adjusted bool
+	End      time.Time
+	// IncludeChanges adds the day over day changes of the latest trading day
+	IncludeChanges bool
+}
+
+// DailyMetric is the daily metric of a ticker with the sessions of the day summed up
+type DailyMetric struct {
+	Ticker         string          `json:"ticker"`
+	TradeDate      time.Time       `json:"trade_date"`
+	MaxRangeValue  decimal.Decimal `json:"max_range_value"`
+	ClosePrice     decimal.Decimal `json:"close_price"`
+	MaxDailyVolume int             `json:"max_daily_volume"`
+}
+
+type TradeFilter struct {
+	Ticker         string
+	Date           time.Time
+	FromTime       time.Time
+	ToTime         time.Time
+	MinQty         int
+	Cursor         int
+	Limit          int
+	InstrumentType instrument.Type
+}
+
+type TradePage struct {
+	Trades     []*Trade `json:"trades"`
+	NextCursor int      `json:"next_cursor,omitempty"`
+}
+
+type Anomaly struct {
+	ID             int             `json:"id"`
+	InstrumentCode string          `json:"instrument_code"`
+	TradePrice     decimal.Decimal `json:"trade_price"`
+	TradeQuantity  int             `json:"trade_quantity"`
+	CloseTime      string          `json:"close_time"`
+	TradeDate      time.Time       `json:"trade_date"`
+	Reason         string          `json:"reason"`
+	ReferenceValue decimal.Decimal `json:"reference_value"`
+	// TradeID is the B3 id of the flagged trade, it links the anomaly to the trade when its upload is rolled back
+	TradeID int64 `json:"-"`
+}
+
+// AnomalyBaseline is the stored reference of a ticker on a trading day, the regular session close and the average
+// trade quantity, that the first trades of the ticker in a file are checked against
+type AnomalyBaseline struct {
+	Ticker          string
+	ClosePrice      decimal.Decimal
+	AverageQuantity decimal.Decimal
+}
+
+type AnomalyFilter struct {
+	Ticker         string
+	Date           time.Time
+	Reason         string
+	InstrumentType instrument.Type
+}
+
+type BlockTrade struct {
+	ID             int             `json:"id"`
+	InstrumentCode string          `json:"instrument_code"`
+	TradePrice     decimal.Decimal `json:"trade_price"`
+	TradeQuantity  int             `json:"trade_quantity"`
+	CloseTime      string          `json:"close_time"`
+	TradeDate      time.Time       `json:"trade_date"`
+	BuyerCode      string          `json:"buyer_code"`
+	SellerCode     string          `json:"seller_code"`
+	Criterion      string          `json:"criterion"`
+}
+
+type BlockTradeFilter struct {
+	Ticker         string
+	Date           time.Time
+	InstrumentType instrument.Type
+}
+
+const (
+	SideBuy  = "buy"
+	SideSell = "sell"
+)
+
+type BrokerFilter struct {
+	Ticker         string
+	Broker         string
+	Start          time.Time
+	End            time.Time
+	Limit          int
+	InstrumentType instrument.Type
+}
+
+type BrokerVolume struct {
+	Broker          string          `json:"broker"`
+	Quantity        int             `json:"quantity"`
+	FinancialVolume decimal.Decimal `json:"financial_volume"`
+	Trades          int             `json:"trades"`
+}
+
+type BrokerRanking struct {
+	Ticker  string          `json:"ticker"`
+	Buyers  []*BrokerVolume `json:"buyers"`
+	Sellers []*BrokerVolume `json:"sellers"`
+}
+
+type BrokerFlow struct {
+	Broker             string          `json:"broker"`
+	TradeDate          time.Time       `json:"trade_date"`
+	BoughtQuantity     int             `json:"bought_quantity"`
+	SoldQuantity       int             `json:"sold_quantity"`
+	NetQuantity        int             `json:"net_quantity"`
+	NetFinancialVolume decimal.Decimal `json:"net_financial_volume"`
+}
+
+type BrokerPosition struct {
+	Broker             string
+	Ticker             string
+	NetQuantity        int
+	NetFinancialVolume decimal.Decimal
+}
+
+// BrokerMatrix holds the net quantity of every broker in every ticker, each row follows the order of Tickers
+type BrokerMatrix struct {
+	Tickers []string           `json:"tickers"`
+	Rows    []*BrokerMatrixRow `json:"rows"`
+}
+
+type BrokerMatrixRow struct {
+	Broker      string `json:"broker"`
+	NetQuantity []int  `json:"net_quantity"`
+}
+
+type Instrument struct {
+	Ticker     string           `json:"ticker"`
+	Type       instrument.Type  `json:"instrument_type"`
+	Underlying string           `json:"underlying,omitempty"`
+	Strike     *decimal.Decimal `json:"strike,omitempty"`
+}
+
+type OptionFilter struct {
+	Underlying string
+	Date       time.Time
+}
+
+// OptionSeries is an option traded on the date, the strike is only known after the instrument master import
+type OptionSeries struct {
+	Ticker         string           `json:"ticker"`
+	Underlying     string           `json:"underlying"`
+	Right          string           `json:"right"`
+	ExpiryMonth    time.Month       `json:"expiry_month"`
+	Series         string           `json:"series"`
+	Strike         *decimal.Decimal `json:"strike,omitempty"`
+	MaxRangeValue  decimal.Decimal  `json:"max_range_value"`
+	MaxDailyVolume int              `json:"max_daily_volume"`
+}
+
+const (
+	RollVolume = "volume"
+	RollExpiry = "expiry"
+
+	AdjustNone       = "none"
+	AdjustDifference = "difference"
+	AdjustRatio      = "ratio"
+)
+
+type ContinuousFilter struct {
+	Root  string
+	Start time.Time
+	End   time.Time
+	// Roll selects the rollover rule, RollDays is only used by the expiry rule
+	Roll     string
+	RollDays int
+	Adjust   string
+}
+
+// ContractDay is the regular session close and volume of a future contract
+type ContractDay struct {
+	Ticker     string
+	TradeDate  time.Time
+	ClosePrice decimal.Decimal
+	Volume     int
+}
+
+type ContinuousPoint struct {
+	TradeDate     time.Time       `json:"trade_date"`
+	Ticker        string          `json:"ticker"`
+	ClosePrice    decimal.Decimal `json:"close_price"`
+	AdjustedPrice decimal.Decimal `json:"adjusted_price"`
+	Volume        int             `json:"volume"`
+	Rolled        bool            `json:"rolled,omitempty"`
+}
+
+const (
+	ActionSplit        = "split"
+	ActionReverseSplit = "reverse_split"
+	ActionBonus        = "bonus"
+)
+
+// CorporateAction changes the number of shares from the ex date on
+// Factor is the number of shares after the action for each share before it, e.g. 2 for a 1:2 split and 0.1 for a 10:1 reverse split
+type CorporateAction struct {
+	ID     int             `json:"id"`
+	Ticker string          `json:"ticker"`
+	ExDate time.Time       `json:"ex_date"`
+	Type   string          `json:"type"`
+	Factor decimal.Decimal `json:"factor"`
+}
+
+// TradingDay describes a date in the B3 trading calendar
+type TradingDay struct {
+	Date       time.Time `json:"date"`
+	TradingDay bool      `json:"trading_day"`
+	Previous   time.Time `json:"previous"`
+	Next       time.Time `json:"next"`
+}
+
+// TradingDays lists the trading days of a period
+type TradingDays struct {
+	Start time.Time   `json:"start"`
+	End   time.Time   `json:"end"`
+	Count int         `json:"count"`
+	Days  []time.Time `json:"days"`
+}
+
+type ActivityFilter struct {
+	Ticker string
+	Start  time.Time
+	End    time.Time
+	// BucketMinutes is the size of the time of day buckets
+	BucketMinutes int
+}
+
+// IntradayVolume is the volume and number of trades of a time of day bucket summed over the days of the filter
+type IntradayVolume struct {
+	Minute int
+	Volume int
+	Trades int
+	Days   int
+}
+
+// ActivityBucket is the average activity of a time of day bucket starting at HH:MM
+type ActivityBucket struct {
+	Start         string          `json:"start"`
+	AverageVolume decimal.Decimal `json:"average_volume"`
+	AverageTrades decimal.Decimal `json:"average_trades"`
+}
+
+// Activity is the intraday activity profile of a ticker averaged over the days it traded
+type Activity struct {
+	Ticker        string            `json:"ticker"`
+	BucketMinutes int               `json:"bucket_minutes"`
+	Days          int               `json:"days"`
+	Buckets       []*ActivityBucket `json:"buckets"`
+}
+
+type BreadthFilter struct {
+	Start time.Time
+	End   time.Time
+	// Days is the number of previous sessions of the ticker a close must exceed to be a new high or low
+	Days int
+	// HistoryStart bounds the sessions read before the start to compare the first days with
+	HistoryStart time.Time
+}
+
+// MarketBreadth is the count of stocks moving in each direction on a trading day and the financial volume of the market
+type MarketBreadth struct {
+	TradeDate       time.Time       `json:"trade_date"`
+	Advancing       int             `json:"advancing"`
+	Declining       int             `json:"declining"`
+	Unchanged       int             `json:"unchanged"`
+	NewHighs        int             `json:"new_highs"`
+	NewLows         int             `json:"new_lows"`
+	FinancialVolume decimal.Decimal `json:"financial_volume"`
+}
+
+type LiquidityFilter struct {
+	Ticker string
+	Start  time.Time
+	End    time.Time
+}
+
+// DailyLiquidity is the close and the volumes of a day the ticker traded, with the sessions of the day summed up
+type DailyLiquidity struct {
+	TradeDate       time.Time
+	ClosePrice      decimal.Decimal
+	Volume          int
+	FinancialVolume decimal.Decimal
+}
+
+// PriceCovariance is the covariance of consecutive trade price changes within the same day
+type PriceCovariance struct {
+	Covariance decimal.Decimal
+	Pairs      int
+}
+
+// Liquidity gathers the liquidity measures of a ticker over a period
+type Liquidity struct {
+	Ticker                 string          `json:"ticker"`
+	Start                  time.Time       `json:"start"`
+	End                    time.Time       `json:"end"`
+	TradingDays            int             `json:"trading_days"`
+	ZeroVolumeDays         int             `json:"zero_volume_days"`
+	AverageFinancialVolume decimal.Decimal `json:"average_financial_volume"`
+	AmihudIlliquidity      decimal.Decimal `json:"amihud_illiquidity"`
+	SerialCovariance       decimal.Decimal `json:"serial_covariance"`
+	RollSpread             decimal.Decimal `json:"roll_spread"`
+}
+
+// OrderFlowBucketMinutes is the size of the time of day buckets the order flow is stored in
+const OrderFlowBucketMinutes = 15
+
+// OrderFlow is the volume initiated by buyers and sellers of a ticker in a time of day bucket, classified by the tick rule
+type OrderFlow struct {
+	Ticker             string
+	TradeDate          time.Time
+	Minute             int
+	BuyVolume          int
+	SellVolume         int
+	UnclassifiedVolume int
+	BuyTrades          int
+	SellTrades         int
+}
+
+type OrderFlowFilter struct {
+	Ticker string
+	Start  time.Time
+	End    time.Time
+	// Intraday adds the time of day buckets to each day
+	Intraday bool
+}
+
+// OrderFlowBucket is the order flow of a time of day bucket starting at HH:MM
+type OrderFlowBucket struct {
+	Start      string          `json:"start"`
+	BuyVolume  int             `json:"buy_volume"`
+	SellVolume int             `json:"sell_volume"`
+	Imbalance  decimal.Decimal `json:"imbalance"`
+}
+
+// DailyOrderFlow is the order flow of a ticker on a day, the imbalance is (buy - sell) / (buy + sell)
+type DailyOrderFlow struct {
+	TradeDate          time.Time          `json:"trade_date"`
+	BuyVolume          int                `json:"buy_volume"`
+	SellVolume         int                `json:"sell_volume"`
+	UnclassifiedVolume int                `json:"unclassified_volume"`
+	BuyTrades          int                `json:"buy_trades"`
+	SellTrades         int                `json:"sell_trades"`
+	Imbalance          decimal.Decimal    `json:"imbalance"`
+	Buckets            []*OrderFlowBucket `json:"buckets,omitempty"`
+}
+
+// BenchmarkWindow is an intraday window of the exchange time, the end is exclusive
+type BenchmarkWindow struct {
+	From time.Time
+	To   time.Time
+}
+
+type BenchmarkFilter struct {
+	Ticker  string
+	Date    time.Time
+	Windows []BenchmarkWindow
+}
+
+// IntervalBenchmark is the volume and time weighted average price of the trades in a window
+type IntervalBenchmark struct {
+	From   string          `json:"from"`
+	To     string          `json:"to"`
+	Trades int             `json:"trades"`
+	Volume int             `json:"volume"`
+	VWAP   decimal.Decimal `json:"vwap"`
+	TWAP   decimal.Decimal `json:"twap"`
+}
+
+type Benchmarks struct {
+	Ticker  string               `json:"ticker"`
+	Date    time.Time            `json:"date"`
+	Windows []*IntervalBenchmark `json:"windows"`
+}
+
+const (
+	AuctionOpening = "opening"
+	AuctionClosing = "closing"
+)
+
+// Auction is the uncross of an opening or closing call auction, every trade of the auction prints at its close time
+type Auction struct {
+	Ticker    string          `json:"ticker"`
+	TradeDate time.Time       `json:"trade_date"`
+	Type      string          `json:"type"`
+	CloseTime string          `json:"close_time"`
+	Price     decimal.Decimal `json:"price"`
+	Volume    int             `json:"volume"`
+	Trades    int             `json:"trades"`
+}
+
+type AuctionFilter struct {
+	Ticker string
+	Start  time.Time
+	End    time.Time
+	Type   string
+}
+
+const (
+	UploadProcessing = "processing"
+	UploadCompleted  = "completed"
+	UploadFailed     = "failed"
+	UploadRejected   = "rejected"
+	UploadDeleted    = "deleted"
+)
+
+// Upload is an ingestion of a B3 trade file, registered in the source files with the checksum of its content
+// The trades and metrics stored from the file keep its id
+type Upload struct {
+	ID            int        `json:"id"`
+	FileName      string     `json:"file_name"`
+	FileSize      int64      `json:"file_size"`
+	Checksum      string     `json:"sha256"`
+	ReferenceDate *time.Time `json:"reference_date,omitempty"`
+	Uploader      string     `json:"uploader,omitempty"`
+	// BlobKey is the key of the raw file in the blob store, ReplayOf the upload of the file replayed by this one
+	BlobKey    string     `json:"blob_key,omitempty"`
+	ReplayOf   *int       `json:"replay_of,omitempty"`
+	Status     string     `json:"status"`
+	Trades     int        `json:"trades"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+const (
+	AuditDelete = "delete"
+	AuditReplay = "replay"
+)
+
+// UploadAudit is an entry of the audit log of the changes made to an upload after its ingestion
+// Trades are the rows removed and Metrics the daily metrics recomputed from the remaining trades
+type UploadAudit struct {
+	ID        int       `json:"id"`
+	UploadID  int       `json:"upload_id"`
+	Action    string    `json:"action"`
+	Actor     string    `json:"actor,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	Trades    int       `json:"trades"`
+	Metrics   int       `json:"metrics"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TradeSequence is the completeness check of the B3 trade ids of a ticker and day in an upload
+// Step is the increment between consecutive ids and Missing the ids absent from the first expected one to the last
+type TradeSequence struct {
+	Ticker       string    `json:"ticker"`
+	TradeDate    time.Time `json:"trade_date"`
+	Trades       int       `json:"trades"`
+	FirstTradeID int64     `json:"first_trade_id"`
+	LastTradeID  int64     `json:"last_trade_id"`
+	Step         int64     `json:"step"`
+	Missing      int64     `json:"missing"`
+	Gaps         int       `json:"gaps"`
+	Duplicates   int       `json:"duplicates"`
+	OutOfOrder   int       `json:"out_of_order"`
+	// OutOfStep counts the ids off the increment inside the range already seen, they are left out of the missing ids
+	OutOfStep int  `json:"out_of_step"`
+	Complete  bool `json:"complete"`
+}
+
+type SequenceFilter struct {
+	UploadID int
+	Ticker   string
+	// Incomplete keeps only the sequences with missing, duplicated, out of order or out of step ids
+	Incomplete bool
+}
+
+// Completeness sums the trade sequences of an upload
+type Completeness struct {
+	UploadID   int              `json:"upload_id"`
+	Sequences  int              `json:"sequences"`
+	Incomplete int              `json:"incomplete"`
+	Missing    int64            `json:"missing"`
+	Duplicates int              `json:"duplicates"`
+	OutOfOrder int              `json:"out_of_order"`
+	OutOfStep  int              `json:"out_of_step"`
+	Details    []*TradeSequence `json:"details"`
+}
+
+const (
+	QualityNonPositivePrice     = config.QualityNonPositivePrice
+	QualityNonPositiveQuantity  = config.QualityNonPositiveQuantity
+	QualityOutsideReferenceDate = config.QualityOutsideReferenceDate
+	QualityPriceDeviation       = config.QualityPriceDeviation
+	QualityOutsideTradingHours  = config.QualityOutsideTradingHours
+)
+
+// QualityIssue counts the rows of an upload with a data quality issue, Ratio is the share of the rows of the file
+// Rejected is set when the ratio is above the threshold of the issue
+type QualityIssue struct {
+	Issue    string           `json:"issue"`
+	Rows     int              `json:"rows"`
+	Ratio    float64          `json:"ratio"`
+	Rejected bool             `json:"rejected"`
+	Samples  []*QualitySample `json:"samples"`
+}
+
+// QualitySample is a row of the file with the issue, Reference is the value it was checked against
+type QualitySample struct {
+	Line      int             `json:"line"`
+	Ticker    string          `json:"ticker"`
+	TradeDate time.Time       `json:"trade_date"`
+	CloseTime string          `json:"close_time"`
+	Price     decimal.Decimal `json:"price"`
+	Quantity  int             `json:"quantity"`
+	Reference string          `json:"reference,omitempty"`
+}
+
+type QualityReport struct {
+	UploadID int             `json:"upload_id"`
+	Status   string          `json:"status"`
+	Rows     int             `json:"rows"`
+	Rejected bool            `json:"rejected"`
+	Issues   []*QualityIssue `json:"issues"`
+}
+
+// Validation is the dry run of the upload of a B3 trade file, nothing is stored
+// Valid is set when the upload would read every row and the quality thresholds would not reject the file
+type Validation struct {
+	Rows            int                `json:"rows"`
+	ValidRows       int                `json:"valid_rows"`
+	InvalidRows     int                `json:"invalid_rows"`
+	Columns         []string           `json:"columns"`
+	ReferenceDate   *time.Time         `json:"reference_date,omitempty"`
+	StartDate       *time.Time         `json:"start_date,omitempty"`
+	EndDate         *time.Time         `json:"end_date,omitempty"`
+	DistinctTickers int                `json:"distinct_tickers"`
+	Tickers         []string           `json:"tickers"`
+	Errors          []*ValidationError `json:"errors"`
+	Quality         []*QualityIssue    `json:"quality"`
+	Valid           bool               `json:"valid"`
+}
+
+type ValidationError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
 }
@@ -0,0 +1,203 @@
+package trade
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// orderFlowAggregator classifies the trades of each ticker and day as buyer or seller initiated with the tick rule
+// and sums them per time of day bucket while the file is read
+// The trades are not guaranteed to be sorted in the file, so only the trades of the latest bucket of each ticker and day
+// are kept and they are ordered by time and trade id when a later bucket starts
+type orderFlowAggregator struct {
+	days map[string]*dayFlow
+}
+
+// dayFlow is the tick rule state of a ticker and day
+type dayFlow struct {
+	ticker    string
+	tradeDate time.Time
+	buckets   map[int]*OrderFlow
+	// pending are the trades of the bucket starting at minute that are not classified yet
+	pending []*signedTrade
+	minute  int
+	// last is the price of the latest classified trade and sign its classification
+	last   decimal.Decimal
+	traded bool
+	sign   int
+}
+
+type signedTrade struct {
+	tradedAt time.Time
+	tradeID  int64
+	price    decimal.Decimal
+	quantity int
+}
+
+func newOrderFlowAggregator() *orderFlowAggregator {
+	return &orderFlowAggregator{
+		days: make(map[string]*dayFlow),
+	}
+}
+
+// add keeps the trade with the trades of its bucket, classifying the previous bucket when the trade starts a later one
+// A trade of a bucket before the current one can no longer be ordered with its neighbours and is left unclassified
+func (a *orderFlowAggregator) add(trade *Trade) {
+	key := fmt.Sprintf("%s|%s", trade.InstrumentCode, trade.TradeDate.Format("2006-01-02"))
+	day, ok := a.days[key]
+	if !ok {
+		day = &dayFlow{
+			ticker:    trade.InstrumentCode,
+			tradeDate: trade.TradeDate,
+			buckets:   make(map[int]*OrderFlow),
+		}
+		a.days[key] = day
+	}
+
+	minute := trade.TradedAt.Hour()*60 + trade.TradedAt.Minute()
+	minute -= minute % OrderFlowBucketMinutes
+
+	switch {
+	case len(day.pending) == 0 || minute > day.minute:
+		day.classify()
+		day.minute = minute
+	case minute < day.minute:
+		flow := day.bucket(minute)
+		flow.UnclassifiedVolume += trade.TradeQuantity
+		return
+	}
+
+	day.pending = append(day.pending, &signedTrade{
+		tradedAt: trade.TradedAt,
+		tradeID:  trade.TradeID,
+		price:    trade.TradePrice,
+		quantity: trade.TradeQuantity,
+	})
+}
+
+// classify orders the pending trades by time and trade id and sums them in their bucket
+// An uptick is a buy and a downtick a sell, a zero tick keeps the previous sign and the trades before the first tick are unclassified
+func (d *dayFlow) classify() {
+	if len(d.pending) == 0 {
+		return
+	}
+
+	trades := d.pending
+	sort.SliceStable(trades, func(i, j int) bool {
+		if !trades[i].tradedAt.Equal(trades[j].tradedAt) {
+			return trades[i].tradedAt.Before(trades[j].tradedAt)
+		}
+		return trades[i].tradeID < trades[j].tradeID
+	})
+
+	flow := d.bucket(d.minute)
+	for _, trade := range trades {
+		if d.traded {
+			d.sign = tickSign(d.last, trade.price, d.sign)
+		}
+		d.last = trade.price
+		d.traded = true
+
+		switch d.sign {
+		case 1:
+			flow.BuyVolume += trade.quantity
+			flow.BuyTrades++
+		case -1:
+			flow.SellVolume += trade.quantity
+			flow.SellTrades++
+		default:
+			flow.UnclassifiedVolume += trade.quantity
+		}
+	}
+
+	d.pending = nil
+}
+
+func (d *dayFlow) bucket(minute int) *OrderFlow {
+	flow, ok := d.buckets[minute]
+	if !ok {
+		flow = &OrderFlow{Ticker: d.ticker, TradeDate: d.tradeDate, Minute: minute}
+		d.buckets[minute] = flow
+	}
+	return flow
+}
+
+// flows classifies the trades left in the last bucket of each ticker and day and returns the buckets
+// ordered by ticker, date and minute
+func (a *orderFlowAggregator) flows() []*OrderFlow {
+	keys := make([]string, 0, len(a.days))
+	for key := range a.days {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	flows := make([]*OrderFlow, 0)
+	for _, key := range keys {
+		day := a.days[key]
+		day.classify()
+
+		start := len(flows)
+		for _, flow := range day.buckets {
+			flows = append(flows, flow)
+		}
+		sort.Slice(flows[start:], func(i, j int) bool { return flows[start+i].Minute < flows[start+j].Minute })
+	}
+
+	return flows
+}
+
+// tickSign compares the price with the previous trade, a zero tick keeps the previous sign
+func tickSign(previous, price decimal.Decimal, sign int) int {
+	switch price.Cmp(previous) {
+	case 1:
+		return 1
+	case -1:
+		return -1
+	default:
+		return sign
+	}
+}
+
+// dailyOrderFlows sums the buckets of each day, the buckets are ordered by date and minute
+func dailyOrderFlows(flows []*OrderFlow, intraday bool) []*DailyOrderFlow {
+	daily := make([]*DailyOrderFlow, 0)
+	var day *DailyOrderFlow
+	for _, flow := range flows {
+		if day == nil || !day.TradeDate.Equal(flow.TradeDate) {
+			day = &DailyOrderFlow{TradeDate: flow.TradeDate}
+			daily = append(daily, day)
+		}
+
+		day.BuyVolume += flow.BuyVolume
+		day.SellVolume += flow.SellVolume
+		day.UnclassifiedVolume += flow.UnclassifiedVolume
+		day.BuyTrades += flow.BuyTrades
+		day.SellTrades += flow.SellTrades
+
+		if intraday {
+			day.Buckets = append(day.Buckets, &OrderFlowBucket{
+				Start:      fmt.Sprintf("%02d:%02d", flow.Minute/60, flow.Minute%60),
+				BuyVolume:  flow.BuyVolume,
+				SellVolume: flow.SellVolume,
+				Imbalance:  imbalance(flow.BuyVolume, flow.SellVolume),
+			})
+		}
+	}
+
+	for _, day := range daily {
+		day.Imbalance = imbalance(day.BuyVolume, day.SellVolume)
+	}
+
+	return daily
+}
+
+// imbalance is the net classified volume over the total classified volume, from -1 to 1
+func imbalance(buy, sell int) decimal.Decimal {
+	if buy+sell == 0 {
+		return decimal.Zero
+	}
+	return decimal.NewFromInt(int64(buy - sell)).Div(decimal.NewFromInt(int64(buy + sell))).Round(4)
+}
@@ -0,0 +1,102 @@
+package trade
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestOrderFlowAggregator(t *testing.T) {
+	date := time.Date(2024, 6, 28, 0, 0, 0, 0, time.UTC)
+	trade := func(ticker string, tradeID int64, clock string, price int64, quantity int) *Trade {
+		tradedAt, _ := time.Parse("2006-01-02 15:04:05", "2024-06-28 "+clock)
+		return &Trade{
+			InstrumentCode: ticker,
+			TradePrice:     decimal.NewFromInt(price),
+			TradeQuantity:  quantity,
+			TradeDate:      date,
+			TradedAt:       tradedAt,
+			TradeID:        tradeID,
+		}
+	}
+
+	aggregator := newOrderFlowAggregator()
+	// out of order in the file, the same instant is ordered by the trade id
+	for _, tr := range []*Trade{
+		trade("PETR4", 30, "10:00:05", 39, 300),
+		trade("PETR4", 10, "10:00:00", 38, 100),
+		trade("PETR4", 20, "10:00:05", 38, 200),
+		trade("PETR4", 40, "10:16:00", 39, 400),
+		trade("PETR4", 50, "10:20:00", 37, 500),
+		trade("VALE3", 10, "10:00:00", 60, 10),
+	} {
+		aggregator.add(tr)
+	}
+
+	assert.Equal(t, []*OrderFlow{
+		// 38 unclassified, 38 zero tick without a previous sign, 39 uptick
+		{Ticker: "PETR4", TradeDate: date, Minute: 600, BuyVolume: 300, UnclassifiedVolume: 300, BuyTrades: 1},
+		// 39 zero tick keeps the buy, 37 downtick
+		{Ticker: "PETR4", TradeDate: date, Minute: 615, BuyVolume: 400, SellVolume: 500, BuyTrades: 1, SellTrades: 1},
+		{Ticker: "VALE3", TradeDate: date, Minute: 600, UnclassifiedVolume: 10},
+	}, aggregator.flows())
+}
+
+func TestOrderFlowAggregatorLateTrade(t *testing.T) {
+	date := time.Date(2024, 6, 28, 0, 0, 0, 0, time.UTC)
+	trade := func(tradeID int64, clock string, price int64, quantity int) *Trade {
+		tradedAt, _ := time.Parse("2006-01-02 15:04:05", "2024-06-28 "+clock)
+		return &Trade{
+			InstrumentCode: "PETR4",
+			TradePrice:     decimal.NewFromInt(price),
+			TradeQuantity:  quantity,
+			TradeDate:      date,
+			TradedAt:       tradedAt,
+			TradeID:        tradeID,
+		}
+	}
+
+	aggregator := newOrderFlowAggregator()
+	for _, tr := range []*Trade{
+		trade(10, "10:00:00", 38, 100),
+		trade(20, "10:01:00", 39, 200),
+		trade(40, "10:16:00", 40, 400),
+		// the 10:00 bucket was classified when the 10:15 one started
+		trade(30, "10:02:00", 41, 300),
+		trade(50, "10:17:00", 39, 500),
+	} {
+		aggregator.add(tr)
+	}
+
+	assert.Equal(t, []*OrderFlow{
+		{Ticker: "PETR4", TradeDate: date, Minute: 600, BuyVolume: 200, UnclassifiedVolume: 400, BuyTrades: 1},
+		{Ticker: "PETR4", TradeDate: date, Minute: 615, BuyVolume: 400, SellVolume: 500, BuyTrades: 1, SellTrades: 1},
+	}, aggregator.flows())
+}
+
+func TestDailyOrderFlows(t *testing.T) {
+	first := time.Date(2024, 6, 27, 0, 0, 0, 0, time.UTC)
+	second := time.Date(2024, 6, 28, 0, 0, 0, 0, time.UTC)
+	flows := []*OrderFlow{
+		{Ticker: "PETR4", TradeDate: first, Minute: 600, BuyVolume: 100, SellVolume: 300, BuyTrades: 1, SellTrades: 2},
+		{Ticker: "PETR4", TradeDate: second, Minute: 600, BuyVolume: 100, BuyTrades: 1},
+		{Ticker: "PETR4", TradeDate: second, Minute: 1005, UnclassifiedVolume: 20},
+	}
+
+	got := dailyOrderFlows(flows, true)
+
+	assert.Len(t, got, 2)
+	assert.Equal(t, first, got[0].TradeDate)
+	assert.Equal(t, "-0.5", got[0].Imbalance.String())
+	assert.Len(t, got[0].Buckets, 1)
+	assert.Equal(t, "10:00", got[0].Buckets[0].Start)
+	assert.Equal(t, "-0.5", got[0].Buckets[0].Imbalance.String())
+	assert.Equal(t, 100, got[1].BuyVolume)
+	assert.Equal(t, 20, got[1].UnclassifiedVolume)
+	assert.Equal(t, "1", got[1].Imbalance.String())
+	assert.Equal(t, "16:45", got[1].Buckets[1].Start)
+	assert.Equal(t, "0", got[1].Buckets[1].Imbalance.String())
+
+	assert.Nil(t, dailyOrderFlows(flows, false)[0].Buckets)
+}
@@ -0,0 +1,114 @@
+package trade
+
+import (
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"quotation-metrics/internal/config"
+)
+
+// qualityChecker validates the meaning of the parsed rows of a file and counts the rows with each issue
+// The file is rejected when the share of the rows with an issue is above its threshold
+type qualityChecker struct {
+	cfg       config.Quality
+	hours     *window
+	reference time.Time
+	rows      int
+	issues    map[string]*QualityIssue
+}
+
+func newQualityChecker(cfg config.Quality) *qualityChecker {
+	checker := &qualityChecker{
+		cfg:    cfg,
+		issues: make(map[string]*QualityIssue),
+	}
+
+	if !cfg.TradingHours.End.IsZero() {
+		hours := newWindow(cfg.TradingHours)
+		checker.hours = &hours
+	}
+
+	return checker
+}
+
+// setReference sets the reference date of the file, the trades are expected to be dated on it
+func (c *qualityChecker) setReference(date time.Time) {
+	c.reference = date
+}
+
+// check records the issues of the trade on the line of the file
+// previousClose is the close of the ticker on the trading day before, zero when it is not known
+func (c *qualityChecker) check(line int, trade *Trade, previousClose decimal.Decimal) {
+	c.rows++
+
+	if !trade.TradePrice.IsPositive() {
+		c.add(QualityNonPositivePrice, line, trade, "")
+	}
+
+	if trade.TradeQuantity <= 0 {
+		c.add(QualityNonPositiveQuantity, line, trade, "")
+	}
+
+	if !c.reference.IsZero() && !trade.TradeDate.Equal(c.reference) {
+		c.add(QualityOutsideReferenceDate, line, trade, c.reference.Format("2006-01-02"))
+	}
+
+	if c.cfg.PriceDeviation > 0 && previousClose.IsPositive() && trade.TradePrice.IsPositive() {
+		deviation := trade.TradePrice.Sub(previousClose).Abs().Div(previousClose)
+		if deviation.GreaterThan(decimal.NewFromFloat(c.cfg.PriceDeviation)) {
+			c.add(QualityPriceDeviation, line, trade, previousClose.String())
+		}
+	}
+
+	if c.hours != nil && !c.hours.contains(trade.CloseTime) {
+		c.add(QualityOutsideTradingHours, line, trade, "")
+	}
+}
+
+func (c *qualityChecker) add(issue string, line int, trade *Trade, reference string) {
+	quality, ok := c.issues[issue]
+	if !ok {
+		quality = &QualityIssue{
+			Issue:   issue,
+			Samples: make([]*QualitySample, 0),
+		}
+		c.issues[issue] = quality
+	}
+
+	quality.Rows++
+	if len(quality.Samples) < c.cfg.Samples {
+		quality.Samples = append(quality.Samples, &QualitySample{
+			Line:      line,
+			Ticker:    trade.InstrumentCode,
+			TradeDate: trade.TradeDate,
+			CloseTime: trade.CloseTime,
+			Price:     trade.TradePrice,
+			Quantity:  trade.TradeQuantity,
+			Reference: reference,
+		})
+	}
+}
+
+// report returns the issues found ordered by name and whether the file is rejected
+func (c *qualityChecker) report() ([]*QualityIssue, bool) {
+	issues := make([]*QualityIssue, 0, len(c.issues))
+	rejected := false
+
+	for _, issue := range c.issues {
+		ratio := float64(issue.Rows) / float64(c.rows)
+		issue.Ratio, _ = decimal.NewFromFloat(ratio).Round(4).Float64()
+
+		// the threshold is compared with the exact ratio so a zero threshold rejects any row
+		if threshold, ok := c.cfg.Thresholds[issue.Issue]; ok && ratio > threshold {
+			issue.Rejected = true
+			rejected = true
+		}
+
+		issues = append(issues, issue)
+	}
+
+	sort.Slice(issues, func(i, j int) bool { return issues[i].Issue < issues[j].Issue })
+
+	return issues, rejected
+}
@@ -0,0 +1,141 @@
+package trade
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"quotation-metrics/internal/config"
+	"testing"
+	"time"
+)
+
+func TestQualityChecker(t *testing.T) {
+	date := time.Date(2024, 6, 28, 0, 0, 0, 0, time.UTC)
+	clock := func(hour, minute int) time.Time {
+		return time.Date(0, 1, 1, hour, minute, 0, 0, time.UTC)
+	}
+	trade := func(ticker, closeTime string, price float64, quantity int, tradeDate time.Time) *Trade {
+		return &Trade{
+			InstrumentCode: ticker,
+			TradePrice:     decimal.NewFromFloat(price),
+			TradeQuantity:  quantity,
+			CloseTime:      closeTime,
+			TradeDate:      tradeDate,
+		}
+	}
+
+	cases := []struct {
+		name         string
+		cfg          config.Quality
+		trades       []*Trade
+		closes       []float64
+		want         []*QualityIssue
+		wantRejected bool
+	}{
+		{
+			name: "clean file",
+			cfg: config.Quality{
+				PriceDeviation: 0.2,
+				TradingHours:   config.Window{Start: clock(9, 0), End: clock(18, 30)},
+				Samples:        2,
+			},
+			trades: []*Trade{
+				trade("PETR4", "100000000", 38.5, 100, date),
+				trade("PETR4", "170000000", 39, 100, date),
+			},
+			closes: []float64{38, 38},
+			want:   []*QualityIssue{},
+		},
+		{
+			name: "issues counted with samples",
+			cfg: config.Quality{
+				PriceDeviation: 0.2,
+				TradingHours:   config.Window{Start: clock(9, 0), End: clock(18, 30)},
+				Samples:        1,
+			},
+			trades: []*Trade{
+				trade("PETR4", "100000000", 0, 100, date),
+				trade("PETR4", "100000000", 38.5, 0, date),
+				trade("PETR4", "100000000", 38.5, -100, date),
+				trade("PETR4", "100000000", 50, 100, date),
+				trade("PETR4", "043000000", 38.5, 100, date.AddDate(0, 0, -1)),
+			},
+			closes: []float64{38, 38, 38, 38, 0},
+			want: []*QualityIssue{
+				{
+					Issue:   QualityNonPositivePrice,
+					Rows:    1,
+					Ratio:   0.2,
+					Samples: []*QualitySample{{Line: 2, Ticker: "PETR4", TradeDate: date, CloseTime: "100000000", Price: decimal.NewFromFloat(0), Quantity: 100}},
+				},
+				{
+					Issue:   QualityNonPositiveQuantity,
+					Rows:    2,
+					Ratio:   0.4,
+					Samples: []*QualitySample{{Line: 3, Ticker: "PETR4", TradeDate: date, CloseTime: "100000000", Price: decimal.NewFromFloat(38.5), Quantity: 0}},
+				},
+				{
+					Issue:   QualityOutsideReferenceDate,
+					Rows:    1,
+					Ratio:   0.2,
+					Samples: []*QualitySample{{Line: 6, Ticker: "PETR4", TradeDate: date.AddDate(0, 0, -1), CloseTime: "043000000", Price: decimal.NewFromFloat(38.5), Quantity: 100, Reference: "2024-06-28"}},
+				},
+				{
+					Issue:   QualityOutsideTradingHours,
+					Rows:    1,
+					Ratio:   0.2,
+					Samples: []*QualitySample{{Line: 6, Ticker: "PETR4", TradeDate: date.AddDate(0, 0, -1), CloseTime: "043000000", Price: decimal.NewFromFloat(38.5), Quantity: 100}},
+				},
+				{
+					Issue:   QualityPriceDeviation,
+					Rows:    1,
+					Ratio:   0.2,
+					Samples: []*QualitySample{{Line: 5, Ticker: "PETR4", TradeDate: date, CloseTime: "100000000", Price: decimal.NewFromFloat(50), Quantity: 100, Reference: "38"}},
+				},
+			},
+		},
+		{
+			name: "rejected above the threshold",
+			cfg: config.Quality{
+				Thresholds: map[string]float64{
+					QualityNonPositiveQuantity: 0,
+					QualityNonPositivePrice:    0.5,
+				},
+			},
+			trades: []*Trade{
+				trade("PETR4", "100000000", 0, 100, date),
+				trade("PETR4", "100000000", 38.5, 0, date),
+				trade("PETR4", "100000000", 38.5, 100, date),
+			},
+			closes: []float64{0, 0, 0},
+			want: []*QualityIssue{
+				{Issue: QualityNonPositivePrice, Rows: 1, Ratio: 0.3333, Samples: []*QualitySample{}},
+				{Issue: QualityNonPositiveQuantity, Rows: 1, Ratio: 0.3333, Rejected: true, Samples: []*QualitySample{}},
+			},
+			wantRejected: true,
+		},
+		{
+			name: "checks without configuration",
+			cfg:  config.Quality{},
+			trades: []*Trade{
+				trade("PETR4", "043000000", 50, 100, date),
+			},
+			closes: []float64{38},
+			want:   []*QualityIssue{},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			checker := newQualityChecker(tc.cfg)
+			checker.setReference(date)
+
+			for i, trade := range tc.trades {
+				checker.check(i+2, trade, decimal.NewFromFloat(tc.closes[i]))
+			}
+
+			got, rejected := checker.report()
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.wantRejected, rejected)
+		})
+	}
+}
@@ -3,15 +3,61 @@ package trade
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+	"quotation-metrics/internal/instrument"
 	"strings"
 	"time"
 )
 
+// uniqueViolation is the postgres error code of a unique constraint violation
+const uniqueViolation = "23505"
+
 type Repository interface {
-	BatchInsertTrade(ctx context.Context, trades []*Trade) error
-	GetMetrics(ctx context.Context, ticker string, date time.Time) (*Metric, error)
-	BatchInsertMetrics(ctx context.Context, metricsMap map[string]*Metric) error
+	BatchInsertTrade(ctx context.Context, sourceFileID int, trades []*Trade) error
+	GetMetrics(ctx context.Context, filter MetricFilter) (*Metric, error)
+	BatchInsertMetrics(ctx context.Context, sourceFileID int, metricsMap map[string]*Metric) error
+	ListTrades(ctx context.Context, filter TradeFilter) ([]*Trade, error)
+	BatchInsertAnomalies(ctx context.Context, sourceFileID int, anomalies []*Anomaly) error
+	ListAnomalies(ctx context.Context, filter AnomalyFilter) ([]*Anomaly, error)
+	BatchInsertBlockTrades(ctx context.Context, sourceFileID int, blocks []*BlockTrade) error
+	ListBlockTrades(ctx context.Context, filter BlockTradeFilter) ([]*BlockTrade, error)
+	TopBrokers(ctx context.Context, filter BrokerFilter, side string) ([]*BrokerVolume, error)
+	BrokerFlows(ctx context.Context, filter BrokerFilter) ([]*BrokerFlow, error)
+	BrokerPositions(ctx context.Context, filter BrokerFilter) ([]*BrokerPosition, error)
+	UpsertInstruments(ctx context.Context, instruments []*Instrument) error
+	UnclassifiedTickers(ctx context.Context) ([]string, error)
+	ImportInstruments(ctx context.Context, instruments []*Instrument) error
+	ListOptionSeries(ctx context.Context, filter OptionFilter) ([]*OptionSeries, error)
+	ListContractDays(ctx context.Context, filter ContinuousFilter) ([]*ContractDay, error)
+	DailyMetrics(ctx context.Context, filter MetricFilter) ([]*DailyMetric, error)
+	UpsertCorporateActions(ctx context.Context, actions []*CorporateAction) error
+	ListCorporateActions(ctx context.Context, ticker string) ([]*CorporateAction, error)
+	IntradayVolumes(ctx context.Context, filter ActivityFilter) ([]*IntradayVolume, error)
+	MarketBreadth(ctx context.Context, filter BreadthFilter) ([]*MarketBreadth, error)
+	DailyLiquidity(ctx context.Context, filter LiquidityFilter) ([]*DailyLiquidity, error)
+	PriceCovariance(ctx context.Context, filter LiquidityFilter) (*PriceCovariance, error)
+	BatchInsertOrderFlow(ctx context.Context, sourceFileID int, flows []*OrderFlow) error
+	ListOrderFlow(ctx context.Context, filter OrderFlowFilter) ([]*OrderFlow, error)
+	ListWindowTrades(ctx context.Context, filter TradeFilter) ([]*Trade, error)
+	BatchInsertAuctions(ctx context.Context, sourceFileID int, auctions []*Auction) error
+	ListAuctions(ctx context.Context, filter AuctionFilter) ([]*Auction, error)
+	CreateUpload(ctx context.Context, upload *Upload) error
+	FinishUpload(ctx context.Context, upload *Upload) error
+	FindUploadByChecksum(ctx context.Context, checksum string) (*Upload, error)
+	GetUpload(ctx context.Context, id int) (*Upload, error)
+	DeleteUpload(ctx context.Context, audit *UploadAudit) error
+	ReplayUpload(ctx context.Context, audit *UploadAudit, replay *Upload) error
+	PurgeUpload(ctx context.Context, uploadID int) error
+	BatchInsertSequences(ctx context.Context, uploadID int, sequences []*TradeSequence) error
+	ListSequences(ctx context.Context, filter SequenceFilter) ([]*TradeSequence, error)
+	ListCloses(ctx context.Context, date time.Time) (map[string]decimal.Decimal, error)
+	ListAnomalyBaselines(ctx context.Context, date time.Time) (map[string]*AnomalyBaseline, error)
+	BatchInsertQualityIssues(ctx context.Context, uploadID int, issues []*QualityIssue) error
+	ListQualityIssues(ctx context.Context, uploadID int) ([]*QualityIssue, error)
 }
 
 type repository struct {
@@ -24,15 +70,16 @@ func NewRepository(db *sql.DB) Repository {
 	}
 }
 
-func (r *repository) BatchInsertTrade(ctx context.Context, trades []*Trade) error {
+func (r *repository) BatchInsertTrade(ctx context.Context, sourceFileID int, trades []*Trade) error {
 	valueStrings := make([]string, len(trades))
-	valueArgs := make([]interface{}, 0, len(trades)*5)
+	valueArgs := make([]interface{}, 0, len(trades)*11)
 
 	for i, trade := range trades {
-		valueStrings[i] = fmt.Sprintf("($%d, $%d, $%d, $%d, $%d)", i*5+1, i*5+2, i*5+3, i*5+4, i*5+5)
-		valueArgs = append(valueArgs, trade.InstrumentCode, trade.TradePrice, trade.TradeQuantity, trade.CloseTime, trade.TradeDate)
+		valueStrings[i] = fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)", i*11+1, i*11+2, i*11+3, i*11+4, i*11+5, i*11+6, i*11+7, i*11+8, i*11+9, i*11+10, i*11+11)
+		valueArgs = append(valueArgs, trade.InstrumentCode, trade.TradePrice, trade.TradeQuantity, trade.CloseTime, trade.TradeDate,
+			trade.TradedAt, trade.BuyerCode, trade.SellerCode, trade.SessionType, trade.TradeID, sourceFileID)
 	}
-	stmt := fmt.Sprintf("INSERT INTO trades (instrument_code, trade_price, trade_quantity, close_time, trade_date) VALUES %s",
+	stmt := fmt.Sprintf("INSERT INTO trades (instrument_code, trade_price, trade_quantity, close_time, trade_date, traded_at, buyer_code, seller_code, session_type, trade_id, source_file_id) VALUES %s",
 		strings.Join(valueStrings, ","))
 	tx, err := r.db.Begin()
 	if err != nil {
@@ -47,16 +94,17 @@ func (r *repository) BatchInsertTrade(ctx context.Context, trades []*Trade) erro
 	return tx.Commit()
 }
 
-func (r *repository) BatchInsertMetrics(ctx context.Context, metricsMap map[string]*Metric) error {
+func (r *repository) BatchInsertMetrics(ctx context.Context, sourceFileID int, metricsMap map[string]*Metric) error {
 
 	valueStrings := make([]string, 0, len(metricsMap))
-	valueArgs := make([]interface{}, 0, len(metricsMap)*4)
+	valueArgs := make([]interface{}, 0, len(metricsMap)*8)
 	argCounter := 1
 
-	for ticker, metrics := range metricsMap {
-		valueStrings = append(valueStrings, fmt.Sprintf("($%d, $%d, $%d, $%d)", argCounter, argCounter+1, argCounter+2, argCounter+3))
-		valueArgs = append(valueArgs, ticker, metrics.MaxRangeValue, metrics.MaxDailyVolume, metrics.TradeDate)
-		argCounter += 4
+	for _, metrics := range metricsMap {
+		valueStrings = append(valueStrings, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)", argCounter, argCounter+1, argCounter+2, argCounter+3, argCounter+4, argCounter+5, argCounter+6, argCounter+7))
+		valueArgs = append(valueArgs, metrics.Ticker, metrics.MaxRangeValue, metrics.MaxDailyVolume, metrics.TradeDate, metrics.SessionType, metrics.ClosePrice,
+			metrics.FinancialVolume, sourceFileID)
+		argCounter += 8
 	}
 
 	tx, err := r.db.Begin()
@@ -64,7 +112,7 @@ func (r *repository) BatchInsertMetrics(ctx context.Context, metricsMap map[stri
 		return err
 	}
 
-	stmt := fmt.Sprintf("INSERT INTO metrics (ticker, max_range_value, max_daily_volume, trade_date) VALUES %s", strings.Join(valueStrings, ","))
+	stmt := fmt.Sprintf("INSERT INTO metrics (ticker, max_range_value, max_daily_volume, trade_date, session_type, close_price, financial_volume, source_file_id) VALUES %s", strings.Join(valueStrings, ","))
 	_, err = tx.ExecContext(ctx, stmt, valueArgs...)
 	if err != nil {
 		tx.Rollback()
@@ -79,34 +127,1816 @@ func (r *repository) BatchInsertMetrics(ctx context.Context, metricsMap map[stri
 	return nil
 }
 
-func (r *repository) GetMetrics(ctx context.Context, ticker string, date time.Time) (*Metric, error) {
-	query := `
+func (r *repository) GetMetrics(ctx context.Context, filter MetricFilter) (*Metric, error) {
+	tickerColumn, conditions, args := metricConditions(filter)
+
+	query := fmt.Sprintf(`
 		SELECT 
-			m.ticker,
+			d.ticker,
+			MAX(d.max_range_value),
+			MAX(d.daily_volume)
+		FROM 
+			(
+				SELECT 
+					%s AS ticker,
+					m.trade_date,
+					MAX(m.max_range_value) AS max_range_value,
+					SUM(m.max_daily_volume) AS daily_volume
+				FROM 
+					metrics m
+				WHERE 
+					%s
+	`, tickerColumn, conditions)
+
+	// sessions and markets of the same day are summed before taking the max daily volume
+	query += fmt.Sprintf(` GROUP BY %s, m.trade_date ) d GROUP BY d.ticker; `, tickerColumn)
+
+	var data Metric
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(
+		&data.Ticker, &data.MaxRangeValue, &data.MaxDailyVolume,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &data, nil
+}
+
+// DailyMetrics returns the metrics of the ticker per day, ordered by date
+// The close price is taken from the latest session of the day and from the standard lot when consolidated
+func (r *repository) DailyMetrics(ctx context.Context, filter MetricFilter) ([]*DailyMetric, error) {
+	_, conditions, args := metricConditions(filter)
+
+	query := fmt.Sprintf(`
+		SELECT 
+			m.trade_date,
 			MAX(m.max_range_value),
-			MAX(m.max_daily_volume)
+			COALESCE((ARRAY_AGG(m.close_price ORDER BY m.session_type DESC, m.ticker))[1], 0),
+			SUM(m.max_daily_volume)
 		FROM 
 			metrics m
 		WHERE 
-			m.ticker = $1
+			%s
+		GROUP BY 
+			m.trade_date
+		ORDER BY 
+			m.trade_date;
+	`, conditions)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	metrics := make([]*DailyMetric, 0)
+	for rows.Next() {
+		metric := DailyMetric{Ticker: filter.Ticker}
+		if err = rows.Scan(&metric.TradeDate, &metric.MaxRangeValue, &metric.ClosePrice, &metric.MaxDailyVolume); err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, &metric)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return metrics, nil
+}
+
+// metricConditions returns the ticker column to group by and the where conditions of the metric filter
+func metricConditions(filter MetricFilter) (string, string, []interface{}) {
+	tickerColumn := "m.ticker"
+	conditions := "m.ticker = $1"
+	args := []interface{}{filter.Ticker}
+
+	if fractional, ok := instrument.Fractional(filter.Ticker); filter.Consolidated && ok {
+		// the fractional ticker is the standard lot ticker with the F suffix, trimming it groups both markets together
+		tickerColumn = "RTRIM(m.ticker, 'F')"
+		conditions = "m.ticker IN ($1, $2)"
+		args = append(args, fractional)
+	}
+
+	if !filter.Date.IsZero() {
+		args = append(args, filter.Date)
+		conditions += fmt.Sprintf(` AND m.trade_date >= $%d `, len(args))
+	}
+
+	if !filter.End.IsZero() {
+		args = append(args, filter.End)
+		conditions += fmt.Sprintf(` AND m.trade_date <= $%d `, len(args))
+	}
+
+	if filter.Session != 0 {
+		args = append(args, filter.Session)
+		conditions += fmt.Sprintf(` AND m.session_type = $%d `, len(args))
+	}
+
+	condition, args := instrumentTypeCondition(tickerColumn, filter.InstrumentType, args)
+
+	return tickerColumn, conditions + condition, args
+}
+
+func (r *repository) ListTrades(ctx context.Context, filter TradeFilter) ([]*Trade, error) {
+	query := `
+		SELECT 
+			t.id,
+			t.instrument_code,
+			t.trade_price,
+			t.trade_quantity,
+			t.close_time,
+			t.trade_date,
+			t.traded_at,
+			COALESCE(t.buyer_code, ''),
+			COALESCE(t.seller_code, ''),
+			COALESCE(t.session_type, 0),
+			COALESCE(t.trade_id, 0),
+			COALESCE((
+				SELECT a.auction_type 
+				FROM auctions a 
+				WHERE a.ticker = t.instrument_code AND a.trade_date = t.trade_date AND a.close_time = t.close_time AND t.session_type = $2
+				LIMIT 1
+			), '')
+		FROM 
+			trades t
+		WHERE 
+			t.id > $1
 	`
 
-	args := []interface{}{ticker}
+	args := []interface{}{filter.Cursor, SessionRegular}
 
-	if !date.IsZero() {
-		query += ` AND m.trade_date >= $2 `
-		args = append(args, date)
+	if filter.Ticker != "" {
+		args = append(args, filter.Ticker)
+		query += fmt.Sprintf(` AND t.instrument_code = $%d `, len(args))
 	}
 
-	query += ` GROUP BY m.ticker; `
+	if !filter.Date.IsZero() {
+		args = append(args, filter.Date)
+		query += fmt.Sprintf(` AND t.trade_date = $%d `, len(args))
+	}
 
-	var data Metric
-	err := r.db.QueryRowContext(ctx, query, args...).Scan(
-		&data.Ticker, &data.MaxRangeValue, &data.MaxDailyVolume,
-	)
+	// close_time is stored as HHMMSSmmm, so a fixed width string comparison keeps the time order
+	if !filter.FromTime.IsZero() {
+		args = append(args, filter.FromTime.Format("150405")+"000")
+		query += fmt.Sprintf(` AND t.close_time >= $%d `, len(args))
+	}
+
+	if !filter.ToTime.IsZero() {
+		args = append(args, filter.ToTime.Format("150405")+"000")
+		query += fmt.Sprintf(` AND t.close_time < $%d `, len(args))
+	}
+
+	if filter.MinQty > 0 {
+		args = append(args, filter.MinQty)
+		query += fmt.Sprintf(` AND t.trade_quantity >= $%d `, len(args))
+	}
+
+	condition, args := instrumentTypeCondition("t.instrument_code", filter.InstrumentType, args)
+	query += condition
+
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(` ORDER BY t.id LIMIT $%d; `, len(args))
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	return &data, nil
+	trades := make([]*Trade, 0)
+	for rows.Next() {
+		var data Trade
+		// trades loaded without a valid close time have no instant
+		var tradedAt sql.NullTime
+		err = rows.Scan(&data.ID, &data.InstrumentCode, &data.TradePrice, &data.TradeQuantity, &data.CloseTime, &data.TradeDate,
+			&tradedAt, &data.BuyerCode, &data.SellerCode, &data.SessionType, &data.TradeID, &data.Auction)
+		if err != nil {
+			return nil, err
+		}
+		data.TradedAt = tradedAt.Time
+		trades = append(trades, &data)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return trades, nil
+}
+
+func (r *repository) BatchInsertAnomalies(ctx context.Context, sourceFileID int, anomalies []*Anomaly) error {
+	valueStrings := make([]string, len(anomalies))
+	valueArgs := make([]interface{}, 0, len(anomalies)*9)
+
+	for i, anomaly := range anomalies {
+		valueStrings[i] = fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)", i*9+1, i*9+2, i*9+3, i*9+4, i*9+5, i*9+6, i*9+7, i*9+8, i*9+9)
+		valueArgs = append(valueArgs, anomaly.InstrumentCode, anomaly.TradePrice, anomaly.TradeQuantity, anomaly.CloseTime,
+			anomaly.TradeDate, anomaly.Reason, anomaly.ReferenceValue, anomaly.TradeID, sourceFileID)
+	}
+	stmt := fmt.Sprintf("INSERT INTO anomalies (instrument_code, trade_price, trade_quantity, close_time, trade_date, reason, reference_value, trade_id, source_file_id) VALUES %s",
+		strings.Join(valueStrings, ","))
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, stmt, valueArgs...)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *repository) ListAnomalies(ctx context.Context, filter AnomalyFilter) ([]*Anomaly, error) {
+	query := `
+		SELECT 
+			a.id,
+			a.instrument_code,
+			a.trade_price,
+			a.trade_quantity,
+			a.close_time,
+			a.trade_date,
+			a.reason,
+			a.reference_value
+		FROM 
+			anomalies a
+		WHERE 
+			1 = 1
+	`
+
+	var args []interface{}
+
+	if filter.Ticker != "" {
+		args = append(args, filter.Ticker)
+		query += fmt.Sprintf(` AND a.instrument_code = $%d `, len(args))
+	}
+
+	if !filter.Date.IsZero() {
+		args = append(args, filter.Date)
+		query += fmt.Sprintf(` AND a.trade_date = $%d `, len(args))
+	}
+
+	if filter.Reason != "" {
+		args = append(args, filter.Reason)
+		query += fmt.Sprintf(` AND a.reason = $%d `, len(args))
+	}
+
+	condition, args := instrumentTypeCondition("a.instrument_code", filter.InstrumentType, args)
+	query += condition
+
+	query += ` ORDER BY a.trade_date, a.instrument_code, a.close_time; `
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	anomalies := make([]*Anomaly, 0)
+	for rows.Next() {
+		var data Anomaly
+		err = rows.Scan(&data.ID, &data.InstrumentCode, &data.TradePrice, &data.TradeQuantity, &data.CloseTime,
+			&data.TradeDate, &data.Reason, &data.ReferenceValue)
+		if err != nil {
+			return nil, err
+		}
+		anomalies = append(anomalies, &data)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return anomalies, nil
+}
+
+func (r *repository) BatchInsertBlockTrades(ctx context.Context, sourceFileID int, blocks []*BlockTrade) error {
+	valueStrings := make([]string, len(blocks))
+	valueArgs := make([]interface{}, 0, len(blocks)*9)
+
+	for i, block := range blocks {
+		valueStrings[i] = fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)", i*9+1, i*9+2, i*9+3, i*9+4, i*9+5, i*9+6, i*9+7, i*9+8, i*9+9)
+		valueArgs = append(valueArgs, block.InstrumentCode, block.TradePrice, block.TradeQuantity, block.CloseTime,
+			block.TradeDate, block.BuyerCode, block.SellerCode, block.Criterion, sourceFileID)
+	}
+	stmt := fmt.Sprintf("INSERT INTO block_trades (instrument_code, trade_price, trade_quantity, close_time, trade_date, buyer_code, seller_code, criterion, source_file_id) VALUES %s",
+		strings.Join(valueStrings, ","))
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, stmt, valueArgs...)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *repository) ListBlockTrades(ctx context.Context, filter BlockTradeFilter) ([]*BlockTrade, error) {
+	query := `
+		SELECT 
+			b.id,
+			b.instrument_code,
+			b.trade_price,
+			b.trade_quantity,
+			b.close_time,
+			b.trade_date,
+			b.buyer_code,
+			b.seller_code,
+			b.criterion
+		FROM 
+			block_trades b
+		WHERE 
+			b.trade_date = $1
+	`
+
+	args := []interface{}{filter.Date}
+
+	if filter.Ticker != "" {
+		args = append(args, filter.Ticker)
+		query += fmt.Sprintf(` AND b.instrument_code = $%d `, len(args))
+	}
+
+	condition, args := instrumentTypeCondition("b.instrument_code", filter.InstrumentType, args)
+	query += condition
+
+	query += ` ORDER BY b.close_time, b.instrument_code; `
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	blocks := make([]*BlockTrade, 0)
+	for rows.Next() {
+		var data BlockTrade
+		err = rows.Scan(&data.ID, &data.InstrumentCode, &data.TradePrice, &data.TradeQuantity, &data.CloseTime,
+			&data.TradeDate, &data.BuyerCode, &data.SellerCode, &data.Criterion)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, &data)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return blocks, nil
+}
+
+// brokerConditions builds the trades conditions shared by the broker queries
+func brokerConditions(filter BrokerFilter, args []interface{}) (string, []interface{}) {
+	conditions := ""
+
+	if filter.Ticker != "" {
+		args = append(args, filter.Ticker)
+		conditions += fmt.Sprintf(` AND t.instrument_code = $%d `, len(args))
+	}
+
+	if !filter.Start.IsZero() {
+		args = append(args, filter.Start)
+		conditions += fmt.Sprintf(` AND t.trade_date >= $%d `, len(args))
+	}
+
+	if !filter.End.IsZero() {
+		args = append(args, filter.End)
+		conditions += fmt.Sprintf(` AND t.trade_date <= $%d `, len(args))
+	}
+
+	condition, args := instrumentTypeCondition("t.instrument_code", filter.InstrumentType, args)
+	conditions += condition
+
+	return conditions, args
+}
+
+// brokerLegs returns both sides of every trade as a row per participant, bought quantities are positive and sold ones negative
+func brokerLegs(conditions string) string {
+	return fmt.Sprintf(`
+		SELECT t.buyer_code AS broker, t.instrument_code, t.trade_date, t.trade_quantity AS quantity, t.trade_price * t.trade_quantity AS financial_volume
+		FROM trades t
+		WHERE t.buyer_code IS NOT NULL AND t.buyer_code <> '' %[1]s
+		UNION ALL
+		SELECT t.seller_code AS broker, t.instrument_code, t.trade_date, -t.trade_quantity AS quantity, -t.trade_price * t.trade_quantity AS financial_volume
+		FROM trades t
+		WHERE t.seller_code IS NOT NULL AND t.seller_code <> '' %[1]s
+	`, conditions)
+}
+
+func (r *repository) TopBrokers(ctx context.Context, filter BrokerFilter, side string) ([]*BrokerVolume, error) {
+	column := "t.buyer_code"
+	if side == SideSell {
+		column = "t.seller_code"
+	}
+
+	conditions, args := brokerConditions(filter, nil)
+	args = append(args, filter.Limit)
+
+	query := fmt.Sprintf(`
+		SELECT 
+			%[1]s,
+			SUM(t.trade_quantity),
+			SUM(t.trade_price * t.trade_quantity),
+			COUNT(*)
+		FROM 
+			trades t
+		WHERE 
+			%[1]s IS NOT NULL AND %[1]s <> '' %[2]s
+		GROUP BY %[1]s
+		ORDER BY SUM(t.trade_quantity) DESC
+		LIMIT $%[3]d;
+	`, column, conditions, len(args))
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	brokers := make([]*BrokerVolume, 0)
+	for rows.Next() {
+		var data BrokerVolume
+		err = rows.Scan(&data.Broker, &data.Quantity, &data.FinancialVolume, &data.Trades)
+		if err != nil {
+			return nil, err
+		}
+		brokers = append(brokers, &data)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return brokers, nil
+}
+
+func (r *repository) BrokerFlows(ctx context.Context, filter BrokerFilter) ([]*BrokerFlow, error) {
+	conditions, args := brokerConditions(filter, nil)
+
+	query := fmt.Sprintf(`
+		SELECT 
+			l.broker,
+			l.trade_date,
+			SUM(CASE WHEN l.quantity > 0 THEN l.quantity ELSE 0 END),
+			SUM(CASE WHEN l.quantity < 0 THEN -l.quantity ELSE 0 END),
+			SUM(l.quantity),
+			SUM(l.financial_volume)
+		FROM 
+			(%s) l
+	`, brokerLegs(conditions))
+
+	if filter.Broker != "" {
+		args = append(args, filter.Broker)
+		query += fmt.Sprintf(` WHERE l.broker = $%d `, len(args))
+	}
+
+	query += ` GROUP BY l.broker, l.trade_date ORDER BY l.trade_date, l.broker; `
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	flows := make([]*BrokerFlow, 0)
+	for rows.Next() {
+		var data BrokerFlow
+		err = rows.Scan(&data.Broker, &data.TradeDate, &data.BoughtQuantity, &data.SoldQuantity, &data.NetQuantity, &data.NetFinancialVolume)
+		if err != nil {
+			return nil, err
+		}
+		flows = append(flows, &data)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return flows, nil
+}
+
+func (r *repository) BrokerPositions(ctx context.Context, filter BrokerFilter) ([]*BrokerPosition, error) {
+	conditions, args := brokerConditions(filter, nil)
+
+	query := fmt.Sprintf(`
+		SELECT 
+			l.broker,
+			l.instrument_code,
+			SUM(l.quantity),
+			SUM(l.financial_volume)
+		FROM 
+			(%s) l
+	`, brokerLegs(conditions))
+
+	if filter.Broker != "" {
+		args = append(args, filter.Broker)
+		query += fmt.Sprintf(` WHERE l.broker = $%d `, len(args))
+	}
+
+	query += ` GROUP BY l.broker, l.instrument_code ORDER BY l.broker, l.instrument_code; `
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	positions := make([]*BrokerPosition, 0)
+	for rows.Next() {
+		var data BrokerPosition
+		err = rows.Scan(&data.Broker, &data.Ticker, &data.NetQuantity, &data.NetFinancialVolume)
+		if err != nil {
+			return nil, err
+		}
+		positions = append(positions, &data)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return positions, nil
+}
+
+// instrumentTypeCondition restricts the ticker column to the tickers classified with the instrument type
+func instrumentTypeCondition(column string, instrumentType instrument.Type, args []interface{}) (string, []interface{}) {
+	if instrumentType == "" {
+		return "", args
+	}
+
+	args = append(args, instrumentType)
+	return fmt.Sprintf(` AND %s IN (SELECT i.ticker FROM instruments i WHERE i.instrument_type = $%d) `, column, len(args)), args
+}
+
+func (r *repository) UpsertInstruments(ctx context.Context, instruments []*Instrument) error {
+	valueStrings := make([]string, len(instruments))
+	valueArgs := make([]interface{}, 0, len(instruments)*2)
+
+	for i, instrument := range instruments {
+		valueStrings[i] = fmt.Sprintf("($%d, $%d)", i*2+1, i*2+2)
+		valueArgs = append(valueArgs, instrument.Ticker, instrument.Type)
+	}
+	stmt := fmt.Sprintf("INSERT INTO instruments (ticker, instrument_type) VALUES %s ON CONFLICT (ticker) DO UPDATE SET instrument_type = EXCLUDED.instrument_type",
+		strings.Join(valueStrings, ","))
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, stmt, valueArgs...)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// UnclassifiedTickers returns the tickers with metrics but without an instrument type
+func (r *repository) UnclassifiedTickers(ctx context.Context) ([]string, error) {
+	query := `
+		SELECT DISTINCT 
+			m.ticker
+		FROM 
+			metrics m
+		LEFT JOIN instruments i ON i.ticker = m.ticker
+		WHERE 
+			i.ticker IS NULL;
+	`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tickers := make([]string, 0)
+	for rows.Next() {
+		var ticker string
+		if err = rows.Scan(&ticker); err != nil {
+			return nil, err
+		}
+		tickers = append(tickers, ticker)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tickers, nil
+}
+
+// ImportInstruments stores the instrument master data, replacing the underlying and strike of known tickers
+func (r *repository) ImportInstruments(ctx context.Context, instruments []*Instrument) error {
+	valueStrings := make([]string, len(instruments))
+	valueArgs := make([]interface{}, 0, len(instruments)*4)
+
+	for i, instrument := range instruments {
+		valueStrings[i] = fmt.Sprintf("($%d, $%d, NULLIF($%d, ''), $%d)", i*4+1, i*4+2, i*4+3, i*4+4)
+		valueArgs = append(valueArgs, instrument.Ticker, instrument.Type, instrument.Underlying, instrument.Strike)
+	}
+	stmt := fmt.Sprintf("INSERT INTO instruments (ticker, instrument_type, underlying, strike) VALUES %s ON CONFLICT (ticker) DO UPDATE SET instrument_type = EXCLUDED.instrument_type, underlying = EXCLUDED.underlying, strike = EXCLUDED.strike",
+		strings.Join(valueStrings, ","))
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, stmt, valueArgs...)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// ListOptionSeries returns the options of the underlying with metrics on the date
+// Options without an imported underlying are matched by the root of their ticker
+func (r *repository) ListOptionSeries(ctx context.Context, filter OptionFilter) ([]*OptionSeries, error) {
+	query := `
+		SELECT 
+			m.ticker,
+			COALESCE(i.underlying, ''),
+			i.strike,
+			MAX(m.max_range_value),
+			SUM(m.max_daily_volume)
+		FROM 
+			metrics m
+		JOIN instruments i ON i.ticker = m.ticker
+		WHERE 
+			i.instrument_type = $1
+			AND m.trade_date = $2
+			AND (i.underlying = $3 OR (i.underlying IS NULL AND LEFT(i.ticker, 4) = $4))
+		GROUP BY 
+			m.ticker, i.underlying, i.strike
+		ORDER BY 
+			m.ticker;
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, instrument.TypeOption, filter.Date, filter.Underlying, instrument.Root(filter.Underlying))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	series := make([]*OptionSeries, 0)
+	for rows.Next() {
+		var option OptionSeries
+		var strike decimal.NullDecimal
+		if err = rows.Scan(&option.Ticker, &option.Underlying, &strike, &option.MaxRangeValue, &option.MaxDailyVolume); err != nil {
+			return nil, err
+		}
+		if strike.Valid {
+			option.Strike = &strike.Decimal
+		}
+		series = append(series, &option)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return series, nil
+}
+
+// ListContractDays returns the regular session close and volume of every contract of the future root, ordered by date
+// Metrics loaded before the close price existed are left out
+func (r *repository) ListContractDays(ctx context.Context, filter ContinuousFilter) ([]*ContractDay, error) {
+	query := `
+		SELECT 
+			m.ticker,
+			m.trade_date,
+			m.close_price,
+			m.max_daily_volume
+		FROM 
+			metrics m
+		JOIN instruments i ON i.ticker = m.ticker
+		WHERE 
+			i.instrument_type = $1
+			AND LEFT(m.ticker, 3) = $2
+			AND m.session_type = $3
+			AND m.close_price IS NOT NULL
+	`
+
+	args := []interface{}{instrument.TypeFuture, filter.Root, SessionRegular}
+
+	if !filter.Start.IsZero() {
+		args = append(args, filter.Start)
+		query += fmt.Sprintf(` AND m.trade_date >= $%d `, len(args))
+	}
+
+	if !filter.End.IsZero() {
+		args = append(args, filter.End)
+		query += fmt.Sprintf(` AND m.trade_date <= $%d `, len(args))
+	}
+
+	query += ` ORDER BY m.trade_date, m.ticker; `
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	days := make([]*ContractDay, 0)
+	for rows.Next() {
+		var day ContractDay
+		if err = rows.Scan(&day.Ticker, &day.TradeDate, &day.ClosePrice, &day.Volume); err != nil {
+			return nil, err
+		}
+		days = append(days, &day)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return days, nil
+}
+
+// UpsertCorporateActions stores the corporate actions, replacing the factor of an action already loaded
+func (r *repository) UpsertCorporateActions(ctx context.Context, actions []*CorporateAction) error {
+	valueStrings := make([]string, len(actions))
+	valueArgs := make([]interface{}, 0, len(actions)*4)
+
+	for i, action := range actions {
+		valueStrings[i] = fmt.Sprintf("($%d, $%d, $%d, $%d)", i*4+1, i*4+2, i*4+3, i*4+4)
+		valueArgs = append(valueArgs, action.Ticker, action.ExDate, action.Type, action.Factor)
+	}
+	stmt := fmt.Sprintf("INSERT INTO corporate_actions (ticker, ex_date, action_type, factor) VALUES %s ON CONFLICT (ticker, ex_date, action_type) DO UPDATE SET factor = EXCLUDED.factor",
+		strings.Join(valueStrings, ","))
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, stmt, valueArgs...)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// ListCorporateActions returns the corporate actions of the ticker ordered by ex date
+func (r *repository) ListCorporateActions(ctx context.Context, ticker string) ([]*CorporateAction, error) {
+	query := `
+		SELECT 
+			c.id,
+			c.ticker,
+			c.ex_date,
+			c.action_type,
+			c.factor
+		FROM 
+			corporate_actions c
+		WHERE 
+			c.ticker = $1
+		ORDER BY 
+			c.ex_date, c.id;
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, ticker)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	actions := make([]*CorporateAction, 0)
+	for rows.Next() {
+		var action CorporateAction
+		if err = rows.Scan(&action.ID, &action.Ticker, &action.ExDate, &action.Type, &action.Factor); err != nil {
+			return nil, err
+		}
+		actions = append(actions, &action)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return actions, nil
+}
+
+// IntradayVolumes sums the volume and number of trades of the ticker per time of day bucket
+// The bucket is taken from the HH and MM of close_time, rows without a valid close time are skipped
+func (r *repository) IntradayVolumes(ctx context.Context, filter ActivityFilter) ([]*IntradayVolume, error) {
+	query := `
+		WITH activity AS (
+			SELECT 
+				t.trade_date,
+				t.trade_quantity,
+				SUBSTRING(t.close_time, 1, 2)::INT * 60 + SUBSTRING(t.close_time, 3, 2)::INT AS minute_of_day
+			FROM 
+				trades t
+			WHERE 
+				t.instrument_code = $1
+				AND t.close_time ~ '^[0-9]{9}$'
+	`
+
+	args := []interface{}{filter.Ticker, filter.BucketMinutes}
+
+	if !filter.Start.IsZero() {
+		args = append(args, filter.Start)
+		query += fmt.Sprintf(` AND t.trade_date >= $%d `, len(args))
+	}
+
+	if !filter.End.IsZero() {
+		args = append(args, filter.End)
+		query += fmt.Sprintf(` AND t.trade_date <= $%d `, len(args))
+	}
+
+	query += `
+		)
+		SELECT 
+			(a.minute_of_day / $2) * $2 AS bucket,
+			SUM(a.trade_quantity),
+			COUNT(*),
+			(SELECT COUNT(DISTINCT trade_date) FROM activity)
+		FROM 
+			activity a
+		GROUP BY 
+			bucket
+		ORDER BY 
+			bucket;
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	volumes := make([]*IntradayVolume, 0)
+	for rows.Next() {
+		var volume IntradayVolume
+		if err = rows.Scan(&volume.Minute, &volume.Volume, &volume.Trades, &volume.Days); err != nil {
+			return nil, err
+		}
+		volumes = append(volumes, &volume)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return volumes, nil
+}
+
+// MarketBreadth compares the regular session close of every stock with its previous sessions, day by day
+// The financial volume sums every ticker and session of the day
+func (r *repository) MarketBreadth(ctx context.Context, filter BreadthFilter) ([]*MarketBreadth, error) {
+	// the window frame takes the number of sessions as a literal, it is an int so it is safe to format
+	query := fmt.Sprintf(`
+		WITH closes AS (
+			SELECT 
+				m.trade_date,
+				m.close_price,
+				LAG(m.close_price) OVER w AS previous_close,
+				MAX(m.close_price) OVER (w ROWS BETWEEN %[1]d PRECEDING AND 1 PRECEDING) AS previous_high,
+				MIN(m.close_price) OVER (w ROWS BETWEEN %[1]d PRECEDING AND 1 PRECEDING) AS previous_low
+			FROM 
+				metrics m
+			JOIN instruments i ON i.ticker = m.ticker
+			WHERE 
+				i.instrument_type = $1
+				AND m.session_type = $2
+				AND m.close_price IS NOT NULL
+				AND m.trade_date >= $3
+				AND m.trade_date <= $5
+			WINDOW w AS (PARTITION BY m.ticker ORDER BY m.trade_date)
+		),
+		volumes AS (
+			SELECT 
+				m.trade_date,
+				SUM(COALESCE(m.financial_volume, 0)) AS financial_volume
+			FROM 
+				metrics m
+			WHERE 
+				m.trade_date >= $4
+				AND m.trade_date <= $5
+			GROUP BY 
+				m.trade_date
+		)
+		SELECT 
+			c.trade_date,
+			COUNT(*) FILTER (WHERE c.close_price > c.previous_close),
+			COUNT(*) FILTER (WHERE c.close_price < c.previous_close),
+			COUNT(*) FILTER (WHERE c.close_price = c.previous_close),
+			COUNT(*) FILTER (WHERE c.close_price > c.previous_high),
+			COUNT(*) FILTER (WHERE c.close_price < c.previous_low),
+			COALESCE(MAX(v.financial_volume), 0)
+		FROM 
+			closes c
+		LEFT JOIN volumes v ON v.trade_date = c.trade_date
+		WHERE 
+			c.trade_date >= $4
+		GROUP BY 
+			c.trade_date
+		ORDER BY 
+			c.trade_date;
+	`, filter.Days)
+
+	rows, err := r.db.QueryContext(ctx, query, instrument.TypeStock, SessionRegular, filter.HistoryStart, filter.Start, filter.End)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	breadth := make([]*MarketBreadth, 0)
+	for rows.Next() {
+		var day MarketBreadth
+		err = rows.Scan(&day.TradeDate, &day.Advancing, &day.Declining, &day.Unchanged, &day.NewHighs, &day.NewLows, &day.FinancialVolume)
+		if err != nil {
+			return nil, err
+		}
+		breadth = append(breadth, &day)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return breadth, nil
+}
+
+// DailyLiquidity returns the daily close and volumes of the ticker, the close is the regular session one so an after
+// market print does not move the returns while the volumes sum every session
+func (r *repository) DailyLiquidity(ctx context.Context, filter LiquidityFilter) ([]*DailyLiquidity, error) {
+	query := `
+		SELECT 
+			m.trade_date,
+			COALESCE((ARRAY_AGG(m.close_price ORDER BY m.id DESC) FILTER (WHERE m.session_type = $4 AND m.close_price IS NOT NULL))[1], 0),
+			SUM(m.max_daily_volume),
+			SUM(COALESCE(m.financial_volume, 0))
+		FROM 
+			metrics m
+		WHERE 
+			m.ticker = $1
+			AND m.trade_date >= $2
+			AND m.trade_date <= $3
+		GROUP BY 
+			m.trade_date
+		ORDER BY 
+			m.trade_date;
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, filter.Ticker, filter.Start, filter.End, SessionRegular)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	daily := make([]*DailyLiquidity, 0)
+	for rows.Next() {
+		var day DailyLiquidity
+		if err = rows.Scan(&day.TradeDate, &day.ClosePrice, &day.Volume, &day.FinancialVolume); err != nil {
+			return nil, err
+		}
+		daily = append(daily, &day)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return daily, nil
+}
+
+// PriceCovariance returns the covariance of each trade price change with the previous one
+// The changes are paired within the same day so the overnight gap is left out
+func (r *repository) PriceCovariance(ctx context.Context, filter LiquidityFilter) (*PriceCovariance, error) {
+	query := `
+		WITH changes AS (
+			SELECT 
+				t.trade_date,
+				t.traded_at,
+				t.id,
+				t.trade_price - LAG(t.trade_price) OVER (PARTITION BY t.trade_date ORDER BY t.traded_at, t.id) AS change
+			FROM 
+				trades t
+			WHERE 
+				t.instrument_code = $1
+				AND t.trade_date >= $2
+				AND t.trade_date <= $3
+		), pairs AS (
+			SELECT 
+				c.change,
+				LAG(c.change) OVER (PARTITION BY c.trade_date ORDER BY c.traded_at, c.id) AS previous_change
+			FROM 
+				changes c
+		)
+		SELECT 
+			COALESCE(COVAR_SAMP(p.change, p.previous_change), 0)::NUMERIC,
+			COUNT(p.previous_change)
+		FROM 
+			pairs p;
+	`
+
+	var covariance PriceCovariance
+	err := r.db.QueryRowContext(ctx, query, filter.Ticker, filter.Start, filter.End).
+		Scan(&covariance.Covariance, &covariance.Pairs)
+	if err != nil {
+		return nil, err
+	}
+
+	return &covariance, nil
+}
+
+func (r *repository) BatchInsertOrderFlow(ctx context.Context, sourceFileID int, flows []*OrderFlow) error {
+	valueStrings := make([]string, len(flows))
+	valueArgs := make([]interface{}, 0, len(flows)*9)
+
+	for i, flow := range flows {
+		valueStrings[i] = fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)", i*9+1, i*9+2, i*9+3, i*9+4, i*9+5, i*9+6, i*9+7, i*9+8, i*9+9)
+		valueArgs = append(valueArgs, flow.Ticker, flow.TradeDate, flow.Minute, flow.BuyVolume, flow.SellVolume,
+			flow.UnclassifiedVolume, flow.BuyTrades, flow.SellTrades, sourceFileID)
+	}
+	stmt := fmt.Sprintf("INSERT INTO order_flow (ticker, trade_date, bucket_minute, buy_volume, sell_volume, unclassified_volume, buy_trades, sell_trades, source_file_id) VALUES %s",
+		strings.Join(valueStrings, ","))
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, stmt, valueArgs...)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// ListOrderFlow returns the order flow buckets of the ticker, summed when a bucket was loaded by more than one file
+func (r *repository) ListOrderFlow(ctx context.Context, filter OrderFlowFilter) ([]*OrderFlow, error) {
+	query := `
+		SELECT 
+			o.trade_date,
+			o.bucket_minute,
+			SUM(o.buy_volume),
+			SUM(o.sell_volume),
+			SUM(o.unclassified_volume),
+			SUM(o.buy_trades),
+			SUM(o.sell_trades)
+		FROM 
+			order_flow o
+		WHERE 
+			o.ticker = $1
+	`
+
+	args := []interface{}{filter.Ticker}
+
+	if !filter.Start.IsZero() {
+		args = append(args, filter.Start)
+		query += fmt.Sprintf(` AND o.trade_date >= $%d `, len(args))
+	}
+
+	if !filter.End.IsZero() {
+		args = append(args, filter.End)
+		query += fmt.Sprintf(` AND o.trade_date <= $%d `, len(args))
+	}
+
+	query += `
+		GROUP BY 
+			o.trade_date, o.bucket_minute
+		ORDER BY 
+			o.trade_date, o.bucket_minute;
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	flows := make([]*OrderFlow, 0)
+	for rows.Next() {
+		flow := OrderFlow{Ticker: filter.Ticker}
+		err = rows.Scan(&flow.TradeDate, &flow.Minute, &flow.BuyVolume, &flow.SellVolume, &flow.UnclassifiedVolume,
+			&flow.BuyTrades, &flow.SellTrades)
+		if err != nil {
+			return nil, err
+		}
+		flows = append(flows, &flow)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return flows, nil
+}
+
+// ListWindowTrades returns the prices and quantities of the ticker trades of the day between the from and to times
+// The trades are ordered by close time and B3 trade id, the to time is exclusive
+func (r *repository) ListWindowTrades(ctx context.Context, filter TradeFilter) ([]*Trade, error) {
+	query := `
+		SELECT 
+			t.trade_price,
+			t.trade_quantity,
+			t.close_time
+		FROM 
+			trades t
+		WHERE 
+			t.instrument_code = $1
+			AND t.trade_date = $2
+			AND t.close_time >= $3
+			AND t.close_time < $4
+		ORDER BY 
+			t.close_time, t.trade_id, t.id;
+	`
+
+	// close_time is stored as HHMMSSmmm, so a fixed width string comparison keeps the time order
+	rows, err := r.db.QueryContext(ctx, query, filter.Ticker, filter.Date,
+		filter.FromTime.Format("150405")+"000", filter.ToTime.Format("150405")+"000")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	trades := make([]*Trade, 0)
+	for rows.Next() {
+		data := Trade{InstrumentCode: filter.Ticker, TradeDate: filter.Date}
+		if err = rows.Scan(&data.TradePrice, &data.TradeQuantity, &data.CloseTime); err != nil {
+			return nil, err
+		}
+		trades = append(trades, &data)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return trades, nil
+}
+
+func (r *repository) BatchInsertAuctions(ctx context.Context, sourceFileID int, auctions []*Auction) error {
+	valueStrings := make([]string, len(auctions))
+	valueArgs := make([]interface{}, 0, len(auctions)*8)
+
+	for i, auction := range auctions {
+		valueStrings[i] = fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)", i*8+1, i*8+2, i*8+3, i*8+4, i*8+5, i*8+6, i*8+7, i*8+8)
+		valueArgs = append(valueArgs, auction.Ticker, auction.TradeDate, auction.Type, auction.CloseTime, auction.Price,
+			auction.Volume, auction.Trades, sourceFileID)
+	}
+	stmt := fmt.Sprintf("INSERT INTO auctions (ticker, trade_date, auction_type, close_time, price, volume, trades, source_file_id) VALUES %s",
+		strings.Join(valueStrings, ","))
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, stmt, valueArgs...)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *repository) ListAuctions(ctx context.Context, filter AuctionFilter) ([]*Auction, error) {
+	query := `
+		SELECT 
+			a.ticker,
+			a.trade_date,
+			a.auction_type,
+			a.close_time,
+			a.price,
+			a.volume,
+			a.trades
+		FROM 
+			auctions a
+		WHERE 
+			a.ticker = $1
+	`
+
+	args := []interface{}{filter.Ticker}
+
+	if !filter.Start.IsZero() {
+		args = append(args, filter.Start)
+		query += fmt.Sprintf(` AND a.trade_date >= $%d `, len(args))
+	}
+
+	if !filter.End.IsZero() {
+		args = append(args, filter.End)
+		query += fmt.Sprintf(` AND a.trade_date <= $%d `, len(args))
+	}
+
+	if filter.Type != "" {
+		args = append(args, filter.Type)
+		query += fmt.Sprintf(` AND a.auction_type = $%d `, len(args))
+	}
+
+	query += ` ORDER BY a.trade_date, a.auction_type DESC; `
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	auctions := make([]*Auction, 0)
+	for rows.Next() {
+		var auction Auction
+		err = rows.Scan(&auction.Ticker, &auction.TradeDate, &auction.Type, &auction.CloseTime, &auction.Price,
+			&auction.Volume, &auction.Trades)
+		if err != nil {
+			return nil, err
+		}
+		auctions = append(auctions, &auction)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return auctions, nil
+}
+
+// CreateUpload registers the file as processing and fills its id and creation time
+// A file with the checksum of an upload processing or completed is rejected with ErrDuplicateFile
+func (r *repository) CreateUpload(ctx context.Context, upload *Upload) error {
+	return createUpload(ctx, r.db, upload)
+}
+
+// queryRower is the part of sql.DB and sql.Tx used to create an upload alone or along a rollback
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func createUpload(ctx context.Context, db queryRower, upload *Upload) error {
+	query := `
+		INSERT INTO source_files (file_name, file_size, sha256, uploader, blob_key, replay_of, status, trades) 
+		VALUES ($1, $2, $3, $4, $5, $6, $7, 0) 
+		RETURNING id, created_at;
+	`
+
+	err := db.QueryRowContext(ctx, query, upload.FileName, upload.FileSize, upload.Checksum, upload.Uploader, upload.BlobKey,
+		upload.ReplayOf, upload.Status).
+		Scan(&upload.ID, &upload.CreatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+			return ErrDuplicateFile
+		}
+		return err
+	}
+
+	return nil
+}
+
+// FinishUpload stores the final status, number of trades and reference date of the upload
+func (r *repository) FinishUpload(ctx context.Context, upload *Upload) error {
+	query := `
+		UPDATE source_files 
+		SET status = $2, trades = $3, reference_date = $4, finished_at = NOW() 
+		WHERE id = $1;
+	`
+
+	_, err := r.db.ExecContext(ctx, query, upload.ID, upload.Status, upload.Trades, upload.ReferenceDate)
+	return err
+}
+
+const uploadColumns = `
+	u.id,
+	COALESCE(u.file_name, ''),
+	COALESCE(u.file_size, 0),
+	COALESCE(u.sha256, ''),
+	u.reference_date,
+	COALESCE(u.uploader, ''),
+	COALESCE(u.blob_key, ''),
+	u.replay_of,
+	u.status,
+	u.trades,
+	u.created_at,
+	u.finished_at
+`
+
+func (r *repository) GetUpload(ctx context.Context, id int) (*Upload, error) {
+	query := `
+		SELECT ` + uploadColumns + `
+		FROM 
+			source_files u
+		WHERE 
+			u.id = $1;
+	`
+
+	return scanUpload(r.db.QueryRowContext(ctx, query, id))
+}
+
+// FindUploadByChecksum returns the latest upload of the file with the checksum that is processing or completed
+func (r *repository) FindUploadByChecksum(ctx context.Context, checksum string) (*Upload, error) {
+	query := `
+		SELECT ` + uploadColumns + `
+		FROM 
+			source_files u
+		WHERE 
+			u.sha256 = $1 
+			AND u.status IN ($2, $3)
+		ORDER BY 
+			u.id DESC
+		LIMIT 1;
+	`
+
+	return scanUpload(r.db.QueryRowContext(ctx, query, checksum, UploadProcessing, UploadCompleted))
+}
+
+func scanUpload(row *sql.Row) (*Upload, error) {
+	var upload Upload
+	var referenceDate, finishedAt sql.NullTime
+	var replayOf sql.NullInt64
+	err := row.Scan(&upload.ID, &upload.FileName, &upload.FileSize, &upload.Checksum, &referenceDate, &upload.Uploader,
+		&upload.BlobKey, &replayOf, &upload.Status, &upload.Trades, &upload.CreatedAt, &finishedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUploadNotFound
+		}
+		return nil, err
+	}
+
+	if referenceDate.Valid {
+		upload.ReferenceDate = &referenceDate.Time
+	}
+	if replayOf.Valid {
+		id := int(replayOf.Int64)
+		upload.ReplayOf = &id
+	}
+	if finishedAt.Valid {
+		upload.FinishedAt = &finishedAt.Time
+	}
+
+	return &upload, nil
+}
+
+// DeleteUpload removes the trades of the upload and recomputes the metrics of their tickers, days and sessions
+// from the remaining trades in a single transaction, marking the upload as deleted and storing the audit entry
+func (r *repository) DeleteUpload(ctx context.Context, audit *UploadAudit) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err = deleteUpload(ctx, tx, audit); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// ReplayUpload rolls back the upload and registers its replay in a single transaction, so a replay that can not be
+// registered keeps the upload loaded. An upload already deleted has nothing left to roll back
+func (r *repository) ReplayUpload(ctx context.Context, audit *UploadAudit, replay *Upload) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err = deleteUpload(ctx, tx, audit); err != nil && !errors.Is(err, ErrUploadDeleted) {
+		tx.Rollback()
+		return err
+	}
+
+	if err = createUpload(ctx, tx, replay); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// PurgeUpload removes every row stored from the upload but its quality issues, which explain a rejected file
+func (r *repository) PurgeUpload(ctx context.Context, uploadID int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	for _, stmt := range []string{
+		`DELETE FROM trades WHERE source_file_id = $1;`,
+		`DELETE FROM metrics WHERE source_file_id = $1;`,
+	} {
+		if _, err = tx.ExecContext(ctx, stmt, uploadID); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err = deleteDerivedRows(ctx, tx, uploadID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// deleteDerivedRows removes the anomalies, block trades, auctions, order flow and trade sequences of the upload
+func deleteDerivedRows(ctx context.Context, tx *sql.Tx, uploadID int) error {
+	for _, stmt := range []string{
+		`DELETE FROM anomalies WHERE source_file_id = $1;`,
+		`DELETE FROM block_trades WHERE source_file_id = $1;`,
+		`DELETE FROM auctions WHERE source_file_id = $1;`,
+		`DELETE FROM order_flow WHERE source_file_id = $1;`,
+		`DELETE FROM trade_sequences WHERE upload_id = $1;`,
+	} {
+		if _, err := tx.ExecContext(ctx, stmt, uploadID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func deleteUpload(ctx context.Context, tx *sql.Tx, audit *UploadAudit) error {
+	// the lock keeps a concurrent delete from running on the same upload
+	var status string
+	err := tx.QueryRowContext(ctx, `SELECT status FROM source_files WHERE id = $1 FOR UPDATE;`, audit.UploadID).Scan(&status)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrUploadNotFound
+		}
+		return err
+	}
+
+	switch status {
+	case UploadProcessing:
+		return ErrUploadProcessing
+	case UploadDeleted:
+		return ErrUploadDeleted
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		WITH deleted AS (
+			DELETE FROM trades 
+			WHERE source_file_id = $1 
+			RETURNING instrument_code, trade_date, session_type
+		)
+		SELECT 
+			instrument_code,
+			trade_date,
+			session_type,
+			COUNT(*)
+		FROM 
+			deleted
+		GROUP BY 
+			instrument_code, trade_date, session_type;
+	`, audit.UploadID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	// tickers, days and sessions with trades removed
+	var tickers, dates []string
+	var sessions []int64
+	for rows.Next() {
+		var ticker string
+		var date time.Time
+		var session int64
+		var trades int
+		if err = rows.Scan(&ticker, &date, &session, &trades); err != nil {
+			return err
+		}
+		tickers = append(tickers, ticker)
+		dates = append(dates, date.Format("2006-01-02"))
+		sessions = append(sessions, session)
+		audit.Trades += trades
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	// the rows derived from the file go with its trades, so a reload of the file does not count them twice
+	if err = deleteDerivedRows(ctx, tx, audit.UploadID); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM metrics 
+		WHERE 
+			source_file_id = $1 
+			OR (ticker, trade_date, session_type) IN (
+				SELECT * FROM UNNEST($2::text[], $3::date[], $4::int[])
+			);
+	`, audit.UploadID, pq.Array(tickers), pq.Array(dates), pq.Array(sessions))
+	if err != nil {
+		return err
+	}
+
+	// the metrics are recomputed per source file as when they were ingested, price outliers are not used for the
+	// max range value and close price unless every trade of the session is one
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO metrics (ticker, max_range_value, max_daily_volume, trade_date, session_type, close_price, financial_volume, source_file_id) 
+		SELECT 
+			t.instrument_code,
+			COALESCE(MAX(t.trade_price) FILTER (WHERE NOT t.outlier), MAX(t.trade_price)),
+			SUM(t.trade_quantity),
+			t.trade_date,
+			t.session_type,
+			COALESCE(
+				(ARRAY_AGG(t.trade_price ORDER BY t.close_time DESC, t.id DESC) FILTER (WHERE NOT t.outlier))[1],
+				(ARRAY_AGG(t.trade_price ORDER BY t.close_time DESC, t.id DESC))[1]
+			),
+			SUM(t.trade_price * t.trade_quantity),
+			t.source_file_id
+		FROM 
+			(
+				SELECT 
+					tr.*,
+					EXISTS (
+						SELECT 1 
+						FROM anomalies a 
+						WHERE 
+							a.source_file_id = tr.source_file_id 
+							AND a.instrument_code = tr.instrument_code 
+							AND a.trade_date = tr.trade_date 
+							AND a.trade_id = tr.trade_id 
+							AND a.reason = $4
+					) AS outlier
+				FROM 
+					trades tr
+				WHERE 
+					(tr.instrument_code, tr.trade_date, tr.session_type) IN (
+						SELECT * FROM UNNEST($1::text[], $2::date[], $3::int[])
+					)
+			) t
+		GROUP BY 
+			t.instrument_code, t.trade_date, t.session_type, t.source_file_id;
+	`, pq.Array(tickers), pq.Array(dates), pq.Array(sessions), AnomalyPriceDeviation)
+	if err != nil {
+		return err
+	}
+
+	metrics, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	audit.Metrics = int(metrics)
+
+	_, err = tx.ExecContext(ctx, `UPDATE source_files SET status = $2 WHERE id = $1;`, audit.UploadID, UploadDeleted)
+	if err != nil {
+		return err
+	}
+
+	return tx.QueryRowContext(ctx, `
+		INSERT INTO upload_audit (upload_id, action, actor, reason, trades, metrics) 
+		VALUES ($1, $2, $3, $4, $5, $6) 
+		RETURNING id, created_at;
+	`, audit.UploadID, audit.Action, audit.Actor, audit.Reason, audit.Trades, audit.Metrics).Scan(&audit.ID, &audit.CreatedAt)
+}
+
+func (r *repository) BatchInsertSequences(ctx context.Context, uploadID int, sequences []*TradeSequence) error {
+	valueStrings := make([]string, len(sequences))
+	valueArgs := make([]interface{}, 0, len(sequences)*12)
+
+	for i, sequence := range sequences {
+		valueStrings[i] = fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)",
+			i*12+1, i*12+2, i*12+3, i*12+4, i*12+5, i*12+6, i*12+7, i*12+8, i*12+9, i*12+10, i*12+11, i*12+12)
+		valueArgs = append(valueArgs, uploadID, sequence.Ticker, sequence.TradeDate, sequence.Trades, sequence.FirstTradeID,
+			sequence.LastTradeID, sequence.Step, sequence.Missing, sequence.Gaps, sequence.Duplicates, sequence.OutOfOrder, sequence.OutOfStep)
+	}
+	stmt := fmt.Sprintf("INSERT INTO trade_sequences (upload_id, ticker, trade_date, trades, first_trade_id, last_trade_id, step, missing, gaps, duplicates, out_of_order, out_of_step) VALUES %s",
+		strings.Join(valueStrings, ","))
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, stmt, valueArgs...)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *repository) ListSequences(ctx context.Context, filter SequenceFilter) ([]*TradeSequence, error) {
+	query := `
+		SELECT 
+			s.ticker,
+			s.trade_date,
+			s.trades,
+			s.first_trade_id,
+			s.last_trade_id,
+			s.step,
+			s.missing,
+			s.gaps,
+			s.duplicates,
+			s.out_of_order,
+			COALESCE(s.out_of_step, 0)
+		FROM 
+			trade_sequences s
+		WHERE 
+			s.upload_id = $1
+	`
+
+	args := []interface{}{filter.UploadID}
+
+	if filter.Ticker != "" {
+		args = append(args, filter.Ticker)
+		query += fmt.Sprintf(` AND s.ticker = $%d `, len(args))
+	}
+
+	if filter.Incomplete {
+		query += ` AND (s.missing > 0 OR s.duplicates > 0 OR s.out_of_order > 0 OR s.out_of_step > 0) `
+	}
+
+	query += ` ORDER BY s.ticker, s.trade_date; `
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sequences := make([]*TradeSequence, 0)
+	for rows.Next() {
+		var sequence TradeSequence
+		err = rows.Scan(&sequence.Ticker, &sequence.TradeDate, &sequence.Trades, &sequence.FirstTradeID, &sequence.LastTradeID,
+			&sequence.Step, &sequence.Missing, &sequence.Gaps, &sequence.Duplicates, &sequence.OutOfOrder, &sequence.OutOfStep)
+		if err != nil {
+			return nil, err
+		}
+		sequence.Complete = sequence.Missing == 0 && sequence.Duplicates == 0 && sequence.OutOfOrder == 0 && sequence.OutOfStep == 0
+		sequences = append(sequences, &sequence)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sequences, nil
+}
+
+// ListCloses returns the regular session close of each ticker on the date
+// A ticker loaded more than once keeps the close of its latest load
+func (r *repository) ListCloses(ctx context.Context, date time.Time) (map[string]decimal.Decimal, error) {
+	query := `
+		SELECT DISTINCT ON (m.ticker)
+			m.ticker,
+			m.close_price
+		FROM 
+			metrics m
+		WHERE 
+			m.trade_date = $1 
+			AND m.session_type = $2 
+			AND m.close_price IS NOT NULL
+		ORDER BY 
+			m.ticker, m.id DESC;
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, date, SessionRegular)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	closes := make(map[string]decimal.Decimal)
+	for rows.Next() {
+		var ticker string
+		var closePrice decimal.Decimal
+		err = rows.Scan(&ticker, &closePrice)
+		if err != nil {
+			return nil, err
+		}
+		closes[ticker] = closePrice
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return closes, nil
+}
+
+// ListAnomalyBaselines returns the regular session close and the average trade quantity of each ticker on the date
+// Trades flagged with an extreme size are kept out of the average
+func (r *repository) ListAnomalyBaselines(ctx context.Context, date time.Time) (map[string]*AnomalyBaseline, error) {
+	query := `
+		SELECT 
+			t.instrument_code,
+			COALESCE((
+				SELECT m.close_price 
+				FROM metrics m 
+				WHERE 
+					m.ticker = t.instrument_code 
+					AND m.trade_date = t.trade_date 
+					AND m.session_type = $2 
+					AND m.close_price IS NOT NULL 
+				ORDER BY m.id DESC 
+				LIMIT 1
+			), 0),
+			AVG(t.trade_quantity)
+		FROM 
+			trades t
+		WHERE 
+			t.trade_date = $1 
+			AND t.session_type = $2 
+			AND NOT EXISTS (
+				SELECT 1 
+				FROM anomalies a 
+				WHERE 
+					a.source_file_id = t.source_file_id 
+					AND a.instrument_code = t.instrument_code 
+					AND a.trade_date = t.trade_date 
+					AND a.trade_id = t.trade_id 
+					AND a.reason = $3
+			)
+		GROUP BY 
+			t.instrument_code, t.trade_date;
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, date, SessionRegular, AnomalyExtremeSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	baselines := make(map[string]*AnomalyBaseline)
+	for rows.Next() {
+		var baseline AnomalyBaseline
+		err = rows.Scan(&baseline.Ticker, &baseline.ClosePrice, &baseline.AverageQuantity)
+		if err != nil {
+			return nil, err
+		}
+		baselines[baseline.Ticker] = &baseline
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return baselines, nil
+}
+
+func (r *repository) BatchInsertQualityIssues(ctx context.Context, uploadID int, issues []*QualityIssue) error {
+	valueStrings := make([]string, len(issues))
+	valueArgs := make([]interface{}, 0, len(issues)*6)
+
+	for i, issue := range issues {
+		samples, err := json.Marshal(issue.Samples)
+		if err != nil {
+			return err
+		}
+
+		valueStrings[i] = fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d)", i*6+1, i*6+2, i*6+3, i*6+4, i*6+5, i*6+6)
+		valueArgs = append(valueArgs, uploadID, issue.Issue, issue.Rows, issue.Ratio, issue.Rejected, samples)
+	}
+	stmt := fmt.Sprintf("INSERT INTO quality_issues (upload_id, issue, row_count, ratio, rejected, samples) VALUES %s",
+		strings.Join(valueStrings, ","))
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, stmt, valueArgs...)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *repository) ListQualityIssues(ctx context.Context, uploadID int) ([]*QualityIssue, error) {
+	query := `
+		SELECT 
+			q.issue,
+			q.row_count,
+			q.ratio,
+			q.rejected,
+			q.samples
+		FROM 
+			quality_issues q
+		WHERE 
+			q.upload_id = $1
+		ORDER BY 
+			q.issue;
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	issues := make([]*QualityIssue, 0)
+	for rows.Next() {
+		var issue QualityIssue
+		var samples []byte
+		err = rows.Scan(&issue.Issue, &issue.Rows, &issue.Ratio, &issue.Rejected, &samples)
+		if err != nil {
+			return nil, err
+		}
+
+		if err = json.Unmarshal(samples, &issue.Samples); err != nil {
+			return nil, err
+		}
+		issues = append(issues, &issue)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return issues, nil
 }
@@ -2,11 +2,15 @@ package trade
 
 import (
 	"context"
+	"database/sql"
+	"database/sql/driver"
 	"errors"
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"quotation-metrics/internal/instrument"
 	"regexp"
 	"testing"
 	"time"
@@ -28,6 +32,11 @@ func TestBatchInsertTrade(t *testing.T) {
 					TradeQuantity:  10,
 					CloseTime:      "15:00:00",
 					TradeDate:      time.Date(2024, 6, 20, 0, 0, 0, 0, time.UTC),
+					TradedAt:       time.Date(2024, 6, 20, 18, 0, 0, 0, time.UTC),
+					BuyerCode:      "3",
+					SellerCode:     "23",
+					SessionType:    1,
+					TradeID:        10,
 				},
 				{
 					InstrumentCode: "AAPL",
@@ -35,13 +44,18 @@ func TestBatchInsertTrade(t *testing.T) {
 					TradeQuantity:  15,
 					CloseTime:      "15:00:00",
 					TradeDate:      time.Date(2024, 6, 20, 0, 0, 0, 0, time.UTC),
+					TradedAt:       time.Date(2024, 6, 20, 18, 0, 0, 0, time.UTC),
+					BuyerCode:      "114",
+					SellerCode:     "114",
+					SessionType:    6,
+					TradeID:        20,
 				},
 			},
 			mockFunc: func(mock sqlmock.Sqlmock) {
 				mock.ExpectBegin()
-				mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO trades (instrument_code, trade_price, trade_quantity, close_time, trade_date) VALUES ($1, $2, $3, $4, $5),($6, $7, $8, $9, $10)`)).
-					WithArgs("GOOG", decimal.NewFromFloat(1500.25), 10, "15:00:00", time.Date(2024, 6, 20, 0, 0, 0, 0, time.UTC),
-						"AAPL", decimal.NewFromFloat(1300.50), 15, "15:00:00", time.Date(2024, 6, 20, 0, 0, 0, 0, time.UTC)).
+				mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO trades (instrument_code, trade_price, trade_quantity, close_time, trade_date, traded_at, buyer_code, seller_code, session_type, trade_id, source_file_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11),($12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22)`)).
+					WithArgs("GOOG", decimal.NewFromFloat(1500.25), 10, "15:00:00", time.Date(2024, 6, 20, 0, 0, 0, 0, time.UTC), time.Date(2024, 6, 20, 18, 0, 0, 0, time.UTC), "3", "23", 1, 10, 7,
+						"AAPL", decimal.NewFromFloat(1300.50), 15, "15:00:00", time.Date(2024, 6, 20, 0, 0, 0, 0, time.UTC), time.Date(2024, 6, 20, 18, 0, 0, 0, time.UTC), "114", "114", 6, 20, 7).
 					WillReturnResult(sqlmock.NewResult(2, 2))
 				mock.ExpectCommit()
 			},
@@ -56,12 +70,17 @@ func TestBatchInsertTrade(t *testing.T) {
 					TradeQuantity:  10,
 					CloseTime:      "15:00:00",
 					TradeDate:      time.Date(2024, 6, 20, 0, 0, 0, 0, time.UTC),
+					TradedAt:       time.Date(2024, 6, 20, 18, 0, 0, 0, time.UTC),
+					BuyerCode:      "3",
+					SellerCode:     "23",
+					SessionType:    1,
+					TradeID:        10,
 				},
 			},
 			mockFunc: func(mock sqlmock.Sqlmock) {
 				mock.ExpectBegin()
-				mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO trades (instrument_code, trade_price, trade_quantity, close_time, trade_date) VALUES ($1, $2, $3, $4, $5)`)).
-					WithArgs("GOOG", decimal.NewFromFloat(1500.25), 10, "15:00:00", time.Date(2024, 6, 20, 0, 0, 0, 0, time.UTC)).
+				mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO trades (instrument_code, trade_price, trade_quantity, close_time, trade_date, traded_at, buyer_code, seller_code, session_type, trade_id, source_file_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`)).
+					WithArgs("GOOG", decimal.NewFromFloat(1500.25), 10, "15:00:00", time.Date(2024, 6, 20, 0, 0, 0, 0, time.UTC), time.Date(2024, 6, 20, 18, 0, 0, 0, time.UTC), "3", "23", 1, 10, 7).
 					WillReturnError(errors.New("insert error"))
 				mock.ExpectRollback()
 			},
@@ -76,12 +95,17 @@ func TestBatchInsertTrade(t *testing.T) {
 					TradeQuantity:  10,
 					CloseTime:      "15:00:00",
 					TradeDate:      time.Date(2024, 6, 20, 0, 0, 0, 0, time.UTC),
+					TradedAt:       time.Date(2024, 6, 20, 18, 0, 0, 0, time.UTC),
+					BuyerCode:      "3",
+					SellerCode:     "23",
+					SessionType:    1,
+					TradeID:        10,
 				},
 			},
 			mockFunc: func(mock sqlmock.Sqlmock) {
 				mock.ExpectBegin()
-				mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO trades (instrument_code, trade_price, trade_quantity, close_time, trade_date) VALUES ($1, $2, $3, $4, $5)`)).
-					WithArgs("GOOG", decimal.NewFromFloat(1500.25), 10, "15:00:00", time.Date(2024, 6, 20, 0, 0, 0, 0, time.UTC)).
+				mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO trades (instrument_code, trade_price, trade_quantity, close_time, trade_date, traded_at, buyer_code, seller_code, session_type, trade_id, source_file_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`)).
+					WithArgs("GOOG", decimal.NewFromFloat(1500.25), 10, "15:00:00", time.Date(2024, 6, 20, 0, 0, 0, 0, time.UTC), time.Date(2024, 6, 20, 18, 0, 0, 0, time.UTC), "3", "23", 1, 10, 7).
 					WillReturnResult(sqlmock.NewResult(1, 1))
 				mock.ExpectCommit().WillReturnError(errors.New("commit error"))
 			},
@@ -106,7 +130,7 @@ func TestBatchInsertTrade(t *testing.T) {
 
 			r := NewRepository(db)
 
-			err = r.BatchInsertTrade(context.Background(), tc.trades)
+			err = r.BatchInsertTrade(context.Background(), 7, tc.trades)
 			assert.Equal(t, tc.wantErr, err)
 			assert.NoError(t, mock.ExpectationsWereMet())
 		})
@@ -124,16 +148,19 @@ func TestBatchInsertMetrics(t *testing.T) {
 			name: "success",
 			metrics: map[string]*Metric{
 				"GOOG": {
-					Ticker:         "GOOG",
-					MaxRangeValue:  decimal.NewFromInt(29),
-					MaxDailyVolume: 11,
-					TradeDate:      time.Date(2024, 06, 20, 0, 0, 0, 0, time.UTC),
+					Ticker:          "GOOG",
+					MaxRangeValue:   decimal.NewFromInt(29),
+					MaxDailyVolume:  11,
+					TradeDate:       time.Date(2024, 06, 20, 0, 0, 0, 0, time.UTC),
+					SessionType:     1,
+					ClosePrice:      decimal.NewFromInt(28),
+					FinancialVolume: decimal.NewFromInt(319),
 				},
 			},
 			mockFunc: func(mock sqlmock.Sqlmock) {
 				mock.ExpectBegin()
-				mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO metrics (ticker, max_range_value, max_daily_volume, trade_date) VALUES ($1, $2, $3, $4)`)).
-					WithArgs("GOOG", decimal.NewFromInt(29), 11, time.Date(2024, 06, 20, 0, 0, 0, 0, time.UTC)).
+				mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO metrics (ticker, max_range_value, max_daily_volume, trade_date, session_type, close_price, financial_volume, source_file_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`)).
+					WithArgs("GOOG", decimal.NewFromInt(29), 11, time.Date(2024, 06, 20, 0, 0, 0, 0, time.UTC), 1, decimal.NewFromInt(28), decimal.NewFromInt(319), 7).
 					WillReturnResult(sqlmock.NewResult(2, 2))
 				mock.ExpectCommit()
 			},
@@ -143,16 +170,19 @@ func TestBatchInsertMetrics(t *testing.T) {
 			name: "failed because insert error",
 			metrics: map[string]*Metric{
 				"GOOG": {
-					Ticker:         "GOOG",
-					MaxRangeValue:  decimal.NewFromInt(29),
-					MaxDailyVolume: 11,
-					TradeDate:      time.Date(2024, 06, 20, 0, 0, 0, 0, time.UTC),
+					Ticker:          "GOOG",
+					MaxRangeValue:   decimal.NewFromInt(29),
+					MaxDailyVolume:  11,
+					TradeDate:       time.Date(2024, 06, 20, 0, 0, 0, 0, time.UTC),
+					SessionType:     1,
+					ClosePrice:      decimal.NewFromInt(28),
+					FinancialVolume: decimal.NewFromInt(319),
 				},
 			},
 			mockFunc: func(mock sqlmock.Sqlmock) {
 				mock.ExpectBegin()
-				mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO metrics (ticker, max_range_value, max_daily_volume, trade_date) VALUES ($1, $2, $3, $4)`)).
-					WithArgs("GOOG", decimal.NewFromInt(29), 11, time.Date(2024, 06, 20, 0, 0, 0, 0, time.UTC)).
+				mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO metrics (ticker, max_range_value, max_daily_volume, trade_date, session_type, close_price, financial_volume, source_file_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`)).
+					WithArgs("GOOG", decimal.NewFromInt(29), 11, time.Date(2024, 06, 20, 0, 0, 0, 0, time.UTC), 1, decimal.NewFromInt(28), decimal.NewFromInt(319), 7).
 					WillReturnError(errors.New("insert error"))
 				mock.ExpectRollback()
 			},
@@ -162,16 +192,19 @@ func TestBatchInsertMetrics(t *testing.T) {
 			name: "failed because commit error",
 			metrics: map[string]*Metric{
 				"GOOG": {
-					Ticker:         "GOOG",
-					MaxRangeValue:  decimal.NewFromInt(29),
-					MaxDailyVolume: 11,
-					TradeDate:      time.Date(2024, 06, 20, 0, 0, 0, 0, time.UTC),
+					Ticker:          "GOOG",
+					MaxRangeValue:   decimal.NewFromInt(29),
+					MaxDailyVolume:  11,
+					TradeDate:       time.Date(2024, 06, 20, 0, 0, 0, 0, time.UTC),
+					SessionType:     1,
+					ClosePrice:      decimal.NewFromInt(28),
+					FinancialVolume: decimal.NewFromInt(319),
 				},
 			},
 			mockFunc: func(mock sqlmock.Sqlmock) {
 				mock.ExpectBegin()
-				mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO metrics (ticker, max_range_value, max_daily_volume, trade_date) VALUES ($1, $2, $3, $4)`)).
-					WithArgs("GOOG", decimal.NewFromInt(29), 11, time.Date(2024, 06, 20, 0, 0, 0, 0, time.UTC)).
+				mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO metrics (ticker, max_range_value, max_daily_volume, trade_date, session_type, close_price, financial_volume, source_file_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`)).
+					WithArgs("GOOG", decimal.NewFromInt(29), 11, time.Date(2024, 06, 20, 0, 0, 0, 0, time.UTC), 1, decimal.NewFromInt(28), decimal.NewFromInt(319), 7).
 					WillReturnResult(sqlmock.NewResult(1, 1))
 				mock.ExpectCommit().WillReturnError(errors.New("commit error"))
 			},
@@ -196,7 +229,7 @@ func TestBatchInsertMetrics(t *testing.T) {
 
 			r := NewRepository(db)
 
-			err = r.BatchInsertMetrics(context.Background(), tc.metrics)
+			err = r.BatchInsertMetrics(context.Background(), 7, tc.metrics)
 			assert.Equal(t, tc.wantErr, err)
 			assert.NoError(t, mock.ExpectationsWereMet())
 		})
@@ -205,19 +238,17 @@ func TestBatchInsertMetrics(t *testing.T) {
 func TestGetMetrics(t *testing.T) {
 	cases := []struct {
 		name     string
-		ticker   string
-		date     time.Time
+		filter   MetricFilter
 		mockFunc func(sqlmock.Sqlmock)
 		want     *Metric
 		wantErr  error
 	}{
 		{
 			name:   "success with date",
-			ticker: "GOOG",
-			date:   time.Date(2024, 06, 20, 0, 0, 0, 0, time.UTC),
+			filter: MetricFilter{Ticker: "GOOG", Date: time.Date(2024, 06, 20, 0, 0, 0, 0, time.UTC), Session: SessionRegular},
 			mockFunc: func(mock sqlmock.Sqlmock) {
-				mock.ExpectQuery(regexp.QuoteMeta(`SELECT m.ticker, MAX(m.max_range_value), MAX(m.max_daily_volume) FROM metrics m WHERE m.ticker = $1 AND m.trade_date >= $2 GROUP BY m.ticker;`)).
-					WithArgs("GOOG", time.Date(2024, 06, 20, 0, 0, 0, 0, time.UTC)).
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT d.ticker, MAX(d.max_range_value), MAX(d.daily_volume) FROM ( SELECT m.ticker AS ticker, m.trade_date, MAX(m.max_range_value) AS max_range_value, SUM(m.max_daily_volume) AS daily_volume FROM metrics m WHERE m.ticker = $1 AND m.trade_date >= $2 AND m.session_type = $3 GROUP BY m.ticker, m.trade_date ) d GROUP BY d.ticker;`)).
+					WithArgs("GOOG", time.Date(2024, 06, 20, 0, 0, 0, 0, time.UTC), SessionRegular).
 					WillReturnRows(sqlmock.NewRows([]string{"ticker", "max_range_value", "max_daily_volume"}).
 						AddRow("GOOG", 29, 11))
 			},
@@ -230,10 +261,9 @@ func TestGetMetrics(t *testing.T) {
 		},
 		{
 			name:   "success without date",
-			ticker: "AAPL",
-			date:   time.Time{},
+			filter: MetricFilter{Ticker: "AAPL"},
 			mockFunc: func(mock sqlmock.Sqlmock) {
-				mock.ExpectQuery(regexp.QuoteMeta(`SELECT m.ticker, MAX(m.max_range_value), MAX(m.max_daily_volume) FROM metrics m WHERE m.ticker = $1 GROUP BY m.ticker;`)).
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT d.ticker, MAX(d.max_range_value), MAX(d.daily_volume) FROM ( SELECT m.ticker AS ticker, m.trade_date, MAX(m.max_range_value) AS max_range_value, SUM(m.max_daily_volume) AS daily_volume FROM metrics m WHERE m.ticker = $1 GROUP BY m.ticker, m.trade_date ) d GROUP BY d.ticker;`)).
 					WithArgs("AAPL").
 					WillReturnRows(sqlmock.NewRows([]string{"ticker", "max_range_value", "max_daily_volume"}).
 						AddRow("AAPL", 50, 20))
@@ -244,12 +274,71 @@ func TestGetMetrics(t *testing.T) {
 				MaxDailyVolume: 20,
 			},
 		},
+		{
+			name:   "success with after market session",
+			filter: MetricFilter{Ticker: "AAPL", Session: SessionAfterMarket},
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT d.ticker, MAX(d.max_range_value), MAX(d.daily_volume) FROM ( SELECT m.ticker AS ticker, m.trade_date, MAX(m.max_range_value) AS max_range_value, SUM(m.max_daily_volume) AS daily_volume FROM metrics m WHERE m.ticker = $1 AND m.session_type = $2 GROUP BY m.ticker, m.trade_date ) d GROUP BY d.ticker;`)).
+					WithArgs("AAPL", SessionAfterMarket).
+					WillReturnRows(sqlmock.NewRows([]string{"ticker", "max_range_value", "max_daily_volume"}).
+						AddRow("AAPL", 51, 2))
+			},
+			want: &Metric{
+				Ticker:         "AAPL",
+				MaxRangeValue:  decimal.NewFromInt(51),
+				MaxDailyVolume: 2,
+			},
+		},
+		{
+			name:   "success consolidated with fractional market",
+			filter: MetricFilter{Ticker: "PETR4", Session: SessionRegular, Consolidated: true},
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT d.ticker, MAX(d.max_range_value), MAX(d.daily_volume) FROM ( SELECT RTRIM(m.ticker, 'F') AS ticker, m.trade_date, MAX(m.max_range_value) AS max_range_value, SUM(m.max_daily_volume) AS daily_volume FROM metrics m WHERE m.ticker IN ($1, $2) AND m.session_type = $3 GROUP BY RTRIM(m.ticker, 'F'), m.trade_date ) d GROUP BY d.ticker;`)).
+					WithArgs("PETR4", "PETR4F", SessionRegular).
+					WillReturnRows(sqlmock.NewRows([]string{"ticker", "max_range_value", "max_daily_volume"}).
+						AddRow("PETR4", 39, 320))
+			},
+			want: &Metric{
+				Ticker:         "PETR4",
+				MaxRangeValue:  decimal.NewFromInt(39),
+				MaxDailyVolume: 320,
+			},
+		},
+		{
+			name:   "success consolidated without fractional market",
+			filter: MetricFilter{Ticker: "WINQ24", Consolidated: true},
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT d.ticker, MAX(d.max_range_value), MAX(d.daily_volume) FROM ( SELECT m.ticker AS ticker, m.trade_date, MAX(m.max_range_value) AS max_range_value, SUM(m.max_daily_volume) AS daily_volume FROM metrics m WHERE m.ticker = $1 GROUP BY m.ticker, m.trade_date ) d GROUP BY d.ticker;`)).
+					WithArgs("WINQ24").
+					WillReturnRows(sqlmock.NewRows([]string{"ticker", "max_range_value", "max_daily_volume"}).
+						AddRow("WINQ24", 5, 40))
+			},
+			want: &Metric{
+				Ticker:         "WINQ24",
+				MaxRangeValue:  decimal.NewFromInt(5),
+				MaxDailyVolume: 40,
+			},
+		},
+		{
+			name:   "success with instrument type",
+			filter: MetricFilter{Ticker: "PETR4", Session: SessionRegular, InstrumentType: instrument.TypeStock},
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT d.ticker, MAX(d.max_range_value), MAX(d.daily_volume) FROM ( SELECT m.ticker AS ticker, m.trade_date, MAX(m.max_range_value) AS max_range_value, SUM(m.max_daily_volume) AS daily_volume FROM metrics m WHERE m.ticker = $1 AND m.session_type = $2 AND m.ticker IN (SELECT i.ticker FROM instruments i WHERE i.instrument_type = $3) GROUP BY m.ticker, m.trade_date ) d GROUP BY d.ticker;`)).
+					WithArgs("PETR4", SessionRegular, "stock").
+					WillReturnRows(sqlmock.NewRows([]string{"ticker", "max_range_value", "max_daily_volume"}).
+						AddRow("PETR4", 39, 300))
+			},
+			want: &Metric{
+				Ticker:         "PETR4",
+				MaxRangeValue:  decimal.NewFromInt(39),
+				MaxDailyVolume: 300,
+			},
+		},
 		{
 			name:   "failed because query error",
-			ticker: "MSFT",
-			date:   time.Time{},
+			filter: MetricFilter{Ticker: "MSFT"},
 			mockFunc: func(mock sqlmock.Sqlmock) {
-				mock.ExpectQuery(regexp.QuoteMeta(`SELECT m.ticker, MAX(m.max_range_value), MAX(m.max_daily_volume) FROM metrics m WHERE m.ticker = $1 GROUP BY m.ticker;`)).
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT d.ticker, MAX(d.max_range_value), MAX(d.daily_volume) FROM ( SELECT m.ticker AS ticker, m.trade_date, MAX(m.max_range_value) AS max_range_value, SUM(m.max_daily_volume) AS daily_volume FROM metrics m WHERE m.ticker = $1 GROUP BY m.ticker, m.trade_date ) d GROUP BY d.ticker;`)).
 					WithArgs("MSFT").
 					WillReturnError(errors.New("query error"))
 			},
@@ -268,7 +357,318 @@ func TestGetMetrics(t *testing.T) {
 
 			r := NewRepository(db)
 
-			got, err := r.GetMetrics(context.Background(), tc.ticker, tc.date)
+			got, err := r.GetMetrics(context.Background(), tc.filter)
+
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestListTrades(t *testing.T) {
+	cases := []struct {
+		name     string
+		filter   TradeFilter
+		mockFunc func(sqlmock.Sqlmock)
+		want     []*Trade
+		wantErr  error
+	}{
+		{
+			name: "success with all filters",
+			filter: TradeFilter{
+				Ticker:   "PETR4",
+				Date:     time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC),
+				FromTime: time.Date(0, 1, 1, 10, 0, 0, 0, time.UTC),
+				ToTime:   time.Date(0, 1, 1, 11, 30, 0, 0, time.UTC),
+				MinQty:   100,
+				Cursor:   10,
+				Limit:    2,
+			},
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT t.id, t.instrument_code, t.trade_price, t.trade_quantity, t.close_time, t.trade_date, t.traded_at, COALESCE(t.buyer_code, ''), COALESCE(t.seller_code, ''), COALESCE(t.session_type, 0), COALESCE(t.trade_id, 0), COALESCE(( SELECT a.auction_type FROM auctions a WHERE a.ticker = t.instrument_code AND a.trade_date = t.trade_date AND a.close_time = t.close_time AND t.session_type = $2 LIMIT 1 ), '') FROM trades t WHERE t.id > $1 AND t.instrument_code = $3 AND t.trade_date = $4 AND t.close_time >= $5 AND t.close_time < $6 AND t.trade_quantity >= $7 ORDER BY t.id LIMIT $8;`)).
+					WithArgs(10, SessionRegular, "PETR4", time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC), "100000000", "113000000", 100, 2).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "instrument_code", "trade_price", "trade_quantity", "close_time", "trade_date", "traded_at", "buyer_code", "seller_code", "session_type", "trade_id", "auction"}).
+						AddRow(11, "PETR4", 38.5, 100, "100001250", time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC), time.Date(2024, 06, 28, 13, 0, 1, 250000000, time.UTC), "3", "23", 1, 5501, "opening").
+						AddRow(15, "PETR4", 38.6, 200, "101500000", time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC), nil, "72", "8", 6, 5502, ""))
+			},
+			want: []*Trade{
+				{
+					ID:             11,
+					InstrumentCode: "PETR4",
+					TradePrice:     decimal.NewFromFloat(38.5),
+					TradeQuantity:  100,
+					CloseTime:      "100001250",
+					TradeDate:      time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC),
+					TradedAt:       time.Date(2024, 06, 28, 13, 0, 1, 250000000, time.UTC),
+					BuyerCode:      "3",
+					SellerCode:     "23",
+					SessionType:    1,
+					TradeID:        5501,
+					Auction:        "opening",
+				},
+				{
+					ID:             15,
+					InstrumentCode: "PETR4",
+					TradePrice:     decimal.NewFromFloat(38.6),
+					TradeQuantity:  200,
+					CloseTime:      "101500000",
+					TradeDate:      time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC),
+					BuyerCode:      "72",
+					SellerCode:     "8",
+					SessionType:    6,
+					TradeID:        5502,
+				},
+			},
+		},
+		{
+			name:   "success without filters",
+			filter: TradeFilter{Limit: 100},
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT t.id, t.instrument_code, t.trade_price, t.trade_quantity, t.close_time, t.trade_date, t.traded_at, COALESCE(t.buyer_code, ''), COALESCE(t.seller_code, ''), COALESCE(t.session_type, 0), COALESCE(t.trade_id, 0), COALESCE(( SELECT a.auction_type FROM auctions a WHERE a.ticker = t.instrument_code AND a.trade_date = t.trade_date AND a.close_time = t.close_time AND t.session_type = $2 LIMIT 1 ), '') FROM trades t WHERE t.id > $1 ORDER BY t.id LIMIT $3;`)).
+					WithArgs(0, SessionRegular, 100).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "instrument_code", "trade_price", "trade_quantity", "close_time", "trade_date", "traded_at", "buyer_code", "seller_code", "session_type", "trade_id", "auction"}))
+			},
+			want: []*Trade{},
+		},
+		{
+			name:   "failed because query error",
+			filter: TradeFilter{Limit: 100},
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT t.id, t.instrument_code, t.trade_price, t.trade_quantity, t.close_time, t.trade_date, t.traded_at, COALESCE(t.buyer_code, ''), COALESCE(t.seller_code, ''), COALESCE(t.session_type, 0), COALESCE(t.trade_id, 0), COALESCE(( SELECT a.auction_type FROM auctions a WHERE a.ticker = t.instrument_code AND a.trade_date = t.trade_date AND a.close_time = t.close_time AND t.session_type = $2 LIMIT 1 ), '') FROM trades t WHERE t.id > $1 ORDER BY t.id LIMIT $3;`)).
+					WithArgs(0, SessionRegular, 100).
+					WillReturnError(errors.New("query error"))
+			},
+			want:    nil,
+			wantErr: errors.New("query error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			tc.mockFunc(mock)
+
+			r := NewRepository(db)
+
+			got, err := r.ListTrades(context.Background(), tc.filter)
+
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+// exchangeDate matches a date argument by its calendar day in its own location, which is the day postgres keeps
+// when the text sent by the driver is cast to DATE, whatever the session timezone is
+type exchangeDate string
+
+func (d exchangeDate) Match(v driver.Value) bool {
+	date, ok := v.(time.Time)
+	return ok && date.Format("2006-01-02") == string(d)
+}
+
+func TestDatePredicatesUseExchangeDate(t *testing.T) {
+	cases := []struct {
+		name     string
+		query    string
+		args     []driver.Value
+		callFunc func(r Repository, date time.Time) error
+	}{
+		{
+			name:  "trades",
+			query: `t.trade_date = $3`,
+			args:  []driver.Value{0, SessionRegular, exchangeDate("2024-06-28"), 100},
+			callFunc: func(r Repository, date time.Time) error {
+				_, err := r.ListTrades(context.Background(), TradeFilter{Date: date, Limit: 100})
+				return err
+			},
+		},
+		{
+			name:  "anomalies",
+			query: `a.trade_date = $1`,
+			args:  []driver.Value{exchangeDate("2024-06-28")},
+			callFunc: func(r Repository, date time.Time) error {
+				_, err := r.ListAnomalies(context.Background(), AnomalyFilter{Date: date})
+				return err
+			},
+		},
+		{
+			name:  "block trades",
+			query: `b.trade_date = $1`,
+			args:  []driver.Value{exchangeDate("2024-06-28")},
+			callFunc: func(r Repository, date time.Time) error {
+				_, err := r.ListBlockTrades(context.Background(), BlockTradeFilter{Date: date})
+				return err
+			},
+		},
+	}
+
+	for _, zone := range []string{"UTC", "America/Sao_Paulo", "Asia/Tokyo", "Pacific/Kiritimati"} {
+		location, err := time.LoadLocation(zone)
+		require.NoError(t, err)
+		date := time.Date(2024, 06, 28, 0, 0, 0, 0, location)
+
+		for _, tc := range cases {
+			t.Run(zone+" "+tc.name, func(t *testing.T) {
+				db, mock, err := sqlmock.New()
+				assert.NoError(t, err)
+				defer db.Close()
+
+				mock.ExpectQuery(regexp.QuoteMeta(tc.query)).
+					WithArgs(tc.args...).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+				assert.NoError(t, tc.callFunc(NewRepository(db), date))
+				assert.NoError(t, mock.ExpectationsWereMet())
+			})
+		}
+	}
+}
+
+func TestBatchInsertAnomalies(t *testing.T) {
+	cases := []struct {
+		name      string
+		anomalies []*Anomaly
+		mockFunc  func(sqlmock.Sqlmock)
+		wantErr   error
+	}{
+		{
+			name: "success",
+			anomalies: []*Anomaly{
+				{
+					InstrumentCode: "PETR4",
+					TradePrice:     decimal.NewFromInt(382),
+					TradeQuantity:  100,
+					CloseTime:      "100200000",
+					TradeDate:      time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC),
+					Reason:         AnomalyPriceDeviation,
+					ReferenceValue: decimal.NewFromFloat(38.1),
+					TradeID:        5510,
+				},
+			},
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO anomalies (instrument_code, trade_price, trade_quantity, close_time, trade_date, reason, reference_value, trade_id, source_file_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`)).
+					WithArgs("PETR4", decimal.NewFromInt(382), 100, "100200000", time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC), AnomalyPriceDeviation, decimal.NewFromFloat(38.1), 5510, 3).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "failed because insert error",
+			anomalies: []*Anomaly{
+				{
+					InstrumentCode: "PETR4",
+					TradePrice:     decimal.NewFromInt(382),
+					TradeQuantity:  100,
+					CloseTime:      "100200000",
+					TradeDate:      time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC),
+					Reason:         AnomalyPriceDeviation,
+					ReferenceValue: decimal.NewFromFloat(38.1),
+				},
+			},
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO anomalies`)).
+					WillReturnError(errors.New("insert error"))
+				mock.ExpectRollback()
+			},
+			wantErr: errors.New("insert error"),
+		},
+		{
+			name: "failed because begin error",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin().WillReturnError(errors.New("begin error"))
+			},
+			wantErr: errors.New("begin error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer db.Close()
+
+			tc.mockFunc(mock)
+
+			r := NewRepository(db)
+
+			err = r.BatchInsertAnomalies(context.Background(), 3, tc.anomalies)
+			assert.Equal(t, tc.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestListAnomalies(t *testing.T) {
+	cases := []struct {
+		name     string
+		filter   AnomalyFilter
+		mockFunc func(sqlmock.Sqlmock)
+		want     []*Anomaly
+		wantErr  error
+	}{
+		{
+			name:   "success with filters",
+			filter: AnomalyFilter{Ticker: "PETR4", Date: time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC), Reason: AnomalyExtremeSize},
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT a.id, a.instrument_code, a.trade_price, a.trade_quantity, a.close_time, a.trade_date, a.reason, a.reference_value FROM anomalies a WHERE 1 = 1 AND a.instrument_code = $1 AND a.trade_date = $2 AND a.reason = $3 ORDER BY a.trade_date, a.instrument_code, a.close_time;`)).
+					WithArgs("PETR4", time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC), AnomalyExtremeSize).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "instrument_code", "trade_price", "trade_quantity", "close_time", "trade_date", "reason", "reference_value"}).
+						AddRow(3, "PETR4", 38, 90000, "100200000", time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC), AnomalyExtremeSize, 150))
+			},
+			want: []*Anomaly{
+				{
+					ID:             3,
+					InstrumentCode: "PETR4",
+					TradePrice:     decimal.NewFromInt(38),
+					TradeQuantity:  90000,
+					CloseTime:      "100200000",
+					TradeDate:      time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC),
+					Reason:         AnomalyExtremeSize,
+					ReferenceValue: decimal.NewFromInt(150),
+				},
+			},
+		},
+		{
+			name:   "success with instrument type",
+			filter: AnomalyFilter{InstrumentType: instrument.TypeStock},
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT a.id, a.instrument_code, a.trade_price, a.trade_quantity, a.close_time, a.trade_date, a.reason, a.reference_value FROM anomalies a WHERE 1 = 1 AND a.instrument_code IN (SELECT i.ticker FROM instruments i WHERE i.instrument_type = $1) ORDER BY a.trade_date, a.instrument_code, a.close_time;`)).
+					WithArgs("stock").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "instrument_code", "trade_price", "trade_quantity", "close_time", "trade_date", "reason", "reference_value"}))
+			},
+			want: []*Anomaly{},
+		},
+		{
+			name:   "failed because query error",
+			filter: AnomalyFilter{},
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT a.id, a.instrument_code, a.trade_price, a.trade_quantity, a.close_time, a.trade_date, a.reason, a.reference_value FROM anomalies a WHERE 1 = 1 ORDER BY a.trade_date, a.instrument_code, a.close_time;`)).
+					WillReturnError(errors.New("query error"))
+			},
+			want:    nil,
+			wantErr: errors.New("query error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			tc.mockFunc(mock)
+
+			r := NewRepository(db)
+
+			got, err := r.ListAnomalies(context.Background(), tc.filter)
 
 			assert.Equal(t, tc.want, got)
 			assert.Equal(t, tc.wantErr, err)
@@ -276,3 +676,2221 @@ func TestGetMetrics(t *testing.T) {
 		})
 	}
 }
+
+func TestBatchInsertBlockTrades(t *testing.T) {
+	cases := []struct {
+		name     string
+		blocks   []*BlockTrade
+		mockFunc func(sqlmock.Sqlmock)
+		wantErr  error
+	}{
+		{
+			name: "success",
+			blocks: []*BlockTrade{
+				{
+					InstrumentCode: "PETR4",
+					TradePrice:     decimal.NewFromFloat(38.2),
+					TradeQuantity:  50000,
+					CloseTime:      "100100000",
+					TradeDate:      time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC),
+					BuyerCode:      "72",
+					SellerCode:     "8",
+					Criterion:      BlockCriterionAbsolute,
+				},
+			},
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO block_trades (instrument_code, trade_price, trade_quantity, close_time, trade_date, buyer_code, seller_code, criterion, source_file_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`)).
+					WithArgs("PETR4", decimal.NewFromFloat(38.2), 50000, "100100000", time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC), "72", "8", BlockCriterionAbsolute, 3).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "failed because insert error",
+			blocks: []*BlockTrade{
+				{InstrumentCode: "PETR4"},
+			},
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO block_trades`)).
+					WillReturnError(errors.New("insert error"))
+				mock.ExpectRollback()
+			},
+			wantErr: errors.New("insert error"),
+		},
+		{
+			name: "failed because begin error",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin().WillReturnError(errors.New("begin error"))
+			},
+			wantErr: errors.New("begin error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer db.Close()
+
+			tc.mockFunc(mock)
+
+			r := NewRepository(db)
+
+			err = r.BatchInsertBlockTrades(context.Background(), 3, tc.blocks)
+			assert.Equal(t, tc.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestListBlockTrades(t *testing.T) {
+	date := time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name     string
+		filter   BlockTradeFilter
+		mockFunc func(sqlmock.Sqlmock)
+		want     []*BlockTrade
+		wantErr  error
+	}{
+		{
+			name:   "success with ticker",
+			filter: BlockTradeFilter{Ticker: "PETR4", Date: date},
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT b.id, b.instrument_code, b.trade_price, b.trade_quantity, b.close_time, b.trade_date, b.buyer_code, b.seller_code, b.criterion FROM block_trades b WHERE b.trade_date = $1 AND b.instrument_code = $2 ORDER BY b.close_time, b.instrument_code;`)).
+					WithArgs(date, "PETR4").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "instrument_code", "trade_price", "trade_quantity", "close_time", "trade_date", "buyer_code", "seller_code", "criterion"}).
+						AddRow(1, "PETR4", 38.2, 50000, "100100000", date, "72", "8", BlockCriterionAbsolute))
+			},
+			want: []*BlockTrade{
+				{
+					ID:             1,
+					InstrumentCode: "PETR4",
+					TradePrice:     decimal.NewFromFloat(38.2),
+					TradeQuantity:  50000,
+					CloseTime:      "100100000",
+					TradeDate:      date,
+					BuyerCode:      "72",
+					SellerCode:     "8",
+					Criterion:      BlockCriterionAbsolute,
+				},
+			},
+		},
+		{
+			name:   "failed because query error",
+			filter: BlockTradeFilter{Date: date},
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT b.id, b.instrument_code, b.trade_price, b.trade_quantity, b.close_time, b.trade_date, b.buyer_code, b.seller_code, b.criterion FROM block_trades b WHERE b.trade_date = $1 ORDER BY b.close_time, b.instrument_code;`)).
+					WithArgs(date).
+					WillReturnError(errors.New("query error"))
+			},
+			want:    nil,
+			wantErr: errors.New("query error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			tc.mockFunc(mock)
+
+			r := NewRepository(db)
+
+			got, err := r.ListBlockTrades(context.Background(), tc.filter)
+
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestTopBrokers(t *testing.T) {
+	cases := []struct {
+		name     string
+		filter   BrokerFilter
+		side     string
+		mockFunc func(sqlmock.Sqlmock)
+		want     []*BrokerVolume
+		wantErr  error
+	}{
+		{
+			name:   "success buy side",
+			filter: BrokerFilter{Ticker: "PETR4", Start: time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC), Limit: 10},
+			side:   SideBuy,
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT t.buyer_code, SUM(t.trade_quantity), SUM(t.trade_price * t.trade_quantity), COUNT(*) FROM trades t WHERE t.buyer_code IS NOT NULL AND t.buyer_code <> '' AND t.instrument_code = $1 AND t.trade_date >= $2 GROUP BY t.buyer_code ORDER BY SUM(t.trade_quantity) DESC LIMIT $3;`)).
+					WithArgs("PETR4", time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC), 10).
+					WillReturnRows(sqlmock.NewRows([]string{"broker", "quantity", "financial_volume", "trades"}).
+						AddRow("3", 5000, 190000, 12))
+			},
+			want: []*BrokerVolume{
+				{Broker: "3", Quantity: 5000, FinancialVolume: decimal.NewFromInt(190000), Trades: 12},
+			},
+		},
+		{
+			name:   "success sell side",
+			filter: BrokerFilter{Ticker: "PETR4", End: time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC), Limit: 10},
+			side:   SideSell,
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT t.seller_code, SUM(t.trade_quantity), SUM(t.trade_price * t.trade_quantity), COUNT(*) FROM trades t WHERE t.seller_code IS NOT NULL AND t.seller_code <> '' AND t.instrument_code = $1 AND t.trade_date <= $2 GROUP BY t.seller_code ORDER BY SUM(t.trade_quantity) DESC LIMIT $3;`)).
+					WithArgs("PETR4", time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC), 10).
+					WillReturnRows(sqlmock.NewRows([]string{"broker", "quantity", "financial_volume", "trades"}))
+			},
+			want: []*BrokerVolume{},
+		},
+		{
+			name:   "failed because query error",
+			filter: BrokerFilter{Ticker: "PETR4", Limit: 10},
+			side:   SideBuy,
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT t.buyer_code`)).
+					WillReturnError(errors.New("query error"))
+			},
+			want:    nil,
+			wantErr: errors.New("query error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			tc.mockFunc(mock)
+
+			r := NewRepository(db)
+
+			got, err := r.TopBrokers(context.Background(), tc.filter, tc.side)
+
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestBrokerFlows(t *testing.T) {
+	date := time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name     string
+		filter   BrokerFilter
+		mockFunc func(sqlmock.Sqlmock)
+		want     []*BrokerFlow
+		wantErr  error
+	}{
+		{
+			name:   "success",
+			filter: BrokerFilter{Ticker: "PETR4", Broker: "3"},
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`SELECT l.broker, l.trade_date, .* FROM \(.*t.buyer_code AS broker.* AND t.instrument_code = \$1 .*UNION ALL.*t.seller_code AS broker.* AND t.instrument_code = \$1 \) l WHERE l.broker = \$2 GROUP BY l.broker, l.trade_date ORDER BY l.trade_date, l.broker;`).
+					WithArgs("PETR4", "3").
+					WillReturnRows(sqlmock.NewRows([]string{"broker", "trade_date", "bought", "sold", "net", "net_financial_volume"}).
+						AddRow("3", date, 500, 200, 300, 11400))
+			},
+			want: []*BrokerFlow{
+				{Broker: "3", TradeDate: date, BoughtQuantity: 500, SoldQuantity: 200, NetQuantity: 300, NetFinancialVolume: decimal.NewFromInt(11400)},
+			},
+		},
+		{
+			name:   "failed because query error",
+			filter: BrokerFilter{},
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT l.broker, l.trade_date`)).
+					WillReturnError(errors.New("query error"))
+			},
+			want:    nil,
+			wantErr: errors.New("query error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			tc.mockFunc(mock)
+
+			r := NewRepository(db)
+
+			got, err := r.BrokerFlows(context.Background(), tc.filter)
+
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestBrokerPositions(t *testing.T) {
+	cases := []struct {
+		name     string
+		filter   BrokerFilter
+		mockFunc func(sqlmock.Sqlmock)
+		want     []*BrokerPosition
+		wantErr  error
+	}{
+		{
+			name:   "success",
+			filter: BrokerFilter{Start: time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC)},
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`SELECT l.broker, l.instrument_code, .* FROM \(.* AND t.trade_date >= \$1 .* AND t.trade_date >= \$1 \) l GROUP BY l.broker, l.instrument_code ORDER BY l.broker, l.instrument_code;`).
+					WithArgs(time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC)).
+					WillReturnRows(sqlmock.NewRows([]string{"broker", "instrument_code", "net", "net_financial_volume"}).
+						AddRow("3", "PETR4", 300, 11400).
+						AddRow("3", "VALE3", -100, -6000))
+			},
+			want: []*BrokerPosition{
+				{Broker: "3", Ticker: "PETR4", NetQuantity: 300, NetFinancialVolume: decimal.NewFromInt(11400)},
+				{Broker: "3", Ticker: "VALE3", NetQuantity: -100, NetFinancialVolume: decimal.NewFromInt(-6000)},
+			},
+		},
+		{
+			name:   "failed because query error",
+			filter: BrokerFilter{},
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT l.broker, l.instrument_code`)).
+					WillReturnError(errors.New("query error"))
+			},
+			want:    nil,
+			wantErr: errors.New("query error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			tc.mockFunc(mock)
+
+			r := NewRepository(db)
+
+			got, err := r.BrokerPositions(context.Background(), tc.filter)
+
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUpsertInstruments(t *testing.T) {
+	cases := []struct {
+		name        string
+		instruments []*Instrument
+		mockFunc    func(sqlmock.Sqlmock)
+		wantErr     error
+	}{
+		{
+			name: "success",
+			instruments: []*Instrument{
+				{Ticker: "PETR4", Type: instrument.TypeStock},
+				{Ticker: "WINQ24", Type: instrument.TypeFuture},
+			},
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO instruments (ticker, instrument_type) VALUES ($1, $2),($3, $4) ON CONFLICT (ticker) DO UPDATE SET instrument_type = EXCLUDED.instrument_type`)).
+					WithArgs("PETR4", "stock", "WINQ24", "future").
+					WillReturnResult(sqlmock.NewResult(2, 2))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "failed because insert error",
+			instruments: []*Instrument{
+				{Ticker: "PETR4", Type: instrument.TypeStock},
+			},
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO instruments`)).
+					WillReturnError(errors.New("insert error"))
+				mock.ExpectRollback()
+			},
+			wantErr: errors.New("insert error"),
+		},
+		{
+			name: "failed because begin error",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin().WillReturnError(errors.New("begin error"))
+			},
+			wantErr: errors.New("begin error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer db.Close()
+
+			tc.mockFunc(mock)
+
+			r := NewRepository(db)
+
+			err = r.UpsertInstruments(context.Background(), tc.instruments)
+			assert.Equal(t, tc.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUnclassifiedTickers(t *testing.T) {
+	cases := []struct {
+		name     string
+		mockFunc func(sqlmock.Sqlmock)
+		want     []string
+		wantErr  error
+	}{
+		{
+			name: "success",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT DISTINCT m.ticker FROM metrics m LEFT JOIN instruments i ON i.ticker = m.ticker WHERE i.ticker IS NULL;`)).
+					WillReturnRows(sqlmock.NewRows([]string{"ticker"}).AddRow("PETR4").AddRow("WINQ24"))
+			},
+			want: []string{"PETR4", "WINQ24"},
+		},
+		{
+			name: "failed because query error",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT DISTINCT m.ticker`)).
+					WillReturnError(errors.New("query error"))
+			},
+			want:    nil,
+			wantErr: errors.New("query error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			tc.mockFunc(mock)
+
+			r := NewRepository(db)
+
+			got, err := r.UnclassifiedTickers(context.Background())
+
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestImportInstruments(t *testing.T) {
+	strike := decimal.NewFromInt(35)
+
+	cases := []struct {
+		name        string
+		instruments []*Instrument
+		mockFunc    func(sqlmock.Sqlmock)
+		wantErr     error
+	}{
+		{
+			name: "success",
+			instruments: []*Instrument{
+				{Ticker: "PETRG350", Type: instrument.TypeOption, Underlying: "PETR4", Strike: &strike},
+				{Ticker: "WINQ24", Type: instrument.TypeFuture},
+			},
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO instruments (ticker, instrument_type, underlying, strike) VALUES ($1, $2, NULLIF($3, ''), $4),($5, $6, NULLIF($7, ''), $8) ON CONFLICT (ticker) DO UPDATE SET instrument_type = EXCLUDED.instrument_type, underlying = EXCLUDED.underlying, strike = EXCLUDED.strike`)).
+					WithArgs("PETRG350", "option", "PETR4", "35", "WINQ24", "future", "", nil).
+					WillReturnResult(sqlmock.NewResult(2, 2))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "failed because insert error",
+			instruments: []*Instrument{
+				{Ticker: "PETRG350", Type: instrument.TypeOption},
+			},
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO instruments`)).
+					WillReturnError(errors.New("insert error"))
+				mock.ExpectRollback()
+			},
+			wantErr: errors.New("insert error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer db.Close()
+
+			tc.mockFunc(mock)
+
+			r := NewRepository(db)
+
+			err = r.ImportInstruments(context.Background(), tc.instruments)
+			assert.Equal(t, tc.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestListOptionSeries(t *testing.T) {
+	date := time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC)
+	strike := decimal.NewFromInt(35)
+
+	cases := []struct {
+		name     string
+		filter   OptionFilter
+		mockFunc func(sqlmock.Sqlmock)
+		want     []*OptionSeries
+		wantErr  error
+	}{
+		{
+			name:   "success",
+			filter: OptionFilter{Underlying: "PETR4", Date: date},
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT m.ticker, COALESCE(i.underlying, ''), i.strike, MAX(m.max_range_value), SUM(m.max_daily_volume) FROM metrics m JOIN instruments i ON i.ticker = m.ticker WHERE i.instrument_type = $1 AND m.trade_date = $2 AND (i.underlying = $3 OR (i.underlying IS NULL AND LEFT(i.ticker, 4) = $4)) GROUP BY m.ticker, i.underlying, i.strike ORDER BY m.ticker;`)).
+					WithArgs("option", date, "PETR4", "PETR").
+					WillReturnRows(sqlmock.NewRows([]string{"ticker", "underlying", "strike", "max_range_value", "max_daily_volume"}).
+						AddRow("PETRG350", "PETR4", "35", "0.45", 12000).
+						AddRow("PETRS330", "", nil, "0.2", 800))
+			},
+			want: []*OptionSeries{
+				{Ticker: "PETRG350", Underlying: "PETR4", Strike: &strike, MaxRangeValue: decimal.NewFromFloat(0.45), MaxDailyVolume: 12000},
+				{Ticker: "PETRS330", MaxRangeValue: decimal.NewFromFloat(0.2), MaxDailyVolume: 800},
+			},
+		},
+		{
+			name:   "failed because query error",
+			filter: OptionFilter{Underlying: "PETR4", Date: date},
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT m.ticker`)).
+					WillReturnError(errors.New("query error"))
+			},
+			want:    nil,
+			wantErr: errors.New("query error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			tc.mockFunc(mock)
+
+			r := NewRepository(db)
+
+			got, err := r.ListOptionSeries(context.Background(), tc.filter)
+
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestListContractDays(t *testing.T) {
+	start := time.Date(2024, 8, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 8, 31, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name     string
+		filter   ContinuousFilter
+		mockFunc func(sqlmock.Sqlmock)
+		want     []*ContractDay
+		wantErr  error
+	}{
+		{
+			name:   "success with start and end",
+			filter: ContinuousFilter{Root: "WIN", Start: start, End: end},
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT m.ticker, m.trade_date, m.close_price, m.max_daily_volume FROM metrics m JOIN instruments i ON i.ticker = m.ticker WHERE i.instrument_type = $1 AND LEFT(m.ticker, 3) = $2 AND m.session_type = $3 AND m.close_price IS NOT NULL AND m.trade_date >= $4 AND m.trade_date <= $5 ORDER BY m.trade_date, m.ticker;`)).
+					WithArgs("future", "WIN", SessionRegular, start, end).
+					WillReturnRows(sqlmock.NewRows([]string{"ticker", "trade_date", "close_price", "max_daily_volume"}).
+						AddRow("WINQ24", time.Date(2024, 8, 7, 0, 0, 0, 0, time.UTC), "130000", 1000).
+						AddRow("WINV24", time.Date(2024, 8, 7, 0, 0, 0, 0, time.UTC), "131500", 100))
+			},
+			want: []*ContractDay{
+				{Ticker: "WINQ24", TradeDate: time.Date(2024, 8, 7, 0, 0, 0, 0, time.UTC), ClosePrice: decimal.NewFromInt(130000), Volume: 1000},
+				{Ticker: "WINV24", TradeDate: time.Date(2024, 8, 7, 0, 0, 0, 0, time.UTC), ClosePrice: decimal.NewFromInt(131500), Volume: 100},
+			},
+		},
+		{
+			name:   "failed because query error",
+			filter: ContinuousFilter{Root: "WIN"},
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT m.ticker, m.trade_date, m.close_price`)).
+					WillReturnError(errors.New("query error"))
+			},
+			want:    nil,
+			wantErr: errors.New("query error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			tc.mockFunc(mock)
+
+			r := NewRepository(db)
+
+			got, err := r.ListContractDays(context.Background(), tc.filter)
+
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestDailyMetrics(t *testing.T) {
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 6, 30, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name     string
+		filter   MetricFilter
+		mockFunc func(sqlmock.Sqlmock)
+		want     []*DailyMetric
+		wantErr  error
+	}{
+		{
+			name:   "success consolidated with start and end",
+			filter: MetricFilter{Ticker: "PETR4", Date: start, End: end, Session: SessionRegular, Consolidated: true},
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT m.trade_date, MAX(m.max_range_value), COALESCE((ARRAY_AGG(m.close_price ORDER BY m.session_type DESC, m.ticker))[1], 0), SUM(m.max_daily_volume) FROM metrics m WHERE m.ticker IN ($1, $2) AND m.trade_date >= $3 AND m.trade_date <= $4 AND m.session_type = $5 GROUP BY m.trade_date ORDER BY m.trade_date;`)).
+					WithArgs("PETR4", "PETR4F", start, end, SessionRegular).
+					WillReturnRows(sqlmock.NewRows([]string{"trade_date", "max_range_value", "close_price", "max_daily_volume"}).
+						AddRow(time.Date(2024, 5, 31, 0, 0, 0, 0, time.UTC), "80", "78", 1000))
+			},
+			want: []*DailyMetric{
+				{Ticker: "PETR4", TradeDate: time.Date(2024, 5, 31, 0, 0, 0, 0, time.UTC), MaxRangeValue: decimal.NewFromInt(80), ClosePrice: decimal.NewFromInt(78), MaxDailyVolume: 1000},
+			},
+		},
+		{
+			name:   "failed because query error",
+			filter: MetricFilter{Ticker: "PETR4"},
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT m.trade_date`)).
+					WillReturnError(errors.New("query error"))
+			},
+			want:    nil,
+			wantErr: errors.New("query error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			tc.mockFunc(mock)
+
+			r := NewRepository(db)
+
+			got, err := r.DailyMetrics(context.Background(), tc.filter)
+
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestUpsertCorporateActions(t *testing.T) {
+	exDate := time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name     string
+		actions  []*CorporateAction
+		mockFunc func(sqlmock.Sqlmock)
+		wantErr  error
+	}{
+		{
+			name: "success",
+			actions: []*CorporateAction{
+				{Ticker: "PETR4", ExDate: exDate, Type: ActionSplit, Factor: decimal.NewFromInt(2)},
+			},
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO corporate_actions (ticker, ex_date, action_type, factor) VALUES ($1, $2, $3, $4) ON CONFLICT (ticker, ex_date, action_type) DO UPDATE SET factor = EXCLUDED.factor`)).
+					WithArgs("PETR4", exDate, "split", decimal.NewFromInt(2)).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "failed because insert error",
+			actions: []*CorporateAction{
+				{Ticker: "PETR4", ExDate: exDate, Type: ActionSplit, Factor: decimal.NewFromInt(2)},
+			},
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO corporate_actions`)).
+					WillReturnError(errors.New("insert error"))
+				mock.ExpectRollback()
+			},
+			wantErr: errors.New("insert error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer db.Close()
+
+			tc.mockFunc(mock)
+
+			r := NewRepository(db)
+
+			err = r.UpsertCorporateActions(context.Background(), tc.actions)
+			assert.Equal(t, tc.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestListCorporateActions(t *testing.T) {
+	exDate := time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name     string
+		mockFunc func(sqlmock.Sqlmock)
+		want     []*CorporateAction
+		wantErr  error
+	}{
+		{
+			name: "success",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT c.id, c.ticker, c.ex_date, c.action_type, c.factor FROM corporate_actions c WHERE c.ticker = $1 ORDER BY c.ex_date, c.id;`)).
+					WithArgs("PETR4").
+					WillReturnRows(sqlmock.NewRows([]string{"id", "ticker", "ex_date", "action_type", "factor"}).
+						AddRow(1, "PETR4", exDate, "split", "2"))
+			},
+			want: []*CorporateAction{
+				{ID: 1, Ticker: "PETR4", ExDate: exDate, Type: ActionSplit, Factor: decimal.NewFromInt(2)},
+			},
+		},
+		{
+			name: "failed because query error",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT c.id`)).
+					WillReturnError(errors.New("query error"))
+			},
+			want:    nil,
+			wantErr: errors.New("query error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			tc.mockFunc(mock)
+
+			r := NewRepository(db)
+
+			got, err := r.ListCorporateActions(context.Background(), "PETR4")
+
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestIntradayVolumes(t *testing.T) {
+	start := time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 6, 28, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name     string
+		filter   ActivityFilter
+		mockFunc func(sqlmock.Sqlmock)
+		want     []*IntradayVolume
+		wantErr  error
+	}{
+		{
+			name:   "success with start and end",
+			filter: ActivityFilter{Ticker: "PETR4", Start: start, End: end, BucketMinutes: 15},
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`WITH activity AS ( SELECT t.trade_date, t.trade_quantity, SUBSTRING(t.close_time, 1, 2)::INT * 60 + SUBSTRING(t.close_time, 3, 2)::INT AS minute_of_day FROM trades t WHERE t.instrument_code = $1 AND t.close_time ~ '^[0-9]{9}$' AND t.trade_date >= $3 AND t.trade_date <= $4 ) SELECT (a.minute_of_day / $2) * $2 AS bucket, SUM(a.trade_quantity), COUNT(*), (SELECT COUNT(DISTINCT trade_date) FROM activity) FROM activity a GROUP BY bucket ORDER BY bucket;`)).
+					WithArgs("PETR4", 15, start, end).
+					WillReturnRows(sqlmock.NewRows([]string{"bucket", "sum", "count", "days"}).
+						AddRow(600, 30000, 120, 2).
+						AddRow(615, 12000, 45, 2))
+			},
+			want: []*IntradayVolume{
+				{Minute: 600, Volume: 30000, Trades: 120, Days: 2},
+				{Minute: 615, Volume: 12000, Trades: 45, Days: 2},
+			},
+		},
+		{
+			name:   "success without trades",
+			filter: ActivityFilter{Ticker: "PETR4", BucketMinutes: 60},
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`WHERE t.instrument_code = $1 AND t.close_time ~ '^[0-9]{9}$' )`)).
+					WithArgs("PETR4", 60).
+					WillReturnRows(sqlmock.NewRows([]string{"bucket", "sum", "count", "days"}))
+			},
+			want: []*IntradayVolume{},
+		},
+		{
+			name:   "failed because query error",
+			filter: ActivityFilter{Ticker: "PETR4", BucketMinutes: 15},
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`WITH activity AS`)).
+					WillReturnError(errors.New("query error"))
+			},
+			want:    nil,
+			wantErr: errors.New("query error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			tc.mockFunc(mock)
+
+			r := NewRepository(db)
+
+			got, err := r.IntradayVolumes(context.Background(), tc.filter)
+
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestMarketBreadth(t *testing.T) {
+	filter := BreadthFilter{
+		Start:        time.Date(2024, 6, 27, 0, 0, 0, 0, time.UTC),
+		End:          time.Date(2024, 6, 28, 0, 0, 0, 0, time.UTC),
+		Days:         20,
+		HistoryStart: time.Date(2024, 5, 28, 0, 0, 0, 0, time.UTC),
+	}
+
+	cases := []struct {
+		name     string
+		mockFunc func(sqlmock.Sqlmock)
+		want     []*MarketBreadth
+		wantErr  error
+	}{
+		{
+			name: "success",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`WITH closes AS ( SELECT m.trade_date, m.close_price, LAG(m.close_price) OVER w AS previous_close, MAX(m.close_price) OVER (w ROWS BETWEEN 20 PRECEDING AND 1 PRECEDING) AS previous_high, MIN(m.close_price) OVER (w ROWS BETWEEN 20 PRECEDING AND 1 PRECEDING) AS previous_low FROM metrics m JOIN instruments i ON i.ticker = m.ticker WHERE i.instrument_type = $1 AND m.session_type = $2 AND m.close_price IS NOT NULL AND m.trade_date >= $3 AND m.trade_date <= $5 WINDOW w AS (PARTITION BY m.ticker ORDER BY m.trade_date) ), volumes AS ( SELECT m.trade_date, SUM(COALESCE(m.financial_volume, 0)) AS financial_volume FROM metrics m WHERE m.trade_date >= $4 AND m.trade_date <= $5 GROUP BY m.trade_date ) SELECT c.trade_date, COUNT(*) FILTER (WHERE c.close_price > c.previous_close), COUNT(*) FILTER (WHERE c.close_price < c.previous_close), COUNT(*) FILTER (WHERE c.close_price = c.previous_close), COUNT(*) FILTER (WHERE c.close_price > c.previous_high), COUNT(*) FILTER (WHERE c.close_price < c.previous_low), COALESCE(MAX(v.financial_volume), 0) FROM closes c LEFT JOIN volumes v ON v.trade_date = c.trade_date WHERE c.trade_date >= $4 GROUP BY c.trade_date ORDER BY c.trade_date;`)).
+					WithArgs("stock", SessionRegular, filter.HistoryStart, filter.Start, filter.End).
+					WillReturnRows(sqlmock.NewRows([]string{"trade_date", "advancing", "declining", "unchanged", "new_highs", "new_lows", "financial_volume"}).
+						AddRow(filter.Start, 200, 150, 10, 5, 2, "1500000.50").
+						AddRow(filter.End, 120, 230, 20, 1, 9, "1700000"))
+			},
+			want: []*MarketBreadth{
+				{TradeDate: filter.Start, Advancing: 200, Declining: 150, Unchanged: 10, NewHighs: 5, NewLows: 2, FinancialVolume: decimal.RequireFromString("1500000.50")},
+				{TradeDate: filter.End, Advancing: 120, Declining: 230, Unchanged: 20, NewHighs: 1, NewLows: 9, FinancialVolume: decimal.RequireFromString("1700000")},
+			},
+		},
+		{
+			name: "failed because query error",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`WITH closes AS`)).
+					WillReturnError(errors.New("query error"))
+			},
+			want:    nil,
+			wantErr: errors.New("query error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			tc.mockFunc(mock)
+
+			r := NewRepository(db)
+
+			got, err := r.MarketBreadth(context.Background(), filter)
+
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestDailyLiquidity(t *testing.T) {
+	filter := LiquidityFilter{
+		Ticker: "PETR4",
+		Start:  time.Date(2024, 7, 8, 0, 0, 0, 0, time.UTC),
+		End:    time.Date(2024, 7, 12, 0, 0, 0, 0, time.UTC),
+	}
+
+	cases := []struct {
+		name     string
+		mockFunc func(sqlmock.Sqlmock)
+		want     []*DailyLiquidity
+		wantErr  error
+	}{
+		{
+			name: "success",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT m.trade_date, COALESCE((ARRAY_AGG(m.close_price ORDER BY m.id DESC) FILTER (WHERE m.session_type = $4 AND m.close_price IS NOT NULL))[1], 0), SUM(m.max_daily_volume), SUM(COALESCE(m.financial_volume, 0)) FROM metrics m WHERE m.ticker = $1 AND m.trade_date >= $2 AND m.trade_date <= $3 GROUP BY m.trade_date ORDER BY m.trade_date;`)).
+					WithArgs("PETR4", filter.Start, filter.End, SessionRegular).
+					WillReturnRows(sqlmock.NewRows([]string{"trade_date", "close_price", "volume", "financial_volume"}).
+						AddRow(filter.Start, "38.50", 1000, "38500.00"))
+			},
+			want: []*DailyLiquidity{
+				{TradeDate: filter.Start, ClosePrice: decimal.RequireFromString("38.50"), Volume: 1000, FinancialVolume: decimal.RequireFromString("38500.00")},
+			},
+		},
+		{
+			// the after market close of 38.90 is left out of the close while its volume is summed
+			name: "success with regular and after market sessions on the same day",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`ARRAY_AGG(m.close_price ORDER BY m.id DESC) FILTER (WHERE m.session_type = $4 AND m.close_price IS NOT NULL)`)).
+					WithArgs("PETR4", filter.Start, filter.End, SessionRegular).
+					WillReturnRows(sqlmock.NewRows([]string{"trade_date", "close_price", "volume", "financial_volume"}).
+						AddRow(filter.Start, "38.50", 1200, "46280.00"))
+			},
+			want: []*DailyLiquidity{
+				{TradeDate: filter.Start, ClosePrice: decimal.RequireFromString("38.50"), Volume: 1200, FinancialVolume: decimal.RequireFromString("46280.00")},
+			},
+		},
+		{
+			name: "failed because query error",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT m.trade_date`)).
+					WillReturnError(errors.New("query error"))
+			},
+			want:    nil,
+			wantErr: errors.New("query error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			tc.mockFunc(mock)
+
+			r := NewRepository(db)
+
+			got, err := r.DailyLiquidity(context.Background(), filter)
+
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestPriceCovariance(t *testing.T) {
+	filter := LiquidityFilter{
+		Ticker: "PETR4",
+		Start:  time.Date(2024, 7, 8, 0, 0, 0, 0, time.UTC),
+		End:    time.Date(2024, 7, 12, 0, 0, 0, 0, time.UTC),
+	}
+
+	cases := []struct {
+		name     string
+		mockFunc func(sqlmock.Sqlmock)
+		want     *PriceCovariance
+		wantErr  error
+	}{
+		{
+			name: "success",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`WITH changes AS ( SELECT t.trade_date, t.traded_at, t.id, t.trade_price - LAG(t.trade_price) OVER (PARTITION BY t.trade_date ORDER BY t.traded_at, t.id) AS change FROM trades t WHERE t.instrument_code = $1 AND t.trade_date >= $2 AND t.trade_date <= $3 ), pairs AS ( SELECT c.change, LAG(c.change) OVER (PARTITION BY c.trade_date ORDER BY c.traded_at, c.id) AS previous_change FROM changes c ) SELECT COALESCE(COVAR_SAMP(p.change, p.previous_change), 0)::NUMERIC, COUNT(p.previous_change) FROM pairs p;`)).
+					WithArgs("PETR4", filter.Start, filter.End).
+					WillReturnRows(sqlmock.NewRows([]string{"covariance", "pairs"}).AddRow("-0.0004", 120))
+			},
+			want: &PriceCovariance{Covariance: decimal.RequireFromString("-0.0004"), Pairs: 120},
+		},
+		{
+			name: "failed because query error",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`WITH changes AS`)).
+					WillReturnError(errors.New("query error"))
+			},
+			want:    nil,
+			wantErr: errors.New("query error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			tc.mockFunc(mock)
+
+			r := NewRepository(db)
+
+			got, err := r.PriceCovariance(context.Background(), filter)
+
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestBatchInsertOrderFlow(t *testing.T) {
+	date := time.Date(2024, 6, 28, 0, 0, 0, 0, time.UTC)
+	flows := []*OrderFlow{
+		{Ticker: "PETR4", TradeDate: date, Minute: 600, BuyVolume: 300, SellVolume: 100, UnclassifiedVolume: 50, BuyTrades: 3, SellTrades: 1},
+		{Ticker: "VALE3", TradeDate: date, Minute: 615, SellVolume: 200, SellTrades: 2},
+	}
+
+	cases := []struct {
+		name     string
+		mockFunc func(sqlmock.Sqlmock)
+		wantErr  error
+	}{
+		{
+			name: "success",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO order_flow (ticker, trade_date, bucket_minute, buy_volume, sell_volume, unclassified_volume, buy_trades, sell_trades, source_file_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9),($10, $11, $12, $13, $14, $15, $16, $17, $18)`)).
+					WithArgs("PETR4", date, 600, 300, 100, 50, 3, 1, 3, "VALE3", date, 615, 0, 200, 0, 0, 2, 3).
+					WillReturnResult(sqlmock.NewResult(2, 2))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "failed because insert error",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO order_flow`)).
+					WillReturnError(errors.New("insert error"))
+				mock.ExpectRollback()
+			},
+			wantErr: errors.New("insert error"),
+		},
+		{
+			name: "failed because begin error",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin().WillReturnError(errors.New("begin error"))
+			},
+			wantErr: errors.New("begin error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			tc.mockFunc(mock)
+
+			r := NewRepository(db)
+
+			err = r.BatchInsertOrderFlow(context.Background(), 3, flows)
+			assert.Equal(t, tc.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestListOrderFlow(t *testing.T) {
+	date := time.Date(2024, 6, 28, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name     string
+		filter   OrderFlowFilter
+		mockFunc func(sqlmock.Sqlmock)
+		want     []*OrderFlow
+		wantErr  error
+	}{
+		{
+			name:   "success",
+			filter: OrderFlowFilter{Ticker: "PETR4", Start: date, End: date},
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT o.trade_date, o.bucket_minute, SUM(o.buy_volume), SUM(o.sell_volume), SUM(o.unclassified_volume), SUM(o.buy_trades), SUM(o.sell_trades) FROM order_flow o WHERE o.ticker = $1 AND o.trade_date >= $2 AND o.trade_date <= $3 GROUP BY o.trade_date, o.bucket_minute ORDER BY o.trade_date, o.bucket_minute;`)).
+					WithArgs("PETR4", date, date).
+					WillReturnRows(sqlmock.NewRows([]string{"trade_date", "bucket_minute", "buy_volume", "sell_volume", "unclassified_volume", "buy_trades", "sell_trades"}).
+						AddRow(date, 600, 300, 100, 50, 3, 1))
+			},
+			want: []*OrderFlow{
+				{Ticker: "PETR4", TradeDate: date, Minute: 600, BuyVolume: 300, SellVolume: 100, UnclassifiedVolume: 50, BuyTrades: 3, SellTrades: 1},
+			},
+		},
+		{
+			name:   "failed because query error",
+			filter: OrderFlowFilter{Ticker: "PETR4"},
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT o.trade_date, o.bucket_minute, SUM(o.buy_volume), SUM(o.sell_volume), SUM(o.unclassified_volume), SUM(o.buy_trades), SUM(o.sell_trades) FROM order_flow o WHERE o.ticker = $1 GROUP BY`)).
+					WithArgs("PETR4").
+					WillReturnError(errors.New("query error"))
+			},
+			want:    nil,
+			wantErr: errors.New("query error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			tc.mockFunc(mock)
+
+			r := NewRepository(db)
+
+			got, err := r.ListOrderFlow(context.Background(), tc.filter)
+
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestListWindowTrades(t *testing.T) {
+	filter := TradeFilter{
+		Ticker:   "PETR4",
+		Date:     time.Date(2024, 6, 28, 0, 0, 0, 0, time.UTC),
+		FromTime: time.Date(0, 1, 1, 10, 0, 0, 0, time.UTC),
+		ToTime:   time.Date(0, 1, 1, 11, 30, 0, 0, time.UTC),
+	}
+
+	cases := []struct {
+		name     string
+		mockFunc func(sqlmock.Sqlmock)
+		want     []*Trade
+		wantErr  error
+	}{
+		{
+			name: "success",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT t.trade_price, t.trade_quantity, t.close_time FROM trades t WHERE t.instrument_code = $1 AND t.trade_date = $2 AND t.close_time >= $3 AND t.close_time < $4 ORDER BY t.close_time, t.trade_id, t.id;`)).
+					WithArgs("PETR4", filter.Date, "100000000", "113000000").
+					WillReturnRows(sqlmock.NewRows([]string{"trade_price", "trade_quantity", "close_time"}).
+						AddRow("38.50", 100, "100001250"))
+			},
+			want: []*Trade{
+				{InstrumentCode: "PETR4", TradeDate: filter.Date, TradePrice: decimal.RequireFromString("38.50"), TradeQuantity: 100, CloseTime: "100001250"},
+			},
+		},
+		{
+			name: "failed because query error",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT t.trade_price`)).
+					WillReturnError(errors.New("query error"))
+			},
+			want:    nil,
+			wantErr: errors.New("query error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			tc.mockFunc(mock)
+
+			r := NewRepository(db)
+
+			got, err := r.ListWindowTrades(context.Background(), filter)
+
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestBatchInsertAuctions(t *testing.T) {
+	date := time.Date(2024, 6, 28, 0, 0, 0, 0, time.UTC)
+	auctions := []*Auction{
+		{Ticker: "PETR4", TradeDate: date, Type: AuctionOpening, CloseTime: "100000120", Price: decimal.NewFromFloat(38.5), Volume: 1500, Trades: 2},
+		{Ticker: "PETR4", TradeDate: date, Type: AuctionClosing, CloseTime: "170312345", Price: decimal.NewFromFloat(39.1), Volume: 5000, Trades: 2},
+	}
+
+	cases := []struct {
+		name     string
+		mockFunc func(sqlmock.Sqlmock)
+		wantErr  error
+	}{
+		{
+			name: "success",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO auctions (ticker, trade_date, auction_type, close_time, price, volume, trades, source_file_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8),($9, $10, $11, $12, $13, $14, $15, $16)`)).
+					WithArgs("PETR4", date, AuctionOpening, "100000120", decimal.NewFromFloat(38.5), 1500, 2, 3,
+						"PETR4", date, AuctionClosing, "170312345", decimal.NewFromFloat(39.1), 5000, 2, 3).
+					WillReturnResult(sqlmock.NewResult(2, 2))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "failed because insert error",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO auctions`)).
+					WillReturnError(errors.New("insert error"))
+				mock.ExpectRollback()
+			},
+			wantErr: errors.New("insert error"),
+		},
+		{
+			name: "failed because commit error",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO auctions`)).
+					WillReturnResult(sqlmock.NewResult(2, 2))
+				mock.ExpectCommit().WillReturnError(errors.New("commit error"))
+			},
+			wantErr: errors.New("commit error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			tc.mockFunc(mock)
+
+			r := NewRepository(db)
+
+			err = r.BatchInsertAuctions(context.Background(), 3, auctions)
+			assert.Equal(t, tc.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestListAuctions(t *testing.T) {
+	date := time.Date(2024, 6, 28, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name     string
+		filter   AuctionFilter
+		mockFunc func(sqlmock.Sqlmock)
+		want     []*Auction
+		wantErr  error
+	}{
+		{
+			name:   "success",
+			filter: AuctionFilter{Ticker: "PETR4", Start: date, End: date, Type: AuctionClosing},
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT a.ticker, a.trade_date, a.auction_type, a.close_time, a.price, a.volume, a.trades FROM auctions a WHERE a.ticker = $1 AND a.trade_date >= $2 AND a.trade_date <= $3 AND a.auction_type = $4 ORDER BY a.trade_date, a.auction_type DESC;`)).
+					WithArgs("PETR4", date, date, AuctionClosing).
+					WillReturnRows(sqlmock.NewRows([]string{"ticker", "trade_date", "auction_type", "close_time", "price", "volume", "trades"}).
+						AddRow("PETR4", date, AuctionClosing, "170312345", "39.10", 5000, 2))
+			},
+			want: []*Auction{
+				{Ticker: "PETR4", TradeDate: date, Type: AuctionClosing, CloseTime: "170312345", Price: decimal.RequireFromString("39.10"), Volume: 5000, Trades: 2},
+			},
+		},
+		{
+			name:   "failed because query error",
+			filter: AuctionFilter{Ticker: "PETR4"},
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT a.ticker, a.trade_date, a.auction_type, a.close_time, a.price, a.volume, a.trades FROM auctions a WHERE a.ticker = $1 ORDER BY`)).
+					WithArgs("PETR4").
+					WillReturnError(errors.New("query error"))
+			},
+			want:    nil,
+			wantErr: errors.New("query error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			tc.mockFunc(mock)
+
+			r := NewRepository(db)
+
+			got, err := r.ListAuctions(context.Background(), tc.filter)
+
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestCreateUpload(t *testing.T) {
+	createdAt := time.Date(2024, 6, 28, 18, 30, 0, 0, time.UTC)
+	replayOf := 3
+	upload := func() *Upload {
+		return &Upload{FileName: "28-06-2024_NEGOCIOSAVISTA.txt", FileSize: 2048, Checksum: "abc123", Uploader: "ops", BlobKey: "source-files/abc123",
+			ReplayOf: &replayOf, Status: UploadProcessing}
+	}
+
+	cases := []struct {
+		name     string
+		mockFunc func(sqlmock.Sqlmock)
+		want     *Upload
+		wantErr  error
+	}{
+		{
+			name: "success",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO source_files (file_name, file_size, sha256, uploader, blob_key, replay_of, status, trades) VALUES ($1, $2, $3, $4, $5, $6, $7, 0) RETURNING id, created_at;`)).
+					WithArgs("28-06-2024_NEGOCIOSAVISTA.txt", int64(2048), "abc123", "ops", "source-files/abc123", &replayOf, UploadProcessing).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(7, createdAt))
+			},
+			want: &Upload{ID: 7, FileName: "28-06-2024_NEGOCIOSAVISTA.txt", FileSize: 2048, Checksum: "abc123", Uploader: "ops", BlobKey: "source-files/abc123",
+				ReplayOf: &replayOf, Status: UploadProcessing, CreatedAt: createdAt},
+		},
+		{
+			name: "failed because file already uploaded",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO source_files`)).
+					WillReturnError(&pq.Error{Code: "23505"})
+			},
+			want:    upload(),
+			wantErr: ErrDuplicateFile,
+		},
+		{
+			name: "failed because insert error",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO source_files`)).
+					WillReturnError(errors.New("insert error"))
+			},
+			want:    upload(),
+			wantErr: errors.New("insert error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			tc.mockFunc(mock)
+
+			r := NewRepository(db)
+
+			got := upload()
+			err = r.CreateUpload(context.Background(), got)
+
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestFinishUpload(t *testing.T) {
+	referenceDate := time.Date(2024, 6, 28, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name     string
+		mockFunc func(sqlmock.Sqlmock)
+		wantErr  error
+	}{
+		{
+			name: "success",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(regexp.QuoteMeta(`UPDATE source_files SET status = $2, trades = $3, reference_date = $4, finished_at = NOW() WHERE id = $1;`)).
+					WithArgs(7, UploadCompleted, 1500, &referenceDate).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			name: "failed because update error",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(regexp.QuoteMeta(`UPDATE source_files`)).
+					WillReturnError(errors.New("update error"))
+			},
+			wantErr: errors.New("update error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			tc.mockFunc(mock)
+
+			r := NewRepository(db)
+
+			err = r.FinishUpload(context.Background(), &Upload{ID: 7, Status: UploadCompleted, Trades: 1500, ReferenceDate: &referenceDate})
+			assert.Equal(t, tc.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestGetUpload(t *testing.T) {
+	referenceDate := time.Date(2024, 6, 28, 0, 0, 0, 0, time.UTC)
+	replayOf := 3
+	createdAt := time.Date(2024, 6, 28, 18, 30, 0, 0, time.UTC)
+	finishedAt := time.Date(2024, 6, 28, 18, 32, 0, 0, time.UTC)
+
+	cases := []struct {
+		name     string
+		mockFunc func(sqlmock.Sqlmock)
+		want     *Upload
+		wantErr  error
+	}{
+		{
+			name: "success",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT u.id, COALESCE(u.file_name, ''), COALESCE(u.file_size, 0), COALESCE(u.sha256, ''), u.reference_date, COALESCE(u.uploader, ''), COALESCE(u.blob_key, ''), u.replay_of, u.status, u.trades, u.created_at, u.finished_at FROM source_files u WHERE u.id = $1;`)).
+					WithArgs(7).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "file_name", "file_size", "sha256", "reference_date", "uploader", "blob_key", "replay_of", "status", "trades", "created_at", "finished_at"}).
+						AddRow(7, "28-06-2024_NEGOCIOSAVISTA.txt", 2048, "abc123", referenceDate, "ops", "source-files/abc123", 3, UploadCompleted, 1500, createdAt, finishedAt))
+			},
+			want: &Upload{ID: 7, FileName: "28-06-2024_NEGOCIOSAVISTA.txt", FileSize: 2048, Checksum: "abc123", ReferenceDate: &referenceDate, Uploader: "ops",
+				BlobKey: "source-files/abc123", ReplayOf: &replayOf, Status: UploadCompleted, Trades: 1500, CreatedAt: createdAt, FinishedAt: &finishedAt},
+		},
+		{
+			name: "success while processing",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT u.id`)).
+					WithArgs(7).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "file_name", "file_size", "sha256", "reference_date", "uploader", "blob_key", "replay_of", "status", "trades", "created_at", "finished_at"}).
+						AddRow(7, "28-06-2024_NEGOCIOSAVISTA.txt", 2048, "abc123", nil, "", "", nil, UploadProcessing, 0, createdAt, nil))
+			},
+			want: &Upload{ID: 7, FileName: "28-06-2024_NEGOCIOSAVISTA.txt", FileSize: 2048, Checksum: "abc123", Status: UploadProcessing, CreatedAt: createdAt},
+		},
+		{
+			name: "failed because upload not found",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT u.id`)).
+					WithArgs(7).
+					WillReturnError(sql.ErrNoRows)
+			},
+			wantErr: ErrUploadNotFound,
+		},
+		{
+			name: "failed because query error",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT u.id`)).
+					WithArgs(7).
+					WillReturnError(errors.New("query error"))
+			},
+			wantErr: errors.New("query error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			tc.mockFunc(mock)
+
+			r := NewRepository(db)
+
+			got, err := r.GetUpload(context.Background(), 7)
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestFindUploadByChecksum(t *testing.T) {
+	createdAt := time.Date(2024, 6, 28, 18, 30, 0, 0, time.UTC)
+
+	cases := []struct {
+		name     string
+		mockFunc func(sqlmock.Sqlmock)
+		want     *Upload
+		wantErr  error
+	}{
+		{
+			name: "success",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT u.id, COALESCE(u.file_name, ''), COALESCE(u.file_size, 0), COALESCE(u.sha256, ''), u.reference_date, COALESCE(u.uploader, ''), COALESCE(u.blob_key, ''), u.replay_of, u.status, u.trades, u.created_at, u.finished_at FROM source_files u WHERE u.sha256 = $1 AND u.status IN ($2, $3) ORDER BY u.id DESC LIMIT 1;`)).
+					WithArgs("abc123", UploadProcessing, UploadCompleted).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "file_name", "file_size", "sha256", "reference_date", "uploader", "blob_key", "replay_of", "status", "trades", "created_at", "finished_at"}).
+						AddRow(7, "28-06-2024_NEGOCIOSAVISTA.txt", 2048, "abc123", nil, "ops", "", nil, UploadProcessing, 0, createdAt, nil))
+			},
+			want: &Upload{ID: 7, FileName: "28-06-2024_NEGOCIOSAVISTA.txt", FileSize: 2048, Checksum: "abc123", Uploader: "ops", Status: UploadProcessing, CreatedAt: createdAt},
+		},
+		{
+			name: "failed because upload not found",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT u.id`)).
+					WithArgs("abc123", UploadProcessing, UploadCompleted).
+					WillReturnError(sql.ErrNoRows)
+			},
+			wantErr: ErrUploadNotFound,
+		},
+		{
+			name: "failed because query error",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT u.id`)).
+					WithArgs("abc123", UploadProcessing, UploadCompleted).
+					WillReturnError(errors.New("query error"))
+			},
+			wantErr: errors.New("query error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			tc.mockFunc(mock)
+
+			r := NewRepository(db)
+
+			got, err := r.FindUploadByChecksum(context.Background(), "abc123")
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestDeleteUpload(t *testing.T) {
+	date := time.Date(2024, 6, 28, 0, 0, 0, 0, time.UTC)
+	createdAt := time.Date(2024, 6, 29, 9, 0, 0, 0, time.UTC)
+
+	expectDeleteTrades := func(mock sqlmock.Sqlmock) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT status FROM source_files WHERE id = $1 FOR UPDATE;`)).
+			WithArgs(7).
+			WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(UploadCompleted))
+		mock.ExpectQuery(regexp.QuoteMeta(`WITH deleted AS ( DELETE FROM trades WHERE source_file_id = $1 RETURNING instrument_code, trade_date, session_type )`)).
+			WithArgs(7).
+			WillReturnRows(sqlmock.NewRows([]string{"instrument_code", "trade_date", "session_type", "count"}).
+				AddRow("PETR4", date, 1, 1000).
+				AddRow("VALE3", date, 1, 500))
+	}
+
+	expectDeleteDerived := func(mock sqlmock.Sqlmock) {
+		for _, stmt := range []string{
+			`DELETE FROM anomalies WHERE source_file_id = $1;`,
+			`DELETE FROM block_trades WHERE source_file_id = $1;`,
+			`DELETE FROM auctions WHERE source_file_id = $1;`,
+			`DELETE FROM order_flow WHERE source_file_id = $1;`,
+			`DELETE FROM trade_sequences WHERE upload_id = $1;`,
+		} {
+			mock.ExpectExec(regexp.QuoteMeta(stmt)).
+				WithArgs(7).
+				WillReturnResult(sqlmock.NewResult(0, 2))
+		}
+	}
+
+	cases := []struct {
+		name     string
+		mockFunc func(sqlmock.Sqlmock)
+		want     *UploadAudit
+		wantErr  error
+	}{
+		{
+			name: "success",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				expectDeleteTrades(mock)
+				expectDeleteDerived(mock)
+				mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM metrics WHERE source_file_id = $1 OR (ticker, trade_date, session_type) IN ( SELECT * FROM UNNEST($2::text[], $3::date[], $4::int[]) );`)).
+					WithArgs(7, pq.Array([]string{"PETR4", "VALE3"}), pq.Array([]string{"2024-06-28", "2024-06-28"}), pq.Array([]int64{1, 1})).
+					WillReturnResult(sqlmock.NewResult(0, 3))
+				// the outliers are the anomalies flagged on the same trade of the same file
+				mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO metrics (ticker, max_range_value, max_daily_volume, trade_date, session_type, close_price, financial_volume, source_file_id) SELECT`)+
+					`.*`+regexp.QuoteMeta(`a.source_file_id = tr.source_file_id AND a.instrument_code = tr.instrument_code AND a.trade_date = tr.trade_date AND a.trade_id = tr.trade_id AND a.reason = $4`)).
+					WithArgs(pq.Array([]string{"PETR4", "VALE3"}), pq.Array([]string{"2024-06-28", "2024-06-28"}), pq.Array([]int64{1, 1}), AnomalyPriceDeviation).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(regexp.QuoteMeta(`UPDATE source_files SET status = $2 WHERE id = $1;`)).
+					WithArgs(7, UploadDeleted).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO upload_audit (upload_id, action, actor, reason, trades, metrics) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at;`)).
+					WithArgs(7, AuditDelete, "ops", "wrong file", 1500, 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, createdAt))
+				mock.ExpectCommit()
+			},
+			want: &UploadAudit{ID: 1, UploadID: 7, Action: AuditDelete, Actor: "ops", Reason: "wrong file", Trades: 1500, Metrics: 1, CreatedAt: createdAt},
+		},
+		{
+			name: "failed because upload not found",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT status FROM source_files`)).
+					WithArgs(7).
+					WillReturnError(sql.ErrNoRows)
+				mock.ExpectRollback()
+			},
+			want:    &UploadAudit{UploadID: 7, Action: AuditDelete, Actor: "ops", Reason: "wrong file"},
+			wantErr: ErrUploadNotFound,
+		},
+		{
+			name: "failed because upload is processing",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT status FROM source_files`)).
+					WithArgs(7).
+					WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(UploadProcessing))
+				mock.ExpectRollback()
+			},
+			want:    &UploadAudit{UploadID: 7, Action: AuditDelete, Actor: "ops", Reason: "wrong file"},
+			wantErr: ErrUploadProcessing,
+		},
+		{
+			name: "failed because upload already deleted",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT status FROM source_files`)).
+					WithArgs(7).
+					WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(UploadDeleted))
+				mock.ExpectRollback()
+			},
+			want:    &UploadAudit{UploadID: 7, Action: AuditDelete, Actor: "ops", Reason: "wrong file"},
+			wantErr: ErrUploadDeleted,
+		},
+		{
+			name: "failed because derived rows delete error",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				expectDeleteTrades(mock)
+				mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM anomalies`)).
+					WithArgs(7).
+					WillReturnError(errors.New("delete error"))
+				mock.ExpectRollback()
+			},
+			want:    &UploadAudit{UploadID: 7, Action: AuditDelete, Actor: "ops", Reason: "wrong file", Trades: 1500},
+			wantErr: errors.New("delete error"),
+		},
+		{
+			name: "failed because recompute error",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				expectDeleteTrades(mock)
+				expectDeleteDerived(mock)
+				mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM metrics`)).
+					WillReturnResult(sqlmock.NewResult(0, 3))
+				mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO metrics`)).
+					WillReturnError(errors.New("insert error"))
+				mock.ExpectRollback()
+			},
+			want:    &UploadAudit{UploadID: 7, Action: AuditDelete, Actor: "ops", Reason: "wrong file", Trades: 1500},
+			wantErr: errors.New("insert error"),
+		},
+		{
+			name: "failed because begin error",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin().WillReturnError(errors.New("begin error"))
+			},
+			want:    &UploadAudit{UploadID: 7, Action: AuditDelete, Actor: "ops", Reason: "wrong file"},
+			wantErr: errors.New("begin error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			tc.mockFunc(mock)
+
+			r := NewRepository(db)
+
+			audit := &UploadAudit{UploadID: 7, Action: AuditDelete, Actor: "ops", Reason: "wrong file"}
+			err = r.DeleteUpload(context.Background(), audit)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.want, audit)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestReplayUpload(t *testing.T) {
+	createdAt := time.Date(2024, 6, 29, 9, 0, 0, 0, time.UTC)
+	replayOf := 7
+	replay := func() *Upload {
+		return &Upload{FileName: "28-06-2024_NEGOCIOSAVISTA.txt", FileSize: 2048, Checksum: "abc123", Uploader: "ops", BlobKey: "source-files/abc123",
+			ReplayOf: &replayOf, Status: UploadProcessing}
+	}
+
+	expectStatus := func(mock sqlmock.Sqlmock, status string) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT status FROM source_files WHERE id = $1 FOR UPDATE;`)).
+			WithArgs(7).
+			WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(status))
+	}
+
+	expectInsert := func(mock sqlmock.Sqlmock) *sqlmock.ExpectedQuery {
+		return mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO source_files (file_name, file_size, sha256, uploader, blob_key, replay_of, status, trades) VALUES ($1, $2, $3, $4, $5, $6, $7, 0) RETURNING id, created_at;`)).
+			WithArgs("28-06-2024_NEGOCIOSAVISTA.txt", int64(2048), "abc123", "ops", "source-files/abc123", &replayOf, UploadProcessing)
+	}
+
+	cases := []struct {
+		name     string
+		mockFunc func(sqlmock.Sqlmock)
+		want     *Upload
+		wantErr  error
+	}{
+		{
+			name: "success",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				expectStatus(mock, UploadCompleted)
+				mock.ExpectQuery(regexp.QuoteMeta(`WITH deleted AS ( DELETE FROM trades`)).
+					WithArgs(7).
+					WillReturnRows(sqlmock.NewRows([]string{"instrument_code", "trade_date", "session_type", "count"}).
+						AddRow("PETR4", time.Date(2024, 6, 28, 0, 0, 0, 0, time.UTC), 1, 1000))
+				for _, stmt := range []string{`DELETE FROM anomalies`, `DELETE FROM block_trades`, `DELETE FROM auctions`, `DELETE FROM order_flow`,
+					`DELETE FROM trade_sequences`, `DELETE FROM metrics`, `INSERT INTO metrics`, `UPDATE source_files`} {
+					mock.ExpectExec(regexp.QuoteMeta(stmt)).
+						WillReturnResult(sqlmock.NewResult(0, 1))
+				}
+				mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO upload_audit`)).
+					WithArgs(7, AuditReplay, "ops", "", 1000, 1).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(1, createdAt))
+				expectInsert(mock).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(8, createdAt))
+				mock.ExpectCommit()
+			},
+			want: &Upload{ID: 8, FileName: "28-06-2024_NEGOCIOSAVISTA.txt", FileSize: 2048, Checksum: "abc123", Uploader: "ops", BlobKey: "source-files/abc123",
+				ReplayOf: &replayOf, Status: UploadProcessing, CreatedAt: createdAt},
+		},
+		{
+			name: "success with deleted upload",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				expectStatus(mock, UploadDeleted)
+				expectInsert(mock).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(8, createdAt))
+				mock.ExpectCommit()
+			},
+			want: &Upload{ID: 8, FileName: "28-06-2024_NEGOCIOSAVISTA.txt", FileSize: 2048, Checksum: "abc123", Uploader: "ops", BlobKey: "source-files/abc123",
+				ReplayOf: &replayOf, Status: UploadProcessing, CreatedAt: createdAt},
+		},
+		{
+			name: "failed because upload is processing",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				expectStatus(mock, UploadProcessing)
+				mock.ExpectRollback()
+			},
+			want:    replay(),
+			wantErr: ErrUploadProcessing,
+		},
+		{
+			// the rollback of the upload is undone with the transaction, so its trades stay loaded
+			name: "failed because file already uploaded again",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				expectStatus(mock, UploadDeleted)
+				expectInsert(mock).
+					WillReturnError(&pq.Error{Code: "23505"})
+				mock.ExpectRollback()
+			},
+			want:    replay(),
+			wantErr: ErrDuplicateFile,
+		},
+		{
+			name: "failed because begin error",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin().WillReturnError(errors.New("begin error"))
+			},
+			want:    replay(),
+			wantErr: errors.New("begin error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer db.Close()
+
+			tc.mockFunc(mock)
+
+			r := NewRepository(db)
+
+			got := replay()
+			err = r.ReplayUpload(context.Background(), &UploadAudit{UploadID: 7, Action: AuditReplay, Actor: "ops"}, got)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.want, got)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestPurgeUpload(t *testing.T) {
+	statements := []string{
+		`DELETE FROM trades WHERE source_file_id = $1;`,
+		`DELETE FROM metrics WHERE source_file_id = $1;`,
+		`DELETE FROM anomalies WHERE source_file_id = $1;`,
+		`DELETE FROM block_trades WHERE source_file_id = $1;`,
+		`DELETE FROM auctions WHERE source_file_id = $1;`,
+		`DELETE FROM order_flow WHERE source_file_id = $1;`,
+		`DELETE FROM trade_sequences WHERE upload_id = $1;`,
+	}
+
+	cases := []struct {
+		name     string
+		mockFunc func(sqlmock.Sqlmock)
+		wantErr  error
+	}{
+		{
+			name: "success",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				for _, stmt := range statements {
+					mock.ExpectExec(regexp.QuoteMeta(stmt)).
+						WithArgs(7).
+						WillReturnResult(sqlmock.NewResult(0, 1))
+				}
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "failed because trades delete error",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM trades`)).
+					WithArgs(7).
+					WillReturnError(errors.New("delete error"))
+				mock.ExpectRollback()
+			},
+			wantErr: errors.New("delete error"),
+		},
+		{
+			name: "failed because derived rows delete error",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				for _, stmt := range statements[:2] {
+					mock.ExpectExec(regexp.QuoteMeta(stmt)).
+						WithArgs(7).
+						WillReturnResult(sqlmock.NewResult(0, 1))
+				}
+				mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM anomalies`)).
+					WithArgs(7).
+					WillReturnError(errors.New("delete error"))
+				mock.ExpectRollback()
+			},
+			wantErr: errors.New("delete error"),
+		},
+		{
+			name: "failed because begin error",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin().WillReturnError(errors.New("begin error"))
+			},
+			wantErr: errors.New("begin error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer db.Close()
+
+			tc.mockFunc(mock)
+
+			r := NewRepository(db)
+
+			err = r.PurgeUpload(context.Background(), 7)
+			assert.Equal(t, tc.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestBatchInsertSequences(t *testing.T) {
+	date := time.Date(2024, 6, 28, 0, 0, 0, 0, time.UTC)
+	sequences := []*TradeSequence{
+		{Ticker: "PETR4", TradeDate: date, Trades: 5, FirstTradeID: 10, LastTradeID: 80, Step: 10, Missing: 3, Gaps: 2},
+		{Ticker: "VALE3", TradeDate: date, Trades: 1, FirstTradeID: 10, LastTradeID: 10, Step: 1, Complete: true},
+	}
+
+	cases := []struct {
+		name     string
+		mockFunc func(sqlmock.Sqlmock)
+		wantErr  error
+	}{
+		{
+			name: "success",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO trade_sequences (upload_id, ticker, trade_date, trades, first_trade_id, last_trade_id, step, missing, gaps, duplicates, out_of_order, out_of_step) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12),($13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24)`)).
+					WithArgs(7, "PETR4", date, 5, int64(10), int64(80), int64(10), int64(3), 2, 0, 0, 0,
+						7, "VALE3", date, 1, int64(10), int64(10), int64(1), int64(0), 0, 0, 0, 0).
+					WillReturnResult(sqlmock.NewResult(2, 2))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "failed because insert error",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO trade_sequences`)).
+					WillReturnError(errors.New("insert error"))
+				mock.ExpectRollback()
+			},
+			wantErr: errors.New("insert error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			tc.mockFunc(mock)
+
+			r := NewRepository(db)
+
+			err = r.BatchInsertSequences(context.Background(), 7, sequences)
+			assert.Equal(t, tc.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestListSequences(t *testing.T) {
+	date := time.Date(2024, 6, 28, 0, 0, 0, 0, time.UTC)
+	columns := []string{"ticker", "trade_date", "trades", "first_trade_id", "last_trade_id", "step", "missing", "gaps", "duplicates", "out_of_order", "out_of_step"}
+
+	cases := []struct {
+		name     string
+		filter   SequenceFilter
+		mockFunc func(sqlmock.Sqlmock)
+		want     []*TradeSequence
+		wantErr  error
+	}{
+		{
+			name:   "success",
+			filter: SequenceFilter{UploadID: 7},
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT s.ticker, s.trade_date, s.trades, s.first_trade_id, s.last_trade_id, s.step, s.missing, s.gaps, s.duplicates, s.out_of_order, COALESCE(s.out_of_step, 0) FROM trade_sequences s WHERE s.upload_id = $1 ORDER BY s.ticker, s.trade_date;`)).
+					WithArgs(7).
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow("PETR4", date, 5, 10, 80, 10, 3, 2, 0, 0, 0).
+						AddRow("VALE3", date, 1, 10, 10, 1, 0, 0, 0, 0, 0))
+			},
+			want: []*TradeSequence{
+				{Ticker: "PETR4", TradeDate: date, Trades: 5, FirstTradeID: 10, LastTradeID: 80, Step: 10, Missing: 3, Gaps: 2},
+				{Ticker: "VALE3", TradeDate: date, Trades: 1, FirstTradeID: 10, LastTradeID: 10, Step: 1, Complete: true},
+			},
+		},
+		{
+			name:   "success with ticker and incomplete filters",
+			filter: SequenceFilter{UploadID: 7, Ticker: "PETR4", Incomplete: true},
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`FROM trade_sequences s WHERE s.upload_id = $1 AND s.ticker = $2 AND (s.missing > 0 OR s.duplicates > 0 OR s.out_of_order > 0 OR s.out_of_step > 0) ORDER BY s.ticker, s.trade_date;`)).
+					WithArgs(7, "PETR4").
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow("PETR4", date, 5, 10, 80, 10, 3, 2, 0, 0, 0))
+			},
+			want: []*TradeSequence{
+				{Ticker: "PETR4", TradeDate: date, Trades: 5, FirstTradeID: 10, LastTradeID: 80, Step: 10, Missing: 3, Gaps: 2},
+			},
+		},
+		{
+			name:   "failed because query error",
+			filter: SequenceFilter{UploadID: 7},
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`FROM trade_sequences s`)).
+					WithArgs(7).
+					WillReturnError(errors.New("query error"))
+			},
+			want:    nil,
+			wantErr: errors.New("query error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			tc.mockFunc(mock)
+
+			r := NewRepository(db)
+
+			got, err := r.ListSequences(context.Background(), tc.filter)
+
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestListCloses(t *testing.T) {
+	date := time.Date(2024, 6, 27, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name     string
+		mockFunc func(sqlmock.Sqlmock)
+		want     map[string]decimal.Decimal
+		wantErr  error
+	}{
+		{
+			name: "success",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT DISTINCT ON (m.ticker) m.ticker, m.close_price FROM metrics m WHERE m.trade_date = $1 AND m.session_type = $2 AND m.close_price IS NOT NULL ORDER BY m.ticker, m.id DESC;`)).
+					WithArgs(date, SessionRegular).
+					WillReturnRows(sqlmock.NewRows([]string{"ticker", "close_price"}).
+						AddRow("PETR4", "38.50").
+						AddRow("VALE3", "60.10"))
+			},
+			want: map[string]decimal.Decimal{
+				"PETR4": decimal.RequireFromString("38.50"),
+				"VALE3": decimal.RequireFromString("60.10"),
+			},
+		},
+		{
+			name: "failed because query error",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`FROM metrics m`)).
+					WithArgs(date, SessionRegular).
+					WillReturnError(errors.New("query error"))
+			},
+			want:    nil,
+			wantErr: errors.New("query error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			tc.mockFunc(mock)
+
+			r := NewRepository(db)
+
+			got, err := r.ListCloses(context.Background(), date)
+
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestListAnomalyBaselines(t *testing.T) {
+	date := time.Date(2024, 6, 27, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name     string
+		mockFunc func(sqlmock.Sqlmock)
+		want     map[string]*AnomalyBaseline
+		wantErr  error
+	}{
+		{
+			name: "success",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT t.instrument_code, COALESCE(( SELECT m.close_price FROM metrics m WHERE m.ticker = t.instrument_code AND m.trade_date = t.trade_date AND m.session_type = $2 AND m.close_price IS NOT NULL ORDER BY m.id DESC LIMIT 1 ), 0), AVG(t.trade_quantity) FROM trades t WHERE t.trade_date = $1 AND t.session_type = $2 AND NOT EXISTS ( SELECT 1 FROM anomalies a WHERE a.source_file_id = t.source_file_id AND a.instrument_code = t.instrument_code AND a.trade_date = t.trade_date AND a.trade_id = t.trade_id AND a.reason = $3 ) GROUP BY t.instrument_code, t.trade_date;`)).
+					WithArgs(date, SessionRegular, AnomalyExtremeSize).
+					WillReturnRows(sqlmock.NewRows([]string{"instrument_code", "close_price", "avg"}).
+						AddRow("PETR4", "38.50", "150.5").
+						AddRow("VALE3", "0", "200"))
+			},
+			want: map[string]*AnomalyBaseline{
+				"PETR4": {Ticker: "PETR4", ClosePrice: decimal.RequireFromString("38.50"), AverageQuantity: decimal.RequireFromString("150.5")},
+				"VALE3": {Ticker: "VALE3", ClosePrice: decimal.RequireFromString("0"), AverageQuantity: decimal.RequireFromString("200")},
+			},
+		},
+		{
+			name: "failed because query error",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`FROM trades t`)).
+					WithArgs(date, SessionRegular, AnomalyExtremeSize).
+					WillReturnError(errors.New("query error"))
+			},
+			want:    nil,
+			wantErr: errors.New("query error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			tc.mockFunc(mock)
+
+			r := NewRepository(db)
+
+			got, err := r.ListAnomalyBaselines(context.Background(), date)
+
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestBatchInsertQualityIssues(t *testing.T) {
+	date := time.Date(2024, 6, 28, 0, 0, 0, 0, time.UTC)
+	issues := []*QualityIssue{
+		{
+			Issue:   QualityNonPositiveQuantity,
+			Rows:    2,
+			Ratio:   0.4,
+			Samples: []*QualitySample{{Line: 3, Ticker: "PETR4", TradeDate: date, CloseTime: "100000000", Price: decimal.RequireFromString("38.5"), Quantity: 0}},
+		},
+		{
+			Issue:    QualityPriceDeviation,
+			Rows:     1,
+			Ratio:    0.2,
+			Rejected: true,
+			Samples:  []*QualitySample{},
+		},
+	}
+
+	cases := []struct {
+		name     string
+		mockFunc func(sqlmock.Sqlmock)
+		wantErr  error
+	}{
+		{
+			name: "success",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO quality_issues (upload_id, issue, row_count, ratio, rejected, samples) VALUES ($1, $2, $3, $4, $5, $6),($7, $8, $9, $10, $11, $12)`)).
+					WithArgs(7, QualityNonPositiveQuantity, 2, 0.4, false,
+						[]byte(`[{"line":3,"ticker":"PETR4","trade_date":"2024-06-28T00:00:00Z","close_time":"100000000","price":"38.5","quantity":0}]`),
+						7, QualityPriceDeviation, 1, 0.2, true, []byte(`[]`)).
+					WillReturnResult(sqlmock.NewResult(2, 2))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "failed because insert error",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO quality_issues`)).
+					WillReturnError(errors.New("insert error"))
+				mock.ExpectRollback()
+			},
+			wantErr: errors.New("insert error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			tc.mockFunc(mock)
+
+			r := NewRepository(db)
+
+			err = r.BatchInsertQualityIssues(context.Background(), 7, issues)
+			assert.Equal(t, tc.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestListQualityIssues(t *testing.T) {
+	date := time.Date(2024, 6, 28, 0, 0, 0, 0, time.UTC)
+	columns := []string{"issue", "row_count", "ratio", "rejected", "samples"}
+
+	cases := []struct {
+		name     string
+		mockFunc func(sqlmock.Sqlmock)
+		want     []*QualityIssue
+		wantErr  error
+	}{
+		{
+			name: "success",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`SELECT q.issue, q.row_count, q.ratio, q.rejected, q.samples FROM quality_issues q WHERE q.upload_id = $1 ORDER BY q.issue;`)).
+					WithArgs(7).
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(QualityNonPositiveQuantity, 2, 0.4, true,
+							[]byte(`[{"line":3,"ticker":"PETR4","trade_date":"2024-06-28T00:00:00Z","close_time":"100000000","price":"38.5","quantity":0}]`)))
+			},
+			want: []*QualityIssue{
+				{
+					Issue:    QualityNonPositiveQuantity,
+					Rows:     2,
+					Ratio:    0.4,
+					Rejected: true,
+					Samples:  []*QualitySample{{Line: 3, Ticker: "PETR4", TradeDate: date, CloseTime: "100000000", Price: decimal.RequireFromString("38.5"), Quantity: 0}},
+				},
+			},
+		},
+		{
+			name: "failed because invalid samples",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`FROM quality_issues q`)).
+					WithArgs(7).
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(QualityNonPositiveQuantity, 2, 0.4, true, []byte(`{`)))
+			},
+			want:    nil,
+			wantErr: errors.New("unexpected end of JSON input"),
+		},
+		{
+			name: "failed because query error",
+			mockFunc: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta(`FROM quality_issues q`)).
+					WithArgs(7).
+					WillReturnError(errors.New("query error"))
+			},
+			want:    nil,
+			wantErr: errors.New("query error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			assert.NoError(t, err)
+			defer db.Close()
+
+			tc.mockFunc(mock)
+
+			r := NewRepository(db)
+
+			got, err := r.ListQualityIssues(context.Background(), 7)
+
+			assert.Equal(t, tc.want, got)
+			if tc.wantErr != nil {
+				assert.EqualError(t, err, tc.wantErr.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
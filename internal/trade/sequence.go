@@ -0,0 +1,159 @@
+package trade
+
+import (
+	"sort"
+	"time"
+)
+
+// DefaultTradeIDStep is the increment B3 numbers the trades of a ticker and day with
+const DefaultTradeIDStep = 10
+
+// results of placing an id in the sequence
+const (
+	idStored = iota
+	idDuplicate
+	idOutOfStep
+)
+
+// sequenceChecker follows the B3 trade ids of each ticker and day in the file order
+// B3 numbers the trades of a ticker and day with a fixed increment, so missing ids point to a truncated file
+type sequenceChecker struct {
+	step int64
+	days map[string]*idSequence
+}
+
+// idSequence keeps the ids seen as sorted runs of consecutive ids instead of every id
+// A file in id order is a single run and each missing range splits it, so the memory grows with the gaps and not with the trades
+type idSequence struct {
+	ticker     string
+	tradeDate  time.Time
+	runs       []idRun
+	last       int64
+	trades     int
+	duplicates int
+	outOfOrder int
+	outOfStep  int
+}
+
+// idRun is a range of ids from first to last, both inclusive, without a missing id
+type idRun struct {
+	first int64
+	last  int64
+}
+
+func newSequenceChecker(step int64) *sequenceChecker {
+	if step <= 0 {
+		step = DefaultTradeIDStep
+	}
+
+	return &sequenceChecker{
+		step: step,
+		days: make(map[string]*idSequence),
+	}
+}
+
+func (c *sequenceChecker) check(trade *Trade) {
+	key := trade.InstrumentCode + "|" + trade.TradeDate.Format("2006-01-02")
+	sequence, ok := c.days[key]
+	if !ok {
+		sequence = &idSequence{
+			ticker:    trade.InstrumentCode,
+			tradeDate: trade.TradeDate,
+		}
+		c.days[key] = sequence
+	}
+
+	sequence.trades++
+	switch sequence.add(trade.TradeID, c.step) {
+	case idDuplicate:
+		sequence.duplicates++
+		return
+	case idOutOfStep:
+		sequence.outOfStep++
+		return
+	}
+	if trade.TradeID < sequence.last {
+		sequence.outOfOrder++
+	}
+	if trade.TradeID > sequence.last {
+		sequence.last = trade.TradeID
+	}
+}
+
+// add places the id in its run, merging the runs it joins, and reports whether it was already seen
+// An id off the increment inside a run is not kept and is reported apart, it does not fill any missing id
+func (s *idSequence) add(id, step int64) int {
+	i := sort.Search(len(s.runs), func(i int) bool { return s.runs[i].last >= id })
+	if i < len(s.runs) && s.runs[i].first <= id {
+		if (id-s.runs[i].first)%step != 0 {
+			return idOutOfStep
+		}
+		return idDuplicate
+	}
+
+	joinsPrevious := i > 0 && s.runs[i-1].last+step == id
+	joinsNext := i < len(s.runs) && id+step == s.runs[i].first
+
+	switch {
+	case joinsPrevious && joinsNext:
+		s.runs[i-1].last = s.runs[i].last
+		s.runs = append(s.runs[:i], s.runs[i+1:]...)
+	case joinsPrevious:
+		s.runs[i-1].last = id
+	case joinsNext:
+		s.runs[i].first = id
+	default:
+		s.runs = append(s.runs, idRun{})
+		copy(s.runs[i+1:], s.runs[i:])
+		s.runs[i] = idRun{first: id, last: id}
+	}
+
+	return idStored
+}
+
+// sequences reports each ticker and day ordered by ticker and date
+func (c *sequenceChecker) sequences() []*TradeSequence {
+	keys := make([]string, 0, len(c.days))
+	for key := range c.days {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	sequences := make([]*TradeSequence, 0, len(keys))
+	for _, key := range keys {
+		sequences = append(sequences, c.days[key].report(c.step))
+	}
+	return sequences
+}
+
+// report counts the missing ids and gaps against the increment
+// When the first id is a multiple of the increment the sequence is expected to start at the increment itself
+func (s *idSequence) report(step int64) *TradeSequence {
+	first, last := s.runs[0].first, s.runs[len(s.runs)-1].last
+
+	expectedFirst := first
+	if first%step == 0 && first > step {
+		expectedFirst = step
+	}
+
+	sequence := &TradeSequence{
+		Ticker:       s.ticker,
+		TradeDate:    s.tradeDate,
+		Trades:       s.trades,
+		FirstTradeID: first,
+		LastTradeID:  last,
+		Step:         step,
+		Missing:      max((last-expectedFirst)/step+1-int64(s.trades-s.duplicates-s.outOfStep), 0),
+		Gaps:         len(s.runs) - 1,
+		Duplicates:   s.duplicates,
+		OutOfOrder:   s.outOfOrder,
+		OutOfStep:    s.outOfStep,
+	}
+	if first > expectedFirst {
+		sequence.Gaps++
+	}
+
+	sequence.Complete = sequence.Missing == 0 && sequence.Duplicates == 0 && sequence.OutOfOrder == 0 && sequence.OutOfStep == 0
+
+	return sequence
+}
@@ -0,0 +1,136 @@
+package trade
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestSequenceChecker(t *testing.T) {
+	date := time.Date(2024, 6, 28, 0, 0, 0, 0, time.UTC)
+	next := date.AddDate(0, 0, 1)
+
+	cases := []struct {
+		name   string
+		step   int64
+		trades []*Trade
+		want   []*TradeSequence
+	}{
+		{
+			name: "complete sequences per ticker and day",
+			trades: []*Trade{
+				{InstrumentCode: "PETR4", TradeDate: date, TradeID: 10},
+				{InstrumentCode: "VALE3", TradeDate: date, TradeID: 10},
+				{InstrumentCode: "PETR4", TradeDate: date, TradeID: 20},
+				{InstrumentCode: "PETR4", TradeDate: date, TradeID: 30},
+				{InstrumentCode: "PETR4", TradeDate: next, TradeID: 10},
+			},
+			want: []*TradeSequence{
+				{Ticker: "PETR4", TradeDate: date, Trades: 3, FirstTradeID: 10, LastTradeID: 30, Step: 10, Complete: true},
+				{Ticker: "PETR4", TradeDate: next, Trades: 1, FirstTradeID: 10, LastTradeID: 10, Step: 10, Complete: true},
+				{Ticker: "VALE3", TradeDate: date, Trades: 1, FirstTradeID: 10, LastTradeID: 10, Step: 10, Complete: true},
+			},
+		},
+		{
+			name: "missing ids in the middle",
+			trades: []*Trade{
+				{InstrumentCode: "PETR4", TradeDate: date, TradeID: 10},
+				{InstrumentCode: "PETR4", TradeDate: date, TradeID: 20},
+				{InstrumentCode: "PETR4", TradeDate: date, TradeID: 50},
+				{InstrumentCode: "PETR4", TradeDate: date, TradeID: 60},
+				{InstrumentCode: "PETR4", TradeDate: date, TradeID: 80},
+			},
+			want: []*TradeSequence{
+				{Ticker: "PETR4", TradeDate: date, Trades: 5, FirstTradeID: 10, LastTradeID: 80, Step: 10, Missing: 3, Gaps: 2},
+			},
+		},
+		{
+			name: "regular gaps are counted against the increment",
+			trades: []*Trade{
+				{InstrumentCode: "PETR4", TradeDate: date, TradeID: 10},
+				{InstrumentCode: "PETR4", TradeDate: date, TradeID: 30},
+				{InstrumentCode: "PETR4", TradeDate: date, TradeID: 50},
+			},
+			want: []*TradeSequence{
+				{Ticker: "PETR4", TradeDate: date, Trades: 3, FirstTradeID: 10, LastTradeID: 50, Step: 10, Missing: 2, Gaps: 2},
+			},
+		},
+		{
+			name: "out of order ids fill the gaps",
+			trades: []*Trade{
+				{InstrumentCode: "PETR4", TradeDate: date, TradeID: 50},
+				{InstrumentCode: "PETR4", TradeDate: date, TradeID: 10},
+				{InstrumentCode: "PETR4", TradeDate: date, TradeID: 30},
+				{InstrumentCode: "PETR4", TradeDate: date, TradeID: 20},
+				{InstrumentCode: "PETR4", TradeDate: date, TradeID: 40},
+				{InstrumentCode: "PETR4", TradeDate: date, TradeID: 20},
+			},
+			want: []*TradeSequence{
+				{Ticker: "PETR4", TradeDate: date, Trades: 6, FirstTradeID: 10, LastTradeID: 50, Step: 10, Duplicates: 1, OutOfOrder: 4},
+			},
+		},
+		{
+			name: "file truncated at the start",
+			trades: []*Trade{
+				{InstrumentCode: "PETR4", TradeDate: date, TradeID: 40},
+				{InstrumentCode: "PETR4", TradeDate: date, TradeID: 50},
+				{InstrumentCode: "PETR4", TradeDate: date, TradeID: 60},
+			},
+			want: []*TradeSequence{
+				{Ticker: "PETR4", TradeDate: date, Trades: 3, FirstTradeID: 40, LastTradeID: 60, Step: 10, Missing: 3, Gaps: 1},
+			},
+		},
+		{
+			name: "duplicated and out of order ids",
+			trades: []*Trade{
+				{InstrumentCode: "PETR4", TradeDate: date, TradeID: 10},
+				{InstrumentCode: "PETR4", TradeDate: date, TradeID: 30},
+				{InstrumentCode: "PETR4", TradeDate: date, TradeID: 20},
+				{InstrumentCode: "PETR4", TradeDate: date, TradeID: 30},
+			},
+			want: []*TradeSequence{
+				{Ticker: "PETR4", TradeDate: date, Trades: 4, FirstTradeID: 10, LastTradeID: 30, Step: 10, Duplicates: 1, OutOfOrder: 1},
+			},
+		},
+		{
+			name: "off step id inside a run does not fill a missing id",
+			trades: []*Trade{
+				{InstrumentCode: "PETR4", TradeDate: date, TradeID: 10},
+				{InstrumentCode: "PETR4", TradeDate: date, TradeID: 20},
+				{InstrumentCode: "PETR4", TradeDate: date, TradeID: 15},
+				{InstrumentCode: "PETR4", TradeDate: date, TradeID: 40},
+			},
+			want: []*TradeSequence{
+				{Ticker: "PETR4", TradeDate: date, Trades: 4, FirstTradeID: 10, LastTradeID: 40, Step: 10, Missing: 1, Gaps: 1, OutOfStep: 1},
+			},
+		},
+		{
+			name: "sequences numbered one by one",
+			step: 1,
+			trades: []*Trade{
+				{InstrumentCode: "WINQ24", TradeDate: date, TradeID: 1},
+				{InstrumentCode: "WINQ24", TradeDate: date, TradeID: 2},
+				{InstrumentCode: "WINQ24", TradeDate: date, TradeID: 4},
+			},
+			want: []*TradeSequence{
+				{Ticker: "WINQ24", TradeDate: date, Trades: 3, FirstTradeID: 1, LastTradeID: 4, Step: 1, Missing: 1, Gaps: 1},
+			},
+		},
+		{
+			name:   "no trades",
+			trades: nil,
+			want:   []*TradeSequence{},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			checker := newSequenceChecker(tc.step)
+			for _, trade := range tc.trades {
+				checker.check(trade)
+			}
+
+			assert.Equal(t, tc.want, checker.sequences())
+		})
+	}
+}
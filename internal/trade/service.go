@@ -2,76 +2,1200 @@ package trade
 
 import (
 	"context"
+	"crypto/sha256"
+	"database/sql"
 	"encoding/csv"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"github.com/shopspring/decimal"
 	"io"
 	"log"
+	"quotation-metrics/internal/calendar"
 	"quotation-metrics/internal/config"
+	"quotation-metrics/internal/instrument"
+	"quotation-metrics/internal/storage"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 type Service interface {
-	BatchInsert(ctx context.Context, reader io.Reader) error
-	Metrics(ctx context.Context, ticker string, date time.Time) (*Metric, error)
+	CreateUpload(ctx context.Context, upload *Upload, file io.ReadSeeker) (*Upload, error)
+	BatchInsert(ctx context.Context, upload *Upload, reader io.Reader) error
+	Metrics(ctx context.Context, filter MetricFilter) (*Metric, error)
+	Trades(ctx context.Context, filter TradeFilter) (*TradePage, error)
+	Anomalies(ctx context.Context, filter AnomalyFilter) ([]*Anomaly, error)
+	BlockTrades(ctx context.Context, filter BlockTradeFilter) ([]*BlockTrade, error)
+	TopBrokers(ctx context.Context, filter BrokerFilter) (*BrokerRanking, error)
+	BrokerFlows(ctx context.Context, filter BrokerFilter) ([]*BrokerFlow, error)
+	BrokerMatrix(ctx context.Context, filter BrokerFilter) (*BrokerMatrix, error)
+	ClassifyInstruments(ctx context.Context) error
+	ImportInstruments(ctx context.Context, reader io.Reader) (int, error)
+	OptionChain(ctx context.Context, filter OptionFilter) ([]*OptionSeries, error)
+	ContinuousFuture(ctx context.Context, filter ContinuousFilter) ([]*ContinuousPoint, error)
+	MetricHistory(ctx context.Context, filter MetricFilter) ([]*DailyMetric, error)
+	CorporateActions(ctx context.Context, ticker string) ([]*CorporateAction, error)
+	SaveCorporateActions(ctx context.Context, actions []*CorporateAction) error
+	ImportCorporateActions(ctx context.Context, reader io.Reader) (int, error)
+	TradingDay(date time.Time) *TradingDay
+	TradingDays(start, end time.Time) *TradingDays
+	Activity(ctx context.Context, filter ActivityFilter) (*Activity, error)
+	MarketBreadth(ctx context.Context, filter BreadthFilter) ([]*MarketBreadth, error)
+	Liquidity(ctx context.Context, filter LiquidityFilter) (*Liquidity, error)
+	OrderFlow(ctx context.Context, filter OrderFlowFilter) ([]*DailyOrderFlow, error)
+	Benchmarks(ctx context.Context, filter BenchmarkFilter) (*Benchmarks, error)
+	Auctions(ctx context.Context, filter AuctionFilter) ([]*Auction, error)
+	Upload(ctx context.Context, id int) (*Upload, error)
+	Completeness(ctx context.Context, filter SequenceFilter) (*Completeness, error)
+	Quality(ctx context.Context, uploadID int) (*QualityReport, error)
+	DeleteUpload(ctx context.Context, audit *UploadAudit) error
+	Replay(ctx context.Context, id int, actor string) (*Upload, io.ReadCloser, error)
+	Validate(ctx context.Context, reader io.Reader, maxErrors int) (*Validation, error)
 }
 
+var (
+	// ErrInvalidInstrumentFile is returned when the instrument master file cannot be parsed
+	ErrInvalidInstrumentFile = errors.New("invalid instrument file")
+	// ErrInvalidCorporateAction is returned when a corporate action has an unknown type or a non positive factor
+	ErrInvalidCorporateAction = errors.New("invalid corporate action")
+	// ErrUploadNotFound is returned when there is no upload with the id
+	ErrUploadNotFound = errors.New("upload not found")
+	// ErrQualityRejected is returned when the share of the rows with a quality issue is above its threshold
+	ErrQualityRejected = errors.New("file rejected by the quality thresholds")
+	// ErrDuplicateFile is returned when a file with the same checksum is processing or was already ingested
+	ErrDuplicateFile = errors.New("file already uploaded")
+	// ErrUploadProcessing is returned when the upload is changed while its file is still being processed
+	ErrUploadProcessing = errors.New("upload is processing")
+	// ErrUploadDeleted is returned when the upload was already deleted
+	ErrUploadDeleted = errors.New("upload already deleted")
+	// ErrFileNotStored is returned when the raw file of the upload is not in the blob store
+	ErrFileNotStored = errors.New("file not stored")
+)
+
+// recordColumns is the number of columns of the B3 trade file
+const recordColumns = 11
+
+// instrumentColumns is the number of columns of the instrument master file: ticker, underlying and strike
+const instrumentColumns = 3
+
+// corporateActionColumns is the number of columns of the corporate actions file: ticker, ex date, type and factor
+const corporateActionColumns = 4
+
+const (
+	DefaultTradePageSize = 100
+	MaxTradePageSize     = 1000
+
+	DefaultBrokerLimit = 10
+	MaxBrokerLimit     = 100
+
+	DefaultValidationErrors = 20
+	MaxValidationErrors     = 1000
+
+	DefaultRollDays = 5
+
+	DefaultActivityBucketMinutes = 15
+
+	DefaultBreadthDays = 20
+	MaxBreadthDays     = 260
+)
+
 type service struct {
 	repository Repository
 	cfg        *config.Config
+	calendar   *calendar.Calendar
+	location   *time.Location
+	blobs      storage.BlobStore
+}
+
+// Metrics returns the metrics for a given ticker and date
+func (s *service) Metrics(ctx context.Context, filter MetricFilter) (*Metric, error) {
+	start := time.Now()
+
+	if filter.Consolidated {
+		// the consolidated view is always reported under the standard lot ticker
+		if underlying, ok := instrument.Underlying(filter.Ticker); ok {
+			filter.Ticker = underlying
+		}
+	}
+
+	var metrics *Metric
+	var err error
+	if filter.Adjusted {
+		metrics, err = s.adjustedMetrics(ctx, filter)
+	} else {
+		metrics, err = s.repository.GetMetrics(ctx, filter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if filter.IncludeChanges {
+		// the changes refer to the latest day, so the history is not limited by the start date
+		history := filter
+		history.Date = time.Time{}
+
+		daily, err := s.MetricHistory(ctx, history)
+		if err != nil {
+			return nil, err
+		}
+		metrics.Changes = metricChanges(daily)
+	}
+
+	log.Println("end metrics found, elapsed time ", time.Since(start))
+
+	return metrics, nil
+}
+
+// adjustedMetrics takes the max range value and daily volume from the adjusted daily metrics
+// The adjustment factor changes from one day to another, so the max can not be taken by the database
+func (s *service) adjustedMetrics(ctx context.Context, filter MetricFilter) (*Metric, error) {
+	daily, err := s.MetricHistory(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(daily) == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	metrics := &Metric{Ticker: filter.Ticker}
+	for _, day := range daily {
+		if day.MaxRangeValue.GreaterThan(metrics.MaxRangeValue) {
+			metrics.MaxRangeValue = day.MaxRangeValue
+		}
+		if day.MaxDailyVolume > metrics.MaxDailyVolume {
+			metrics.MaxDailyVolume = day.MaxDailyVolume
+		}
+	}
+
+	return metrics, nil
+}
+
+// MetricHistory returns the daily metrics of the ticker, adjusted by the corporate actions when requested
+func (s *service) MetricHistory(ctx context.Context, filter MetricFilter) ([]*DailyMetric, error) {
+	if filter.Consolidated {
+		if underlying, ok := instrument.Underlying(filter.Ticker); ok {
+			filter.Ticker = underlying
+		}
+	}
+
+	daily, err := s.repository.DailyMetrics(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	if !filter.Adjusted || len(daily) == 0 {
+		return daily, nil
+	}
+
+	actions, err := s.repository.ListCorporateActions(ctx, filter.Ticker)
+	if err != nil {
+		return nil, err
+	}
+
+	adjustDailyMetrics(daily, actions)
+
+	return daily, nil
+}
+
+// CorporateActions returns the corporate actions of the ticker
+func (s *service) CorporateActions(ctx context.Context, ticker string) ([]*CorporateAction, error) {
+	return s.repository.ListCorporateActions(ctx, strings.ToUpper(ticker))
+}
+
+// SaveCorporateActions validates and stores the corporate actions
+func (s *service) SaveCorporateActions(ctx context.Context, actions []*CorporateAction) error {
+	for _, action := range actions {
+		action.Ticker = strings.ToUpper(strings.TrimSpace(action.Ticker))
+		if err := validateCorporateAction(action); err != nil {
+			return err
+		}
+	}
+
+	if len(actions) == 0 {
+		return nil
+	}
+
+	return s.repository.UpsertCorporateActions(ctx, actions)
+}
+
+// ImportCorporateActions reads the corporate actions file and stores every action
+// It returns the number of imported actions
+func (s *service) ImportCorporateActions(ctx context.Context, reader io.Reader) (int, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.Comma = ';'
+	csvReader.FieldsPerRecord = -1
+
+	var lineNum int
+	actions := make([]*CorporateAction, 0)
+	for {
+		record, err := csvReader.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return 0, fmt.Errorf("%w: %v", ErrInvalidCorporateAction, err)
+		}
+
+		lineNum++
+
+		if lineNum == 1 {
+			continue
+		}
+
+		action, err := parseCorporateAction(record)
+		if err != nil {
+			return 0, fmt.Errorf("%w: line %d: %v", ErrInvalidCorporateAction, lineNum, err)
+		}
+		actions = append(actions, action)
+	}
+
+	err := s.SaveCorporateActions(ctx, actions)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(actions), nil
+}
+
+func parseCorporateAction(record []string) (*CorporateAction, error) {
+	if len(record) < corporateActionColumns {
+		return nil, fmt.Errorf("unexpected number of columns: %d", len(record))
+	}
+
+	exDate, err := time.Parse("2006-01-02", strings.TrimSpace(record[1]))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ex date: %v", err)
+	}
+
+	factor, err := decimal.NewFromString(strings.Replace(strings.TrimSpace(record[3]), ",", ".", 1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse factor: %v", err)
+	}
+
+	return &CorporateAction{
+		Ticker: record[0],
+		ExDate: exDate,
+		Type:   strings.TrimSpace(record[2]),
+		Factor: factor,
+	}, nil
+}
+
+func validateCorporateAction(action *CorporateAction) error {
+	if action.Ticker == "" {
+		return fmt.Errorf("%w: missing ticker", ErrInvalidCorporateAction)
+	}
+
+	switch action.Type {
+	case ActionSplit, ActionReverseSplit, ActionBonus:
+	default:
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidCorporateAction, action.Type)
+	}
+
+	if !action.Factor.IsPositive() {
+		return fmt.Errorf("%w: factor must be positive", ErrInvalidCorporateAction)
+	}
+
+	return nil
+}
+
+// Trades returns a page of trades matching the filter, ordered by id
+// The next cursor is only set when there are more trades after the returned page
+func (s *service) Trades(ctx context.Context, filter TradeFilter) (*TradePage, error) {
+	if err := s.checkTradingDay(filter.Date); err != nil {
+		return nil, err
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultTradePageSize
+	}
+	if filter.Limit > MaxTradePageSize {
+		filter.Limit = MaxTradePageSize
+	}
+
+	pageSize := filter.Limit
+	// fetch one extra trade to know if there is a next page
+	filter.Limit++
+
+	trades, err := s.repository.ListTrades(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &TradePage{Trades: trades}
+	if len(trades) > pageSize {
+		page.Trades = trades[:pageSize]
+		page.NextCursor = page.Trades[pageSize-1].ID
+	}
+
+	return page, nil
+}
+
+// Anomalies returns the trades flagged during ingestion that match the filter
+func (s *service) Anomalies(ctx context.Context, filter AnomalyFilter) ([]*Anomaly, error) {
+	if err := s.checkTradingDay(filter.Date); err != nil {
+		return nil, err
+	}
+
+	return s.repository.ListAnomalies(ctx, filter)
+}
+
+// BlockTrades returns the trades classified as block trades during ingestion
+func (s *service) BlockTrades(ctx context.Context, filter BlockTradeFilter) ([]*BlockTrade, error) {
+	if err := s.checkTradingDay(filter.Date); err != nil {
+		return nil, err
+	}
+
+	return s.repository.ListBlockTrades(ctx, filter)
+}
+
+// TopBrokers returns the brokers with the largest bought and sold quantities of a ticker
+func (s *service) TopBrokers(ctx context.Context, filter BrokerFilter) (*BrokerRanking, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultBrokerLimit
+	}
+	if filter.Limit > MaxBrokerLimit {
+		filter.Limit = MaxBrokerLimit
+	}
+
+	buyers, err := s.repository.TopBrokers(ctx, filter, SideBuy)
+	if err != nil {
+		return nil, err
+	}
+
+	sellers, err := s.repository.TopBrokers(ctx, filter, SideSell)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BrokerRanking{
+		Ticker:  filter.Ticker,
+		Buyers:  buyers,
+		Sellers: sellers,
+	}, nil
+}
+
+// BrokerFlows returns the bought, sold and net quantities of every broker per day
+func (s *service) BrokerFlows(ctx context.Context, filter BrokerFilter) ([]*BrokerFlow, error) {
+	return s.repository.BrokerFlows(ctx, filter)
+}
+
+// BrokerMatrix returns the net quantity of every broker in every ticker
+func (s *service) BrokerMatrix(ctx context.Context, filter BrokerFilter) (*BrokerMatrix, error) {
+	positions, err := s.repository.BrokerPositions(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	matrix := &BrokerMatrix{
+		Tickers: make([]string, 0),
+		Rows:    make([]*BrokerMatrixRow, 0),
+	}
+
+	tickerIndex := make(map[string]int)
+	for _, position := range positions {
+		if _, ok := tickerIndex[position.Ticker]; !ok {
+			tickerIndex[position.Ticker] = len(matrix.Tickers)
+			matrix.Tickers = append(matrix.Tickers, position.Ticker)
+		}
+	}
+	sort.Strings(matrix.Tickers)
+	for i, ticker := range matrix.Tickers {
+		tickerIndex[ticker] = i
+	}
+
+	// positions are ordered by broker, so each broker is a contiguous run of rows
+	var row *BrokerMatrixRow
+	for _, position := range positions {
+		if row == nil || row.Broker != position.Broker {
+			row = &BrokerMatrixRow{
+				Broker:      position.Broker,
+				NetQuantity: make([]int, len(matrix.Tickers)),
+			}
+			matrix.Rows = append(matrix.Rows, row)
+		}
+		row.NetQuantity[tickerIndex[position.Ticker]] = position.NetQuantity
+	}
+
+	return matrix, nil
+}
+
+// ClassifyInstruments stores the instrument type of the tickers loaded before the classification existed
+func (s *service) ClassifyInstruments(ctx context.Context) error {
+	tickers, err := s.repository.UnclassifiedTickers(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(tickers) == 0 {
+		return nil
+	}
+
+	return s.repository.UpsertInstruments(ctx, classify(tickers))
+}
+
+// classify returns the instrument of every ticker sorted by ticker
+func classify(tickers []string) []*Instrument {
+	sort.Strings(tickers)
+
+	instruments := make([]*Instrument, len(tickers))
+	for i, ticker := range tickers {
+		instruments[i] = &Instrument{
+			Ticker: ticker,
+			Type:   instrument.Classify(ticker),
+		}
+	}
+
+	return instruments
+}
+
+// ImportInstruments reads the instrument master file and stores the underlying and strike of every ticker
+// It returns the number of imported instruments
+func (s *service) ImportInstruments(ctx context.Context, reader io.Reader) (int, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.Comma = ';'
+	csvReader.FieldsPerRecord = -1
+
+	var lineNum int
+	instruments := make([]*Instrument, 0)
+	for {
+		record, err := csvReader.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return 0, fmt.Errorf("%w: %v", ErrInvalidInstrumentFile, err)
+		}
+
+		lineNum++
+
+		if lineNum == 1 {
+			continue
+		}
+
+		imported, err := parseInstrument(record)
+		if err != nil {
+			return 0, fmt.Errorf("%w: line %d: %v", ErrInvalidInstrumentFile, lineNum, err)
+		}
+		instruments = append(instruments, imported)
+	}
+
+	if len(instruments) == 0 {
+		return 0, nil
+	}
+
+	err := s.repository.ImportInstruments(ctx, instruments)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(instruments), nil
+}
+
+func parseInstrument(record []string) (*Instrument, error) {
+	if len(record) < instrumentColumns {
+		return nil, fmt.Errorf("unexpected number of columns: %d", len(record))
+	}
+
+	ticker := strings.ToUpper(strings.TrimSpace(record[0]))
+	if ticker == "" {
+		return nil, errors.New("missing ticker")
+	}
+
+	imported := &Instrument{
+		Ticker:     ticker,
+		Type:       instrument.Classify(ticker),
+		Underlying: strings.ToUpper(strings.TrimSpace(record[1])),
+	}
+
+	if value := strings.TrimSpace(record[2]); value != "" {
+		strike, err := decimal.NewFromString(strings.Replace(value, ",", ".", 1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse strike: %v", err)
+		}
+		imported.Strike = &strike
+	}
+
+	return imported, nil
+}
+
+// OptionChain returns the option series of the underlying traded on the date
+// The right, expiry month and series are read from the ticker
+func (s *service) OptionChain(ctx context.Context, filter OptionFilter) ([]*OptionSeries, error) {
+	filter.Underlying = strings.ToUpper(filter.Underlying)
+
+	if err := s.checkTradingDay(filter.Date); err != nil {
+		return nil, err
+	}
+
+	series, err := s.repository.ListOptionSeries(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, option := range series {
+		parsed, ok := instrument.ParseOption(option.Ticker)
+		if !ok {
+			continue
+		}
+		if option.Underlying == "" {
+			option.Underlying = parsed.Root
+		}
+		option.Right = parsed.Right
+		option.ExpiryMonth = parsed.ExpiryMonth
+		option.Series = parsed.Series
+	}
+
+	return series, nil
+}
+
+// ContinuousFuture stitches the daily closes of the contracts of the future root
+// The rollover rule defaults to the volume crossover and the prices are not adjusted by default
+func (s *service) ContinuousFuture(ctx context.Context, filter ContinuousFilter) ([]*ContinuousPoint, error) {
+	filter.Root = strings.ToUpper(filter.Root)
+	if filter.Roll == "" {
+		filter.Roll = RollVolume
+	}
+	if filter.Adjust == "" {
+		filter.Adjust = AdjustNone
+	}
+
+	days, err := s.repository.ListContractDays(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return continuousSeries(days, filter, s.calendar), nil
+}
+
+// Activity returns the average volume and number of trades of the ticker per time of day bucket
+// The averages are taken over the days the ticker traded in the period
+func (s *service) Activity(ctx context.Context, filter ActivityFilter) (*Activity, error) {
+	filter.Ticker = strings.ToUpper(filter.Ticker)
+	if filter.BucketMinutes <= 0 {
+		filter.BucketMinutes = DefaultActivityBucketMinutes
+	}
+
+	volumes, err := s.repository.IntradayVolumes(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	activity := &Activity{
+		Ticker:        filter.Ticker,
+		BucketMinutes: filter.BucketMinutes,
+		Buckets:       make([]*ActivityBucket, 0, len(volumes)),
+	}
+
+	for _, volume := range volumes {
+		activity.Days = volume.Days
+		days := decimal.NewFromInt(int64(volume.Days))
+		activity.Buckets = append(activity.Buckets, &ActivityBucket{
+			Start:         fmt.Sprintf("%02d:%02d", volume.Minute/60, volume.Minute%60),
+			AverageVolume: decimal.NewFromInt(int64(volume.Volume)).Div(days).Round(2),
+			AverageTrades: decimal.NewFromInt(int64(volume.Trades)).Div(days).Round(2),
+		})
+	}
+
+	return activity, nil
+}
+
+// MarketBreadth returns the advancing, declining and unchanged stocks, the new highs and lows and the market volume per day
+// The sessions before the start are read so the first days of the period are compared as well
+func (s *service) MarketBreadth(ctx context.Context, filter BreadthFilter) ([]*MarketBreadth, error) {
+	if filter.Days <= 0 {
+		filter.Days = DefaultBreadthDays
+	}
+	if filter.Days > MaxBreadthDays {
+		filter.Days = MaxBreadthDays
+	}
+
+	filter.HistoryStart = filter.Start
+	for i := 0; i < filter.Days; i++ {
+		filter.HistoryStart = s.calendar.Previous(filter.HistoryStart)
+	}
+
+	return s.repository.MarketBreadth(ctx, filter)
+}
+
+// Liquidity returns the average financial volume, Amihud illiquidity, Roll spread and zero volume days of the ticker
+func (s *service) Liquidity(ctx context.Context, filter LiquidityFilter) (*Liquidity, error) {
+	filter.Ticker = strings.ToUpper(filter.Ticker)
+
+	daily, err := s.repository.DailyLiquidity(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	covariance, err := s.repository.PriceCovariance(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	liquidity := &Liquidity{
+		Ticker:           filter.Ticker,
+		Start:            filter.Start,
+		End:              filter.End,
+		SerialCovariance: covariance.Covariance.Round(6),
+		RollSpread:       rollSpread(covariance.Covariance),
+	}
+	liquidityMeasures(liquidity, daily, s.calendar.TradingDays(filter.Start, filter.End))
+
+	return liquidity, nil
+}
+
+// OrderFlow returns the daily buyer and seller initiated volume of the ticker and its imbalance
+func (s *service) OrderFlow(ctx context.Context, filter OrderFlowFilter) ([]*DailyOrderFlow, error) {
+	filter.Ticker = strings.ToUpper(filter.Ticker)
+
+	flows, err := s.repository.ListOrderFlow(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return dailyOrderFlows(flows, filter.Intraday), nil
+}
+
+// Benchmarks returns the VWAP and TWAP of the ticker on each intraday window of the date
+// The trades spanning every window are read at once and split per window
+func (s *service) Benchmarks(ctx context.Context, filter BenchmarkFilter) (*Benchmarks, error) {
+	if err := s.checkTradingDay(filter.Date); err != nil {
+		return nil, err
+	}
+
+	filter.Ticker = strings.ToUpper(filter.Ticker)
+
+	benchmarks := &Benchmarks{
+		Ticker:  filter.Ticker,
+		Date:    filter.Date,
+		Windows: make([]*IntervalBenchmark, 0, len(filter.Windows)),
+	}
+	if len(filter.Windows) == 0 {
+		return benchmarks, nil
+	}
+
+	span := TradeFilter{Ticker: filter.Ticker, Date: filter.Date, FromTime: filter.Windows[0].From, ToTime: filter.Windows[0].To}
+	for _, window := range filter.Windows[1:] {
+		if window.From.Before(span.FromTime) {
+			span.FromTime = window.From
+		}
+		if window.To.After(span.ToTime) {
+			span.ToTime = window.To
+		}
+	}
+
+	trades, err := s.repository.ListWindowTrades(ctx, span)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, window := range filter.Windows {
+		benchmarks.Windows = append(benchmarks.Windows, intervalBenchmark(trades, window))
+	}
+
+	return benchmarks, nil
+}
+
+// Auctions returns the opening and closing auctions of the ticker identified during the upload
+func (s *service) Auctions(ctx context.Context, filter AuctionFilter) ([]*Auction, error) {
+	filter.Ticker = strings.ToUpper(filter.Ticker)
+	return s.repository.ListAuctions(ctx, filter)
 }
 
-// Metrics returns the metrics for a given ticker and date
-func (s *service) Metrics(ctx context.Context, ticker string, date time.Time) (*Metric, error) {
-	start := time.Now()
+// Upload returns the upload with its status
+func (s *service) Upload(ctx context.Context, id int) (*Upload, error) {
+	return s.repository.GetUpload(ctx, id)
+}
+
+// DeleteUpload rolls back the ingestion of the upload, removing its trades and recomputing the affected daily metrics
+// The audit entry is filled with the number of trades removed and metrics recomputed
+func (s *service) DeleteUpload(ctx context.Context, audit *UploadAudit) error {
+	audit.Action = AuditDelete
+	return s.repository.DeleteUpload(ctx, audit)
+}
+
+// Completeness returns the trade id sequences of the upload with the totals of the problems found
+func (s *service) Completeness(ctx context.Context, filter SequenceFilter) (*Completeness, error) {
+	if _, err := s.repository.GetUpload(ctx, filter.UploadID); err != nil {
+		return nil, err
+	}
 
-	metrics, err := s.repository.GetMetrics(ctx, ticker, date)
+	filter.Ticker = strings.ToUpper(filter.Ticker)
+	sequences, err := s.repository.ListSequences(ctx, filter)
 	if err != nil {
 		return nil, err
 	}
 
-	log.Println("end metrics found, elapsed time ", time.Since(start))
+	completeness := &Completeness{
+		UploadID:  filter.UploadID,
+		Sequences: len(sequences),
+		Details:   sequences,
+	}
+	for _, sequence := range sequences {
+		if !sequence.Complete {
+			completeness.Incomplete++
+		}
+		completeness.Missing += sequence.Missing
+		completeness.Duplicates += sequence.Duplicates
+		completeness.OutOfOrder += sequence.OutOfOrder
+		completeness.OutOfStep += sequence.OutOfStep
+	}
 
-	return metrics, nil
+	return completeness, nil
+}
+
+// Quality returns the data quality report of the upload
+func (s *service) Quality(ctx context.Context, uploadID int) (*QualityReport, error) {
+	upload, err := s.repository.GetUpload(ctx, uploadID)
+	if err != nil {
+		return nil, err
+	}
+
+	issues, err := s.repository.ListQualityIssues(ctx, uploadID)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &QualityReport{
+		UploadID: upload.ID,
+		Status:   upload.Status,
+		Rows:     upload.Trades,
+		Issues:   issues,
+	}
+	for _, issue := range issues {
+		if issue.Rejected {
+			report.Rejected = true
+		}
+	}
+
+	return report, nil
+}
+
+// TradingDay returns whether the date has a trading session and its surrounding trading days
+func (s *service) TradingDay(date time.Time) *TradingDay {
+	return &TradingDay{
+		Date:       date,
+		TradingDay: s.calendar.IsTradingDay(date),
+		Previous:   s.calendar.Previous(date),
+		Next:       s.calendar.Next(date),
+	}
+}
+
+// TradingDays returns the trading days from start to end, both inclusive
+func (s *service) TradingDays(start, end time.Time) *TradingDays {
+	days := s.calendar.TradingDays(start, end)
+	return &TradingDays{
+		Start: start,
+		End:   end,
+		Count: len(days),
+		Days:  days,
+	}
+}
+
+// checkTradingDay rejects single day filters on weekends and holidays, a zero date means no filter
+func (s *service) checkTradingDay(date time.Time) error {
+	if date.IsZero() || s.calendar.IsTradingDay(date) {
+		return nil
+	}
+	return calendar.ErrNotTradingDay
+}
+
+// CreateUpload registers the source file with its size and sha256 checksum before it is processed
+// The raw file is kept in the blob store under its checksum so it can be replayed, and read back from the start
+// A file processing or already ingested is not uploaded again, the existing upload is returned with ErrDuplicateFile
+func (s *service) CreateUpload(ctx context.Context, upload *Upload, file io.ReadSeeker) (*Upload, error) {
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %v", err)
+	}
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to read file: %v", err)
+	}
+
+	upload.FileSize = size
+	upload.Checksum = hex.EncodeToString(hash.Sum(nil))
+	upload.Status = UploadProcessing
+
+	existing, err := s.repository.FindUploadByChecksum(ctx, upload.Checksum)
+	switch {
+	case err == nil:
+		return existing, ErrDuplicateFile
+	case !errors.Is(err, ErrUploadNotFound):
+		return nil, err
+	}
+
+	upload.BlobKey = blobKey(upload.Checksum)
+	if err = s.blobs.Put(ctx, upload.BlobKey, file, size); err != nil {
+		return nil, fmt.Errorf("failed to store file: %v", err)
+	}
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to read file: %v", err)
+	}
+
+	if err = s.repository.CreateUpload(ctx, upload); err != nil {
+		return nil, err
+	}
+
+	return upload, nil
+}
+
+// Replay registers a new upload of the stored file of the upload to be ingested again through the current parser
+// The trades and metrics of the upload are rolled back in the transaction that registers the replay, the caller
+// processes the returned file with BatchInsert and closes it
+func (s *service) Replay(ctx context.Context, id int, actor string) (*Upload, io.ReadCloser, error) {
+	upload, err := s.repository.GetUpload(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if upload.Status == UploadProcessing {
+		return nil, nil, ErrUploadProcessing
+	}
+	if upload.BlobKey == "" {
+		return nil, nil, ErrFileNotStored
+	}
+
+	file, err := s.blobs.Get(ctx, upload.BlobKey)
+	if err != nil {
+		if errors.Is(err, storage.ErrBlobNotFound) {
+			return nil, nil, ErrFileNotStored
+		}
+		return nil, nil, err
+	}
+
+	replay := &Upload{
+		FileName: upload.FileName,
+		FileSize: upload.FileSize,
+		Checksum: upload.Checksum,
+		Uploader: actor,
+		BlobKey:  upload.BlobKey,
+		ReplayOf: &upload.ID,
+		Status:   UploadProcessing,
+	}
+	if replay.Uploader == "" {
+		replay.Uploader = upload.Uploader
+	}
+
+	audit := &UploadAudit{UploadID: upload.ID, Action: AuditReplay, Actor: actor}
+	if err = s.repository.ReplayUpload(ctx, audit, replay); err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+
+	return replay, file, nil
+}
+
+// blobKey is the key of the raw file in the blob store, files with the same content share it
+func blobKey(checksum string) string {
+	return "source-files/" + checksum
 }
 
 // BatchInsert reads the csv file from the buffer and inserts the trades into the database
 // It also calculates the metrics for the trades and inserts them into the database
-func (s *service) BatchInsert(ctx context.Context, reader io.Reader) error {
+// The upload is finished as completed or failed with the number of trades read
+func (s *service) BatchInsert(ctx context.Context, upload *Upload, reader io.Reader) error {
+	trades, err := s.batchInsert(ctx, upload, reader)
+
+	// the workers may have stored trades and rows derived from them before the rejection or the error, they are
+	// removed so only the quality issues remain and a new upload of the file does not count them twice
+	if err != nil {
+		if purgeErr := s.repository.PurgeUpload(ctx, upload.ID); purgeErr != nil {
+			log.Println("failed to purge upload ", purgeErr)
+			if errors.Is(err, ErrQualityRejected) {
+				err = purgeErr
+			}
+		}
+	}
+
+	switch {
+	case err == nil:
+		upload.Status = UploadCompleted
+	case errors.Is(err, ErrQualityRejected):
+		upload.Status = UploadRejected
+	default:
+		upload.Status = UploadFailed
+	}
+	upload.Trades = trades
+
+	if finishErr := s.repository.FinishUpload(ctx, upload); finishErr != nil {
+		log.Println("failed to finish upload ", finishErr)
+		if err == nil {
+			err = finishErr
+		}
+	}
+
+	return err
+}
+
+func (s *service) batchInsert(ctx context.Context, upload *Upload, reader io.Reader) (int, error) {
 	tradeCh := make(chan []*Trade)
-	doneCh := make(chan error)
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	// set workers to process the trades
+	// set workers to process the trades, the first worker error cancels the others and the reading of the file
+	var wg sync.WaitGroup
+	var once sync.Once
+	var workerErr error
 	for i := 0; i < s.cfg.App.Workers; i++ {
-		go s.worker(ctx, tradeCh, doneCh)
+		wg.Add(1)
 		go func() {
-			if err := <-doneCh; err != nil {
-				cancel()
+			defer wg.Done()
+			if err := s.worker(ctx, upload.ID, tradeCh); err != nil {
+				once.Do(func() {
+					workerErr = err
+					cancel()
+				})
 			}
 		}()
 	}
 
 	// process the csv file and send the trades to the workers
-	metrics, err := s.processCSV(reader, tradeCh, ctx)
-	if err != nil {
-		return err
-	}
+	result, err := s.processCSV(reader, tradeCh, ctx)
 
+	// the upload is only finished once every trade sent is stored
 	close(tradeCh)
-	err = s.repository.BatchInsertMetrics(ctx, metrics)
+	wg.Wait()
+	if workerErr != nil {
+		return 0, workerErr
+	}
 	if err != nil {
-		return err
+		return 0, err
 	}
+	upload.ReferenceDate = result.referenceDate
 
-	return nil
+	if len(result.quality) > 0 {
+		err = s.repository.BatchInsertQualityIssues(ctx, upload.ID, result.quality)
+		if err != nil {
+			return result.trades, err
+		}
+	}
+
+	// the metrics of a rejected file are not stored
+	if result.rejected {
+		return result.trades, ErrQualityRejected
+	}
+
+	for _, metrics := range metricBatches(result.metrics, s.cfg.App.BatchSize) {
+		err = s.repository.BatchInsertMetrics(ctx, upload.ID, metrics)
+		if err != nil {
+			return result.trades, err
+		}
+	}
+
+	if len(result.tickers) > 0 {
+		tickers := make([]string, 0, len(result.tickers))
+		for ticker := range result.tickers {
+			tickers = append(tickers, ticker)
+		}
+
+		err = s.repository.UpsertInstruments(ctx, classify(tickers))
+		if err != nil {
+			return result.trades, err
+		}
+	}
+
+	for _, anomalies := range batches(result.anomalies, s.cfg.App.BatchSize) {
+		err = s.repository.BatchInsertAnomalies(ctx, upload.ID, anomalies)
+		if err != nil {
+			return result.trades, err
+		}
+	}
+
+	for _, blocks := range batches(result.blocks, s.cfg.App.BatchSize) {
+		err = s.repository.BatchInsertBlockTrades(ctx, upload.ID, blocks)
+		if err != nil {
+			return result.trades, err
+		}
+	}
+
+	if len(result.auctions) > 0 {
+		err = s.repository.BatchInsertAuctions(ctx, upload.ID, result.auctions)
+		if err != nil {
+			return result.trades, err
+		}
+	}
+
+	for _, sequences := range batches(result.sequences, s.cfg.App.BatchSize) {
+		err = s.repository.BatchInsertSequences(ctx, upload.ID, sequences)
+		if err != nil {
+			return result.trades, err
+		}
+	}
+
+	for _, flows := range batches(result.orderFlows, s.cfg.App.BatchSize) {
+		err = s.repository.BatchInsertOrderFlow(ctx, upload.ID, flows)
+		if err != nil {
+			return result.trades, err
+		}
+	}
+
+	return result.trades, nil
+}
+
+// batches splits the rows in slices of at most size rows, so each multi row insert stays under the postgres
+// limit of bind parameters as the trades sent to the workers do
+func batches[T any](rows []T, size int) [][]T {
+	if size <= 0 {
+		size = len(rows)
+	}
+
+	var chunks [][]T
+	for start := 0; start < len(rows); start += size {
+		end := min(start+size, len(rows))
+		chunks = append(chunks, rows[start:end])
+	}
+	return chunks
+}
+
+// metricBatches splits the metrics in maps of at most size metrics, taken in key order so the split is stable
+func metricBatches(metrics map[string]*Metric, size int) []map[string]*Metric {
+	keys := make([]string, 0, len(metrics))
+	for key := range metrics {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	chunks := make([]map[string]*Metric, 0)
+	for _, batch := range batches(keys, size) {
+		chunk := make(map[string]*Metric, len(batch))
+		for _, key := range batch {
+			chunk[key] = metrics[key]
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+// Validate runs the parse and quality checks of the upload on the file without storing anything
+// Unlike the upload it goes on after an invalid row and keeps the first maxErrors errors
+func (s *service) Validate(ctx context.Context, reader io.Reader, maxErrors int) (*Validation, error) {
+	if maxErrors <= 0 {
+		maxErrors = DefaultValidationErrors
+	}
+	if maxErrors > MaxValidationErrors {
+		maxErrors = MaxValidationErrors
+	}
+
+	csvReader := csv.NewReader(reader)
+	csvReader.Comma = ';'
+	// the rows with a wrong number of columns are reported by parseRecord
+	csvReader.FieldsPerRecord = -1
+
+	validation := &Validation{
+		Columns: make([]string, 0),
+		Tickers: make([]string, 0),
+		Errors:  make([]*ValidationError, 0),
+	}
+	quality := newQualityChecker(s.cfg.Quality)
+	metrics := make(map[string]*Metric)
+	closes := make(map[time.Time]map[string]decimal.Decimal)
+	tickers := make(map[string]struct{})
+
+	var lineNum int
+	invalid := func(err error) {
+		if lineNum > 1 {
+			validation.InvalidRows++
+		}
+		if len(validation.Errors) < maxErrors {
+			validation.Errors = append(validation.Errors, &ValidationError{Line: lineNum, Error: err.Error()})
+		}
+	}
+
+	for {
+		record, err := csvReader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		lineNum++
+		if lineNum > 1 {
+			validation.Rows++
+		}
+
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, err
+			}
+			invalid(err)
+			continue
+		}
+
+		if lineNum == 1 {
+			validation.Columns = record
+			continue
+		}
+
+		trade, err := s.parseRecord(record)
+		if err != nil {
+			invalid(err)
+			continue
+		}
+
+		// the reference date of the first valid row is the reference date of the file, the rows before it are
+		// already reported as invalid
+		if validation.ReferenceDate == nil {
+			referenceDate, err := time.Parse("2006-01-02", record[0])
+			if err != nil {
+				invalid(fmt.Errorf("failed to parse reference date: %v", err))
+				continue
+			}
+			validation.ReferenceDate = &referenceDate
+			quality.setReference(referenceDate)
+		}
+
+		previousClose, err := s.previousClose(ctx, trade, metrics, closes)
+		if err != nil {
+			return nil, err
+		}
+		quality.check(lineNum, trade, previousClose)
+		s.updateMetrics(metrics, trade, false)
+
+		validation.ValidRows++
+		tickers[trade.InstrumentCode] = struct{}{}
+
+		tradeDate := trade.TradeDate
+		if validation.StartDate == nil || tradeDate.Before(*validation.StartDate) {
+			validation.StartDate = &tradeDate
+		}
+		if validation.EndDate == nil || tradeDate.After(*validation.EndDate) {
+			validation.EndDate = &tradeDate
+		}
+	}
+
+	for ticker := range tickers {
+		validation.Tickers = append(validation.Tickers, ticker)
+	}
+	sort.Strings(validation.Tickers)
+	validation.DistinctTickers = len(validation.Tickers)
+
+	var rejected bool
+	validation.Quality, rejected = quality.report()
+	validation.Valid = validation.InvalidRows == 0 && !rejected
+
+	return validation, nil
+}
+
+// batchResult holds everything aggregated from the csv file besides the trades themselves
+type batchResult struct {
+	metrics       map[string]*Metric
+	anomalies     []*Anomaly
+	blocks        []*BlockTrade
+	orderFlows    []*OrderFlow
+	auctions      []*Auction
+	sequences     []*TradeSequence
+	quality       []*QualityIssue
+	rejected      bool
+	trades        int
+	tickers       map[string]struct{}
+	referenceDate *time.Time
 }
 
-func (s *service) processCSV(reader io.Reader, tradeCh chan []*Trade, ctx context.Context) (map[string]*Metric, error) {
+func (s *service) processCSV(reader io.Reader, tradeCh chan []*Trade, ctx context.Context) (*batchResult, error) {
 
 	start := time.Now()
 
@@ -80,7 +1204,22 @@ func (s *service) processCSV(reader io.Reader, tradeCh chan []*Trade, ctx contex
 
 	var lineNum int
 	var tradeList []*Trade
-	metrics := make(map[string]*Metric)
+	result := &batchResult{
+		metrics: make(map[string]*Metric),
+		tickers: make(map[string]struct{}),
+	}
+	detector := newAnomalyDetector(s.cfg.Anomaly)
+	classifier := newBlockClassifier(s.cfg.Block)
+	orderFlow := newOrderFlowAggregator()
+	auctions := newAuctionTracker(s.cfg.Auction)
+	sequences := newSequenceChecker(int64(s.cfg.Sequence.TradeIDStep))
+	quality := newQualityChecker(s.cfg.Quality)
+	// stored closes of the trading days before the dates of the file
+	closes := make(map[time.Time]map[string]decimal.Decimal)
+	// stored anomaly baselines of the trading days before the dates of the file
+	baselines := make(map[time.Time]map[string]*AnomalyBaseline)
+	// non trading dates are only reported once per file
+	warnedDates := make(map[time.Time]struct{})
 
 	for {
 		record, err := csvReader.Read()
@@ -105,10 +1244,50 @@ func (s *service) processCSV(reader io.Reader, tradeCh chan []*Trade, ctx contex
 			return nil, err
 		}
 
+		// the reference date of the first row is the reference date of the file
+		if lineNum == 2 {
+			referenceDate, err := time.Parse("2006-01-02", record[0])
+			if err != nil {
+				log.Println("failed to parse record ", err)
+				return nil, fmt.Errorf("failed to parse reference date: %v", err)
+			}
+			quality.setReference(referenceDate)
+			result.referenceDate = &referenceDate
+		}
+
+		previousClose, err := s.previousClose(ctx, trade, result.metrics, closes)
+		if err != nil {
+			log.Println("failed to get previous close ", err)
+			return nil, err
+		}
+		quality.check(lineNum, trade, previousClose)
+
+		if _, ok := warnedDates[trade.TradeDate]; !ok && !s.calendar.IsTradingDay(trade.TradeDate) {
+			log.Printf("trade on line %d is dated on a non trading day %s\n", lineNum, trade.TradeDate.Format("2006-01-02"))
+			warnedDates[trade.TradeDate] = struct{}{}
+		}
+
 		tradeList = append(tradeList, trade)
+		result.tickers[trade.InstrumentCode] = struct{}{}
+
+		// flag outliers against the ticker history, price outliers are kept out of the max range value
+		baseline, err := s.anomalyBaseline(ctx, trade, baselines)
+		if err != nil {
+			log.Println("failed to get anomaly baseline ", err)
+			return nil, err
+		}
+		anomalies := detector.check(trade, baseline)
+		result.anomalies = append(result.anomalies, anomalies...)
+
+		if block := classifier.classify(trade); block != nil {
+			result.blocks = append(result.blocks, block)
+		}
 
 		// add the trade to the metrics map
-		s.updateMetrics(metrics, trade)
+		s.updateMetrics(result.metrics, trade, hasReason(anomalies, AnomalyPriceDeviation))
+		orderFlow.add(trade)
+		auctions.track(trade)
+		sequences.check(trade)
 
 		// send the trades to the workers when the batch size is reached
 		if len(tradeList) == s.cfg.App.BatchSize {
@@ -130,12 +1309,23 @@ func (s *service) processCSV(reader io.Reader, tradeCh chan []*Trade, ctx contex
 		}
 	}
 
-	log.Printf("end process CSV, total trades %d, total metrics %d elapsed time %s\n", lineNum-1, len(metrics), time.Since(start))
+	result.orderFlows = orderFlow.flows()
+	result.auctions = auctions.list()
+	result.sequences = sequences.sequences()
+	result.quality, result.rejected = quality.report()
+	result.trades = lineNum - 1
 
-	return metrics, nil
+	log.Printf("end process CSV, total trades %d, total metrics %d, total anomalies %d, total block trades %d elapsed time %s\n",
+		lineNum-1, len(result.metrics), len(result.anomalies), len(result.blocks), time.Since(start))
+
+	return result, nil
 }
 
 func (s *service) parseRecord(record []string) (*Trade, error) {
+	if len(record) < recordColumns {
+		return nil, fmt.Errorf("unexpected number of columns: %d", len(record))
+	}
+
 	tradePrice, err := decimal.NewFromString(strings.Replace(record[3], ",", ".", 1))
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse trade price: %v", err)
@@ -146,51 +1336,164 @@ func (s *service) parseRecord(record []string) (*Trade, error) {
 		return nil, fmt.Errorf("failed to parse trade quantity: %v", err)
 	}
 
+	tradeID, err := strconv.ParseInt(record[6], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse trade id: %v", err)
+	}
+
+	sessionType, err := strconv.Atoi(record[7])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse session type: %v", err)
+	}
+
+	// the trade date is a calendar day of the exchange, kept as midnight UTC whatever the server timezone
 	tradeDate, err := time.Parse("2006-01-02", record[8])
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse trade date: %v", err)
 	}
 
+	tradedAt, err := tradeInstant(tradeDate, record[5], s.location)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse close time: %v", err)
+	}
+
 	return &Trade{
 		InstrumentCode: record[1],
 		TradePrice:     tradePrice,
 		TradeQuantity:  tradeQuantity,
 		CloseTime:      record[5],
 		TradeDate:      tradeDate,
+		TradedAt:       tradedAt,
+		BuyerCode:      record[9],
+		SellerCode:     record[10],
+		SessionType:    sessionType,
+		TradeID:        tradeID,
 	}, nil
 }
 
-func (s *service) updateMetrics(metrics map[string]*Metric, trade *Trade) {
-	if v, ok := metrics[trade.InstrumentCode]; ok {
-		if trade.TradePrice.GreaterThan(v.MaxRangeValue) {
+// tradeInstant combines the trade date and the HHMMSSmmm close time in the exchange timezone
+func tradeInstant(tradeDate time.Time, closeTime string, location *time.Location) (time.Time, error) {
+	if len(closeTime) != 9 {
+		return time.Time{}, fmt.Errorf("unexpected close time: %s", closeTime)
+	}
+
+	clock, err := time.Parse("150405.000", closeTime[:6]+"."+closeTime[6:])
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Date(tradeDate.Year(), tradeDate.Month(), tradeDate.Day(),
+		clock.Hour(), clock.Minute(), clock.Second(), clock.Nanosecond(), location), nil
+}
+
+// previousClose returns the regular session close of the ticker on the trading day before the trade
+// The close comes from the file when it has that day and from the stored metrics otherwise
+func (s *service) previousClose(ctx context.Context, trade *Trade, metrics map[string]*Metric, closes map[time.Time]map[string]decimal.Decimal) (decimal.Decimal, error) {
+	if s.cfg.Quality.PriceDeviation <= 0 {
+		return decimal.Zero, nil
+	}
+
+	previous := s.calendar.Previous(trade.TradeDate)
+	key := fmt.Sprintf("%s|%s|%d", trade.InstrumentCode, previous.Format("2006-01-02"), SessionRegular)
+	if metric, ok := metrics[key]; ok {
+		return metric.ClosePrice, nil
+	}
+
+	stored, ok := closes[previous]
+	if !ok {
+		var err error
+		stored, err = s.repository.ListCloses(ctx, previous)
+		if err != nil {
+			return decimal.Zero, err
+		}
+		closes[previous] = stored
+	}
+
+	return stored[trade.InstrumentCode], nil
+}
+
+// anomalyBaseline returns the stored baseline of the ticker on the trading day before the trade, so the first
+// trades of the ticker in the file are checked too
+func (s *service) anomalyBaseline(ctx context.Context, trade *Trade, baselines map[time.Time]map[string]*AnomalyBaseline) (*AnomalyBaseline, error) {
+	if s.cfg.Anomaly.PriceDeviation <= 0 && s.cfg.Anomaly.SizeMultiplier <= 0 {
+		return nil, nil
+	}
+
+	previous := s.calendar.Previous(trade.TradeDate)
+	stored, ok := baselines[previous]
+	if !ok {
+		var err error
+		stored, err = s.repository.ListAnomalyBaselines(ctx, previous)
+		if err != nil {
+			return nil, err
+		}
+		baselines[previous] = stored
+	}
+
+	return stored[trade.InstrumentCode], nil
+}
+
+// metricKey identifies the daily metric of a ticker in a trading session
+func metricKey(trade *Trade) string {
+	return fmt.Sprintf("%s|%s|%d", trade.InstrumentCode, trade.TradeDate.Format("2006-01-02"), trade.SessionType)
+}
+
+func (s *service) updateMetrics(metrics map[string]*Metric, trade *Trade, priceOutlier bool) {
+	key := metricKey(trade)
+	if v, ok := metrics[key]; ok {
+		if !priceOutlier && trade.TradePrice.GreaterThan(v.MaxRangeValue) {
 			v.MaxRangeValue = trade.TradePrice
 		}
+		// close time is a fixed width HHMMSSmmm string, trades are not guaranteed to be sorted in the file
+		if !priceOutlier && trade.CloseTime >= v.CloseTime {
+			v.ClosePrice = trade.TradePrice
+			v.CloseTime = trade.CloseTime
+		}
 		v.MaxDailyVolume += trade.TradeQuantity
-		metrics[trade.InstrumentCode] = v
+		v.FinancialVolume = v.FinancialVolume.Add(financialVolume(trade))
+		metrics[key] = v
 	} else {
-		metrics[trade.InstrumentCode] = &Metric{
-			Ticker:         trade.InstrumentCode,
-			MaxRangeValue:  trade.TradePrice,
-			MaxDailyVolume: trade.TradeQuantity,
-			TradeDate:      trade.TradeDate,
+		metrics[key] = &Metric{
+			Ticker:          trade.InstrumentCode,
+			MaxRangeValue:   trade.TradePrice,
+			MaxDailyVolume:  trade.TradeQuantity,
+			TradeDate:       trade.TradeDate,
+			SessionType:     trade.SessionType,
+			ClosePrice:      trade.TradePrice,
+			CloseTime:       trade.CloseTime,
+			FinancialVolume: financialVolume(trade),
 		}
 	}
 }
 
-func (s *service) worker(ctx context.Context, tradeCh chan []*Trade, doneCh chan error) {
+// financialVolume is the traded amount of the trade, price outliers are real trades and are kept in it
+func financialVolume(trade *Trade) decimal.Decimal {
+	return trade.TradePrice.Mul(decimal.NewFromInt(int64(trade.TradeQuantity)))
+}
+
+// worker stores the trades received until the channel is closed and stops at the first error
+func (s *service) worker(ctx context.Context, sourceFileID int, tradeCh chan []*Trade) error {
 	for trades := range tradeCh {
-		err := s.repository.BatchInsertTrade(ctx, trades)
+		err := s.repository.BatchInsertTrade(ctx, sourceFileID, trades)
 		if err != nil {
-			doneCh <- err
-			return
+			return err
 		}
 	}
-	doneCh <- nil
+	return nil
 }
 
-func NewService(repository Repository, cfg *config.Config) Service {
+func NewService(repository Repository, cfg *config.Config, blobs storage.BlobStore) Service {
+	// the timezone is validated when the config is loaded, an empty one is UTC
+	location, err := time.LoadLocation(cfg.App.Timezone)
+	if err != nil {
+		location = time.UTC
+	}
+
 	return &service{
 		repository: repository,
 		cfg:        cfg,
+		calendar:   calendar.New(cfg.Calendar.Holidays),
+		location:   location,
+		blobs:      blobs,
 	}
 }
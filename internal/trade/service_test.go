@@ -3,12 +3,20 @@ package trade
 import (
 	"bytes"
 	"context"
+	"database/sql"
 	"errors"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"io"
 	"math/big"
+	"quotation-metrics/internal/calendar"
 	"quotation-metrics/internal/config"
+	"quotation-metrics/internal/instrument"
+	"quotation-metrics/internal/storage"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -17,59 +25,2952 @@ type MockRepository struct {
 	mock.Mock
 }
 
-func (m *MockRepository) BatchInsertTrade(ctx context.Context, trades []*Trade) error {
-	args := m.Called(ctx, trades)
+func (m *MockRepository) BatchInsertTrade(ctx context.Context, sourceFileID int, trades []*Trade) error {
+	args := m.Called(ctx, sourceFileID, trades)
 	return args.Error(0)
 }
 
-func (m *MockRepository) GetMetrics(ctx context.Context, ticker string, date time.Time) (*Metric, error) {
-	args := m.Called(ctx, ticker, date)
+func (m *MockRepository) GetMetrics(ctx context.Context, filter MetricFilter) (*Metric, error) {
+	args := m.Called(ctx, filter)
 	if args.Get(0) != nil {
 		return args.Get(0).(*Metric), args.Error(1)
 	}
 	return nil, args.Error(1)
 }
 
-func (m *MockRepository) BatchInsertMetrics(ctx context.Context, metricsMap map[string]*Metric) error {
-	args := m.Called(ctx, metricsMap)
+func (m *MockRepository) BatchInsertMetrics(ctx context.Context, sourceFileID int, metricsMap map[string]*Metric) error {
+	args := m.Called(ctx, sourceFileID, metricsMap)
 	return args.Error(0)
 }
 
+func (m *MockRepository) ListTrades(ctx context.Context, filter TradeFilter) ([]*Trade, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) != nil {
+		return args.Get(0).([]*Trade), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepository) BatchInsertAnomalies(ctx context.Context, sourceFileID int, anomalies []*Anomaly) error {
+	args := m.Called(ctx, sourceFileID, anomalies)
+	return args.Error(0)
+}
+
+func (m *MockRepository) ListAnomalies(ctx context.Context, filter AnomalyFilter) ([]*Anomaly, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) != nil {
+		return args.Get(0).([]*Anomaly), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepository) BatchInsertBlockTrades(ctx context.Context, sourceFileID int, blocks []*BlockTrade) error {
+	args := m.Called(ctx, sourceFileID, blocks)
+	return args.Error(0)
+}
+
+func (m *MockRepository) ListBlockTrades(ctx context.Context, filter BlockTradeFilter) ([]*BlockTrade, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) != nil {
+		return args.Get(0).([]*BlockTrade), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepository) TopBrokers(ctx context.Context, filter BrokerFilter, side string) ([]*BrokerVolume, error) {
+	args := m.Called(ctx, filter, side)
+	if args.Get(0) != nil {
+		return args.Get(0).([]*BrokerVolume), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepository) BrokerFlows(ctx context.Context, filter BrokerFilter) ([]*BrokerFlow, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) != nil {
+		return args.Get(0).([]*BrokerFlow), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepository) BrokerPositions(ctx context.Context, filter BrokerFilter) ([]*BrokerPosition, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) != nil {
+		return args.Get(0).([]*BrokerPosition), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepository) UpsertInstruments(ctx context.Context, instruments []*Instrument) error {
+	args := m.Called(ctx, instruments)
+	return args.Error(0)
+}
+
+func (m *MockRepository) UnclassifiedTickers(ctx context.Context) ([]string, error) {
+	args := m.Called(ctx)
+	if args.Get(0) != nil {
+		return args.Get(0).([]string), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepository) ImportInstruments(ctx context.Context, instruments []*Instrument) error {
+	args := m.Called(ctx, instruments)
+	return args.Error(0)
+}
+
+func (m *MockRepository) ListOptionSeries(ctx context.Context, filter OptionFilter) ([]*OptionSeries, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) != nil {
+		return args.Get(0).([]*OptionSeries), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepository) ListContractDays(ctx context.Context, filter ContinuousFilter) ([]*ContractDay, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) != nil {
+		return args.Get(0).([]*ContractDay), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepository) IntradayVolumes(ctx context.Context, filter ActivityFilter) ([]*IntradayVolume, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) != nil {
+		return args.Get(0).([]*IntradayVolume), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepository) MarketBreadth(ctx context.Context, filter BreadthFilter) ([]*MarketBreadth, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) != nil {
+		return args.Get(0).([]*MarketBreadth), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepository) DailyLiquidity(ctx context.Context, filter LiquidityFilter) ([]*DailyLiquidity, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) != nil {
+		return args.Get(0).([]*DailyLiquidity), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepository) PriceCovariance(ctx context.Context, filter LiquidityFilter) (*PriceCovariance, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) != nil {
+		return args.Get(0).(*PriceCovariance), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepository) BatchInsertOrderFlow(ctx context.Context, sourceFileID int, flows []*OrderFlow) error {
+	args := m.Called(ctx, sourceFileID, flows)
+	return args.Error(0)
+}
+
+func (m *MockRepository) ListOrderFlow(ctx context.Context, filter OrderFlowFilter) ([]*OrderFlow, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) != nil {
+		return args.Get(0).([]*OrderFlow), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepository) ListWindowTrades(ctx context.Context, filter TradeFilter) ([]*Trade, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) != nil {
+		return args.Get(0).([]*Trade), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepository) BatchInsertAuctions(ctx context.Context, sourceFileID int, auctions []*Auction) error {
+	args := m.Called(ctx, sourceFileID, auctions)
+	return args.Error(0)
+}
+
+func (m *MockRepository) ListAuctions(ctx context.Context, filter AuctionFilter) ([]*Auction, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) != nil {
+		return args.Get(0).([]*Auction), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepository) CreateUpload(ctx context.Context, upload *Upload) error {
+	args := m.Called(ctx, upload)
+	return args.Error(0)
+}
+
+func (m *MockRepository) FindUploadByChecksum(ctx context.Context, checksum string) (*Upload, error) {
+	args := m.Called(ctx, checksum)
+	if args.Get(0) != nil {
+		return args.Get(0).(*Upload), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepository) FinishUpload(ctx context.Context, upload *Upload) error {
+	args := m.Called(ctx, upload)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetUpload(ctx context.Context, id int) (*Upload, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) != nil {
+		return args.Get(0).(*Upload), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepository) BatchInsertSequences(ctx context.Context, uploadID int, sequences []*TradeSequence) error {
+	args := m.Called(ctx, uploadID, sequences)
+	return args.Error(0)
+}
+
+func (m *MockRepository) ListSequences(ctx context.Context, filter SequenceFilter) ([]*TradeSequence, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) != nil {
+		return args.Get(0).([]*TradeSequence), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepository) ListCloses(ctx context.Context, date time.Time) (map[string]decimal.Decimal, error) {
+	args := m.Called(ctx, date)
+	if args.Get(0) != nil {
+		return args.Get(0).(map[string]decimal.Decimal), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepository) BatchInsertQualityIssues(ctx context.Context, uploadID int, issues []*QualityIssue) error {
+	args := m.Called(ctx, uploadID, issues)
+	return args.Error(0)
+}
+
+func (m *MockRepository) ListQualityIssues(ctx context.Context, uploadID int) ([]*QualityIssue, error) {
+	args := m.Called(ctx, uploadID)
+	if args.Get(0) != nil {
+		return args.Get(0).([]*QualityIssue), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepository) DeleteUpload(ctx context.Context, audit *UploadAudit) error {
+	args := m.Called(ctx, audit)
+	return args.Error(0)
+}
+
+func (m *MockRepository) ListAnomalyBaselines(ctx context.Context, date time.Time) (map[string]*AnomalyBaseline, error) {
+	args := m.Called(ctx, date)
+	if args.Get(0) != nil {
+		return args.Get(0).(map[string]*AnomalyBaseline), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepository) ReplayUpload(ctx context.Context, audit *UploadAudit, replay *Upload) error {
+	args := m.Called(ctx, audit, replay)
+	return args.Error(0)
+}
+
+func (m *MockRepository) PurgeUpload(ctx context.Context, uploadID int) error {
+	args := m.Called(ctx, uploadID)
+	return args.Error(0)
+}
+
+func (m *MockRepository) DailyMetrics(ctx context.Context, filter MetricFilter) ([]*DailyMetric, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) != nil {
+		return args.Get(0).([]*DailyMetric), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockRepository) UpsertCorporateActions(ctx context.Context, actions []*CorporateAction) error {
+	args := m.Called(ctx, actions)
+	return args.Error(0)
+}
+
+func (m *MockRepository) ListCorporateActions(ctx context.Context, ticker string) ([]*CorporateAction, error) {
+	args := m.Called(ctx, ticker)
+	if args.Get(0) != nil {
+		return args.Get(0).([]*CorporateAction), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+type MockBlobStore struct {
+	mock.Mock
+}
+
+func (m *MockBlobStore) Put(ctx context.Context, key string, reader io.Reader, size int64) error {
+	args := m.Called(ctx, key, reader, size)
+	return args.Error(0)
+}
+
+func (m *MockBlobStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	args := m.Called(ctx, key)
+	if args.Get(0) != nil {
+		return args.Get(0).(io.ReadCloser), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
 func TestServiceMetrics(t *testing.T) {
 	cases := []struct {
 		name     string
-		ticker   string
-		date     time.Time
+		filter   MetricFilter
+		mockFunc func(m *MockRepository)
+		want     *Metric
+		wantErr  error
+	}{
+		{
+			name:   "success",
+			filter: MetricFilter{Ticker: "AAPL", Date: time.Date(2024, 6, 20, 0, 0, 0, 0, time.UTC), Session: SessionRegular},
+			mockFunc: func(m *MockRepository) {
+				m.On("GetMetrics", mock.Anything, MetricFilter{Ticker: "AAPL", Date: time.Date(2024, 6, 20, 0, 0, 0, 0, time.UTC), Session: SessionRegular}).
+					Return(&Metric{
+						Ticker:         "AAPL",
+						MaxRangeValue:  decimal.NewFromInt(100),
+						MaxDailyVolume: 50,
+					}, nil).Once()
+			},
+			want: &Metric{
+				Ticker:         "AAPL",
+				MaxRangeValue:  decimal.NewFromInt(100),
+				MaxDailyVolume: 50,
+			},
+			wantErr: nil,
+		},
+		{
+			name:   "success consolidated from fractional ticker",
+			filter: MetricFilter{Ticker: "PETR4F", Session: SessionRegular, Consolidated: true},
+			mockFunc: func(m *MockRepository) {
+				m.On("GetMetrics", mock.Anything, MetricFilter{Ticker: "PETR4", Session: SessionRegular, Consolidated: true}).
+					Return(&Metric{
+						Ticker:         "PETR4",
+						MaxRangeValue:  decimal.NewFromInt(39),
+						MaxDailyVolume: 320,
+					}, nil).Once()
+			},
+			want: &Metric{
+				Ticker:         "PETR4",
+				MaxRangeValue:  decimal.NewFromInt(39),
+				MaxDailyVolume: 320,
+			},
+		},
+		{
+			name:   "success adjusted by corporate actions",
+			filter: MetricFilter{Ticker: "PETR4", Session: SessionRegular, Adjusted: true},
+			mockFunc: func(m *MockRepository) {
+				m.On("DailyMetrics", mock.Anything, MetricFilter{Ticker: "PETR4", Session: SessionRegular, Adjusted: true}).
+					Return([]*DailyMetric{
+						{Ticker: "PETR4", TradeDate: time.Date(2024, 5, 31, 0, 0, 0, 0, time.UTC), MaxRangeValue: decimal.NewFromInt(80), MaxDailyVolume: 1000},
+						{Ticker: "PETR4", TradeDate: time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC), MaxRangeValue: decimal.NewFromInt(39), MaxDailyVolume: 1500},
+					}, nil).Once()
+				m.On("ListCorporateActions", mock.Anything, "PETR4").
+					Return([]*CorporateAction{
+						{Ticker: "PETR4", ExDate: time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC), Type: ActionSplit, Factor: decimal.NewFromInt(2)},
+					}, nil).Once()
+			},
+			want: &Metric{
+				Ticker:         "PETR4",
+				MaxRangeValue:  decimal.New(400000, -4),
+				MaxDailyVolume: 2000,
+			},
+		},
+		{
+			name:   "success with changes",
+			filter: MetricFilter{Ticker: "AAPL", Date: time.Date(2024, 6, 20, 0, 0, 0, 0, time.UTC), Session: SessionRegular, IncludeChanges: true},
+			mockFunc: func(m *MockRepository) {
+				m.On("GetMetrics", mock.Anything, MetricFilter{Ticker: "AAPL", Date: time.Date(2024, 6, 20, 0, 0, 0, 0, time.UTC), Session: SessionRegular, IncludeChanges: true}).
+					Return(&Metric{
+						Ticker:         "AAPL",
+						MaxRangeValue:  decimal.NewFromInt(100),
+						MaxDailyVolume: 50,
+					}, nil).Once()
+				m.On("DailyMetrics", mock.Anything, MetricFilter{Ticker: "AAPL", Session: SessionRegular, IncludeChanges: true}).
+					Return([]*DailyMetric{
+						{Ticker: "AAPL", TradeDate: time.Date(2024, 6, 20, 0, 0, 0, 0, time.UTC), ClosePrice: decimal.NewFromInt(100), MaxDailyVolume: 40},
+						{Ticker: "AAPL", TradeDate: time.Date(2024, 6, 21, 0, 0, 0, 0, time.UTC), ClosePrice: decimal.NewFromInt(95), MaxDailyVolume: 50},
+					}, nil).Once()
+			},
+			want: &Metric{
+				Ticker:         "AAPL",
+				MaxRangeValue:  decimal.NewFromInt(100),
+				MaxDailyVolume: 50,
+				Changes: &MetricChanges{
+					TradeDate:       time.Date(2024, 6, 21, 0, 0, 0, 0, time.UTC),
+					ClosePrice:      decimal.NewFromInt(95),
+					PreviousDate:    time.Date(2024, 6, 20, 0, 0, 0, 0, time.UTC),
+					PreviousClose:   decimal.NewFromInt(100),
+					Change:          decimal.NewFromInt(-5),
+					ChangePercent:   decimal.New(-500, -2),
+					Volume:          50,
+					PreviousVolume:  40,
+					VolumeChange:    10,
+					AverageVolume:   decimal.New(4000, -2),
+					VolumeVsAverage: decimal.New(125, -2),
+				},
+			},
+		},
+		{
+			name:   "failed because error in changes history",
+			filter: MetricFilter{Ticker: "AAPL", Session: SessionRegular, IncludeChanges: true},
+			mockFunc: func(m *MockRepository) {
+				m.On("GetMetrics", mock.Anything, MetricFilter{Ticker: "AAPL", Session: SessionRegular, IncludeChanges: true}).
+					Return(&Metric{Ticker: "AAPL"}, nil).Once()
+				m.On("DailyMetrics", mock.Anything, MetricFilter{Ticker: "AAPL", Session: SessionRegular, IncludeChanges: true}).
+					Return(nil, errors.New("repository error")).Once()
+			},
+			want:    nil,
+			wantErr: errors.New("repository error"),
+		},
+		{
+			name:   "failed because adjusted metrics not found",
+			filter: MetricFilter{Ticker: "PETR4", Session: SessionRegular, Adjusted: true},
+			mockFunc: func(m *MockRepository) {
+				m.On("DailyMetrics", mock.Anything, MetricFilter{Ticker: "PETR4", Session: SessionRegular, Adjusted: true}).
+					Return([]*DailyMetric{}, nil).Once()
+			},
+			want:    nil,
+			wantErr: sql.ErrNoRows,
+		},
+		{
+			name:   "failed because repository error",
+			filter: MetricFilter{Ticker: "AAPL", Date: time.Date(2024, 6, 20, 0, 0, 0, 0, time.UTC), Session: SessionRegular},
+			mockFunc: func(m *MockRepository) {
+				m.On("GetMetrics", mock.Anything, MetricFilter{Ticker: "AAPL", Date: time.Date(2024, 6, 20, 0, 0, 0, 0, time.UTC), Session: SessionRegular}).
+					Return(nil, errors.New("repository error")).Once()
+			},
+			want:    nil,
+			wantErr: errors.New("repository error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := new(MockRepository)
+			tc.mockFunc(mockRepo)
+
+			cfg := &config.Config{}
+			svc := NewService(mockRepo, cfg, nil)
+
+			got, err := svc.Metrics(context.Background(), tc.filter)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.want, got)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestServiceTrades(t *testing.T) {
+	cases := []struct {
+		name     string
+		filter   TradeFilter
+		mockFunc func(m *MockRepository)
+		want     *TradePage
+		wantErr  error
+	}{
+		{
+			name:   "success with next cursor",
+			filter: TradeFilter{Ticker: "PETR4", Limit: 2},
+			mockFunc: func(m *MockRepository) {
+				m.On("ListTrades", mock.Anything, TradeFilter{Ticker: "PETR4", Limit: 3}).
+					Return([]*Trade{{ID: 1}, {ID: 4}, {ID: 7}}, nil).Once()
+			},
+			want: &TradePage{
+				Trades:     []*Trade{{ID: 1}, {ID: 4}},
+				NextCursor: 4,
+			},
+		},
+		{
+			name:   "success on last page with default limit",
+			filter: TradeFilter{Ticker: "PETR4", Cursor: 4},
+			mockFunc: func(m *MockRepository) {
+				m.On("ListTrades", mock.Anything, TradeFilter{Ticker: "PETR4", Cursor: 4, Limit: DefaultTradePageSize + 1}).
+					Return([]*Trade{{ID: 7}}, nil).Once()
+			},
+			want: &TradePage{
+				Trades: []*Trade{{ID: 7}},
+			},
+		},
+		{
+			name:   "success with limit above max",
+			filter: TradeFilter{Limit: MaxTradePageSize * 2},
+			mockFunc: func(m *MockRepository) {
+				m.On("ListTrades", mock.Anything, TradeFilter{Limit: MaxTradePageSize + 1}).
+					Return([]*Trade{}, nil).Once()
+			},
+			want: &TradePage{
+				Trades: []*Trade{},
+			},
+		},
+		{
+			name:     "failed because not a trading day",
+			filter:   TradeFilter{Ticker: "PETR4", Date: time.Date(2024, 6, 29, 0, 0, 0, 0, time.UTC)},
+			mockFunc: func(m *MockRepository) {},
+			want:     nil,
+			wantErr:  calendar.ErrNotTradingDay,
+		},
+		{
+			name:   "failed because repository error",
+			filter: TradeFilter{Limit: 2},
+			mockFunc: func(m *MockRepository) {
+				m.On("ListTrades", mock.Anything, TradeFilter{Limit: 3}).
+					Return(nil, errors.New("repository error")).Once()
+			},
+			want:    nil,
+			wantErr: errors.New("repository error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := new(MockRepository)
+			tc.mockFunc(mockRepo)
+
+			svc := NewService(mockRepo, &config.Config{}, nil)
+
+			got, err := svc.Trades(context.Background(), tc.filter)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.want, got)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_BatchInsert(t *testing.T) {
+	referenceDate := time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name       string
+		csvContent string
+		mockFunc   func(m *MockRepository)
+		wantErr    error
+	}{
+		{
+			name: "success",
+			csvContent: `DataReferencia;CodigoInstrumento;AcaoAtualizacao;PrecoNegocio;QuantidadeNegociada;HoraFechamento;CodigoIdentificadorNegocio;TipoSessaoPregao;DataNegocio;CodigoParticipanteComprador;CodigoParticipanteVendedor
+2024-06-28;TF583R;0;10,000;10000;041646257;10;1;2024-06-28;100;100
+2024-06-28;DI1F25;0;10,600;6;090000017;10;1;2024-06-28;3;23
+2024-06-28;DI1F25;0;10,601;9;090000017;20;1;2024-06-28;3;23
+2024-06-28;DI1N24;0;10,398;1;090000017;10;1;2024-06-28;114;114
+`,
+			mockFunc: func(m *MockRepository) {
+				m.On("BatchInsertTrade", mock.Anything, 1, []*Trade{
+					{
+						InstrumentCode: "TF583R",
+						TradePrice:     decimal.NewFromBigInt(big.NewInt(10000), -3),
+						TradeQuantity:  10000,
+						CloseTime:      "041646257",
+						TradeDate:      time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC),
+						TradedAt:       time.Date(2024, 06, 28, 4, 16, 46, 257000000, time.UTC),
+						BuyerCode:      "100",
+						SellerCode:     "100",
+						SessionType:    1,
+						TradeID:        10,
+					},
+					{
+						InstrumentCode: "DI1F25",
+						TradePrice:     decimal.NewFromBigInt(big.NewInt(10600), -3),
+						TradeQuantity:  6,
+						CloseTime:      "090000017",
+						TradeDate:      time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC),
+						TradedAt:       time.Date(2024, 06, 28, 9, 0, 0, 17000000, time.UTC),
+						BuyerCode:      "3",
+						SellerCode:     "23",
+						SessionType:    1,
+						TradeID:        10,
+					},
+				}).Return(nil).Once()
+
+				m.On("BatchInsertTrade", mock.Anything, 1, []*Trade{
+					{
+						InstrumentCode: "DI1F25",
+						TradePrice:     decimal.NewFromBigInt(big.NewInt(10601), -3),
+						TradeQuantity:  9,
+						CloseTime:      "090000017",
+						TradeDate:      time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC),
+						TradedAt:       time.Date(2024, 06, 28, 9, 0, 0, 17000000, time.UTC),
+						BuyerCode:      "3",
+						SellerCode:     "23",
+						SessionType:    1,
+						TradeID:        20,
+					},
+					{
+						InstrumentCode: "DI1N24",
+						TradePrice:     decimal.NewFromBigInt(big.NewInt(10398), -3),
+						TradeQuantity:  1,
+						CloseTime:      "090000017",
+						TradeDate:      time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC),
+						TradedAt:       time.Date(2024, 06, 28, 9, 0, 0, 17000000, time.UTC),
+						BuyerCode:      "114",
+						SellerCode:     "114",
+						SessionType:    1,
+						TradeID:        10,
+					},
+				}).Return(nil).Once()
+
+				// the metrics are inserted in batches of BatchSize in key order
+				m.On("BatchInsertMetrics", mock.Anything, 1, map[string]*Metric{
+					"DI1F25|2024-06-28|1": {
+						Ticker:          "DI1F25",
+						MaxRangeValue:   decimal.NewFromBigInt(big.NewInt(10601), -3),
+						MaxDailyVolume:  15,
+						TradeDate:       time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC),
+						SessionType:     1,
+						ClosePrice:      decimal.NewFromBigInt(big.NewInt(10601), -3),
+						CloseTime:       "090000017",
+						FinancialVolume: decimal.New(159009, -3),
+					},
+					"DI1N24|2024-06-28|1": {
+						Ticker:          "DI1N24",
+						MaxRangeValue:   decimal.NewFromBigInt(big.NewInt(10398), -3),
+						MaxDailyVolume:  1,
+						TradeDate:       time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC),
+						SessionType:     1,
+						ClosePrice:      decimal.NewFromBigInt(big.NewInt(10398), -3),
+						CloseTime:       "090000017",
+						FinancialVolume: decimal.New(10398, -3),
+					},
+				}).Return(nil).Once()
+
+				m.On("BatchInsertMetrics", mock.Anything, 1, map[string]*Metric{
+					"TF583R|2024-06-28|1": {
+						Ticker:          "TF583R",
+						MaxRangeValue:   decimal.NewFromBigInt(big.NewInt(10000), -3),
+						MaxDailyVolume:  10000,
+						TradeDate:       time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC),
+						SessionType:     1,
+						ClosePrice:      decimal.NewFromBigInt(big.NewInt(10000), -3),
+						CloseTime:       "041646257",
+						FinancialVolume: decimal.New(100000000, -3),
+					},
+				}).Return(nil).Once()
+
+				m.On("UpsertInstruments", mock.Anything, []*Instrument{
+					{Ticker: "DI1F25", Type: instrument.TypeFuture},
+					{Ticker: "DI1N24", Type: instrument.TypeFuture},
+					{Ticker: "TF583R", Type: instrument.TypeOther},
+				}).Return(nil).Once()
+
+				m.On("BatchInsertSequences", mock.Anything, 1, []*TradeSequence{
+					{Ticker: "DI1F25", TradeDate: time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC), Trades: 2, FirstTradeID: 10, LastTradeID: 20, Step: 10, Complete: true},
+					{Ticker: "DI1N24", TradeDate: time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC), Trades: 1, FirstTradeID: 10, LastTradeID: 10, Step: 10, Complete: true},
+				}).Return(nil).Once()
+				m.On("BatchInsertSequences", mock.Anything, 1, []*TradeSequence{
+					{Ticker: "TF583R", TradeDate: time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC), Trades: 1, FirstTradeID: 10, LastTradeID: 10, Step: 10, Complete: true},
+				}).Return(nil).Once()
+
+				m.On("FinishUpload", mock.Anything, &Upload{ID: 1, Status: UploadCompleted, ReferenceDate: &referenceDate, Trades: 4}).Return(nil).Once()
+
+				m.On("BatchInsertOrderFlow", mock.Anything, 1, []*OrderFlow{
+					{Ticker: "DI1F25", TradeDate: time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC), Minute: 540, BuyVolume: 9, UnclassifiedVolume: 6, BuyTrades: 1},
+					{Ticker: "DI1N24", TradeDate: time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC), Minute: 540, UnclassifiedVolume: 1},
+				}).Return(nil).Once()
+				m.On("BatchInsertOrderFlow", mock.Anything, 1, []*OrderFlow{
+					{Ticker: "TF583R", TradeDate: time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC), Minute: 255, UnclassifiedVolume: 10000},
+				}).Return(nil).Once()
+			},
+			wantErr: nil,
+		},
+		{
+			name: "failed because error in batch insert metrics",
+			csvContent: `DataReferencia;CodigoInstrumento;AcaoAtualizacao;PrecoNegocio;QuantidadeNegociada;HoraFechamento;CodigoIdentificadorNegocio;TipoSessaoPregao;DataNegocio;CodigoParticipanteComprador;CodigoParticipanteVendedor
+2024-06-28;TF583R;0;10,000;10000;041646257;10;1;2024-06-28;100;100
+2024-06-28;DI1F25;0;10,600;6;090000017;10;1;2024-06-28;3;23
+2024-06-28;DI1F25;0;10,600;9;090000017;20;1;2024-06-28;3;23
+2024-06-28;DI1N24;0;10,398;1;090000017;10;1;2024-06-28;114;114
+`,
+			mockFunc: func(m *MockRepository) {
+				m.On("BatchInsertTrade", mock.Anything, 1, []*Trade{
+					{
+						InstrumentCode: "TF583R",
+						TradePrice:     decimal.NewFromBigInt(big.NewInt(10000), -3),
+						TradeQuantity:  10000,
+						CloseTime:      "041646257",
+						TradeDate:      time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC),
+						TradedAt:       time.Date(2024, 06, 28, 4, 16, 46, 257000000, time.UTC),
+						BuyerCode:      "100",
+						SellerCode:     "100",
+						SessionType:    1,
+						TradeID:        10,
+					},
+					{
+						InstrumentCode: "DI1F25",
+						TradePrice:     decimal.NewFromBigInt(big.NewInt(10600), -3),
+						TradeQuantity:  6,
+						CloseTime:      "090000017",
+						TradeDate:      time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC),
+						TradedAt:       time.Date(2024, 06, 28, 9, 0, 0, 17000000, time.UTC),
+						BuyerCode:      "3",
+						SellerCode:     "23",
+						SessionType:    1,
+						TradeID:        10,
+					},
+				}).Return(nil).Once()
+
+				m.On("BatchInsertTrade", mock.Anything, 1, []*Trade{
+					{
+						InstrumentCode: "DI1F25",
+						TradePrice:     decimal.NewFromBigInt(big.NewInt(10600), -3),
+						TradeQuantity:  9,
+						CloseTime:      "090000017",
+						TradeDate:      time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC),
+						TradedAt:       time.Date(2024, 06, 28, 9, 0, 0, 17000000, time.UTC),
+						BuyerCode:      "3",
+						SellerCode:     "23",
+						SessionType:    1,
+						TradeID:        20,
+					},
+					{
+						InstrumentCode: "DI1N24",
+						TradePrice:     decimal.NewFromBigInt(big.NewInt(10398), -3),
+						TradeQuantity:  1,
+						CloseTime:      "090000017",
+						TradeDate:      time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC),
+						TradedAt:       time.Date(2024, 06, 28, 9, 0, 0, 17000000, time.UTC),
+						BuyerCode:      "114",
+						SellerCode:     "114",
+						SessionType:    1,
+						TradeID:        10,
+					},
+				}).Return(nil).Once()
+
+				m.On("BatchInsertMetrics", mock.Anything, 1, map[string]*Metric{
+					"DI1F25|2024-06-28|1": {
+						Ticker:          "DI1F25",
+						MaxRangeValue:   decimal.NewFromBigInt(big.NewInt(10600), -3),
+						MaxDailyVolume:  15,
+						TradeDate:       time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC),
+						SessionType:     1,
+						ClosePrice:      decimal.NewFromBigInt(big.NewInt(10600), -3),
+						CloseTime:       "090000017",
+						FinancialVolume: decimal.New(159000, -3),
+					},
+					"DI1N24|2024-06-28|1": {
+						Ticker:          "DI1N24",
+						MaxRangeValue:   decimal.NewFromBigInt(big.NewInt(10398), -3),
+						MaxDailyVolume:  1,
+						TradeDate:       time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC),
+						SessionType:     1,
+						ClosePrice:      decimal.NewFromBigInt(big.NewInt(10398), -3),
+						CloseTime:       "090000017",
+						FinancialVolume: decimal.New(10398, -3),
+					},
+				}).Return(errors.New("mock-error")).Once()
+
+				m.On("PurgeUpload", mock.Anything, 1).Return(nil).Once()
+				m.On("FinishUpload", mock.Anything, &Upload{ID: 1, Status: UploadFailed, ReferenceDate: &referenceDate, Trades: 4}).Return(nil).Once()
+			},
+			wantErr: errors.New("mock-error"),
+		},
+		{
+			name: "failed because error in batch insert trade",
+			csvContent: `DataReferencia;CodigoInstrumento;AcaoAtualizacao;PrecoNegocio;QuantidadeNegociada;HoraFechamento;CodigoIdentificadorNegocio;TipoSessaoPregao;DataNegocio;CodigoParticipanteComprador;CodigoParticipanteVendedor
+2024-06-28;TF583R;0;10,000;10000;041646257;10;1;2024-06-28;100;100
+2024-06-28;DI1F25;0;10,600;6;090000017;10;1;2024-06-28;3;23
+2024-06-28;DI1F25;0;10,600;9;090000017;20;1;2024-06-28;3;23
+2024-06-28;DI1N24;0;10,398;1;090000017;10;1;2024-06-28;114;114
+`,
+			mockFunc: func(m *MockRepository) {
+				m.On("BatchInsertTrade", mock.Anything, 1, []*Trade{
+					{
+						InstrumentCode: "TF583R",
+						TradePrice:     decimal.NewFromBigInt(big.NewInt(10000), -3),
+						TradeQuantity:  10000,
+						CloseTime:      "041646257",
+						TradeDate:      time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC),
+						TradedAt:       time.Date(2024, 06, 28, 4, 16, 46, 257000000, time.UTC),
+						BuyerCode:      "100",
+						SellerCode:     "100",
+						SessionType:    1,
+						TradeID:        10,
+					},
+					{
+						InstrumentCode: "DI1F25",
+						TradePrice:     decimal.NewFromBigInt(big.NewInt(10600), -3),
+						TradeQuantity:  6,
+						CloseTime:      "090000017",
+						TradeDate:      time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC),
+						TradedAt:       time.Date(2024, 06, 28, 9, 0, 0, 17000000, time.UTC),
+						BuyerCode:      "3",
+						SellerCode:     "23",
+						SessionType:    1,
+						TradeID:        10,
+					},
+				}).Return(errors.New("mock-error")).Once()
+
+				m.On("PurgeUpload", mock.Anything, 1).Return(nil).Once()
+				m.On("FinishUpload", mock.Anything, &Upload{ID: 1, Status: UploadFailed}).Return(nil).Once()
+			},
+			wantErr: errors.New("mock-error"),
+		},
+		{
+			name: "failed because error in parse trade date",
+			csvContent: `DataReferencia;CodigoInstrumento;AcaoAtualizacao;PrecoNegocio;QuantidadeNegociada;HoraFechamento;CodigoIdentificadorNegocio;TipoSessaoPregao;DataNegocio;CodigoParticipanteComprador;CodigoParticipanteVendedor
+2024-06-28;TF583R;0;10,000;10000;041646257;10;1;2024-0-28;100;100
+2024-06-28;DI1F25;0;10,600;6;090000017;10;1;2024-06-28;3;23
+2024-06-28;DI1F25;0;10,600;9;090000017;20;1;2024-06-28;3;23
+2024-06-28;DI1N24;0;10,398;1;090000017;10;1;2024-06-28;114;114
+`,
+			mockFunc: func(m *MockRepository) {
+				m.On("PurgeUpload", mock.Anything, 1).Return(nil).Once()
+				m.On("FinishUpload", mock.Anything, &Upload{ID: 1, Status: UploadFailed}).Return(nil).Once()
+			},
+			wantErr: errors.New("failed to parse trade date: parsing time \"2024-0-28\" as \"2006-01-02\": cannot parse \"0-28\" as \"01\""),
+		},
+		{
+			name: "failed because error in parse close time",
+			csvContent: `DataReferencia;CodigoInstrumento;AcaoAtualizacao;PrecoNegocio;QuantidadeNegociada;HoraFechamento;CodigoIdentificadorNegocio;TipoSessaoPregao;DataNegocio;CodigoParticipanteComprador;CodigoParticipanteVendedor
+2024-06-28;TF583R;0;10,000;10000;04:16:46;10;1;2024-06-28;100;100
+`,
+			mockFunc: func(m *MockRepository) {
+				m.On("PurgeUpload", mock.Anything, 1).Return(nil).Once()
+				m.On("FinishUpload", mock.Anything, &Upload{ID: 1, Status: UploadFailed}).Return(nil).Once()
+			},
+			wantErr: errors.New("failed to parse close time: unexpected close time: 04:16:46"),
+		},
+		{
+			name: "failed because error in number of columns",
+			csvContent: `DataReferencia;CodigoInstrumento;AcaoAtualizacao;PrecoNegocio;QuantidadeNegociada
+2024-06-28;TF583R;0;10,000;10000
+`,
+			mockFunc: func(m *MockRepository) {
+				m.On("PurgeUpload", mock.Anything, 1).Return(nil).Once()
+				m.On("FinishUpload", mock.Anything, &Upload{ID: 1, Status: UploadFailed}).Return(nil).Once()
+			},
+			wantErr: errors.New("unexpected number of columns: 5"),
+		},
+		{
+			name: "failed because error in parse trade quantity",
+			csvContent: `DataReferencia;CodigoInstrumento;AcaoAtualizacao;PrecoNegocio;QuantidadeNegociada;HoraFechamento;CodigoIdentificadorNegocio;TipoSessaoPregao;DataNegocio;CodigoParticipanteComprador;CodigoParticipanteVendedor
+2024-06-28;TF583R;0;i,000;10000;041646257;10;1;2024-0-28;100;100
+2024-06-28;DI1F25;0;10,600;6;090000017;10;1;2024-06-28;3;23
+2024-06-28;DI1F25;0;10,600;9;090000017;20;1;2024-06-28;3;23
+2024-06-28;DI1N24;0;10,398;1;090000017;10;1;2024-06-28;114;114
+`,
+			mockFunc: func(m *MockRepository) {
+				m.On("PurgeUpload", mock.Anything, 1).Return(nil).Once()
+				m.On("FinishUpload", mock.Anything, &Upload{ID: 1, Status: UploadFailed}).Return(nil).Once()
+			},
+			wantErr: errors.New("failed to parse trade price: can't convert i.000 to decimal"),
+		},
+		{
+			name: "failed because error in parse trade price",
+			csvContent: `DataReferencia;CodigoInstrumento;AcaoAtualizacao;PrecoNegocio;QuantidadeNegociada;HoraFechamento;CodigoIdentificadorNegocio;TipoSessaoPregao;DataNegocio;CodigoParticipanteComprador;CodigoParticipanteVendedor
+2024-06-28;TF583R;0;1j,000;10000;041646257;10;1;2024-06-28;100;100
+2024-06-28;DI1F25;0;10,600;6;090000017;10;1;2024-06-28;3;23
+2024-06-28;DI1F25;0;10,600;9;090000017;20;1;2024-06-28;3;23
+2024-06-28;DI1N24;0;10,398;1;090000017;10;1;2024-06-28;114;114
+`,
+			mockFunc: func(m *MockRepository) {
+				m.On("PurgeUpload", mock.Anything, 1).Return(nil).Once()
+				m.On("FinishUpload", mock.Anything, &Upload{ID: 1, Status: UploadFailed}).Return(nil).Once()
+			},
+			wantErr: errors.New("failed to parse trade price: can't convert 1j.000 to decimal"),
+		},
+		{
+			name: "failed because error in parse trade id",
+			csvContent: `DataReferencia;CodigoInstrumento;AcaoAtualizacao;PrecoNegocio;QuantidadeNegociada;HoraFechamento;CodigoIdentificadorNegocio;TipoSessaoPregao;DataNegocio;CodigoParticipanteComprador;CodigoParticipanteVendedor
+2024-06-28;TF583R;0;10,000;10000;041646257;1O;1;2024-06-28;100;100
+`,
+			mockFunc: func(m *MockRepository) {
+				m.On("PurgeUpload", mock.Anything, 1).Return(nil).Once()
+				m.On("FinishUpload", mock.Anything, &Upload{ID: 1, Status: UploadFailed}).Return(nil).Once()
+			},
+			wantErr: errors.New("failed to parse trade id: strconv.ParseInt: parsing \"1O\": invalid syntax"),
+		},
+		{
+			name: "failed because error in parse reference date",
+			csvContent: `DataReferencia;CodigoInstrumento;AcaoAtualizacao;PrecoNegocio;QuantidadeNegociada;HoraFechamento;CodigoIdentificadorNegocio;TipoSessaoPregao;DataNegocio;CodigoParticipanteComprador;CodigoParticipanteVendedor
+28/06/2024;TF583R;0;10,000;10000;041646257;10;1;2024-06-28;100;100
+`,
+			mockFunc: func(m *MockRepository) {
+				m.On("PurgeUpload", mock.Anything, 1).Return(nil).Once()
+				m.On("FinishUpload", mock.Anything, &Upload{ID: 1, Status: UploadFailed}).Return(nil).Once()
+			},
+			wantErr: errors.New("failed to parse reference date: parsing time \"28/06/2024\" as \"2006-01-02\": cannot parse \"28/06/2024\" as \"2006\""),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &config.Config{
+				App: config.App{
+					Workers:   1,
+					BatchSize: 2,
+				},
+			}
+
+			mockRepo := new(MockRepository)
+
+			tc.mockFunc(mockRepo)
+
+			svc := NewService(mockRepo, cfg, nil)
+
+			reader := bytes.NewReader([]byte(tc.csvContent))
+			err := svc.BatchInsert(context.Background(), &Upload{ID: 1}, reader)
+			assert.Equal(t, tc.wantErr, err)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_BatchInsertAnomalies(t *testing.T) {
+	csvContent := `DataReferencia;CodigoInstrumento;AcaoAtualizacao;PrecoNegocio;QuantidadeNegociada;HoraFechamento;CodigoIdentificadorNegocio;TipoSessaoPregao;DataNegocio;CodigoParticipanteComprador;CodigoParticipanteVendedor
+2024-06-28;PETR4;0;38,00;100;100000000;10;1;2024-06-28;3;23
+2024-06-28;PETR4;0;38,20;100;100100000;20;1;2024-06-28;3;23
+2024-06-28;PETR4;0;382,00;100;100200000;30;1;2024-06-28;3;23
+2024-06-28;PETR4;0;38,10;100;100300000;40;1;2024-06-28;3;23
+`
+	date := time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC)
+	previous := time.Date(2024, 06, 27, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name       string
+		csvContent string
+		mockFunc   func(m *MockRepository)
+		wantErr    error
+	}{
+		{
+			name: "success",
+			mockFunc: func(m *MockRepository) {
+				m.On("BatchInsertTrade", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+				m.On("ListAnomalyBaselines", mock.Anything, previous).Return(map[string]*AnomalyBaseline{}, nil).Once()
+				m.On("BatchInsertMetrics", mock.Anything, mock.Anything, map[string]*Metric{
+					"PETR4|2024-06-28|1": {
+						Ticker:         "PETR4",
+						MaxRangeValue:  decimal.NewFromBigInt(big.NewInt(3820), -2),
+						MaxDailyVolume: 400,
+						TradeDate:      date,
+						SessionType:    SessionRegular,
+						ClosePrice:     decimal.NewFromBigInt(big.NewInt(3810), -2),
+						CloseTime:      "100300000",
+						// the price outlier is kept out of the max range value but not out of the financial volume
+						FinancialVolume: decimal.New(4963000, -2),
+					},
+				}).Return(nil).Once()
+				m.On("UpsertInstruments", mock.Anything, mock.Anything).Return(nil).Once()
+				m.On("BatchInsertAnomalies", mock.Anything, 1, []*Anomaly{
+					{
+						InstrumentCode: "PETR4",
+						TradePrice:     decimal.NewFromBigInt(big.NewInt(38200), -2),
+						TradeQuantity:  100,
+						CloseTime:      "100200000",
+						TradeDate:      date,
+						Reason:         AnomalyPriceDeviation,
+						ReferenceValue: decimal.NewFromBigInt(big.NewInt(381000), -4),
+						TradeID:        30,
+					},
+				}).Return(nil).Once()
+				m.On("BatchInsertSequences", mock.Anything, 1, mock.Anything).Return(nil).Once()
+				m.On("BatchInsertOrderFlow", mock.Anything, 1, mock.Anything).Return(nil).Once()
+			},
+		},
+		{
+			name: "success with the first trade checked against the stored baseline",
+			csvContent: `DataReferencia;CodigoInstrumento;AcaoAtualizacao;PrecoNegocio;QuantidadeNegociada;HoraFechamento;CodigoIdentificadorNegocio;TipoSessaoPregao;DataNegocio;CodigoParticipanteComprador;CodigoParticipanteVendedor
+2024-06-28;PETR4;0;382,00;100;100000000;10;1;2024-06-28;3;23
+2024-06-28;PETR4;0;38,00;100;100100000;20;1;2024-06-28;3;23
+2024-06-28;PETR4;0;38,20;100;100200000;30;1;2024-06-28;3;23
+`,
+			mockFunc: func(m *MockRepository) {
+				m.On("BatchInsertTrade", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+				m.On("ListAnomalyBaselines", mock.Anything, previous).Return(map[string]*AnomalyBaseline{
+					"PETR4": {Ticker: "PETR4", ClosePrice: decimal.NewFromBigInt(big.NewInt(3810), -2), AverageQuantity: decimal.NewFromInt(150)},
+				}, nil).Once()
+				m.On("BatchInsertMetrics", mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
+				m.On("UpsertInstruments", mock.Anything, mock.Anything).Return(nil).Once()
+				m.On("BatchInsertAnomalies", mock.Anything, 1, []*Anomaly{
+					{
+						InstrumentCode: "PETR4",
+						TradePrice:     decimal.NewFromBigInt(big.NewInt(38200), -2),
+						TradeQuantity:  100,
+						CloseTime:      "100000000",
+						TradeDate:      date,
+						Reason:         AnomalyPriceDeviation,
+						ReferenceValue: decimal.NewFromBigInt(big.NewInt(381000), -4),
+						TradeID:        10,
+					},
+				}).Return(nil).Once()
+				m.On("BatchInsertSequences", mock.Anything, 1, mock.Anything).Return(nil).Once()
+				m.On("BatchInsertOrderFlow", mock.Anything, 1, mock.Anything).Return(nil).Once()
+			},
+		},
+		{
+			name: "failed because error in list anomaly baselines",
+			mockFunc: func(m *MockRepository) {
+				m.On("ListAnomalyBaselines", mock.Anything, previous).Return(nil, errors.New("mock-error")).Once()
+				m.On("PurgeUpload", mock.Anything, 1).Return(nil).Once()
+			},
+			wantErr: errors.New("mock-error"),
+		},
+		{
+			name: "failed because error in batch insert anomalies",
+			mockFunc: func(m *MockRepository) {
+				m.On("BatchInsertTrade", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+				m.On("ListAnomalyBaselines", mock.Anything, previous).Return(map[string]*AnomalyBaseline{}, nil).Once()
+				m.On("BatchInsertMetrics", mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
+				m.On("UpsertInstruments", mock.Anything, mock.Anything).Return(nil).Once()
+				m.On("BatchInsertAnomalies", mock.Anything, 1, mock.Anything).Return(errors.New("mock-error")).Once()
+				m.On("PurgeUpload", mock.Anything, 1).Return(nil).Once()
+			},
+			wantErr: errors.New("mock-error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &config.Config{
+				App: config.App{
+					Workers:   1,
+					BatchSize: 10,
+				},
+				Anomaly: config.Anomaly{
+					PriceDeviation: 0.2,
+					MinSamples:     2,
+				},
+			}
+
+			mockRepo := new(MockRepository)
+			tc.mockFunc(mockRepo)
+
+			svc := NewService(mockRepo, cfg, nil)
+
+			mockRepo.On("FinishUpload", mock.Anything, mock.Anything).Return(nil).Once()
+
+			content := tc.csvContent
+			if content == "" {
+				content = csvContent
+			}
+
+			err := svc.BatchInsert(context.Background(), &Upload{ID: 1}, bytes.NewReader([]byte(content)))
+			assert.Equal(t, tc.wantErr, err)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestBatches(t *testing.T) {
+	rows := []int{1, 2, 3, 4, 5}
+
+	assert.Equal(t, [][]int{{1, 2}, {3, 4}, {5}}, batches(rows, 2))
+	assert.Equal(t, [][]int{{1, 2, 3, 4, 5}}, batches(rows, 10))
+	assert.Equal(t, [][]int{{1, 2, 3, 4, 5}}, batches(rows, 0))
+	assert.Empty(t, batches([]int{}, 2))
+}
+
+func TestServiceAnomalies(t *testing.T) {
+	cases := []struct {
+		name     string
+		filter   AnomalyFilter
+		mockFunc func(m *MockRepository)
+		want     []*Anomaly
+		wantErr  error
+	}{
+		{
+			name:   "success",
+			filter: AnomalyFilter{Ticker: "PETR4"},
+			mockFunc: func(m *MockRepository) {
+				m.On("ListAnomalies", mock.Anything, AnomalyFilter{Ticker: "PETR4"}).
+					Return([]*Anomaly{{ID: 1, InstrumentCode: "PETR4", Reason: AnomalyExtremeSize}}, nil).Once()
+			},
+			want: []*Anomaly{{ID: 1, InstrumentCode: "PETR4", Reason: AnomalyExtremeSize}},
+		},
+		{
+			name:   "failed because repository error",
+			filter: AnomalyFilter{},
+			mockFunc: func(m *MockRepository) {
+				m.On("ListAnomalies", mock.Anything, AnomalyFilter{}).
+					Return(nil, errors.New("repository error")).Once()
+			},
+			want:    nil,
+			wantErr: errors.New("repository error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := new(MockRepository)
+			tc.mockFunc(mockRepo)
+
+			svc := NewService(mockRepo, &config.Config{}, nil)
+
+			got, err := svc.Anomalies(context.Background(), tc.filter)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.want, got)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_BatchInsertBlockTrades(t *testing.T) {
+	csvContent := `DataReferencia;CodigoInstrumento;AcaoAtualizacao;PrecoNegocio;QuantidadeNegociada;HoraFechamento;CodigoIdentificadorNegocio;TipoSessaoPregao;DataNegocio;CodigoParticipanteComprador;CodigoParticipanteVendedor
+2024-06-28;PETR4;0;38,00;100;100000000;10;1;2024-06-28;3;23
+2024-06-28;PETR4;0;38,20;50000;100100000;20;1;2024-06-28;72;8
+`
+	date := time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name     string
+		mockFunc func(m *MockRepository)
+		wantErr  error
+	}{
+		{
+			name: "success",
+			mockFunc: func(m *MockRepository) {
+				m.On("BatchInsertTrade", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+				m.On("BatchInsertMetrics", mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
+				m.On("UpsertInstruments", mock.Anything, mock.Anything).Return(nil).Once()
+				m.On("BatchInsertBlockTrades", mock.Anything, 1, []*BlockTrade{
+					{
+						InstrumentCode: "PETR4",
+						TradePrice:     decimal.NewFromBigInt(big.NewInt(3820), -2),
+						TradeQuantity:  50000,
+						CloseTime:      "100100000",
+						TradeDate:      date,
+						BuyerCode:      "72",
+						SellerCode:     "8",
+						Criterion:      BlockCriterionAbsolute,
+					},
+				}).Return(nil).Once()
+				m.On("BatchInsertSequences", mock.Anything, 1, mock.Anything).Return(nil).Once()
+				m.On("BatchInsertOrderFlow", mock.Anything, 1, mock.Anything).Return(nil).Once()
+			},
+		},
+		{
+			name: "failed because error in batch insert block trades",
+			mockFunc: func(m *MockRepository) {
+				m.On("BatchInsertTrade", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+				m.On("BatchInsertMetrics", mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
+				m.On("UpsertInstruments", mock.Anything, mock.Anything).Return(nil).Once()
+				m.On("BatchInsertBlockTrades", mock.Anything, 1, mock.Anything).Return(errors.New("mock-error")).Once()
+				m.On("PurgeUpload", mock.Anything, 1).Return(nil).Once()
+			},
+			wantErr: errors.New("mock-error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &config.Config{
+				App: config.App{
+					Workers:   1,
+					BatchSize: 10,
+				},
+				Block: config.Block{
+					MinQuantity: 10000,
+				},
+			}
+
+			mockRepo := new(MockRepository)
+			tc.mockFunc(mockRepo)
+
+			svc := NewService(mockRepo, cfg, nil)
+
+			mockRepo.On("FinishUpload", mock.Anything, mock.Anything).Return(nil).Once()
+
+			err := svc.BatchInsert(context.Background(), &Upload{ID: 1}, bytes.NewReader([]byte(csvContent)))
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
+
+func TestServiceBlockTrades(t *testing.T) {
+	date := time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name     string
+		filter   BlockTradeFilter
+		mockFunc func(m *MockRepository)
+		want     []*BlockTrade
+		wantErr  error
+	}{
+		{
+			name:   "success",
+			filter: BlockTradeFilter{Date: date},
+			mockFunc: func(m *MockRepository) {
+				m.On("ListBlockTrades", mock.Anything, BlockTradeFilter{Date: date}).
+					Return([]*BlockTrade{{ID: 1, InstrumentCode: "PETR4", TradeQuantity: 50000}}, nil).Once()
+			},
+			want: []*BlockTrade{{ID: 1, InstrumentCode: "PETR4", TradeQuantity: 50000}},
+		},
+		{
+			name:     "failed because not a trading day",
+			filter:   BlockTradeFilter{Date: time.Date(2024, 11, 20, 0, 0, 0, 0, time.UTC)},
+			mockFunc: func(m *MockRepository) {},
+			want:     nil,
+			wantErr:  calendar.ErrNotTradingDay,
+		},
+		{
+			name:   "failed because repository error",
+			filter: BlockTradeFilter{Date: date},
+			mockFunc: func(m *MockRepository) {
+				m.On("ListBlockTrades", mock.Anything, BlockTradeFilter{Date: date}).
+					Return(nil, errors.New("repository error")).Once()
+			},
+			want:    nil,
+			wantErr: errors.New("repository error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := new(MockRepository)
+			tc.mockFunc(mockRepo)
+
+			svc := NewService(mockRepo, &config.Config{}, nil)
+
+			got, err := svc.BlockTrades(context.Background(), tc.filter)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.want, got)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestServiceTopBrokers(t *testing.T) {
+	cases := []struct {
+		name     string
+		filter   BrokerFilter
+		mockFunc func(m *MockRepository)
+		want     *BrokerRanking
+		wantErr  error
+	}{
+		{
+			name:   "success with default limit",
+			filter: BrokerFilter{Ticker: "PETR4"},
+			mockFunc: func(m *MockRepository) {
+				m.On("TopBrokers", mock.Anything, BrokerFilter{Ticker: "PETR4", Limit: DefaultBrokerLimit}, SideBuy).
+					Return([]*BrokerVolume{{Broker: "3", Quantity: 500}}, nil).Once()
+				m.On("TopBrokers", mock.Anything, BrokerFilter{Ticker: "PETR4", Limit: DefaultBrokerLimit}, SideSell).
+					Return([]*BrokerVolume{{Broker: "72", Quantity: 400}}, nil).Once()
+			},
+			want: &BrokerRanking{
+				Ticker:  "PETR4",
+				Buyers:  []*BrokerVolume{{Broker: "3", Quantity: 500}},
+				Sellers: []*BrokerVolume{{Broker: "72", Quantity: 400}},
+			},
+		},
+		{
+			name:   "failed because repository error on sell side",
+			filter: BrokerFilter{Ticker: "PETR4", Limit: MaxBrokerLimit + 1},
+			mockFunc: func(m *MockRepository) {
+				m.On("TopBrokers", mock.Anything, BrokerFilter{Ticker: "PETR4", Limit: MaxBrokerLimit}, SideBuy).
+					Return([]*BrokerVolume{}, nil).Once()
+				m.On("TopBrokers", mock.Anything, BrokerFilter{Ticker: "PETR4", Limit: MaxBrokerLimit}, SideSell).
+					Return(nil, errors.New("repository error")).Once()
+			},
+			want:    nil,
+			wantErr: errors.New("repository error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := new(MockRepository)
+			tc.mockFunc(mockRepo)
+
+			svc := NewService(mockRepo, &config.Config{}, nil)
+
+			got, err := svc.TopBrokers(context.Background(), tc.filter)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.want, got)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestServiceBrokerMatrix(t *testing.T) {
+	cases := []struct {
+		name     string
+		filter   BrokerFilter
+		mockFunc func(m *MockRepository)
+		want     *BrokerMatrix
+		wantErr  error
+	}{
+		{
+			name:   "success",
+			filter: BrokerFilter{},
+			mockFunc: func(m *MockRepository) {
+				m.On("BrokerPositions", mock.Anything, BrokerFilter{}).
+					Return([]*BrokerPosition{
+						{Broker: "3", Ticker: "VALE3", NetQuantity: -100},
+						{Broker: "3", Ticker: "PETR4", NetQuantity: 300},
+						{Broker: "72", Ticker: "VALE3", NetQuantity: 100},
+					}, nil).Once()
+			},
+			want: &BrokerMatrix{
+				Tickers: []string{"PETR4", "VALE3"},
+				Rows: []*BrokerMatrixRow{
+					{Broker: "3", NetQuantity: []int{300, -100}},
+					{Broker: "72", NetQuantity: []int{0, 100}},
+				},
+			},
+		},
+		{
+			name:   "success without positions",
+			filter: BrokerFilter{},
+			mockFunc: func(m *MockRepository) {
+				m.On("BrokerPositions", mock.Anything, BrokerFilter{}).
+					Return([]*BrokerPosition{}, nil).Once()
+			},
+			want: &BrokerMatrix{
+				Tickers: []string{},
+				Rows:    []*BrokerMatrixRow{},
+			},
+		},
+		{
+			name:   "failed because repository error",
+			filter: BrokerFilter{},
+			mockFunc: func(m *MockRepository) {
+				m.On("BrokerPositions", mock.Anything, BrokerFilter{}).
+					Return(nil, errors.New("repository error")).Once()
+			},
+			want:    nil,
+			wantErr: errors.New("repository error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := new(MockRepository)
+			tc.mockFunc(mockRepo)
+
+			svc := NewService(mockRepo, &config.Config{}, nil)
+
+			got, err := svc.BrokerMatrix(context.Background(), tc.filter)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.want, got)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_BatchInsertSessions(t *testing.T) {
+	csvContent := `DataReferencia;CodigoInstrumento;AcaoAtualizacao;PrecoNegocio;QuantidadeNegociada;HoraFechamento;CodigoIdentificadorNegocio;TipoSessaoPregao;DataNegocio;CodigoParticipanteComprador;CodigoParticipanteVendedor
+2024-06-28;PETR4;0;38,00;100;100000000;10;1;2024-06-28;3;23
+2024-06-28;PETR4;0;38,20;200;160000000;20;1;2024-06-28;3;23
+2024-06-28;PETR4;0;39,00;50;173000000;30;6;2024-06-28;3;23
+`
+	date := time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC)
+
+	mockRepo := new(MockRepository)
+	mockRepo.On("BatchInsertTrade", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	mockRepo.On("BatchInsertMetrics", mock.Anything, mock.Anything, map[string]*Metric{
+		"PETR4|2024-06-28|1": {
+			Ticker:          "PETR4",
+			MaxRangeValue:   decimal.NewFromBigInt(big.NewInt(3820), -2),
+			MaxDailyVolume:  300,
+			TradeDate:       date,
+			SessionType:     SessionRegular,
+			ClosePrice:      decimal.NewFromBigInt(big.NewInt(3820), -2),
+			CloseTime:       "160000000",
+			FinancialVolume: decimal.New(1144000, -2),
+		},
+		"PETR4|2024-06-28|6": {
+			Ticker:          "PETR4",
+			MaxRangeValue:   decimal.NewFromBigInt(big.NewInt(3900), -2),
+			MaxDailyVolume:  50,
+			TradeDate:       date,
+			SessionType:     SessionAfterMarket,
+			ClosePrice:      decimal.NewFromBigInt(big.NewInt(3900), -2),
+			CloseTime:       "173000000",
+			FinancialVolume: decimal.New(195000, -2),
+		},
+	}).Return(nil).Once()
+	mockRepo.On("UpsertInstruments", mock.Anything, []*Instrument{{Ticker: "PETR4", Type: instrument.TypeStock}}).Return(nil).Once()
+	mockRepo.On("BatchInsertSequences", mock.Anything, 1, mock.Anything).Return(nil).Once()
+	mockRepo.On("BatchInsertOrderFlow", mock.Anything, 1, mock.Anything).Return(nil).Once()
+
+	cfg := &config.Config{
+		App: config.App{
+			Workers:   1,
+			BatchSize: 10,
+		},
+	}
+
+	svc := NewService(mockRepo, cfg, nil)
+
+	mockRepo.On("FinishUpload", mock.Anything, mock.Anything).Return(nil).Once()
+
+	err := svc.BatchInsert(context.Background(), &Upload{ID: 1}, bytes.NewReader([]byte(csvContent)))
+	assert.NoError(t, err)
+}
+
+func TestServiceClassifyInstruments(t *testing.T) {
+	cases := []struct {
+		name     string
+		mockFunc func(m *MockRepository)
+		wantErr  error
+	}{
+		{
+			name: "success",
+			mockFunc: func(m *MockRepository) {
+				m.On("UnclassifiedTickers", mock.Anything).
+					Return([]string{"WINQ24", "PETR4F", "PETRG350"}, nil).Once()
+				m.On("UpsertInstruments", mock.Anything, []*Instrument{
+					{Ticker: "PETR4F", Type: instrument.TypeFractional},
+					{Ticker: "PETRG350", Type: instrument.TypeOption},
+					{Ticker: "WINQ24", Type: instrument.TypeFuture},
+				}).Return(nil).Once()
+			},
+		},
+		{
+			name: "success without unclassified tickers",
+			mockFunc: func(m *MockRepository) {
+				m.On("UnclassifiedTickers", mock.Anything).Return([]string{}, nil).Once()
+			},
+		},
+		{
+			name: "failed because repository error",
+			mockFunc: func(m *MockRepository) {
+				m.On("UnclassifiedTickers", mock.Anything).Return(nil, errors.New("repository error")).Once()
+			},
+			wantErr: errors.New("repository error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := new(MockRepository)
+			tc.mockFunc(mockRepo)
+
+			svc := NewService(mockRepo, &config.Config{}, nil)
+
+			err := svc.ClassifyInstruments(context.Background())
+			assert.Equal(t, tc.wantErr, err)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestServiceImportInstruments(t *testing.T) {
+	strike := decimal.RequireFromString("35.50")
+
+	cases := []struct {
+		name     string
+		file     string
+		mockFunc func(m *MockRepository)
+		want     int
+		wantErr  error
+	}{
+		{
+			name: "success",
+			file: "ticker;underlying;strike\nPETRG355;PETR4;35,50\nwinq24;;\n",
+			mockFunc: func(m *MockRepository) {
+				m.On("ImportInstruments", mock.Anything, []*Instrument{
+					{Ticker: "PETRG355", Type: instrument.TypeOption, Underlying: "PETR4", Strike: &strike},
+					{Ticker: "WINQ24", Type: instrument.TypeFuture},
+				}).Return(nil).Once()
+			},
+			want: 2,
+		},
+		{
+			name:     "success with empty file",
+			file:     "ticker;underlying;strike\n",
+			mockFunc: func(m *MockRepository) {},
+			want:     0,
+		},
+		{
+			name:     "failed because invalid strike",
+			file:     "ticker;underlying;strike\nPETRG355;PETR4;abc\n",
+			mockFunc: func(m *MockRepository) {},
+			wantErr:  ErrInvalidInstrumentFile,
+		},
+		{
+			name:     "failed because missing columns",
+			file:     "ticker;underlying;strike\nPETRG355\n",
+			mockFunc: func(m *MockRepository) {},
+			wantErr:  ErrInvalidInstrumentFile,
+		},
+		{
+			name: "failed because repository error",
+			file: "ticker;underlying;strike\nPETRG355;PETR4;35,50\n",
+			mockFunc: func(m *MockRepository) {
+				m.On("ImportInstruments", mock.Anything, mock.Anything).Return(errors.New("repository error")).Once()
+			},
+			wantErr: errors.New("repository error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := new(MockRepository)
+			tc.mockFunc(mockRepo)
+
+			svc := NewService(mockRepo, &config.Config{}, nil)
+
+			got, err := svc.ImportInstruments(context.Background(), bytes.NewBufferString(tc.file))
+			if errors.Is(tc.wantErr, ErrInvalidInstrumentFile) {
+				assert.ErrorIs(t, err, ErrInvalidInstrumentFile)
+			} else {
+				assert.Equal(t, tc.wantErr, err)
+			}
+			assert.Equal(t, tc.want, got)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestServiceOptionChain(t *testing.T) {
+	date := time.Date(2024, 6, 28, 0, 0, 0, 0, time.UTC)
+	strike := decimal.NewFromInt(35)
+
+	cases := []struct {
+		name     string
+		filter   OptionFilter
+		mockFunc func(m *MockRepository)
+		want     []*OptionSeries
+		wantErr  error
+	}{
+		{
+			name:   "success",
+			filter: OptionFilter{Underlying: "petr4", Date: date},
+			mockFunc: func(m *MockRepository) {
+				m.On("ListOptionSeries", mock.Anything, OptionFilter{Underlying: "PETR4", Date: date}).
+					Return([]*OptionSeries{
+						{Ticker: "PETRG350", Underlying: "PETR4", Strike: &strike, MaxRangeValue: decimal.NewFromFloat(0.45), MaxDailyVolume: 12000},
+						{Ticker: "PETRS330", MaxRangeValue: decimal.NewFromFloat(0.2), MaxDailyVolume: 800},
+					}, nil).Once()
+			},
+			want: []*OptionSeries{
+				{Ticker: "PETRG350", Underlying: "PETR4", Right: instrument.RightCall, ExpiryMonth: time.July, Series: "350", Strike: &strike, MaxRangeValue: decimal.NewFromFloat(0.45), MaxDailyVolume: 12000},
+				{Ticker: "PETRS330", Underlying: "PETR", Right: instrument.RightPut, ExpiryMonth: time.July, Series: "330", MaxRangeValue: decimal.NewFromFloat(0.2), MaxDailyVolume: 800},
+			},
+		},
+		{
+			name:   "failed because repository error",
+			filter: OptionFilter{Underlying: "PETR4", Date: date},
+			mockFunc: func(m *MockRepository) {
+				m.On("ListOptionSeries", mock.Anything, OptionFilter{Underlying: "PETR4", Date: date}).
+					Return(nil, errors.New("repository error")).Once()
+			},
+			wantErr: errors.New("repository error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := new(MockRepository)
+			tc.mockFunc(mockRepo)
+
+			svc := NewService(mockRepo, &config.Config{}, nil)
+
+			got, err := svc.OptionChain(context.Background(), tc.filter)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.want, got)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestServiceContinuousFuture(t *testing.T) {
+	date := time.Date(2024, 8, 7, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name     string
+		filter   ContinuousFilter
+		mockFunc func(m *MockRepository)
+		want     []*ContinuousPoint
+		wantErr  error
+	}{
+		{
+			name:   "success with default rollover",
+			filter: ContinuousFilter{Root: "win"},
+			mockFunc: func(m *MockRepository) {
+				m.On("ListContractDays", mock.Anything, ContinuousFilter{Root: "WIN", Roll: RollVolume, Adjust: AdjustNone}).
+					Return([]*ContractDay{
+						{Ticker: "WINQ24", TradeDate: date, ClosePrice: decimal.NewFromInt(130000), Volume: 1000},
+					}, nil).Once()
+			},
+			want: []*ContinuousPoint{
+				{TradeDate: date, Ticker: "WINQ24", ClosePrice: decimal.NewFromInt(130000), AdjustedPrice: decimal.NewFromInt(130000), Volume: 1000},
+			},
+		},
+		{
+			name:   "failed because repository error",
+			filter: ContinuousFilter{Root: "WIN", Roll: RollExpiry, RollDays: 3, Adjust: AdjustRatio},
+			mockFunc: func(m *MockRepository) {
+				m.On("ListContractDays", mock.Anything, ContinuousFilter{Root: "WIN", Roll: RollExpiry, RollDays: 3, Adjust: AdjustRatio}).
+					Return(nil, errors.New("repository error")).Once()
+			},
+			wantErr: errors.New("repository error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := new(MockRepository)
+			tc.mockFunc(mockRepo)
+
+			svc := NewService(mockRepo, &config.Config{}, nil)
+
+			got, err := svc.ContinuousFuture(context.Background(), tc.filter)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.want, got)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestServiceMetricHistory(t *testing.T) {
+	before := time.Date(2024, 5, 31, 0, 0, 0, 0, time.UTC)
+	after := time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name     string
+		filter   MetricFilter
+		mockFunc func(m *MockRepository)
+		want     []*DailyMetric
+		wantErr  error
+	}{
+		{
+			name:   "success without adjustment",
+			filter: MetricFilter{Ticker: "PETR4F", Session: SessionRegular, Consolidated: true},
+			mockFunc: func(m *MockRepository) {
+				m.On("DailyMetrics", mock.Anything, MetricFilter{Ticker: "PETR4", Session: SessionRegular, Consolidated: true}).
+					Return([]*DailyMetric{
+						{Ticker: "PETR4", TradeDate: before, MaxRangeValue: decimal.NewFromInt(80), ClosePrice: decimal.NewFromInt(78), MaxDailyVolume: 1000},
+					}, nil).Once()
+			},
+			want: []*DailyMetric{
+				{Ticker: "PETR4", TradeDate: before, MaxRangeValue: decimal.NewFromInt(80), ClosePrice: decimal.NewFromInt(78), MaxDailyVolume: 1000},
+			},
+		},
+		{
+			name:   "success adjusted",
+			filter: MetricFilter{Ticker: "PETR4", Adjusted: true},
+			mockFunc: func(m *MockRepository) {
+				m.On("DailyMetrics", mock.Anything, MetricFilter{Ticker: "PETR4", Adjusted: true}).
+					Return([]*DailyMetric{
+						{Ticker: "PETR4", TradeDate: before, MaxRangeValue: decimal.NewFromInt(80), ClosePrice: decimal.NewFromInt(78), MaxDailyVolume: 1000},
+						{Ticker: "PETR4", TradeDate: after, MaxRangeValue: decimal.NewFromInt(40), ClosePrice: decimal.NewFromInt(39), MaxDailyVolume: 2100},
+					}, nil).Once()
+				m.On("ListCorporateActions", mock.Anything, "PETR4").
+					Return([]*CorporateAction{
+						{Ticker: "PETR4", ExDate: after, Type: ActionSplit, Factor: decimal.NewFromInt(2)},
+					}, nil).Once()
+			},
+			want: []*DailyMetric{
+				{Ticker: "PETR4", TradeDate: before, MaxRangeValue: decimal.New(400000, -4), ClosePrice: decimal.New(390000, -4), MaxDailyVolume: 2000},
+				{Ticker: "PETR4", TradeDate: after, MaxRangeValue: decimal.NewFromInt(40), ClosePrice: decimal.NewFromInt(39), MaxDailyVolume: 2100},
+			},
+		},
+		{
+			name:   "failed because corporate actions error",
+			filter: MetricFilter{Ticker: "PETR4", Adjusted: true},
+			mockFunc: func(m *MockRepository) {
+				m.On("DailyMetrics", mock.Anything, MetricFilter{Ticker: "PETR4", Adjusted: true}).
+					Return([]*DailyMetric{{Ticker: "PETR4", TradeDate: before}}, nil).Once()
+				m.On("ListCorporateActions", mock.Anything, "PETR4").Return(nil, errors.New("repository error")).Once()
+			},
+			wantErr: errors.New("repository error"),
+		},
+		{
+			name:   "failed because repository error",
+			filter: MetricFilter{Ticker: "PETR4"},
+			mockFunc: func(m *MockRepository) {
+				m.On("DailyMetrics", mock.Anything, MetricFilter{Ticker: "PETR4"}).Return(nil, errors.New("repository error")).Once()
+			},
+			wantErr: errors.New("repository error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := new(MockRepository)
+			tc.mockFunc(mockRepo)
+
+			svc := NewService(mockRepo, &config.Config{}, nil)
+
+			got, err := svc.MetricHistory(context.Background(), tc.filter)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.want, got)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestServiceImportCorporateActions(t *testing.T) {
+	cases := []struct {
+		name     string
+		file     string
+		mockFunc func(m *MockRepository)
+		want     int
+		wantErr  error
+	}{
+		{
+			name: "success",
+			file: "ticker;ex_date;type;factor\npetr4;2024-06-03;split;2\nMGLU3;2024-05-02;reverse_split;0,1\n",
+			mockFunc: func(m *MockRepository) {
+				m.On("UpsertCorporateActions", mock.Anything, []*CorporateAction{
+					{Ticker: "PETR4", ExDate: time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC), Type: ActionSplit, Factor: decimal.NewFromInt(2)},
+					{Ticker: "MGLU3", ExDate: time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC), Type: ActionReverseSplit, Factor: decimal.RequireFromString("0.1")},
+				}).Return(nil).Once()
+			},
+			want: 2,
+		},
+		{
+			name:     "failed because invalid ex date",
+			file:     "ticker;ex_date;type;factor\nPETR4;2024-06-3J;split;2\n",
+			mockFunc: func(m *MockRepository) {},
+			wantErr:  ErrInvalidCorporateAction,
+		},
+		{
+			name:     "failed because unknown type",
+			file:     "ticker;ex_date;type;factor\nPETR4;2024-06-03;merger;2\n",
+			mockFunc: func(m *MockRepository) {},
+			wantErr:  ErrInvalidCorporateAction,
+		},
+		{
+			name:     "failed because non positive factor",
+			file:     "ticker;ex_date;type;factor\nPETR4;2024-06-03;split;0\n",
+			mockFunc: func(m *MockRepository) {},
+			wantErr:  ErrInvalidCorporateAction,
+		},
+		{
+			name: "failed because repository error",
+			file: "ticker;ex_date;type;factor\nPETR4;2024-06-03;split;2\n",
+			mockFunc: func(m *MockRepository) {
+				m.On("UpsertCorporateActions", mock.Anything, mock.Anything).Return(errors.New("repository error")).Once()
+			},
+			wantErr: errors.New("repository error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := new(MockRepository)
+			tc.mockFunc(mockRepo)
+
+			svc := NewService(mockRepo, &config.Config{}, nil)
+
+			got, err := svc.ImportCorporateActions(context.Background(), bytes.NewBufferString(tc.file))
+			if errors.Is(tc.wantErr, ErrInvalidCorporateAction) {
+				assert.ErrorIs(t, err, ErrInvalidCorporateAction)
+			} else {
+				assert.Equal(t, tc.wantErr, err)
+			}
+			assert.Equal(t, tc.want, got)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestServiceTradingDays(t *testing.T) {
+	// 2024-07-09 is only a holiday in Sao Paulo, set through the config
+	cfg := &config.Config{Calendar: config.Calendar{Holidays: []time.Time{time.Date(2024, 7, 9, 0, 0, 0, 0, time.UTC)}}}
+	svc := NewService(new(MockRepository), cfg, nil)
+
+	assert.Equal(t, &TradingDay{
+		Date:       time.Date(2024, 7, 9, 0, 0, 0, 0, time.UTC),
+		TradingDay: false,
+		Previous:   time.Date(2024, 7, 8, 0, 0, 0, 0, time.UTC),
+		Next:       time.Date(2024, 7, 10, 0, 0, 0, 0, time.UTC),
+	}, svc.TradingDay(time.Date(2024, 7, 9, 0, 0, 0, 0, time.UTC)))
+
+	start := time.Date(2024, 7, 5, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 7, 10, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, &TradingDays{
+		Start: start,
+		End:   end,
+		Count: 3,
+		Days: []time.Time{
+			time.Date(2024, 7, 5, 0, 0, 0, 0, time.UTC),
+			time.Date(2024, 7, 8, 0, 0, 0, 0, time.UTC),
+			time.Date(2024, 7, 10, 0, 0, 0, 0, time.UTC),
+		},
+	}, svc.TradingDays(start, end))
+}
+
+func TestServiceParseRecordTimezone(t *testing.T) {
+	record := func(closeTime string) []string {
+		return []string{"2024-06-28", "PETR4", "0", "38,10", "100", closeTime, "10", "1", "2024-06-28", "3", "23"}
+	}
+
+	cases := []struct {
+		name      string
+		closeTime string
+		want      time.Time
+	}{
+		{
+			name:      "opening of the day",
+			closeTime: "000000000",
+			want:      time.Date(2024, 06, 28, 3, 0, 0, 0, time.UTC),
+		},
+		{
+			name:      "end of the day",
+			closeTime: "235959999",
+			want:      time.Date(2024, 06, 29, 2, 59, 59, 999000000, time.UTC),
+		},
+	}
+
+	exchange, err := time.LoadLocation("America/Sao_Paulo")
+	assert.NoError(t, err)
+	svc := &service{cfg: &config.Config{}, location: exchange}
+
+	// the day boundaries must not depend on the timezone of the server or of the database session reading the trades
+	for _, server := range []string{"UTC", "Asia/Tokyo", "Pacific/Kiritimati", "America/Los_Angeles"} {
+		location, err := time.LoadLocation(server)
+		assert.NoError(t, err)
+
+		for _, tc := range cases {
+			t.Run(server+" "+tc.name, func(t *testing.T) {
+				got, err := svc.parseRecord(record(tc.closeTime))
+				assert.NoError(t, err)
+				assert.Equal(t, time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC), got.TradeDate)
+				assert.True(t, tc.want.Equal(got.TradedAt.In(location)))
+				assert.Equal(t, "2024-06-28", got.TradedAt.In(exchange).Format("2006-01-02"))
+			})
+		}
+	}
+}
+
+func TestServiceActivity(t *testing.T) {
+	cases := []struct {
+		name     string
+		filter   ActivityFilter
+		mockFunc func(m *MockRepository)
+		want     *Activity
+		wantErr  error
+	}{
+		{
+			name:   "success with default bucket",
+			filter: ActivityFilter{Ticker: "petr4"},
+			mockFunc: func(m *MockRepository) {
+				m.On("IntradayVolumes", mock.Anything, ActivityFilter{Ticker: "PETR4", BucketMinutes: DefaultActivityBucketMinutes}).
+					Return([]*IntradayVolume{
+						{Minute: 600, Volume: 30000, Trades: 120, Days: 3},
+						{Minute: 615, Volume: 1000, Trades: 5, Days: 3},
+					}, nil).Once()
+			},
+			want: &Activity{
+				Ticker:        "PETR4",
+				BucketMinutes: DefaultActivityBucketMinutes,
+				Days:          3,
+				Buckets: []*ActivityBucket{
+					{Start: "10:00", AverageVolume: decimal.New(1000000, -2), AverageTrades: decimal.New(4000, -2)},
+					{Start: "10:15", AverageVolume: decimal.New(33333, -2), AverageTrades: decimal.New(167, -2)},
+				},
+			},
+		},
+		{
+			name:   "success without trades",
+			filter: ActivityFilter{Ticker: "PETR4", BucketMinutes: 60},
+			mockFunc: func(m *MockRepository) {
+				m.On("IntradayVolumes", mock.Anything, ActivityFilter{Ticker: "PETR4", BucketMinutes: 60}).
+					Return([]*IntradayVolume{}, nil).Once()
+			},
+			want: &Activity{
+				Ticker:        "PETR4",
+				BucketMinutes: 60,
+				Buckets:       []*ActivityBucket{},
+			},
+		},
+		{
+			name:   "failed because repository error",
+			filter: ActivityFilter{Ticker: "PETR4", BucketMinutes: 60},
+			mockFunc: func(m *MockRepository) {
+				m.On("IntradayVolumes", mock.Anything, mock.Anything).Return(nil, errors.New("repository error")).Once()
+			},
+			want:    nil,
+			wantErr: errors.New("repository error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := new(MockRepository)
+			tc.mockFunc(mockRepo)
+
+			svc := NewService(mockRepo, &config.Config{}, nil)
+
+			got, err := svc.Activity(context.Background(), tc.filter)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.want, got)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestServiceMarketBreadth(t *testing.T) {
+	start := time.Date(2024, 7, 10, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 7, 12, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name     string
+		filter   BreadthFilter
+		mockFunc func(m *MockRepository)
+		want     []*MarketBreadth
+		wantErr  error
+	}{
+		{
+			name:   "success with history over the weekend",
+			filter: BreadthFilter{Start: start, End: end, Days: 3},
+			mockFunc: func(m *MockRepository) {
+				m.On("MarketBreadth", mock.Anything, BreadthFilter{
+					Start:        start,
+					End:          end,
+					Days:         3,
+					HistoryStart: time.Date(2024, 7, 5, 0, 0, 0, 0, time.UTC),
+				}).Return([]*MarketBreadth{{TradeDate: start, Advancing: 10, Declining: 5}}, nil).Once()
+			},
+			want: []*MarketBreadth{{TradeDate: start, Advancing: 10, Declining: 5}},
+		},
+		{
+			name:   "success with days above max",
+			filter: BreadthFilter{Start: start, End: end, Days: MaxBreadthDays * 2},
+			mockFunc: func(m *MockRepository) {
+				m.On("MarketBreadth", mock.Anything, mock.MatchedBy(func(filter BreadthFilter) bool {
+					return filter.Days == MaxBreadthDays && filter.HistoryStart.Before(start.AddDate(-1, 0, 0))
+				})).Return([]*MarketBreadth{}, nil).Once()
+			},
+			want: []*MarketBreadth{},
+		},
+		{
+			name:   "failed because repository error",
+			filter: BreadthFilter{Start: start, End: end},
+			mockFunc: func(m *MockRepository) {
+				m.On("MarketBreadth", mock.Anything, mock.MatchedBy(func(filter BreadthFilter) bool {
+					return filter.Days == DefaultBreadthDays
+				})).Return(nil, errors.New("repository error")).Once()
+			},
+			want:    nil,
+			wantErr: errors.New("repository error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := new(MockRepository)
+			tc.mockFunc(mockRepo)
+
+			svc := NewService(mockRepo, &config.Config{}, nil)
+
+			got, err := svc.MarketBreadth(context.Background(), tc.filter)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.want, got)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestServiceLiquidity(t *testing.T) {
+	// 2024-07-08 to 2024-07-12 is a week without holidays
+	start := time.Date(2024, 7, 8, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 7, 12, 0, 0, 0, 0, time.UTC)
+	filter := LiquidityFilter{Ticker: "PETR4", Start: start, End: end}
+
+	cases := []struct {
+		name     string
+		mockFunc func(m *MockRepository)
+		want     *Liquidity
+		wantErr  error
+	}{
+		{
+			name: "success",
+			mockFunc: func(m *MockRepository) {
+				m.On("DailyLiquidity", mock.Anything, filter).Return([]*DailyLiquidity{
+					{TradeDate: start, ClosePrice: decimal.NewFromInt(40), Volume: 1000, FinancialVolume: decimal.NewFromInt(40000)},
+					{TradeDate: start.AddDate(0, 0, 1), ClosePrice: decimal.NewFromInt(44), Volume: 1000, FinancialVolume: decimal.NewFromInt(44000)},
+				}, nil).Once()
+				m.On("PriceCovariance", mock.Anything, filter).
+					Return(&PriceCovariance{Covariance: decimal.New(-1, -4), Pairs: 10}, nil).Once()
+			},
+			want: &Liquidity{
+				Ticker:                 "PETR4",
+				Start:                  start,
+				End:                    end,
+				TradingDays:            5,
+				ZeroVolumeDays:         3,
+				AverageFinancialVolume: decimal.NewFromInt(16800),
+				AmihudIlliquidity:      decimal.New(2272727, -6),
+				SerialCovariance:       decimal.New(-1, -4),
+				RollSpread:             decimal.New(2, -2),
+			},
+		},
+		{
+			name: "failed because daily liquidity error",
+			mockFunc: func(m *MockRepository) {
+				m.On("DailyLiquidity", mock.Anything, filter).Return(nil, errors.New("repository error")).Once()
+			},
+			want:    nil,
+			wantErr: errors.New("repository error"),
+		},
+		{
+			name: "failed because price covariance error",
+			mockFunc: func(m *MockRepository) {
+				m.On("DailyLiquidity", mock.Anything, filter).Return([]*DailyLiquidity{}, nil).Once()
+				m.On("PriceCovariance", mock.Anything, filter).Return(nil, errors.New("repository error")).Once()
+			},
+			want:    nil,
+			wantErr: errors.New("repository error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := new(MockRepository)
+			tc.mockFunc(mockRepo)
+
+			svc := NewService(mockRepo, &config.Config{}, nil)
+
+			got, err := svc.Liquidity(context.Background(), LiquidityFilter{Ticker: "petr4", Start: start, End: end})
+			assert.Equal(t, tc.wantErr, err)
+			if tc.want == nil {
+				assert.Nil(t, got)
+			} else {
+				assert.Equal(t, tc.want.TradingDays, got.TradingDays)
+				assert.Equal(t, tc.want.ZeroVolumeDays, got.ZeroVolumeDays)
+				assert.Equal(t, tc.want.AverageFinancialVolume.String(), got.AverageFinancialVolume.String())
+				assert.Equal(t, tc.want.AmihudIlliquidity.String(), got.AmihudIlliquidity.String())
+				assert.Equal(t, tc.want.SerialCovariance.String(), got.SerialCovariance.String())
+				assert.Equal(t, tc.want.RollSpread.String(), got.RollSpread.String())
+				assert.Equal(t, tc.want.Ticker, got.Ticker)
+			}
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestServiceOrderFlow(t *testing.T) {
+	date := time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name     string
+		filter   OrderFlowFilter
+		mockFunc func(m *MockRepository)
+		want     []*DailyOrderFlow
+		wantErr  error
+	}{
+		{
+			name:   "success",
+			filter: OrderFlowFilter{Ticker: "petr4"},
+			mockFunc: func(m *MockRepository) {
+				m.On("ListOrderFlow", mock.Anything, OrderFlowFilter{Ticker: "PETR4"}).Return([]*OrderFlow{
+					{Ticker: "PETR4", TradeDate: date, Minute: 600, BuyVolume: 300, SellVolume: 100, BuyTrades: 3, SellTrades: 1},
+					{Ticker: "PETR4", TradeDate: date, Minute: 615, BuyVolume: 100, SellVolume: 100, UnclassifiedVolume: 50, BuyTrades: 1, SellTrades: 1},
+				}, nil).Once()
+			},
+			want: []*DailyOrderFlow{
+				{
+					TradeDate:          date,
+					BuyVolume:          400,
+					SellVolume:         200,
+					UnclassifiedVolume: 50,
+					BuyTrades:          4,
+					SellTrades:         2,
+					Imbalance:          decimal.New(3333, -4),
+				},
+			},
+		},
+		{
+			name:   "failed because repository error",
+			filter: OrderFlowFilter{Ticker: "PETR4", Intraday: true},
+			mockFunc: func(m *MockRepository) {
+				m.On("ListOrderFlow", mock.Anything, OrderFlowFilter{Ticker: "PETR4", Intraday: true}).
+					Return(nil, errors.New("repository error")).Once()
+			},
+			want:    nil,
+			wantErr: errors.New("repository error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := new(MockRepository)
+			tc.mockFunc(mockRepo)
+
+			svc := NewService(mockRepo, &config.Config{}, nil)
+
+			got, err := svc.OrderFlow(context.Background(), tc.filter)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.want, got)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestServiceBenchmarks(t *testing.T) {
+	date := time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC)
+	clock := func(hour, minute int) time.Time {
+		return time.Date(0, 1, 1, hour, minute, 0, 0, time.UTC)
+	}
+	windows := []BenchmarkWindow{
+		{From: clock(10, 0), To: clock(11, 0)},
+		{From: clock(10, 30), To: clock(12, 0)},
+	}
+	span := TradeFilter{Ticker: "PETR4", Date: date, FromTime: clock(10, 0), ToTime: clock(12, 0)}
+
+	cases := []struct {
+		name     string
+		filter   BenchmarkFilter
+		mockFunc func(m *MockRepository)
+		want     []*IntervalBenchmark
+		wantErr  error
+	}{
+		{
+			name:   "success reading the span of the windows",
+			filter: BenchmarkFilter{Ticker: "petr4", Date: date, Windows: windows},
+			mockFunc: func(m *MockRepository) {
+				m.On("ListWindowTrades", mock.Anything, span).Return([]*Trade{
+					{InstrumentCode: "PETR4", TradePrice: decimal.NewFromInt(38), TradeQuantity: 100, CloseTime: "100000000"},
+					{InstrumentCode: "PETR4", TradePrice: decimal.NewFromInt(40), TradeQuantity: 300, CloseTime: "104500000"},
+				}, nil).Once()
+			},
+			want: []*IntervalBenchmark{
+				{From: "10:00:00", To: "11:00:00", Trades: 2, Volume: 400, VWAP: decimal.NewFromFloat(39.5), TWAP: decimal.NewFromFloat(38.5)},
+				{From: "10:30:00", To: "12:00:00", Trades: 1, Volume: 300, VWAP: decimal.NewFromInt(40), TWAP: decimal.NewFromInt(40)},
+			},
+		},
+		{
+			name:     "failed because not a trading day",
+			filter:   BenchmarkFilter{Ticker: "PETR4", Date: time.Date(2024, 06, 29, 0, 0, 0, 0, time.UTC), Windows: windows},
+			mockFunc: func(m *MockRepository) {},
+			wantErr:  calendar.ErrNotTradingDay,
+		},
+		{
+			name:   "failed because repository error",
+			filter: BenchmarkFilter{Ticker: "PETR4", Date: date, Windows: windows},
+			mockFunc: func(m *MockRepository) {
+				m.On("ListWindowTrades", mock.Anything, span).Return(nil, errors.New("repository error")).Once()
+			},
+			wantErr: errors.New("repository error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := new(MockRepository)
+			tc.mockFunc(mockRepo)
+
+			svc := NewService(mockRepo, &config.Config{}, nil)
+
+			got, err := svc.Benchmarks(context.Background(), tc.filter)
+			assert.Equal(t, tc.wantErr, err)
+			if tc.want == nil {
+				assert.Nil(t, got)
+			} else {
+				assert.Equal(t, "PETR4", got.Ticker)
+				assert.Len(t, got.Windows, len(tc.want))
+				for i, want := range tc.want {
+					assert.Equal(t, want.From, got.Windows[i].From)
+					assert.Equal(t, want.To, got.Windows[i].To)
+					assert.Equal(t, want.Trades, got.Windows[i].Trades)
+					assert.Equal(t, want.Volume, got.Windows[i].Volume)
+					assert.Equal(t, want.VWAP.String(), got.Windows[i].VWAP.String())
+					assert.Equal(t, want.TWAP.String(), got.Windows[i].TWAP.String())
+				}
+			}
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_BatchInsertAuctions(t *testing.T) {
+	csvContent := `DataReferencia;CodigoInstrumento;AcaoAtualizacao;PrecoNegocio;QuantidadeNegociada;HoraFechamento;CodigoIdentificadorNegocio;TipoSessaoPregao;DataNegocio;CodigoParticipanteComprador;CodigoParticipanteVendedor
+2024-06-28;PETR4;0;38,00;1000;100000120;10;1;2024-06-28;3;23
+2024-06-28;PETR4;0;38,10;100;100500000;20;1;2024-06-28;3;23
+2024-06-28;PETR4;0;38,20;2000;170312345;30;1;2024-06-28;3;23
+`
+	date := time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC)
+	clock := func(hour, minute int) time.Time {
+		return time.Date(0, 1, 1, hour, minute, 0, 0, time.UTC)
+	}
+
+	cases := []struct {
+		name     string
+		mockFunc func(m *MockRepository)
+		wantErr  error
+	}{
+		{
+			name: "success",
+			mockFunc: func(m *MockRepository) {
+				m.On("BatchInsertAuctions", mock.Anything, 1, []*Auction{
+					{Ticker: "PETR4", TradeDate: date, Type: AuctionClosing, CloseTime: "170312345", Price: decimal.NewFromBigInt(big.NewInt(3820), -2), Volume: 2000, Trades: 1},
+					{Ticker: "PETR4", TradeDate: date, Type: AuctionOpening, CloseTime: "100000120", Price: decimal.NewFromBigInt(big.NewInt(3800), -2), Volume: 1000, Trades: 1},
+				}).Return(nil).Once()
+				m.On("BatchInsertSequences", mock.Anything, 1, mock.Anything).Return(nil).Once()
+				m.On("BatchInsertOrderFlow", mock.Anything, 1, mock.Anything).Return(nil).Once()
+			},
+		},
+		{
+			name: "failed because error in batch insert auctions",
+			mockFunc: func(m *MockRepository) {
+				m.On("BatchInsertAuctions", mock.Anything, 1, mock.Anything).Return(errors.New("mock-error")).Once()
+				m.On("PurgeUpload", mock.Anything, 1).Return(nil).Once()
+			},
+			wantErr: errors.New("mock-error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := new(MockRepository)
+			mockRepo.On("BatchInsertTrade", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+			mockRepo.On("BatchInsertMetrics", mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
+			mockRepo.On("UpsertInstruments", mock.Anything, mock.Anything).Return(nil).Once()
+			tc.mockFunc(mockRepo)
+
+			cfg := &config.Config{
+				App: config.App{
+					Workers:   1,
+					BatchSize: 10,
+				},
+				Auction: config.Auction{
+					Opening: config.Window{Start: clock(10, 0), End: clock(10, 15)},
+					Closing: config.Window{Start: clock(16, 55), End: clock(17, 10)},
+				},
+			}
+
+			svc := NewService(mockRepo, cfg, nil)
+
+			mockRepo.On("FinishUpload", mock.Anything, mock.Anything).Return(nil).Once()
+
+			err := svc.BatchInsert(context.Background(), &Upload{ID: 1}, bytes.NewReader([]byte(csvContent)))
+			assert.Equal(t, tc.wantErr, err)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestServiceAuctions(t *testing.T) {
+	date := time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name     string
+		mockFunc func(m *MockRepository)
+		want     []*Auction
+		wantErr  error
+	}{
+		{
+			name: "success",
+			mockFunc: func(m *MockRepository) {
+				m.On("ListAuctions", mock.Anything, AuctionFilter{Ticker: "PETR4", Type: AuctionOpening}).
+					Return([]*Auction{{Ticker: "PETR4", TradeDate: date, Type: AuctionOpening, Volume: 1000}}, nil).Once()
+			},
+			want: []*Auction{{Ticker: "PETR4", TradeDate: date, Type: AuctionOpening, Volume: 1000}},
+		},
+		{
+			name: "failed because repository error",
+			mockFunc: func(m *MockRepository) {
+				m.On("ListAuctions", mock.Anything, AuctionFilter{Ticker: "PETR4", Type: AuctionOpening}).
+					Return(nil, errors.New("repository error")).Once()
+			},
+			want:    nil,
+			wantErr: errors.New("repository error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := new(MockRepository)
+			tc.mockFunc(mockRepo)
+
+			svc := NewService(mockRepo, &config.Config{}, nil)
+
+			got, err := svc.Auctions(context.Background(), AuctionFilter{Ticker: "petr4", Type: AuctionOpening})
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.want, got)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestServiceCreateUpload(t *testing.T) {
+	content := "DataReferencia;CodigoInstrumento\n"
+	// sha256 of the content
+	checksum := "a6dc0596c5b9f23089ea5bf6a23b9ce54384cb3198613bee6ee1febf736bb55a"
+
+	cases := []struct {
+		name     string
+		mockFunc func(m *MockRepository, b *MockBlobStore)
+		want     *Upload
+		wantErr  error
+	}{
+		{
+			name: "success",
+			mockFunc: func(m *MockRepository, b *MockBlobStore) {
+				m.On("FindUploadByChecksum", mock.Anything, checksum).Return(nil, ErrUploadNotFound).Once()
+				b.On("Put", mock.Anything, "source-files/"+checksum, mock.Anything, int64(len(content))).
+					Run(func(args mock.Arguments) {
+						// the stored file is read in full
+						stored, _ := io.ReadAll(args.Get(2).(io.Reader))
+						assert.Equal(t, content, string(stored))
+					}).
+					Return(nil).Once()
+				m.On("CreateUpload", mock.Anything, &Upload{
+					FileName: "28-06-2024_NEGOCIOSAVISTA.txt",
+					FileSize: int64(len(content)),
+					Checksum: checksum,
+					Uploader: "ops",
+					BlobKey:  "source-files/" + checksum,
+					Status:   UploadProcessing,
+				}).
+					Run(func(args mock.Arguments) {
+						args.Get(1).(*Upload).ID = 7
+					}).
+					Return(nil).Once()
+			},
+			want: &Upload{
+				ID:       7,
+				FileName: "28-06-2024_NEGOCIOSAVISTA.txt",
+				FileSize: int64(len(content)),
+				Checksum: checksum,
+				Uploader: "ops",
+				BlobKey:  "source-files/" + checksum,
+				Status:   UploadProcessing,
+			},
+		},
+		{
+			name: "failed because file already uploaded",
+			mockFunc: func(m *MockRepository, b *MockBlobStore) {
+				m.On("FindUploadByChecksum", mock.Anything, checksum).
+					Return(&Upload{ID: 3, Checksum: checksum, Status: UploadCompleted}, nil).Once()
+			},
+			want:    &Upload{ID: 3, Checksum: checksum, Status: UploadCompleted},
+			wantErr: ErrDuplicateFile,
+		},
+		{
+			name: "failed because find error",
+			mockFunc: func(m *MockRepository, b *MockBlobStore) {
+				m.On("FindUploadByChecksum", mock.Anything, checksum).Return(nil, errors.New("repository error")).Once()
+			},
+			want:    nil,
+			wantErr: errors.New("repository error"),
+		},
+		{
+			name: "failed because blob store error",
+			mockFunc: func(m *MockRepository, b *MockBlobStore) {
+				m.On("FindUploadByChecksum", mock.Anything, checksum).Return(nil, ErrUploadNotFound).Once()
+				b.On("Put", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(errors.New("blob error")).Once()
+			},
+			want:    nil,
+			wantErr: errors.New("failed to store file: blob error"),
+		},
+		{
+			name: "failed because repository error",
+			mockFunc: func(m *MockRepository, b *MockBlobStore) {
+				m.On("FindUploadByChecksum", mock.Anything, checksum).Return(nil, ErrUploadNotFound).Once()
+				b.On("Put", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
+				m.On("CreateUpload", mock.Anything, mock.Anything).
+					Return(errors.New("repository error")).Once()
+			},
+			want:    nil,
+			wantErr: errors.New("repository error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := new(MockRepository)
+			mockBlobs := new(MockBlobStore)
+			tc.mockFunc(mockRepo, mockBlobs)
+
+			svc := NewService(mockRepo, &config.Config{}, mockBlobs)
+
+			file := strings.NewReader(content)
+			got, err := svc.CreateUpload(context.Background(), &Upload{FileName: "28-06-2024_NEGOCIOSAVISTA.txt", Uploader: "ops"}, file)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.want, got)
+			mockRepo.AssertExpectations(t)
+			mockBlobs.AssertExpectations(t)
+
+			// the file is read again from the start
+			rest, _ := io.ReadAll(file)
+			assert.Equal(t, content, string(rest))
+		})
+	}
+}
+
+func TestServiceReplay(t *testing.T) {
+	stored := func() *Upload {
+		return &Upload{ID: 3, FileName: "28-06-2024_NEGOCIOSAVISTA.txt", FileSize: 2048, Checksum: "abc123", Uploader: "ops",
+			BlobKey: "source-files/abc123", Status: UploadCompleted}
+	}
+	replayOf := 3
+	file := io.NopCloser(strings.NewReader("DataReferencia;CodigoInstrumento\n"))
+
+	cases := []struct {
+		name     string
+		actor    string
+		mockFunc func(m *MockRepository, b *MockBlobStore)
+		want     *Upload
+		wantErr  error
+	}{
+		{
+			name:  "success",
+			actor: "admin",
+			mockFunc: func(m *MockRepository, b *MockBlobStore) {
+				m.On("GetUpload", mock.Anything, 3).Return(stored(), nil).Once()
+				b.On("Get", mock.Anything, "source-files/abc123").Return(file, nil).Once()
+				m.On("ReplayUpload", mock.Anything, &UploadAudit{UploadID: 3, Action: AuditReplay, Actor: "admin"},
+					&Upload{FileName: "28-06-2024_NEGOCIOSAVISTA.txt", FileSize: 2048, Checksum: "abc123",
+						Uploader: "admin", BlobKey: "source-files/abc123", ReplayOf: &replayOf, Status: UploadProcessing}).
+					Run(func(args mock.Arguments) {
+						args.Get(2).(*Upload).ID = 8
+					}).
+					Return(nil).Once()
+			},
+			want: &Upload{ID: 8, FileName: "28-06-2024_NEGOCIOSAVISTA.txt", FileSize: 2048, Checksum: "abc123",
+				Uploader: "admin", BlobKey: "source-files/abc123", ReplayOf: &replayOf, Status: UploadProcessing},
+		},
+		{
+			name: "success with deleted upload",
+			mockFunc: func(m *MockRepository, b *MockBlobStore) {
+				upload := stored()
+				upload.Status = UploadDeleted
+				m.On("GetUpload", mock.Anything, 3).Return(upload, nil).Once()
+				b.On("Get", mock.Anything, "source-files/abc123").Return(file, nil).Once()
+				m.On("ReplayUpload", mock.Anything, &UploadAudit{UploadID: 3, Action: AuditReplay}, mock.Anything).
+					Run(func(args mock.Arguments) {
+						args.Get(2).(*Upload).ID = 8
+					}).
+					Return(nil).Once()
+			},
+			want: &Upload{ID: 8, FileName: "28-06-2024_NEGOCIOSAVISTA.txt", FileSize: 2048, Checksum: "abc123",
+				Uploader: "ops", BlobKey: "source-files/abc123", ReplayOf: &replayOf, Status: UploadProcessing},
+		},
+		{
+			name: "failed because upload not found",
+			mockFunc: func(m *MockRepository, b *MockBlobStore) {
+				m.On("GetUpload", mock.Anything, 3).Return(nil, ErrUploadNotFound).Once()
+			},
+			wantErr: ErrUploadNotFound,
+		},
+		{
+			name: "failed because upload is processing",
+			mockFunc: func(m *MockRepository, b *MockBlobStore) {
+				upload := stored()
+				upload.Status = UploadProcessing
+				m.On("GetUpload", mock.Anything, 3).Return(upload, nil).Once()
+			},
+			wantErr: ErrUploadProcessing,
+		},
+		{
+			name: "failed because file uploaded before the blob store",
+			mockFunc: func(m *MockRepository, b *MockBlobStore) {
+				upload := stored()
+				upload.BlobKey = ""
+				m.On("GetUpload", mock.Anything, 3).Return(upload, nil).Once()
+			},
+			wantErr: ErrFileNotStored,
+		},
+		{
+			name: "failed because blob not found",
+			mockFunc: func(m *MockRepository, b *MockBlobStore) {
+				m.On("GetUpload", mock.Anything, 3).Return(stored(), nil).Once()
+				b.On("Get", mock.Anything, "source-files/abc123").Return(nil, storage.ErrBlobNotFound).Once()
+			},
+			wantErr: ErrFileNotStored,
+		},
+		{
+			name: "failed because replay error",
+			mockFunc: func(m *MockRepository, b *MockBlobStore) {
+				m.On("GetUpload", mock.Anything, 3).Return(stored(), nil).Once()
+				b.On("Get", mock.Anything, "source-files/abc123").Return(file, nil).Once()
+				m.On("ReplayUpload", mock.Anything, mock.Anything, mock.Anything).Return(errors.New("repository error")).Once()
+			},
+			wantErr: errors.New("repository error"),
+		},
+		{
+			name: "failed because file already uploaded again",
+			mockFunc: func(m *MockRepository, b *MockBlobStore) {
+				m.On("GetUpload", mock.Anything, 3).Return(stored(), nil).Once()
+				b.On("Get", mock.Anything, "source-files/abc123").Return(file, nil).Once()
+				m.On("ReplayUpload", mock.Anything, mock.Anything, mock.Anything).Return(ErrDuplicateFile).Once()
+			},
+			wantErr: ErrDuplicateFile,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := new(MockRepository)
+			mockBlobs := new(MockBlobStore)
+			tc.mockFunc(mockRepo, mockBlobs)
+
+			svc := NewService(mockRepo, &config.Config{}, mockBlobs)
+
+			got, reader, err := svc.Replay(context.Background(), 3, tc.actor)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.want, got)
+			if tc.wantErr == nil {
+				assert.Equal(t, file, reader)
+			} else {
+				assert.Nil(t, reader)
+			}
+			mockRepo.AssertExpectations(t)
+			mockBlobs.AssertExpectations(t)
+		})
+	}
+}
+
+// replayRepository keeps the uploads and the number of trades of each one as the database would, the other calls go
+// to the embedded mock
+type replayRepository struct {
+	*MockRepository
+	mu      sync.Mutex
+	uploads map[int]*Upload
+	trades  map[int]int
+	lastID  int
+}
+
+func (r *replayRepository) GetUpload(ctx context.Context, id int) (*Upload, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	upload, ok := r.uploads[id]
+	if !ok {
+		return nil, ErrUploadNotFound
+	}
+	stored := *upload
+	return &stored, nil
+}
+
+func (r *replayRepository) ReplayUpload(ctx context.Context, audit *UploadAudit, replay *Upload) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// the rollback is only kept when the replay is registered, as in the transaction
+	upload := r.uploads[audit.UploadID]
+	for _, other := range r.uploads {
+		if other.Checksum == replay.Checksum && other.ID != upload.ID &&
+			(other.Status == UploadProcessing || other.Status == UploadCompleted) {
+			return ErrDuplicateFile
+		}
+	}
+
+	upload.Status = UploadDeleted
+	delete(r.trades, upload.ID)
+
+	r.lastID++
+	replay.ID = r.lastID
+	stored := *replay
+	r.uploads[replay.ID] = &stored
+	return nil
+}
+
+func (r *replayRepository) BatchInsertTrade(ctx context.Context, sourceFileID int, trades []*Trade) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.trades[sourceFileID] += len(trades)
+	return nil
+}
+
+func (r *replayRepository) FinishUpload(ctx context.Context, upload *Upload) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.uploads[upload.ID].Status = upload.Status
+	r.uploads[upload.ID].Trades = upload.Trades
+	return nil
+}
+
+func TestServiceReplayTwice(t *testing.T) {
+	csvContent := `DataReferencia;CodigoInstrumento;AcaoAtualizacao;PrecoNegocio;QuantidadeNegociada;HoraFechamento;CodigoIdentificadorNegocio;TipoSessaoPregao;DataNegocio;CodigoParticipanteComprador;CodigoParticipanteVendedor
+2024-06-28;PETR4;0;38,00;100;100000000;10;1;2024-06-28;3;23
+2024-06-28;PETR4;0;38,20;200;100100000;20;1;2024-06-28;3;23
+2024-06-28;VALE3;0;61,50;300;100200000;10;1;2024-06-28;3;23
+`
+	repo := &replayRepository{
+		MockRepository: new(MockRepository),
+		uploads: map[int]*Upload{
+			3: {ID: 3, FileName: "28-06-2024_NEGOCIOSAVISTA.txt", Checksum: "abc123", BlobKey: "source-files/abc123", Status: UploadCompleted, Trades: 3},
+		},
+		trades: map[int]int{3: 3},
+		lastID: 3,
+	}
+	repo.On("BatchInsertMetrics", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	repo.On("UpsertInstruments", mock.Anything, mock.Anything).Return(nil)
+	repo.On("BatchInsertSequences", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	repo.On("BatchInsertOrderFlow", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	blobs := new(MockBlobStore)
+	for i := 0; i < 3; i++ {
+		blobs.On("Get", mock.Anything, "source-files/abc123").Return(io.NopCloser(strings.NewReader(csvContent)), nil).Once()
+	}
+
+	svc := NewService(repo, &config.Config{App: config.App{Workers: 2, BatchSize: 2}}, blobs)
+
+	replay := func(id int) (*Upload, error) {
+		upload, file, err := svc.Replay(context.Background(), id, "ops")
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		return upload, svc.BatchInsert(context.Background(), upload, file)
+	}
+
+	first, err := replay(3)
+	require.NoError(t, err)
+	second, err := replay(first.ID)
+	require.NoError(t, err)
+
+	// the original upload is deleted and its file is loaded by the second replay, replaying it again is refused
+	// without losing the trades of the second replay
+	_, err = replay(3)
+	assert.Equal(t, ErrDuplicateFile, err)
+
+	assert.Equal(t, map[int]int{second.ID: 3}, repo.trades)
+	assert.Equal(t, UploadDeleted, repo.uploads[3].Status)
+	assert.Equal(t, UploadDeleted, repo.uploads[first.ID].Status)
+	assert.Equal(t, UploadCompleted, repo.uploads[second.ID].Status)
+	assert.Equal(t, 3, repo.uploads[second.ID].Trades)
+	assert.Len(t, repo.uploads, 3)
+}
+
+func TestService_BatchInsertSequences(t *testing.T) {
+	csvContent := `DataReferencia;CodigoInstrumento;AcaoAtualizacao;PrecoNegocio;QuantidadeNegociada;HoraFechamento;CodigoIdentificadorNegocio;TipoSessaoPregao;DataNegocio;CodigoParticipanteComprador;CodigoParticipanteVendedor
+2024-06-28;PETR4;0;38,00;100;100000000;10;1;2024-06-28;3;23
+2024-06-28;PETR4;0;38,20;100;100100000;20;1;2024-06-28;3;23
+2024-06-28;PETR4;0;38,10;100;100300000;50;1;2024-06-28;3;23
+`
+	date := time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name     string
+		mockFunc func(m *MockRepository)
+		wantErr  error
+	}{
+		{
+			name: "success",
+			mockFunc: func(m *MockRepository) {
+				m.On("BatchInsertTrade", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+				m.On("BatchInsertMetrics", mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
+				m.On("UpsertInstruments", mock.Anything, mock.Anything).Return(nil).Once()
+				m.On("BatchInsertSequences", mock.Anything, 7, []*TradeSequence{
+					{Ticker: "PETR4", TradeDate: date, Trades: 3, FirstTradeID: 10, LastTradeID: 50, Step: 10, Missing: 2, Gaps: 1},
+				}).Return(nil).Once()
+				m.On("BatchInsertOrderFlow", mock.Anything, 7, mock.Anything).Return(nil).Once()
+				m.On("FinishUpload", mock.Anything, &Upload{ID: 7, Status: UploadCompleted, ReferenceDate: &date, Trades: 3}).Return(nil).Once()
+			},
+		},
+		{
+			name: "failed because error in batch insert sequences",
+			mockFunc: func(m *MockRepository) {
+				m.On("BatchInsertTrade", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+				m.On("BatchInsertMetrics", mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
+				m.On("UpsertInstruments", mock.Anything, mock.Anything).Return(nil).Once()
+				m.On("BatchInsertSequences", mock.Anything, 7, mock.Anything).Return(errors.New("mock-error")).Once()
+				m.On("PurgeUpload", mock.Anything, 7).Return(nil).Once()
+				m.On("FinishUpload", mock.Anything, &Upload{ID: 7, Status: UploadFailed, ReferenceDate: &date, Trades: 3}).Return(nil).Once()
+			},
+			wantErr: errors.New("mock-error"),
+		},
+		{
+			name: "failed because error in finish upload",
+			mockFunc: func(m *MockRepository) {
+				m.On("BatchInsertTrade", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+				m.On("BatchInsertMetrics", mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
+				m.On("UpsertInstruments", mock.Anything, mock.Anything).Return(nil).Once()
+				m.On("BatchInsertSequences", mock.Anything, 7, mock.Anything).Return(nil).Once()
+				m.On("BatchInsertOrderFlow", mock.Anything, 7, mock.Anything).Return(nil).Once()
+				m.On("FinishUpload", mock.Anything, mock.Anything).Return(errors.New("finish error")).Once()
+			},
+			wantErr: errors.New("finish error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &config.Config{
+				App: config.App{
+					Workers:   1,
+					BatchSize: 10,
+				},
+			}
+
+			mockRepo := new(MockRepository)
+			tc.mockFunc(mockRepo)
+
+			svc := NewService(mockRepo, cfg, nil)
+
+			err := svc.BatchInsert(context.Background(), &Upload{ID: 7}, bytes.NewReader([]byte(csvContent)))
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
+
+func TestServiceUpload(t *testing.T) {
+	createdAt := time.Date(2024, 06, 28, 18, 30, 0, 0, time.UTC)
+
+	cases := []struct {
+		name     string
+		mockFunc func(m *MockRepository)
+		want     *Upload
+		wantErr  error
+	}{
+		{
+			name: "success",
+			mockFunc: func(m *MockRepository) {
+				m.On("GetUpload", mock.Anything, 7).
+					Return(&Upload{ID: 7, Status: UploadProcessing, CreatedAt: createdAt}, nil).Once()
+			},
+			want: &Upload{ID: 7, Status: UploadProcessing, CreatedAt: createdAt},
+		},
+		{
+			name: "failed because upload not found",
+			mockFunc: func(m *MockRepository) {
+				m.On("GetUpload", mock.Anything, 7).Return(nil, ErrUploadNotFound).Once()
+			},
+			want:    nil,
+			wantErr: ErrUploadNotFound,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := new(MockRepository)
+			tc.mockFunc(mockRepo)
+
+			svc := NewService(mockRepo, &config.Config{}, nil)
+
+			got, err := svc.Upload(context.Background(), 7)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.want, got)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestServiceCompleteness(t *testing.T) {
+	date := time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC)
+	petr4 := &TradeSequence{Ticker: "PETR4", TradeDate: date, Trades: 6, FirstTradeID: 10, LastTradeID: 80, Step: 10, Missing: 3, Gaps: 2, Duplicates: 1, OutOfOrder: 1}
+	vale3 := &TradeSequence{Ticker: "VALE3", TradeDate: date, Trades: 1, FirstTradeID: 10, LastTradeID: 10, Step: 1, Complete: true}
+
+	cases := []struct {
+		name     string
+		mockFunc func(m *MockRepository)
+		want     *Completeness
+		wantErr  error
+	}{
+		{
+			name: "success",
+			mockFunc: func(m *MockRepository) {
+				m.On("GetUpload", mock.Anything, 7).Return(&Upload{ID: 7, Status: UploadCompleted}, nil).Once()
+				m.On("ListSequences", mock.Anything, SequenceFilter{UploadID: 7, Ticker: "PETR4"}).
+					Return([]*TradeSequence{petr4, vale3}, nil).Once()
+			},
+			want: &Completeness{
+				UploadID:   7,
+				Sequences:  2,
+				Incomplete: 1,
+				Missing:    3,
+				Duplicates: 1,
+				OutOfOrder: 1,
+				Details:    []*TradeSequence{petr4, vale3},
+			},
+		},
+		{
+			name: "failed because upload not found",
+			mockFunc: func(m *MockRepository) {
+				m.On("GetUpload", mock.Anything, 7).Return(nil, ErrUploadNotFound).Once()
+			},
+			want:    nil,
+			wantErr: ErrUploadNotFound,
+		},
+		{
+			name: "failed because repository error",
+			mockFunc: func(m *MockRepository) {
+				m.On("GetUpload", mock.Anything, 7).Return(&Upload{ID: 7, Status: UploadCompleted}, nil).Once()
+				m.On("ListSequences", mock.Anything, mock.Anything).Return(nil, errors.New("repository error")).Once()
+			},
+			want:    nil,
+			wantErr: errors.New("repository error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := new(MockRepository)
+			tc.mockFunc(mockRepo)
+
+			svc := NewService(mockRepo, &config.Config{}, nil)
+
+			got, err := svc.Completeness(context.Background(), SequenceFilter{UploadID: 7, Ticker: "petr4"})
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.want, got)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestService_BatchInsertQuality(t *testing.T) {
+	csvContent := `DataReferencia;CodigoInstrumento;AcaoAtualizacao;PrecoNegocio;QuantidadeNegociada;HoraFechamento;CodigoIdentificadorNegocio;TipoSessaoPregao;DataNegocio;CodigoParticipanteComprador;CodigoParticipanteVendedor
+2024-06-28;PETR4;0;30,00;100;170000000;10;1;2024-06-27;3;23
+2024-06-28;PETR4;0;38,00;100;100000000;10;1;2024-06-28;3;23
+2024-06-28;PETR4;0;30,50;100;100100000;20;1;2024-06-28;3;23
+`
+	previous := time.Date(2024, 06, 26, 0, 0, 0, 0, time.UTC)
+	referenceDate := time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC)
+	issues := []*QualityIssue{
+		{
+			Issue: QualityOutsideReferenceDate,
+			Rows:  1,
+			Ratio: 0.3333,
+			Samples: []*QualitySample{
+				{Line: 2, Ticker: "PETR4", TradeDate: time.Date(2024, 06, 27, 0, 0, 0, 0, time.UTC), CloseTime: "170000000", Price: decimal.RequireFromString("30.00"), Quantity: 100, Reference: "2024-06-28"},
+			},
+		},
+		{
+			Issue: QualityPriceDeviation,
+			Rows:  1,
+			Ratio: 0.3333,
+			Samples: []*QualitySample{
+				{Line: 3, Ticker: "PETR4", TradeDate: time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC), CloseTime: "100000000", Price: decimal.RequireFromString("38.00"), Quantity: 100, Reference: "30"},
+			},
+		},
+	}
+	rejected := []*QualityIssue{issues[0], {
+		Issue:    issues[1].Issue,
+		Rows:     issues[1].Rows,
+		Ratio:    issues[1].Ratio,
+		Rejected: true,
+		Samples:  issues[1].Samples,
+	}}
+
+	cases := []struct {
+		name       string
+		thresholds map[string]float64
+		mockFunc   func(m *MockRepository)
+		wantErr    error
+	}{
+		{
+			name: "success",
+			mockFunc: func(m *MockRepository) {
+				m.On("BatchInsertTrade", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+				// the close of 2024-06-27 is read from the file
+				m.On("ListCloses", mock.Anything, previous).Return(map[string]decimal.Decimal{}, nil).Once()
+				m.On("BatchInsertQualityIssues", mock.Anything, 7, issues).Return(nil).Once()
+				m.On("BatchInsertMetrics", mock.Anything, mock.Anything, mock.Anything).Return(nil).Once()
+				m.On("UpsertInstruments", mock.Anything, mock.Anything).Return(nil).Once()
+				m.On("BatchInsertSequences", mock.Anything, 7, mock.Anything).Return(nil).Once()
+				m.On("BatchInsertOrderFlow", mock.Anything, 7, mock.Anything).Return(nil).Once()
+				m.On("FinishUpload", mock.Anything, &Upload{ID: 7, Status: UploadCompleted, ReferenceDate: &referenceDate, Trades: 3}).Return(nil).Once()
+			},
+		},
+		{
+			name:       "failed because rejected by the quality thresholds",
+			thresholds: map[string]float64{QualityPriceDeviation: 0.1},
+			mockFunc: func(m *MockRepository) {
+				m.On("BatchInsertTrade", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+				m.On("ListCloses", mock.Anything, previous).Return(map[string]decimal.Decimal{}, nil).Once()
+				m.On("BatchInsertQualityIssues", mock.Anything, 7, rejected).Return(nil).Once()
+				// the trades stored by the workers are removed
+				m.On("PurgeUpload", mock.Anything, 7).Return(nil).Once()
+				m.On("FinishUpload", mock.Anything, &Upload{ID: 7, Status: UploadRejected, ReferenceDate: &referenceDate, Trades: 3}).Return(nil).Once()
+			},
+			wantErr: ErrQualityRejected,
+		},
+		{
+			name:       "failed because error in purge upload",
+			thresholds: map[string]float64{QualityPriceDeviation: 0.1},
+			mockFunc: func(m *MockRepository) {
+				m.On("BatchInsertTrade", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+				m.On("ListCloses", mock.Anything, previous).Return(map[string]decimal.Decimal{}, nil).Once()
+				m.On("BatchInsertQualityIssues", mock.Anything, 7, rejected).Return(nil).Once()
+				m.On("PurgeUpload", mock.Anything, 7).Return(errors.New("mock-error")).Once()
+				m.On("FinishUpload", mock.Anything, &Upload{ID: 7, Status: UploadFailed, ReferenceDate: &referenceDate, Trades: 3}).Return(nil).Once()
+			},
+			wantErr: errors.New("mock-error"),
+		},
+		{
+			name: "failed because error in batch insert quality issues",
+			mockFunc: func(m *MockRepository) {
+				m.On("BatchInsertTrade", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+				m.On("ListCloses", mock.Anything, previous).Return(map[string]decimal.Decimal{}, nil).Once()
+				m.On("BatchInsertQualityIssues", mock.Anything, 7, mock.Anything).Return(errors.New("mock-error")).Once()
+				m.On("PurgeUpload", mock.Anything, 7).Return(nil).Once()
+				m.On("FinishUpload", mock.Anything, &Upload{ID: 7, Status: UploadFailed, ReferenceDate: &referenceDate, Trades: 3}).Return(nil).Once()
+			},
+			wantErr: errors.New("mock-error"),
+		},
+		{
+			name: "failed because error in list closes",
+			mockFunc: func(m *MockRepository) {
+				m.On("ListCloses", mock.Anything, previous).Return(nil, errors.New("mock-error")).Once()
+				m.On("PurgeUpload", mock.Anything, 7).Return(nil).Once()
+				m.On("FinishUpload", mock.Anything, &Upload{ID: 7, Status: UploadFailed}).Return(nil).Once()
+			},
+			wantErr: errors.New("mock-error"),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &config.Config{
+				App: config.App{
+					Workers:   1,
+					BatchSize: 10,
+				},
+				Quality: config.Quality{
+					PriceDeviation: 0.2,
+					Thresholds:     tc.thresholds,
+					Samples:        5,
+				},
+			}
+
+			mockRepo := new(MockRepository)
+			tc.mockFunc(mockRepo)
+
+			svc := NewService(mockRepo, cfg, nil)
+
+			err := svc.BatchInsert(context.Background(), &Upload{ID: 7}, bytes.NewReader([]byte(csvContent)))
+			assert.Equal(t, tc.wantErr, err)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestServiceQuality(t *testing.T) {
+	issues := []*QualityIssue{
+		{Issue: QualityNonPositiveQuantity, Rows: 2, Ratio: 0.0002, Samples: []*QualitySample{}},
+		{Issue: QualityPriceDeviation, Rows: 40, Ratio: 0.004, Rejected: true, Samples: []*QualitySample{}},
+	}
+
+	cases := []struct {
+		name     string
 		mockFunc func(m *MockRepository)
-		want     *Metric
+		want     *QualityReport
 		wantErr  error
 	}{
 		{
-			name:   "success",
-			ticker: "AAPL",
-			date:   time.Date(2024, 6, 20, 0, 0, 0, 0, time.UTC),
+			name: "success",
 			mockFunc: func(m *MockRepository) {
-				m.On("GetMetrics", mock.Anything, "AAPL", time.Date(2024, 6, 20, 0, 0, 0, 0, time.UTC)).
-					Return(&Metric{
-						Ticker:         "AAPL",
-						MaxRangeValue:  decimal.NewFromInt(100),
-						MaxDailyVolume: 50,
-					}, nil).Once()
+				m.On("GetUpload", mock.Anything, 7).Return(&Upload{ID: 7, Status: UploadRejected, Trades: 10000}, nil).Once()
+				m.On("ListQualityIssues", mock.Anything, 7).Return(issues, nil).Once()
 			},
-			want: &Metric{
-				Ticker:         "AAPL",
-				MaxRangeValue:  decimal.NewFromInt(100),
-				MaxDailyVolume: 50,
+			want: &QualityReport{UploadID: 7, Status: UploadRejected, Rows: 10000, Rejected: true, Issues: issues},
+		},
+		{
+			name: "success without issues",
+			mockFunc: func(m *MockRepository) {
+				m.On("GetUpload", mock.Anything, 7).Return(&Upload{ID: 7, Status: UploadCompleted, Trades: 10000}, nil).Once()
+				m.On("ListQualityIssues", mock.Anything, 7).Return([]*QualityIssue{}, nil).Once()
 			},
-			wantErr: nil,
+			want: &QualityReport{UploadID: 7, Status: UploadCompleted, Rows: 10000, Issues: []*QualityIssue{}},
 		},
 		{
-			name:   "failed because repository error",
-			ticker: "AAPL",
-			date:   time.Date(2024, 6, 20, 0, 0, 0, 0, time.UTC),
+			name: "failed because upload not found",
 			mockFunc: func(m *MockRepository) {
-				m.On("GetMetrics", mock.Anything, "AAPL", time.Date(2024, 6, 20, 0, 0, 0, 0, time.UTC)).
-					Return(nil, errors.New("repository error")).Once()
+				m.On("GetUpload", mock.Anything, 7).Return(nil, ErrUploadNotFound).Once()
+			},
+			want:    nil,
+			wantErr: ErrUploadNotFound,
+		},
+		{
+			name: "failed because repository error",
+			mockFunc: func(m *MockRepository) {
+				m.On("GetUpload", mock.Anything, 7).Return(&Upload{ID: 7, Status: UploadCompleted}, nil).Once()
+				m.On("ListQualityIssues", mock.Anything, 7).Return(nil, errors.New("repository error")).Once()
 			},
 			want:    nil,
 			wantErr: errors.New("repository error"),
@@ -81,10 +2982,9 @@ func TestServiceMetrics(t *testing.T) {
 			mockRepo := new(MockRepository)
 			tc.mockFunc(mockRepo)
 
-			cfg := &config.Config{}
-			svc := NewService(mockRepo, cfg)
+			svc := NewService(mockRepo, &config.Config{}, nil)
 
-			got, err := svc.Metrics(context.Background(), tc.ticker, tc.date)
+			got, err := svc.Quality(context.Background(), 7)
 			assert.Equal(t, tc.wantErr, err)
 			assert.Equal(t, tc.want, got)
 			mockRepo.AssertExpectations(t)
@@ -92,226 +2992,206 @@ func TestServiceMetrics(t *testing.T) {
 	}
 }
 
-func TestService_BatchInsert(t *testing.T) {
-	testCases := []struct {
+func TestServiceValidate(t *testing.T) {
+	header := `DataReferencia;CodigoInstrumento;AcaoAtualizacao;PrecoNegocio;QuantidadeNegociada;HoraFechamento;CodigoIdentificadorNegocio;TipoSessaoPregao;DataNegocio;CodigoParticipanteComprador;CodigoParticipanteVendedor
+`
+	columns := []string{"DataReferencia", "CodigoInstrumento", "AcaoAtualizacao", "PrecoNegocio", "QuantidadeNegociada", "HoraFechamento",
+		"CodigoIdentificadorNegocio", "TipoSessaoPregao", "DataNegocio", "CodigoParticipanteComprador", "CodigoParticipanteVendedor"}
+	previous := time.Date(2024, 06, 27, 0, 0, 0, 0, time.UTC)
+	date := time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
 		name       string
 		csvContent string
+		maxErrors  int
+		quality    config.Quality
 		mockFunc   func(m *MockRepository)
+		want       *Validation
 		wantErr    error
 	}{
 		{
 			name: "success",
-			csvContent: `DataReferencia;CodigoInstrumento;AcaoAtualizacao;PrecoNegocio;QuantidadeNegociada;HoraFechamento;CodigoIdentificadorNegocio;TipoSessaoPregao;DataNegocio;CodigoParticipanteComprador;CodigoParticipanteVendedor
-2024-06-28;TF583R;0;10,000;10000;041646257;10;1;2024-06-28;100;100
-2024-06-28;DI1F25;0;10,600;6;090000017;10;1;2024-06-28;3;23
-2024-06-28;DI1F25;0;10,601;9;090000017;20;1;2024-06-28;3;23
-2024-06-28;DI1N24;0;10,398;1;090000017;10;1;2024-06-28;114;114
+			csvContent: header + `2024-06-28;VALE3;0;60,10;100;100000000;10;1;2024-06-28;3;23
+2024-06-28;PETR4;0;38,50;100;100000000;10;1;2024-06-28;3;23
+2024-06-28;PETR4;0;38,60;100;100100000;20;1;2024-06-28;3;23
 `,
-			mockFunc: func(m *MockRepository) {
-				m.On("BatchInsertTrade", mock.Anything, []*Trade{
-					{
-						InstrumentCode: "TF583R",
-						TradePrice:     decimal.NewFromBigInt(big.NewInt(10000), -3),
-						TradeQuantity:  10000,
-						CloseTime:      "041646257",
-						TradeDate:      time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC),
-					},
-					{
-						InstrumentCode: "DI1F25",
-						TradePrice:     decimal.NewFromBigInt(big.NewInt(10600), -3),
-						TradeQuantity:  6,
-						CloseTime:      "090000017",
-						TradeDate:      time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC),
-					},
-				}).Return(nil).Once()
-
-				m.On("BatchInsertTrade", mock.Anything, []*Trade{
-					{
-						InstrumentCode: "DI1F25",
-						TradePrice:     decimal.NewFromBigInt(big.NewInt(10601), -3),
-						TradeQuantity:  9,
-						CloseTime:      "090000017",
-						TradeDate:      time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC),
-					},
-					{
-						InstrumentCode: "DI1N24",
-						TradePrice:     decimal.NewFromBigInt(big.NewInt(10398), -3),
-						TradeQuantity:  1,
-						CloseTime:      "090000017",
-						TradeDate:      time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC),
-					},
-				}).Return(nil).Once()
-
-				m.On("BatchInsertMetrics", mock.Anything, map[string]*Metric{
-					"TF583R": {
-						Ticker:         "TF583R",
-						MaxRangeValue:  decimal.NewFromBigInt(big.NewInt(10000), -3),
-						MaxDailyVolume: 10000,
-						TradeDate:      time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC),
-					},
-					"DI1F25": {
-						Ticker:         "DI1F25",
-						MaxRangeValue:  decimal.NewFromBigInt(big.NewInt(10601), -3),
-						MaxDailyVolume: 15,
-						TradeDate:      time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC),
-					},
-					"DI1N24": {
-						Ticker:         "DI1N24",
-						MaxRangeValue:  decimal.NewFromBigInt(big.NewInt(10398), -3),
-						MaxDailyVolume: 1,
-						TradeDate:      time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC),
-					},
-				}).Return(nil).Once()
+			mockFunc: func(m *MockRepository) {},
+			want: &Validation{
+				Rows:            3,
+				ValidRows:       3,
+				Columns:         columns,
+				ReferenceDate:   &date,
+				StartDate:       &date,
+				EndDate:         &date,
+				DistinctTickers: 2,
+				Tickers:         []string{"PETR4", "VALE3"},
+				Errors:          []*ValidationError{},
+				Quality:         []*QualityIssue{},
+				Valid:           true,
 			},
-			wantErr: nil,
 		},
 		{
-			name: "failed because error in batch insert metrics",
-			csvContent: `DataReferencia;CodigoInstrumento;AcaoAtualizacao;PrecoNegocio;QuantidadeNegociada;HoraFechamento;CodigoIdentificadorNegocio;TipoSessaoPregao;DataNegocio;CodigoParticipanteComprador;CodigoParticipanteVendedor
-2024-06-28;TF583R;0;10,000;10000;041646257;10;1;2024-06-28;100;100
-2024-06-28;DI1F25;0;10,600;6;090000017;10;1;2024-06-28;3;23
-2024-06-28;DI1F25;0;10,600;9;090000017;20;1;2024-06-28;3;23
-2024-06-28;DI1N24;0;10,398;1;090000017;10;1;2024-06-28;114;114
+			name: "success with invalid rows",
+			csvContent: header + `2024-06-28;PETR4;0;38,50;100;100000000;10;1;2024-06-28;3;23
+2024-06-28;PETR4;0;3i,50;100;100100000;20;1;2024-06-28;3;23
+2024-06-28;PETR4;0;38,50
+2024-06-28;PETR4;0;38,50;100;100200000;30;1;2024-06-2;3;23
+2024-06-28;PETR4;0;38,50;100;100300000;40;1;2024-06-28;3;23
 `,
-			mockFunc: func(m *MockRepository) {
-				m.On("BatchInsertTrade", mock.Anything, []*Trade{
-					{
-						InstrumentCode: "TF583R",
-						TradePrice:     decimal.NewFromBigInt(big.NewInt(10000), -3),
-						TradeQuantity:  10000,
-						CloseTime:      "041646257",
-						TradeDate:      time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC),
-					},
-					{
-						InstrumentCode: "DI1F25",
-						TradePrice:     decimal.NewFromBigInt(big.NewInt(10600), -3),
-						TradeQuantity:  6,
-						CloseTime:      "090000017",
-						TradeDate:      time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC),
-					},
-				}).Return(nil).Once()
-
-				m.On("BatchInsertTrade", mock.Anything, []*Trade{
-					{
-						InstrumentCode: "DI1F25",
-						TradePrice:     decimal.NewFromBigInt(big.NewInt(10600), -3),
-						TradeQuantity:  9,
-						CloseTime:      "090000017",
-						TradeDate:      time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC),
-					},
-					{
-						InstrumentCode: "DI1N24",
-						TradePrice:     decimal.NewFromBigInt(big.NewInt(10398), -3),
-						TradeQuantity:  1,
-						CloseTime:      "090000017",
-						TradeDate:      time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC),
-					},
-				}).Return(nil).Once()
-
-				m.On("BatchInsertMetrics", mock.Anything, map[string]*Metric{
-					"TF583R": {
-						Ticker:         "TF583R",
-						MaxRangeValue:  decimal.NewFromBigInt(big.NewInt(10000), -3),
-						MaxDailyVolume: 10000,
-						TradeDate:      time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC),
-					},
-					"DI1F25": {
-						Ticker:         "DI1F25",
-						MaxRangeValue:  decimal.NewFromBigInt(big.NewInt(10600), -3),
-						MaxDailyVolume: 15,
-						TradeDate:      time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC),
-					},
-					"DI1N24": {
-						Ticker:         "DI1N24",
-						MaxRangeValue:  decimal.NewFromBigInt(big.NewInt(10398), -3),
-						MaxDailyVolume: 1,
-						TradeDate:      time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC),
-					},
-				}).Return(errors.New("mock-error")).Once()
+			maxErrors: 2,
+			mockFunc:  func(m *MockRepository) {},
+			want: &Validation{
+				Rows:            5,
+				ValidRows:       2,
+				InvalidRows:     3,
+				Columns:         columns,
+				ReferenceDate:   &date,
+				StartDate:       &date,
+				EndDate:         &date,
+				DistinctTickers: 1,
+				Tickers:         []string{"PETR4"},
+				Errors: []*ValidationError{
+					{Line: 3, Error: "failed to parse trade price: can't convert 3i.50 to decimal"},
+					{Line: 4, Error: "unexpected number of columns: 4"},
+				},
+				Quality: []*QualityIssue{},
 			},
-			wantErr: errors.New("mock-error"),
 		},
 		{
-			name: "failed because error in batch insert trade",
-			csvContent: `DataReferencia;CodigoInstrumento;AcaoAtualizacao;PrecoNegocio;QuantidadeNegociada;HoraFechamento;CodigoIdentificadorNegocio;TipoSessaoPregao;DataNegocio;CodigoParticipanteComprador;CodigoParticipanteVendedor
-2024-06-28;TF583R;0;10,000;10000;041646257;10;1;2024-06-28;100;100
-2024-06-28;DI1F25;0;10,600;6;090000017;10;1;2024-06-28;3;23
-2024-06-28;DI1F25;0;10,600;9;090000017;20;1;2024-06-28;3;23
-2024-06-28;DI1N24;0;10,398;1;090000017;10;1;2024-06-28;114;114
+			name: "success with the first row invalid",
+			csvContent: header + `2024-06-28;PETR4;0;3i,50;100;100000000;10;1;2024-06-28;3;23
+2024-06-28;PETR4;0;38,50;100;100100000;20;1;2024-06-28;3;23
+2024-06-28;PETR4;0;38,50;100;100200000;30;1;2024-06-27;3;23
 `,
-			mockFunc: func(m *MockRepository) {
-				m.On("BatchInsertTrade", mock.Anything, []*Trade{
-					{
-						InstrumentCode: "TF583R",
-						TradePrice:     decimal.NewFromBigInt(big.NewInt(10000), -3),
-						TradeQuantity:  10000,
-						CloseTime:      "041646257",
-						TradeDate:      time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC),
-					},
-					{
-						InstrumentCode: "DI1F25",
-						TradePrice:     decimal.NewFromBigInt(big.NewInt(10600), -3),
-						TradeQuantity:  6,
-						CloseTime:      "090000017",
-						TradeDate:      time.Date(2024, 06, 28, 0, 0, 0, 0, time.UTC),
-					},
-				}).Return(errors.New("mock-error")).Once()
+			mockFunc: func(m *MockRepository) {},
+			want: &Validation{
+				Rows:            3,
+				ValidRows:       2,
+				InvalidRows:     1,
+				Columns:         columns,
+				ReferenceDate:   &date,
+				StartDate:       &previous,
+				EndDate:         &date,
+				DistinctTickers: 1,
+				Tickers:         []string{"PETR4"},
+				Errors: []*ValidationError{
+					{Line: 2, Error: "failed to parse trade price: can't convert 3i.50 to decimal"},
+				},
+				Quality: []*QualityIssue{
+					{Issue: QualityOutsideReferenceDate, Rows: 1, Ratio: 0.5, Samples: []*QualitySample{}},
+				},
 			},
-			wantErr: errors.New("context canceled"),
 		},
 		{
-			name: "failed because error in parse trade date",
-			csvContent: `DataReferencia;CodigoInstrumento;AcaoAtualizacao;PrecoNegocio;QuantidadeNegociada;HoraFechamento;CodigoIdentificadorNegocio;TipoSessaoPregao;DataNegocio;CodigoParticipanteComprador;CodigoParticipanteVendedor
-2024-06-28;TF583R;0;10,000;10000;041646257;10;1;2024-0-28;100;100
-2024-06-28;DI1F25;0;10,600;6;090000017;10;1;2024-06-28;3;23
-2024-06-28;DI1F25;0;10,600;9;090000017;20;1;2024-06-28;3;23
-2024-06-28;DI1N24;0;10,398;1;090000017;10;1;2024-06-28;114;114
+			name: "success rejected by the quality thresholds",
+			csvContent: header + `2024-06-28;PETR4;0;50,00;100;100000000;10;1;2024-06-28;3;23
+2024-06-28;PETR4;0;38,50;100;100100000;20;1;2024-06-28;3;23
 `,
-			mockFunc: func(m *MockRepository) {},
-			wantErr:  errors.New("failed to parse trade date: parsing time \"2024-0-28\" as \"2006-01-02\": cannot parse \"0-28\" as \"01\""),
+			quality: config.Quality{
+				PriceDeviation: 0.2,
+				Thresholds:     map[string]float64{QualityPriceDeviation: 0.1},
+			},
+			mockFunc: func(m *MockRepository) {
+				m.On("ListCloses", mock.Anything, previous).Return(map[string]decimal.Decimal{"PETR4": decimal.NewFromInt(38)}, nil).Once()
+			},
+			want: &Validation{
+				Rows:            2,
+				ValidRows:       2,
+				Columns:         columns,
+				ReferenceDate:   &date,
+				StartDate:       &date,
+				EndDate:         &date,
+				DistinctTickers: 1,
+				Tickers:         []string{"PETR4"},
+				Errors:          []*ValidationError{},
+				Quality: []*QualityIssue{
+					{Issue: QualityPriceDeviation, Rows: 1, Ratio: 0.5, Rejected: true, Samples: []*QualitySample{}},
+				},
+			},
 		},
 		{
-			name: "failed because error in parse trade quantity",
-			csvContent: `DataReferencia;CodigoInstrumento;AcaoAtualizacao;PrecoNegocio;QuantidadeNegociada;HoraFechamento;CodigoIdentificadorNegocio;TipoSessaoPregao;DataNegocio;CodigoParticipanteComprador;CodigoParticipanteVendedor
-2024-06-28;TF583R;0;i,000;10000;041646257;10;1;2024-0-28;100;100
-2024-06-28;DI1F25;0;10,600;6;090000017;10;1;2024-06-28;3;23
-2024-06-28;DI1F25;0;10,600;9;090000017;20;1;2024-06-28;3;23
-2024-06-28;DI1N24;0;10,398;1;090000017;10;1;2024-06-28;114;114
-`,
-			mockFunc: func(m *MockRepository) {},
-			wantErr:  errors.New("failed to parse trade price: can't convert i.000 to decimal"),
+			name:       "success with an empty file",
+			csvContent: "",
+			mockFunc:   func(m *MockRepository) {},
+			want: &Validation{
+				Columns: []string{},
+				Tickers: []string{},
+				Errors:  []*ValidationError{},
+				Quality: []*QualityIssue{},
+				Valid:   true,
+			},
 		},
 		{
-			name: "failed because error in parse trade price",
-			csvContent: `DataReferencia;CodigoInstrumento;AcaoAtualizacao;PrecoNegocio;QuantidadeNegociada;HoraFechamento;CodigoIdentificadorNegocio;TipoSessaoPregao;DataNegocio;CodigoParticipanteComprador;CodigoParticipanteVendedor
-2024-06-28;TF583R;0;1j,000;10000;041646257;10;1;2024-06-28;100;100
-2024-06-28;DI1F25;0;10,600;6;090000017;10;1;2024-06-28;3;23
-2024-06-28;DI1F25;0;10,600;9;090000017;20;1;2024-06-28;3;23
-2024-06-28;DI1N24;0;10,398;1;090000017;10;1;2024-06-28;114;114
+			name: "failed because error in list closes",
+			csvContent: header + `2024-06-28;PETR4;0;50,00;100;100000000;10;1;2024-06-28;3;23
 `,
-			mockFunc: func(m *MockRepository) {},
-			wantErr:  errors.New("failed to parse trade price: can't convert 1j.000 to decimal"),
+			quality: config.Quality{PriceDeviation: 0.2},
+			mockFunc: func(m *MockRepository) {
+				m.On("ListCloses", mock.Anything, previous).Return(nil, errors.New("repository error")).Once()
+			},
+			want:    nil,
+			wantErr: errors.New("repository error"),
 		},
 	}
 
-	for _, tc := range testCases {
+	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
-			cfg := &config.Config{
-				App: config.App{
-					Workers:   1,
-					BatchSize: 2,
-				},
-			}
-
 			mockRepo := new(MockRepository)
+			tc.mockFunc(mockRepo)
+
+			svc := NewService(mockRepo, &config.Config{Quality: tc.quality}, nil)
+
+			got, err := svc.Validate(context.Background(), bytes.NewReader([]byte(tc.csvContent)), tc.maxErrors)
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.want, got)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestServiceDeleteUpload(t *testing.T) {
+	cases := []struct {
+		name     string
+		mockFunc func(m *MockRepository)
+		want     *UploadAudit
+		wantErr  error
+	}{
+		{
+			name: "success",
+			mockFunc: func(m *MockRepository) {
+				m.On("DeleteUpload", mock.Anything, &UploadAudit{UploadID: 7, Action: AuditDelete, Actor: "ops"}).
+					Run(func(args mock.Arguments) {
+						audit := args.Get(1).(*UploadAudit)
+						audit.ID = 1
+						audit.Trades = 1500
+						audit.Metrics = 3
+					}).
+					Return(nil).Once()
+			},
+			want: &UploadAudit{ID: 1, UploadID: 7, Action: AuditDelete, Actor: "ops", Trades: 1500, Metrics: 3},
+		},
+		{
+			name: "failed because upload is processing",
+			mockFunc: func(m *MockRepository) {
+				m.On("DeleteUpload", mock.Anything, mock.Anything).Return(ErrUploadProcessing).Once()
+			},
+			want:    &UploadAudit{UploadID: 7, Action: AuditDelete, Actor: "ops"},
+			wantErr: ErrUploadProcessing,
+		},
+	}
 
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := new(MockRepository)
 			tc.mockFunc(mockRepo)
 
-			svc := NewService(mockRepo, cfg)
+			svc := NewService(mockRepo, &config.Config{}, nil)
 
-			reader := bytes.NewReader([]byte(tc.csvContent))
-			err := svc.BatchInsert(context.Background(), reader)
+			audit := &UploadAudit{UploadID: 7, Actor: "ops"}
+			err := svc.DeleteUpload(context.Background(), audit)
 			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.want, audit)
+			mockRepo.AssertExpectations(t)
 		})
 	}
 }